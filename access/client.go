@@ -15,8 +15,11 @@ import (
 	"github.com/safing/spn/access/token"
 )
 
+// AccountServer is the address of the account server and token issuer.
+// It is replaced by the token issuer of a private network, if configured.
+var AccountServer = "https://api.account.safing.io"
+
 const (
-	AccountServer         = "https://api.account.safing.io"
 	LoginPath             = "/api/v1/authenticate"
 	UserProfilePath       = "/api/v1/user/profile"
 	TokenRequestSetupPath = "/api/v1/token/request/setup"
@@ -0,0 +1,112 @@
+package issuer
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mr-tron/base58"
+
+	"github.com/safing/spn/access/account"
+)
+
+const (
+	deviceIDSize = 16
+
+	// defaultAuthTokenMaxAge defines how long auth tokens are valid by default.
+	defaultAuthTokenMaxAge = 30 * 24 * time.Hour
+
+	// authTokenClockSkew defines how far in the future the issue time of an
+	// auth token may be, in order to tolerate clock changes.
+	authTokenClockSkew = 5 * time.Minute
+)
+
+var (
+	errInvalidAuthToken = errors.New("invalid auth token")
+	errExpiredAuthToken = errors.New("expired auth token")
+)
+
+// newDeviceID returns a new random device ID.
+func newDeviceID() (string, error) {
+	id := make([]byte, deviceIDSize)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return base58.Encode(id), nil
+}
+
+// createAuthToken creates the auth token for the given user and device.
+// Auth tokens are stateless, so that clients stay logged in when the issuer
+// is restarted with the same auth secret. They include the time they were
+// issued at and expire after the configured maximum age. A new token is
+// issued with every authenticated request, so only inactive devices need to
+// log in again. All tokens of a user are revoked by removing the user, all
+// tokens of all users by changing the auth secret.
+func (iss *Issuer) createAuthToken(username, deviceID string, issuedAt time.Time) string {
+	return base58.Encode([]byte(username)) + "." +
+		strconv.FormatInt(issuedAt.Unix(), 10) + "." +
+		base58.Encode(iss.authMAC(username, deviceID, issuedAt.Unix()))
+}
+
+// checkAuthToken checks the auth token of the given request and returns the
+// authenticated username and device ID.
+func (iss *Issuer) checkAuthToken(r *http.Request) (username, deviceID string, err error) {
+	authToken, err := account.GetAuthTokenFromRequest(r)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Parse auth token.
+	splitted := strings.SplitN(authToken.Token, ".", 3)
+	if len(splitted) != 3 {
+		return "", "", errInvalidAuthToken
+	}
+	usernameData, err := base58.Decode(splitted[0])
+	if err != nil {
+		return "", "", errInvalidAuthToken
+	}
+	issuedAt, err := strconv.ParseInt(splitted[1], 10, 64)
+	if err != nil {
+		return "", "", errInvalidAuthToken
+	}
+	mac, err := base58.Decode(splitted[2])
+	if err != nil {
+		return "", "", errInvalidAuthToken
+	}
+
+	// Verify auth token.
+	username = string(usernameData)
+	if !hmac.Equal(mac, iss.authMAC(username, authToken.Device, issuedAt)) {
+		return "", "", errInvalidAuthToken
+	}
+
+	// Check age of auth token.
+	issued := time.Unix(issuedAt, 0)
+	switch {
+	case time.Until(issued) > authTokenClockSkew:
+		return "", "", errInvalidAuthToken
+	case time.Since(issued) > iss.authTokenMaxAge:
+		return "", "", errExpiredAuthToken
+	}
+
+	return username, authToken.Device, nil
+}
+
+func (iss *Issuer) authMAC(username, deviceID string, issuedAt int64) []byte {
+	issuedAtData := make([]byte, 8)
+	binary.BigEndian.PutUint64(issuedAtData, uint64(issuedAt))
+
+	mac := hmac.New(sha256.New, iss.authSecret)
+	_, _ = mac.Write([]byte(username))
+	_, _ = mac.Write([]byte{0})
+	_, _ = mac.Write([]byte(deviceID))
+	_, _ = mac.Write([]byte{0})
+	_, _ = mac.Write(issuedAtData)
+	return mac.Sum(nil)
+}
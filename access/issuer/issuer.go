@@ -0,0 +1,336 @@
+// Package issuer implements a self-hostable token issuer. It serves the token
+// request protocol of the access module, so that private networks and
+// integration tests do not depend on the hosted account server.
+package issuer
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/safing/portbase/formats/dsd"
+	"github.com/safing/portbase/log"
+	"github.com/safing/spn/access"
+	"github.com/safing/spn/access/account"
+	"github.com/safing/spn/access/token"
+)
+
+const (
+	// requestStateTTL defines how long the state of a token request setup is
+	// kept for the following issue request.
+	requestStateTTL = 1 * time.Minute
+
+	authSecretSize = 32
+)
+
+// Options holds the options of an issuer.
+type Options struct {
+	// Zones holds the pblind zones to issue tokens for.
+	Zones []*Zone
+
+	// Users is the backend used to authenticate users.
+	Users UserBackend
+
+	// Quota defines how many token batches a user may request.
+	Quota Quota
+
+	// AuthSecret is the secret used to create auth tokens for devices.
+	// If not set, a random secret is used and all devices need to log in
+	// again after a restart.
+	AuthSecret []byte
+
+	// AuthTokenMaxAge defines how long auth tokens are valid. Devices that do
+	// not use their auth token within this time need to log in again.
+	// Defaults to 30 days.
+	AuthTokenMaxAge time.Duration
+}
+
+// Issuer issues tokens to authenticated users.
+type Issuer struct {
+	users           UserBackend
+	quota           *quotaTracker
+	authSecret      []byte
+	authTokenMaxAge time.Duration
+
+	// requestStates holds the token request states of users, which are
+	// created in the setup step and used in the issue step.
+	requestStates     map[string]*requestState
+	requestStatesLock sync.Mutex
+
+	mux *http.ServeMux
+}
+
+type requestState struct {
+	state   *token.RequestHandlingState
+	expires time.Time
+}
+
+// New returns a new issuer. Tokens are issued using the token handler
+// registry, so only one issuer may be created per process.
+func New(opts Options) (*Issuer, error) {
+	if opts.Users == nil {
+		return nil, errors.New("no user backend defined")
+	}
+	if opts.Quota.Requests > 0 && opts.Quota.Period <= 0 {
+		return nil, errors.New("quota is missing a period")
+	}
+	if opts.AuthTokenMaxAge < 0 {
+		return nil, errors.New("auth token max age must not be negative")
+	}
+	if opts.AuthTokenMaxAge == 0 {
+		opts.AuthTokenMaxAge = defaultAuthTokenMaxAge
+	}
+
+	// Get auth secret.
+	authSecret := opts.AuthSecret
+	if len(authSecret) == 0 {
+		authSecret = make([]byte, authSecretSize)
+		if _, err := rand.Read(authSecret); err != nil {
+			return nil, fmt.Errorf("failed to generate auth secret: %w", err)
+		}
+	}
+
+	// Register zones.
+	if err := registerZones(opts.Zones); err != nil {
+		return nil, err
+	}
+
+	iss := &Issuer{
+		users:           opts.Users,
+		quota:           newQuotaTracker(opts.Quota),
+		authSecret:      authSecret,
+		authTokenMaxAge: opts.AuthTokenMaxAge,
+		requestStates:   make(map[string]*requestState),
+		mux:             http.NewServeMux(),
+	}
+	iss.mux.HandleFunc(access.LoginPath, iss.handleLogin)
+	iss.mux.HandleFunc(access.UserProfilePath, iss.handleUserProfile)
+	iss.mux.HandleFunc(access.TokenRequestSetupPath, iss.handleTokenRequestSetup)
+	iss.mux.HandleFunc(access.TokenRequestIssuePath, iss.handleTokenRequestIssue)
+	iss.mux.HandleFunc(access.HealthCheckPath, iss.handleHealthCheck)
+
+	return iss, nil
+}
+
+// ServeHTTP serves the token issuer API.
+func (iss *Issuer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	iss.mux.ServeHTTP(w, r)
+}
+
+func (iss *Issuer) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Authenticate user.
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		http.Error(w, "missing credentials", account.StatusInvalidAuth)
+		return
+	}
+	user, err := iss.users.Authenticate(username, password)
+	if err != nil {
+		if !errors.Is(err, ErrInvalidCredentials) {
+			log.Warningf("issuer: failed to authenticate %s: %s", username, err)
+		}
+		http.Error(w, "invalid credentials", account.StatusInvalidAuth)
+		return
+	}
+
+	// Reuse the device ID, if provided.
+	deviceID := r.Header.Get(account.AuthHeaderDevice)
+	if deviceID == "" {
+		deviceID, err = newDeviceID()
+		if err != nil {
+			log.Warningf("issuer: failed to create device ID: %s", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+	}
+	user.Device = &account.Device{
+		Name: deviceID,
+		ID:   deviceID,
+	}
+
+	// Reply with user and initial auth token.
+	account.ApplyNextTokenToResponse(w, iss.createAuthToken(user.Username, deviceID, time.Now()))
+	iss.reply(w, r, user)
+	log.Infof("issuer: %s logged in on device %s", user.Username, deviceID)
+}
+
+func (iss *Issuer) handleUserProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, deviceID, ok := iss.authenticate(w, r)
+	if !ok {
+		return
+	}
+	user.Device = &account.Device{
+		Name: deviceID,
+		ID:   deviceID,
+	}
+
+	iss.reply(w, r, user)
+}
+
+func (iss *Issuer) handleTokenRequestSetup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, _, ok := iss.authenticate(w, r)
+	if !ok {
+		return
+	}
+	if !user.MayUseSPN() {
+		http.Error(w, "user may not use the SPN", http.StatusForbidden)
+		return
+	}
+	if !iss.quota.Check(user.Username) {
+		http.Error(w, "token quota exceeded", http.StatusTooManyRequests)
+		return
+	}
+
+	// Parse setup request.
+	setupRequest := &token.SetupRequest{}
+	_, err := dsd.LoadFromHTTPRequest(r, setupRequest)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to parse setup request: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	// Create setup.
+	state, setupResponse, err := token.HandleSetupRequest(setupRequest)
+	if err != nil {
+		log.Warningf("issuer: failed to handle setup request of %s: %s", user.Username, err)
+		http.Error(w, "failed to handle setup request", http.StatusInternalServerError)
+		return
+	}
+	iss.setRequestState(user.Username, state)
+
+	iss.reply(w, r, setupResponse)
+}
+
+func (iss *Issuer) handleTokenRequestIssue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, _, ok := iss.authenticate(w, r)
+	if !ok {
+		return
+	}
+	if !user.MayUseSPN() {
+		http.Error(w, "user may not use the SPN", http.StatusForbidden)
+		return
+	}
+
+	// Parse token request.
+	tokenRequest := &token.TokenRequest{}
+	_, err := dsd.LoadFromHTTPRequest(r, tokenRequest)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to parse token request: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	// Get the state of the setup.
+	// Token requests without setup only request scramble tokens, which this
+	// issuer does not support.
+	state := iss.takeRequestState(user.Username, tokenRequest.SessionID)
+	if state == nil {
+		http.Error(w, "unknown or expired session", http.StatusBadRequest)
+		return
+	}
+
+	// Issue tokens.
+	if !iss.quota.Use(user.Username) {
+		http.Error(w, "token quota exceeded", http.StatusTooManyRequests)
+		return
+	}
+	issuedTokens, err := token.IssueTokens(state, tokenRequest)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to issue tokens: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	iss.reply(w, r, issuedTokens)
+	log.Infof("issuer: issued tokens to %s", user.Username)
+}
+
+func (iss *Issuer) handleHealthCheck(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// authenticate checks the auth token of the request and returns the user.
+// If authentication fails, an error is written to the response.
+func (iss *Issuer) authenticate(w http.ResponseWriter, r *http.Request) (user *account.User, deviceID string, ok bool) {
+	username, deviceID, err := iss.checkAuthToken(r)
+	if err != nil {
+		http.Error(w, "invalid auth token", account.StatusInvalidAuth)
+		return nil, "", false
+	}
+
+	user, err = iss.users.GetUser(username)
+	if err != nil {
+		if !errors.Is(err, ErrUserNotFound) {
+			log.Warningf("issuer: failed to get user %s: %s", username, err)
+		}
+		http.Error(w, "invalid auth token", account.StatusInvalidAuth)
+		return nil, "", false
+	}
+
+	// Rotate the auth token, so that active devices stay logged in.
+	account.ApplyNextTokenToResponse(w, iss.createAuthToken(username, deviceID, time.Now()))
+	return user, deviceID, true
+}
+
+func (iss *Issuer) reply(w http.ResponseWriter, r *http.Request, data interface{}) {
+	err := dsd.DumpToHTTPResponse(w, r, data)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to write response: %s", err), http.StatusNotAcceptable)
+	}
+}
+
+func (iss *Issuer) setRequestState(username string, state *token.RequestHandlingState) {
+	iss.requestStatesLock.Lock()
+	defer iss.requestStatesLock.Unlock()
+
+	// Remove expired states.
+	now := time.Now()
+	for key, rs := range iss.requestStates {
+		if now.After(rs.expires) {
+			delete(iss.requestStates, key)
+		}
+	}
+
+	// Every user may only have one pending request.
+	iss.requestStates[username] = &requestState{
+		state:   state,
+		expires: now.Add(requestStateTTL),
+	}
+}
+
+func (iss *Issuer) takeRequestState(username, sessionID string) *token.RequestHandlingState {
+	iss.requestStatesLock.Lock()
+	defer iss.requestStatesLock.Unlock()
+
+	rs, ok := iss.requestStates[username]
+	if !ok || rs.state.SessionID != sessionID {
+		return nil
+	}
+	delete(iss.requestStates, username)
+
+	if time.Now().After(rs.expires) {
+		return nil
+	}
+	return rs.state
+}
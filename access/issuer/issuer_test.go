@@ -0,0 +1,207 @@
+package issuer
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/safing/portbase/formats/dsd"
+	"github.com/safing/spn/access"
+	"github.com/safing/spn/access/account"
+	"github.com/safing/spn/access/token"
+)
+
+const testZone = "test-issuer"
+
+func TestIssuer(t *testing.T) {
+	privateKey, _, err := GenerateZoneKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	passwordHash, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := NewStaticUsers([]*StaticUser{{
+		Username:     "test",
+		PasswordHash: passwordHash,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create issuer with a quota of one token batch.
+	iss, err := New(Options{
+		Zones: []*Zone{{
+			Zone:       testZone,
+			PrivateKey: privateKey,
+		}},
+		Users: users,
+		Quota: Quota{
+			Requests: 1,
+			Period:   time.Hour,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(iss)
+	defer server.Close()
+
+	// Log in with wrong and correct password.
+	_, resp := testRequest(t, server, http.MethodPost, access.LoginPath, nil, nil, func(r *http.Request) {
+		r.SetBasicAuth("test", "wrong")
+	})
+	if resp.StatusCode != account.StatusInvalidAuth {
+		t.Fatalf("expected login with wrong password to fail, got %d", resp.StatusCode)
+	}
+	user := &account.User{}
+	authToken, resp := testRequest(t, server, http.MethodPost, access.LoginPath, nil, user, func(r *http.Request) {
+		r.SetBasicAuth("test", "secret")
+	})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("failed to log in: %d", resp.StatusCode)
+	}
+	if !user.MayUseSPN() {
+		t.Fatal("user should be able to use the SPN")
+	}
+
+	// Get user profile with auth token.
+	_, resp = testRequest(t, server, http.MethodGet, access.UserProfilePath, nil, user, authToken.ApplyTo)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("failed to get user profile: %d", resp.StatusCode)
+	}
+	_, resp = testRequest(t, server, http.MethodGet, access.UserProfilePath, nil, user, func(r *http.Request) {
+		(&account.AuthToken{Device: "other", Token: authToken.Token}).ApplyTo(r)
+	})
+	if resp.StatusCode != account.StatusInvalidAuth {
+		t.Fatalf("expected auth token of other device to fail, got %d", resp.StatusCode)
+	}
+
+	// Request tokens.
+	setupRequest, _ := token.CreateSetupRequest()
+	setupResponse := &token.SetupResponse{}
+	_, resp = testRequest(t, server, http.MethodPost, access.TokenRequestSetupPath, setupRequest, setupResponse, authToken.ApplyTo)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("failed to request setup: %d", resp.StatusCode)
+	}
+	tokenRequest, _, err := token.CreateTokenRequest(setupResponse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuedTokens := &token.IssuedTokens{}
+	_, resp = testRequest(t, server, http.MethodPost, access.TokenRequestIssuePath, tokenRequest, issuedTokens, authToken.ApplyTo)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("failed to request tokens: %d", resp.StatusCode)
+	}
+	err = token.ProcessIssuedTokens(issuedTokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Use a token.
+	newToken, err := token.GetToken(testZone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = token.VerifyToken(newToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Check quota.
+	_, resp = testRequest(t, server, http.MethodPost, access.TokenRequestSetupPath, setupRequest, nil, authToken.ApplyTo)
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected quota to be exceeded, got %d", resp.StatusCode)
+	}
+}
+
+func testRequest(
+	t *testing.T,
+	server *httptest.Server,
+	method, path string,
+	send, recv interface{},
+	setup func(*http.Request),
+) (*account.AuthToken, *http.Response) {
+	t.Helper()
+
+	request, err := http.NewRequest(method, server.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if send != nil {
+		err = dsd.DumpToHTTPRequest(request, send, dsd.MsgPack)
+	} else {
+		_, err = dsd.RequestHTTPResponseFormat(request, dsd.JSON)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if setup != nil {
+		setup(request)
+	}
+
+	resp, err := server.Client().Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK && recv != nil {
+		_, err = dsd.LoadFromHTTPResponse(resp, recv)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Get auth token for following requests.
+	var authToken *account.AuthToken
+	if nextToken, ok := account.GetNextTokenFromResponse(resp); ok {
+		user, ok := recv.(*account.User)
+		if ok && user.Device != nil {
+			authToken = &account.AuthToken{
+				Device: user.Device.ID,
+				Token:  nextToken,
+			}
+		}
+	}
+	return authToken, resp
+}
+
+func TestAuthTokenExpiry(t *testing.T) {
+	iss := &Issuer{
+		authSecret:      []byte("test-secret"),
+		authTokenMaxAge: time.Hour,
+	}
+	check := func(token string) error {
+		request := httptest.NewRequest(http.MethodGet, access.UserProfilePath, nil)
+		(&account.AuthToken{Device: "device", Token: token}).ApplyTo(request)
+		_, _, err := iss.checkAuthToken(request)
+		return err
+	}
+
+	// Fresh tokens are accepted.
+	if err := check(iss.createAuthToken("test", "device", time.Now())); err != nil {
+		t.Fatalf("fresh auth token should be accepted: %s", err)
+	}
+
+	// Tokens older than the max age are rejected.
+	if err := check(iss.createAuthToken("test", "device", time.Now().Add(-2*time.Hour))); !errors.Is(err, errExpiredAuthToken) {
+		t.Fatalf("expired auth token should be rejected, got %v", err)
+	}
+
+	// Tokens from the future are rejected.
+	if err := check(iss.createAuthToken("test", "device", time.Now().Add(time.Hour))); !errors.Is(err, errInvalidAuthToken) {
+		t.Fatalf("auth token from the future should be rejected, got %v", err)
+	}
+
+	// The issue time is authenticated.
+	splitted := strings.SplitN(iss.createAuthToken("test", "device", time.Now().Add(-2*time.Hour)), ".", 3)
+	splitted[1] = strconv.FormatInt(time.Now().Unix(), 10)
+	if err := check(strings.Join(splitted, ".")); !errors.Is(err, errInvalidAuthToken) {
+		t.Fatalf("auth token with modified issue time should be rejected, got %v", err)
+	}
+}
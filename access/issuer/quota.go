@@ -0,0 +1,79 @@
+package issuer
+
+import (
+	"sync"
+	"time"
+)
+
+// Quota defines how many token batches a user may request.
+type Quota struct {
+	// Requests is the amount of token batches a user may request per period.
+	// If zero, the amount is not limited.
+	Requests int
+
+	// Period is the period the quota applies to.
+	Period time.Duration
+}
+
+// quotaTracker tracks token issuance per user.
+type quotaTracker struct {
+	sync.Mutex
+
+	quota  Quota
+	issued map[string][]time.Time
+}
+
+func newQuotaTracker(quota Quota) *quotaTracker {
+	return &quotaTracker{
+		quota:  quota,
+		issued: make(map[string][]time.Time),
+	}
+}
+
+// Check returns whether the user may request tokens.
+func (qt *quotaTracker) Check(username string) bool {
+	if qt.quota.Requests <= 0 {
+		return true
+	}
+
+	qt.Lock()
+	defer qt.Unlock()
+
+	return len(qt.clean(username, time.Now())) < qt.quota.Requests
+}
+
+// Use uses one request of the quota of the user. It returns false if the
+// quota is exceeded.
+func (qt *quotaTracker) Use(username string) bool {
+	if qt.quota.Requests <= 0 {
+		return true
+	}
+
+	qt.Lock()
+	defer qt.Unlock()
+
+	now := time.Now()
+	issued := qt.clean(username, now)
+	if len(issued) >= qt.quota.Requests {
+		return false
+	}
+	qt.issued[username] = append(issued, now)
+	return true
+}
+
+// clean removes issuances outside of the quota period and returns the
+// remaining ones. The tracker must be locked.
+func (qt *quotaTracker) clean(username string, now time.Time) []time.Time {
+	issued := qt.issued[username]
+	periodStart := now.Add(-qt.quota.Period)
+	for len(issued) > 0 && issued[0].Before(periodStart) {
+		issued = issued[1:]
+	}
+
+	if len(issued) == 0 {
+		delete(qt.issued, username)
+		return nil
+	}
+	qt.issued[username] = issued
+	return issued
+}
@@ -0,0 +1,153 @@
+package issuer
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/safing/spn/access/account"
+)
+
+var (
+	// ErrInvalidCredentials is returned by user backends when the given
+	// credentials are invalid.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+
+	// ErrUserNotFound is returned by user backends when the user does not exist.
+	ErrUserNotFound = errors.New("user not found")
+)
+
+// UserBackend authenticates users and provides their account data.
+// Implementations must be safe for concurrent use.
+type UserBackend interface {
+	// Authenticate checks the given credentials and returns the user.
+	// It must return ErrInvalidCredentials if the credentials are invalid.
+	Authenticate(username, password string) (*account.User, error)
+
+	// GetUser returns the current account data of the given user.
+	// It must return ErrUserNotFound if the user does not exist anymore.
+	GetUser(username string) (*account.User, error)
+}
+
+// StaticUser is a user of the StaticUsers backend.
+type StaticUser struct {
+	// Username is the name of the user.
+	Username string
+
+	// PasswordHash is the bcrypt hash of the password of the user.
+	// Use HashPassword to create it.
+	PasswordHash string
+
+	// ValidUntil defines until when the user may use the SPN.
+	// If not set, the user may use the SPN indefinitely.
+	ValidUntil time.Time
+
+	// Suspended defines whether the user is suspended.
+	Suspended bool
+}
+
+// StaticUsers is a user backend with a fixed set of users.
+type StaticUsers struct {
+	lock  sync.RWMutex
+	users map[string]*StaticUser
+}
+
+// NewStaticUsers returns a new user backend with the given users.
+func NewStaticUsers(users []*StaticUser) (*StaticUsers, error) {
+	su := &StaticUsers{}
+	if err := su.SetUsers(users); err != nil {
+		return nil, err
+	}
+	return su, nil
+}
+
+// SetUsers replaces all users of the backend.
+func (su *StaticUsers) SetUsers(users []*StaticUser) error {
+	userMap := make(map[string]*StaticUser, len(users))
+	for _, user := range users {
+		switch {
+		case user.Username == "":
+			return errors.New("user is missing a username")
+		case user.PasswordHash == "":
+			return fmt.Errorf("user %s is missing a password hash", user.Username)
+		}
+		if _, ok := userMap[user.Username]; ok {
+			return fmt.Errorf("user %s is defined twice", user.Username)
+		}
+		userMap[user.Username] = user
+	}
+
+	su.lock.Lock()
+	defer su.lock.Unlock()
+
+	su.users = userMap
+	return nil
+}
+
+// Authenticate checks the given credentials and returns the user.
+func (su *StaticUsers) Authenticate(username, password string) (*account.User, error) {
+	su.lock.RLock()
+	user, ok := su.users[username]
+	su.lock.RUnlock()
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return user.accountUser(), nil
+}
+
+// GetUser returns the current account data of the given user.
+func (su *StaticUsers) GetUser(username string) (*account.User, error) {
+	su.lock.RLock()
+	user, ok := su.users[username]
+	su.lock.RUnlock()
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+
+	return user.accountUser(), nil
+}
+
+func (user *StaticUser) accountUser() *account.User {
+	// Users without an end date are extended continuously.
+	endsAt := user.ValidUntil
+	if endsAt.IsZero() {
+		endsAt = time.Now().Add(30 * 24 * time.Hour)
+	}
+
+	state := account.UserStateApproved
+	if user.Suspended {
+		state = account.UserStateSuspended
+	}
+
+	subscriptionState := account.SubscriptionStateActive
+	if time.Now().After(endsAt) {
+		subscriptionState = account.SubscriptionStateExpired
+	}
+
+	return &account.User{
+		Username: user.Username,
+		State:    state,
+		Subscription: &account.Subscription{
+			EndsAt: endsAt,
+			State:  subscriptionState,
+		},
+	}
+}
+
+// HashPassword returns the password hash to use for a StaticUser.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
@@ -0,0 +1,75 @@
+package issuer
+
+import (
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+
+	"github.com/mr-tron/base58"
+	"github.com/rot256/pblind"
+
+	"github.com/safing/spn/access/token"
+)
+
+// Zone defines a pblind token zone served by the issuer.
+// The settings of the zone match the private zones of the access module.
+type Zone struct {
+	// Zone is the name of the zone.
+	Zone string
+
+	// PrivateKey is the base58 encoded P-256 private key of the zone.
+	// It is used without epoch and validity window.
+	// Use GenerateZoneKey to create it.
+	PrivateKey string
+
+	// Keys holds the private keys of the zone with their epochs and validity
+	// windows. Tokens are issued with the valid key that became valid last.
+	Keys []*token.PBlindKey
+}
+
+// GenerateZoneKey generates a new key pair for a pblind zone. The public key
+// is used as the PublicKey of the access.PrivateZone on clients and Hubs.
+func GenerateZoneKey() (privateKey, publicKey string, err error) {
+	secretKey, err := pblind.NewSecretKey(elliptic.P256())
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	pubKey := secretKey.GetPublicKey()
+
+	return base58.Encode(secretKey.Bytes()), base58.Encode(pubKey.Bytes()), nil
+}
+
+// registerZones registers token handlers for the given zones.
+func registerZones(zones []*Zone) error {
+	if len(zones) == 0 {
+		return errors.New("no zones defined")
+	}
+
+	for _, zone := range zones {
+		switch {
+		case zone.Zone == "":
+			return errors.New("zone is missing a name")
+		case zone.PrivateKey == "" && len(zone.Keys) == 0:
+			return fmt.Errorf("zone %s is missing a private key", zone.Zone)
+		}
+
+		ph, err := token.NewPBlindHandler(token.PBlindOptions{
+			Zone:           zone.Zone,
+			CurveName:      "P-256",
+			PrivateKey:     zone.PrivateKey,
+			Keys:           zone.Keys,
+			UseSerials:     true,
+			BatchSize:      1000,
+			RandomizeOrder: true,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create %s token handler: %w", zone.Zone, err)
+		}
+		err = token.RegisterPBlindHandler(ph)
+		if err != nil {
+			return fmt.Errorf("failed to register %s token handler: %w", zone.Zone, err)
+		}
+	}
+
+	return nil
+}
@@ -35,7 +35,7 @@ var (
 )
 
 func init() {
-	module = modules.Register("access", prep, start, stop)
+	module = modules.Register("access", prep, start, stop, "base")
 }
 
 func prep() error {
@@ -68,6 +68,14 @@ func start() error {
 		// First execution is done by the client manager in the captain module.
 	}
 
+	if conf.PublicHub() {
+		// Prune spent tokens of expired key epochs.
+		pruneSpentTokensTask = module.NewTask(
+			"prune spent tokens",
+			pruneSpentTokens,
+		).Repeat(1 * time.Hour).Queue()
+	}
+
 	return nil
 }
 
@@ -81,6 +89,11 @@ func stop() error {
 		storeTokens()
 	}
 
+	if pruneSpentTokensTask != nil {
+		pruneSpentTokensTask.Cancel()
+		pruneSpentTokensTask = nil
+	}
+
 	// Reset zones.
 	resetZones()
 
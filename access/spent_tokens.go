@@ -0,0 +1,360 @@
+package access
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/safing/portbase/database/query"
+	"github.com/safing/portbase/database/record"
+	"github.com/safing/portbase/log"
+	"github.com/safing/portbase/modules"
+	"github.com/safing/spn/access/token"
+)
+
+const (
+	spentTokenKeyPrefix = "core:spn/access/spent/"
+
+	// spentTokenCacheSize defines how many spent tokens are kept in memory in
+	// order to answer repeated checks without a database lookup.
+	spentTokenCacheSize = 65536
+
+	// spentTokenPendingMax defines how many spent tokens are kept for sharing
+	// with other Hubs at most. Further tokens are not shared.
+	spentTokenPendingMax = 10000
+
+	// spentTokenMaxBatchSize defines how many spent tokens are accepted in a
+	// single import.
+	spentTokenMaxBatchSize = spentTokenPendingMax
+)
+
+// ErrTokenAlreadySpent is returned when a token was already spent.
+var ErrTokenAlreadySpent = errors.New("token was already spent")
+
+// SpentToken identifies a spent token.
+// Spent tokens are bucketed by zone, key epoch and serial, so that the
+// buckets of retired epochs can be pruned.
+type SpentToken struct {
+	Zone   string `json:"Z"`
+	Epoch  string `json:"E"`
+	Serial int    `json:"N,omitempty"`
+	Hash   []byte `json:"H"`
+}
+
+// NewSpentToken returns the spent token entry for the given pblind token.
+func NewSpentToken(zone, epoch string, t *token.PBlindToken) *SpentToken {
+	hash := sha256.Sum256(t.Token)
+	return &SpentToken{
+		Zone:   zone,
+		Epoch:  epoch,
+		Serial: t.Serial,
+		Hash:   hash[:],
+	}
+}
+
+func (st *SpentToken) key() string {
+	return fmt.Sprintf(
+		"%s%s/%s/%d/%s",
+		spentTokenKeyPrefix,
+		st.Zone,
+		st.Epoch,
+		st.Serial,
+		hex.EncodeToString(st.Hash),
+	)
+}
+
+func (st *SpentToken) check() error {
+	switch {
+	case st.Zone == "" || strings.Contains(st.Zone, "/"):
+		return errors.New("invalid zone")
+	case st.Epoch == "" || strings.Contains(st.Epoch, "/"):
+		return errors.New("invalid epoch")
+	case st.Serial < 0:
+		return errors.New("invalid serial")
+	case len(st.Hash) != sha256.Size:
+		return errors.New("invalid hash")
+	}
+	return nil
+}
+
+type spentTokenRecord struct {
+	record.Base
+	sync.Mutex
+
+	SpentAt int64
+}
+
+// SpentTokenStore persistently stores spent tokens in order to protect
+// against double spending. It is safe for concurrent use and keeps only a
+// bounded amount of spent tokens in memory.
+type SpentTokenStore struct {
+	lock sync.Mutex
+
+	// cache holds recently spent tokens. cacheOrder is used to evict the
+	// oldest entries.
+	cache      map[string]struct{}
+	cacheOrder []string
+
+	// pending holds spent tokens that were not yet shared with other Hubs.
+	pending []*SpentToken
+	// sharing defines whether spent tokens should be kept for sharing.
+	sharing bool
+}
+
+// SpentTokens is the store of spent tokens of this Hub.
+var SpentTokens = NewSpentTokenStore()
+
+// NewSpentTokenStore returns a new spent token store.
+func NewSpentTokenStore() *SpentTokenStore {
+	return &SpentTokenStore{
+		cache: make(map[string]struct{}),
+	}
+}
+
+// EnableSharing enables keeping spent tokens for sharing with other Hubs.
+func (s *SpentTokenStore) EnableSharing(enable bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.sharing = enable
+	if !enable {
+		s.pending = nil
+	}
+}
+
+// Spend marks the given token as spent. It returns ErrTokenAlreadySpent if
+// the token was already spent.
+func (s *SpentTokenStore) Spend(st *SpentToken) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	spent, err := s.add(st)
+	if err != nil {
+		return err
+	}
+	if !spent {
+		return ErrTokenAlreadySpent
+	}
+
+	// Keep for sharing.
+	if s.sharing && len(s.pending) < spentTokenPendingMax {
+		s.pending = append(s.pending, st)
+	}
+	return nil
+}
+
+// Import imports spent tokens from another Hub and returns how many were new.
+func (s *SpentTokenStore) Import(spentTokens []*SpentToken) (imported int, err error) {
+	if len(spentTokens) > spentTokenMaxBatchSize {
+		return 0, fmt.Errorf("too many spent tokens: %d", len(spentTokens))
+	}
+
+	// Check all spent tokens before importing any.
+	for _, st := range spentTokens {
+		if err := st.check(); err != nil {
+			return 0, fmt.Errorf("invalid spent token: %w", err)
+		}
+	}
+
+	for _, st := range spentTokens {
+		added, err := s.importOne(st)
+		if err != nil {
+			return imported, err
+		}
+		if added {
+			imported++
+		}
+	}
+	return imported, nil
+}
+
+// importOne imports a single spent token. The store is only locked per entry,
+// so that large imports do not block spending tokens.
+func (s *SpentTokenStore) importOne(st *SpentToken) (added bool, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.add(st)
+}
+
+// TakePending returns and removes the spent tokens that are waiting to be
+// shared with other Hubs.
+func (s *SpentTokenStore) TakePending() []*SpentToken {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	pending := s.pending
+	s.pending = nil
+	return pending
+}
+
+// add adds the spent token to the store and returns whether it was added.
+// The store must be locked.
+func (s *SpentTokenStore) add(st *SpentToken) (added bool, err error) {
+	key := st.key()
+
+	// Check cache.
+	if _, ok := s.cache[key]; ok {
+		return false, nil
+	}
+
+	// Check database.
+	exists, err := db.Exists(key)
+	if err != nil {
+		return false, fmt.Errorf("failed to check spent token: %w", err)
+	}
+	if !exists {
+		r := &spentTokenRecord{
+			SpentAt: time.Now().Unix(),
+		}
+		r.SetKey(key)
+		r.UpdateMeta()
+		if err := db.Put(r); err != nil {
+			return false, fmt.Errorf("failed to save spent token: %w", err)
+		}
+	}
+
+	// Add to cache and evict oldest entries.
+	s.cache[key] = struct{}{}
+	s.cacheOrder = append(s.cacheOrder, key)
+	if len(s.cacheOrder) > spentTokenCacheSize {
+		evict := len(s.cacheOrder) - spentTokenCacheSize
+		for _, evictKey := range s.cacheOrder[:evict] {
+			delete(s.cache, evictKey)
+		}
+		s.cacheOrder = append(s.cacheOrder[:0:0], s.cacheOrder[evict:]...)
+	}
+
+	return !exists, nil
+}
+
+// Prune removes all spent tokens of the given zone that are not in one of the
+// given epochs. Tokens of expired epochs cannot be verified anymore, so their
+// spent tokens are not needed anymore.
+func (s *SpentTokenStore) Prune(zone string, activeEpochs []string) (pruned int, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	zonePrefix := spentTokenKeyPrefix + zone + "/"
+	it, err := db.Query(query.New(zonePrefix))
+	if err != nil {
+		return 0, fmt.Errorf("failed to query spent tokens: %w", err)
+	}
+
+	// Collect keys of expired epochs.
+	var retired []string
+	for r := range it.Next {
+		epoch := strings.SplitN(strings.TrimPrefix(r.Key(), zonePrefix), "/", 2)[0]
+		if !stringInSlice(epoch, activeEpochs) {
+			retired = append(retired, r.Key())
+		}
+	}
+	if it.Err() != nil {
+		return 0, fmt.Errorf("failed to query spent tokens: %w", it.Err())
+	}
+
+	// Delete retired spent tokens.
+	for _, key := range retired {
+		if err := db.Delete(key); err != nil {
+			return pruned, fmt.Errorf("failed to delete spent token: %w", err)
+		}
+		pruned++
+	}
+
+	return pruned, nil
+}
+
+var (
+	// spentTokenZones holds the keys of the zones with double spend
+	// protection, in order to prune spent tokens of expired epochs.
+	spentTokenZones     = make(map[string][]*token.PBlindKey)
+	spentTokenZonesLock sync.Mutex
+
+	pruneSpentTokensTask *modules.Task
+)
+
+// pblindDoubleSpendProtection returns a double spend protection for the
+// given pblind zone and its keys.
+func pblindDoubleSpendProtection(zone string, keys []*token.PBlindKey) func(*token.PBlindToken) error {
+	spentTokenZonesLock.Lock()
+	defer spentTokenZonesLock.Unlock()
+
+	spentTokenZones[zone] = keys
+	return func(t *token.PBlindToken) error {
+		return SpentTokens.Spend(NewSpentToken(zone, spentTokenEpoch(keys, t.Epoch), t))
+	}
+}
+
+// spentTokenEpoch returns the epoch to bucket spent tokens of the given key
+// epoch in. Spent tokens of the key without epoch are bucketed by the hash of
+// its public key, so that they are pruned when the key is replaced.
+func spentTokenEpoch(keys []*token.PBlindKey, epoch string) string {
+	if epoch != "" {
+		return epoch
+	}
+	for _, key := range keys {
+		if key.Epoch == "" {
+			hash := sha256.Sum256([]byte(key.PublicKey))
+			return hex.EncodeToString(hash[:8])
+		}
+	}
+	return "none"
+}
+
+// pruneSpentTokens prunes spent tokens of expired key epochs.
+func pruneSpentTokens(_ context.Context, _ *modules.Task) error {
+	spentTokenZonesLock.Lock()
+	zones := make(map[string][]*token.PBlindKey, len(spentTokenZones))
+	for zone, keys := range spentTokenZones {
+		zones[zone] = keys
+	}
+	spentTokenZonesLock.Unlock()
+
+	for zone, keys := range zones {
+		// Get active epochs from handler.
+		handler, ok := token.GetHandler(zone)
+		if !ok {
+			continue
+		}
+		pblindHandler, ok := handler.(*token.PBlindHandler)
+		if !ok {
+			continue
+		}
+		activeEpochs := pblindHandler.ActiveEpochs()
+		for i, epoch := range activeEpochs {
+			activeEpochs[i] = spentTokenEpoch(keys, epoch)
+		}
+
+		// Prune spent tokens of all other epochs.
+		pruned, err := SpentTokens.Prune(zone, activeEpochs)
+		switch {
+		case err != nil:
+			log.Warningf("access: failed to prune spent %s tokens: %s", zone, err)
+		case pruned > 0:
+			log.Infof("access: pruned %d spent %s tokens of expired key epochs", pruned, zone)
+		}
+	}
+
+	return nil
+}
+
+func resetSpentTokenZones() {
+	spentTokenZonesLock.Lock()
+	defer spentTokenZonesLock.Unlock()
+
+	spentTokenZones = make(map[string][]*token.PBlindKey)
+}
+
+func stringInSlice(s string, slice []string) bool {
+	for _, entry := range slice {
+		if entry == s {
+			return true
+		}
+	}
+	return false
+}
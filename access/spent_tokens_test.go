@@ -0,0 +1,80 @@
+package access
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSpentTokenStore(t *testing.T) {
+	// Use a unique zone, as spent tokens are persisted.
+	zone := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	defer func() {
+		_, _ = NewSpentTokenStore().Prune(zone, nil)
+	}()
+
+	s := NewSpentTokenStore()
+	s.EnableSharing(true)
+
+	first := testSpentToken(zone, "epoch1", 1)
+	if err := s.Spend(first); err != nil {
+		t.Fatalf("failed to spend token: %s", err)
+	}
+	if err := s.Spend(first); !errors.Is(err, ErrTokenAlreadySpent) {
+		t.Fatalf("expected token to be already spent, got %v", err)
+	}
+
+	// Spent tokens are persisted, so a new store must detect them too.
+	if err := NewSpentTokenStore().Spend(first); !errors.Is(err, ErrTokenAlreadySpent) {
+		t.Fatalf("expected token to be already spent in new store, got %v", err)
+	}
+
+	// Check sharing.
+	pending := s.TakePending()
+	if len(pending) != 1 {
+		t.Fatalf("expected 1 pending spent token, got %d", len(pending))
+	}
+	if len(s.TakePending()) != 0 {
+		t.Fatal("expected no pending spent tokens after taking them")
+	}
+
+	// Import from another Hub.
+	second := testSpentToken(zone, "epoch2", 2)
+	imported, err := s.Import([]*SpentToken{first, second})
+	if err != nil {
+		t.Fatalf("failed to import spent tokens: %s", err)
+	}
+	if imported != 1 {
+		t.Fatalf("expected 1 imported spent token, got %d", imported)
+	}
+	if err := s.Spend(second); !errors.Is(err, ErrTokenAlreadySpent) {
+		t.Fatalf("expected imported token to be already spent, got %v", err)
+	}
+	if _, err := s.Import([]*SpentToken{{Zone: zone, Epoch: "a/b"}}); err == nil {
+		t.Fatal("expected invalid spent token to fail import")
+	}
+
+	// Prune the retired epoch.
+	pruned, err := s.Prune(zone, []string{"epoch2"})
+	if err != nil {
+		t.Fatalf("failed to prune spent tokens: %s", err)
+	}
+	if pruned != 1 {
+		t.Fatalf("expected 1 pruned spent token, got %d", pruned)
+	}
+	if err := NewSpentTokenStore().Spend(second); !errors.Is(err, ErrTokenAlreadySpent) {
+		t.Fatalf("expected token of active epoch to be kept, got %v", err)
+	}
+}
+
+func testSpentToken(zone, epoch string, serial int) *SpentToken {
+	hash := sha256.Sum256([]byte(epoch))
+	return &SpentToken{
+		Zone:   zone,
+		Epoch:  epoch,
+		Serial: serial,
+		Hash:   hash[:],
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"math/big"
 	mrand "math/rand"
 	"sync"
+	"time"
 
 	"github.com/mr-tron/base58"
 	"github.com/rot256/pblind"
@@ -22,6 +23,7 @@ const (
 
 type PBlindToken struct {
 	Serial    int               `json:"N,omitempty"`
+	Epoch     string            `json:"E,omitempty"`
 	Token     []byte            `json:"T,omitempty"`
 	Signature *pblind.Signature `json:"S,omitempty"`
 }
@@ -45,37 +47,72 @@ type PBlindHandler struct {
 	sync.Mutex
 	opts *PBlindOptions
 
-	publicKey  *pblind.PublicKey
-	privateKey *pblind.SecretKey
+	keys []*pblindKey
 
 	storageLock sync.Mutex
 	Storage     []*PBlindToken
+	// checkExpiryAt holds when the stored tokens need to be checked for
+	// expired epochs next.
+	checkExpiryAt time.Time
 
 	// Client request state.
 	requestStateLock sync.Mutex
 	requestState     []RequestState
+	requestKey       *pblindKey
 }
 
 type PBlindOptions struct {
-	Zone                  string
-	CurveName             string
-	Curve                 elliptic.Curve
-	PublicKey             string
-	PrivateKey            string
+	Zone       string
+	CurveName  string
+	Curve      elliptic.Curve
+	PublicKey  string
+	PrivateKey string
+	// Keys holds the keys of the zone with their epochs. PublicKey and
+	// PrivateKey are added as a key without epoch and validity window.
+	Keys                  []*PBlindKey
 	UseSerials            bool
 	BatchSize             int
 	RandomizeOrder        bool
 	SignalShouldRequest   func(Handler)
-	DoubleSpendProtection func([]byte) error
+	DoubleSpendProtection func(*PBlindToken) error
 	Fallback              bool
 }
 
+// PBlindKey is a key of a zone, which is only valid for a certain time.
+// Tokens are bound to the epoch of the key they were issued with and can only
+// be used while that key is valid. In order to rotate keys, add a new key
+// with a new epoch and overlapping validity windows.
+type PBlindKey struct {
+	// Epoch identifies the key within the zone.
+	// Only keys created from the legacy options have no epoch.
+	Epoch string
+
+	// PublicKey is the base58 encoded public key.
+	PublicKey string
+	// PrivateKey is the base58 encoded private key. Only needed by issuers.
+	PrivateKey string
+
+	// ValidFrom defines from when on the key is used. Optional.
+	ValidFrom time.Time
+	// ValidUntil defines until when the key and its tokens may be used.
+	// Optional.
+	ValidUntil time.Time
+}
+
+type pblindKey struct {
+	*PBlindKey
+
+	publicKey  *pblind.PublicKey
+	privateKey *pblind.SecretKey
+}
+
 type PBlindSignerState struct {
 	signers []*pblind.StateSigner
 }
 
 type PBlindSetupResponse struct {
-	Msgs []*pblind.Message1
+	Epoch string `json:",omitempty"`
+	Msgs  []*pblind.Message1
 }
 
 type PBlindTokenRequest struct {
@@ -112,50 +149,180 @@ func NewPBlindHandler(opts PBlindOptions) (*PBlindHandler, error) {
 		return nil, errors.New("both curve and curve name supplied")
 	}
 
+	// Gather keys.
+	keys := opts.Keys
+	if opts.PrivateKey != "" || opts.PublicKey != "" {
+		keys = append([]*PBlindKey{{
+			PublicKey:  opts.PublicKey,
+			PrivateKey: opts.PrivateKey,
+		}}, keys...)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no key supplied")
+	}
+
 	// Load keys.
+	epochs := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		if _, ok := epochs[key.Epoch]; ok {
+			return nil, fmt.Errorf("epoch %q is defined twice", key.Epoch)
+		}
+		epochs[key.Epoch] = struct{}{}
+
+		loadedKey, err := loadPBlindKey(opts.Curve, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load key of epoch %q: %w", key.Epoch, err)
+		}
+		pbh.keys = append(pbh.keys, loadedKey)
+	}
+
+	return pbh, nil
+}
+
+func loadPBlindKey(curve elliptic.Curve, key *PBlindKey) (*pblindKey, error) {
+	loadedKey := &pblindKey{
+		PBlindKey: key,
+	}
+
 	switch {
-	case pbh.opts.PrivateKey != "":
-		keyData, err := base58.Decode(pbh.opts.PrivateKey)
+	case key.PrivateKey != "":
+		keyData, err := base58.Decode(key.PrivateKey)
 		if err != nil {
 			return nil, fmt.Errorf("failed to decode private key: %w", err)
 		}
-		pivateKey := pblind.SecretKeyFromBytes(pbh.opts.Curve, keyData)
-		pbh.privateKey = &pivateKey
-		publicKey := pbh.privateKey.GetPublicKey()
-		pbh.publicKey = &publicKey
+		pivateKey := pblind.SecretKeyFromBytes(curve, keyData)
+		loadedKey.privateKey = &pivateKey
+		publicKey := loadedKey.privateKey.GetPublicKey()
+		loadedKey.publicKey = &publicKey
 
 		// Check public key if also provided.
-		if pbh.opts.PublicKey != "" {
-			if pbh.opts.PublicKey != base58.Encode(pbh.publicKey.Bytes()) {
+		if key.PublicKey != "" {
+			if key.PublicKey != base58.Encode(loadedKey.publicKey.Bytes()) {
 				return nil, errors.New("private and public mismatch")
 			}
 		}
 
-	case pbh.opts.PublicKey != "":
-		keyData, err := base58.Decode(pbh.opts.PublicKey)
+	case key.PublicKey != "":
+		keyData, err := base58.Decode(key.PublicKey)
 		if err != nil {
 			return nil, fmt.Errorf("failed to decode public key: %w", err)
 		}
-		publicKey, err := pblind.PublicKeyFromBytes(pbh.opts.Curve, keyData)
+		publicKey, err := pblind.PublicKeyFromBytes(curve, keyData)
 		if err != nil {
 			return nil, fmt.Errorf("failed to decode public key: %w", err)
 		}
-		pbh.publicKey = &publicKey
+		loadedKey.publicKey = &publicKey
 
 	default:
 		return nil, errors.New("no key supplied")
 	}
 
-	return pbh, nil
+	return loadedKey, nil
+}
+
+// isValid returns whether the key may be used at the given time.
+func (key *pblindKey) isValid(now time.Time) bool {
+	return (key.ValidFrom.IsZero() || !now.Before(key.ValidFrom)) &&
+		!key.isExpired(now)
+}
+
+// isExpired returns whether the key has expired at the given time.
+func (key *pblindKey) isExpired(now time.Time) bool {
+	return !key.ValidUntil.IsZero() && !now.Before(key.ValidUntil)
+}
+
+// getKey returns the key of the given epoch.
+func (pbh *PBlindHandler) getKey(epoch string) *pblindKey {
+	for _, key := range pbh.keys {
+		if key.Epoch == epoch {
+			return key
+		}
+	}
+	return nil
 }
 
-func (pbh *PBlindHandler) makeInfo(serial int) (*pblind.Info, error) {
+// getValidKey returns the key of the given epoch, if it is currently valid.
+func (pbh *PBlindHandler) getValidKey(epoch string) *pblindKey {
+	key := pbh.getKey(epoch)
+	if key == nil || !key.isValid(time.Now()) {
+		return nil
+	}
+	return key
+}
+
+// getIssuingKey returns the key to issue new tokens with. This is the valid
+// key that became valid last.
+func (pbh *PBlindHandler) getIssuingKey() *pblindKey {
+	now := time.Now()
+	var selected *pblindKey
+	for _, key := range pbh.keys {
+		if key.privateKey == nil || !key.isValid(now) {
+			continue
+		}
+		if selected == nil || !key.ValidFrom.Before(selected.ValidFrom) {
+			selected = key
+		}
+	}
+	return selected
+}
+
+// ActiveEpochs returns the epochs of all keys that have not expired yet.
+func (pbh *PBlindHandler) ActiveEpochs() []string {
+	now := time.Now()
+	epochs := make([]string, 0, len(pbh.keys))
+	for _, key := range pbh.keys {
+		if !key.isExpired(now) {
+			epochs = append(epochs, key.Epoch)
+		}
+	}
+	return epochs
+}
+
+// dropExpiredTokens removes tokens of expired epochs from the storage.
+// The storage lock must be held.
+func (pbh *PBlindHandler) dropExpiredTokens() {
+	now := time.Now()
+	if now.Before(pbh.checkExpiryAt) {
+		return
+	}
+
+	// Keep tokens of epochs that have not expired.
+	kept := pbh.Storage[:0]
+	for _, t := range pbh.Storage {
+		if key := pbh.getKey(t.Epoch); key != nil && !key.isExpired(now) {
+			kept = append(kept, t)
+		}
+	}
+	for i := len(kept); i < len(pbh.Storage); i++ {
+		pbh.Storage[i] = nil
+	}
+	pbh.Storage = kept
+
+	// Check again when the next key expires.
+	pbh.checkExpiryAt = time.Time{}
+	for _, key := range pbh.keys {
+		if key.ValidUntil.After(now) &&
+			(pbh.checkExpiryAt.IsZero() || key.ValidUntil.Before(pbh.checkExpiryAt)) {
+			pbh.checkExpiryAt = key.ValidUntil
+		}
+	}
+	if pbh.checkExpiryAt.IsZero() {
+		pbh.checkExpiryAt = now.Add(24 * time.Hour)
+	}
+}
+
+func (pbh *PBlindHandler) makeInfo(serial int, epoch string) (*pblind.Info, error) {
 	// Gather data for info.
 	infoData := container.New()
 	infoData.AppendAsBlock([]byte(pbh.opts.Zone))
 	if pbh.opts.UseSerials {
 		infoData.AppendInt(serial)
 	}
+	// Bind token to the epoch of the key.
+	// Keys without epoch keep the info compatible with older tokens.
+	if epoch != "" {
+		infoData.AppendAsBlock([]byte(epoch))
+	}
 
 	// Compress to point.
 	info, err := pblind.CompressInfo(pbh.opts.Curve, infoData.CompileData())
@@ -176,6 +343,7 @@ func (pbh *PBlindHandler) ShouldRequest() bool {
 	pbh.storageLock.Lock()
 	defer pbh.storageLock.Unlock()
 
+	pbh.dropExpiredTokens()
 	return pbh.shouldRequest()
 }
 
@@ -189,6 +357,7 @@ func (pbh *PBlindHandler) Amount() int {
 	pbh.storageLock.Lock()
 	defer pbh.storageLock.Unlock()
 
+	pbh.dropExpiredTokens()
 	return len(pbh.Storage)
 }
 
@@ -199,22 +368,29 @@ func (pbh *PBlindHandler) IsFallback() bool {
 
 // CreateSetup sets up signers for a request.
 func (pbh *PBlindHandler) CreateSetup() (state *PBlindSignerState, setupResponse *PBlindSetupResponse, err error) {
+	// Get key to issue tokens with.
+	key := pbh.getIssuingKey()
+	if key == nil {
+		return nil, nil, errors.New("no valid private key available")
+	}
+
 	state = &PBlindSignerState{
 		signers: make([]*pblind.StateSigner, pbh.opts.BatchSize),
 	}
 	setupResponse = &PBlindSetupResponse{
-		Msgs: make([]*pblind.Message1, pbh.opts.BatchSize),
+		Epoch: key.Epoch,
+		Msgs:  make([]*pblind.Message1, pbh.opts.BatchSize),
 	}
 
 	// Go through the batch.
 	for i := 0; i < pbh.opts.BatchSize; i++ {
-		info, err := pbh.makeInfo(i + 1)
+		info, err := pbh.makeInfo(i+1, key.Epoch)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to create info #%d: %w", i, err)
 		}
 
 		// Create signer.
-		signer, err := pblind.CreateSigner(*pbh.privateKey, *info)
+		signer, err := pblind.CreateSigner(*key.privateKey, *info)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to create signer #%d: %w", i, err)
 		}
@@ -238,10 +414,17 @@ func (pbh *PBlindHandler) CreateTokenRequest(requestSetup *PBlindSetupResponse)
 		return nil, fmt.Errorf("invalid request setup msg count of %d", len(requestSetup.Msgs))
 	}
 
+	// Get key of the epoch the issuer uses.
+	key := pbh.getValidKey(requestSetup.Epoch)
+	if key == nil {
+		return nil, fmt.Errorf("unknown or invalid key epoch %q", requestSetup.Epoch)
+	}
+
 	// Lock and reset the request state.
 	pbh.requestStateLock.Lock()
 	defer pbh.requestStateLock.Unlock()
 	pbh.requestState = make([]RequestState, pbh.opts.BatchSize)
+	pbh.requestKey = key
 	request = &PBlindTokenRequest{
 		Msgs: make([]*pblind.Message2, pbh.opts.BatchSize),
 	}
@@ -265,13 +448,13 @@ func (pbh *PBlindHandler) CreateTokenRequest(requestSetup *PBlindSetupResponse)
 		pbh.requestState[i].Token = token
 
 		// Create public metadata.
-		info, err := pbh.makeInfo(i + 1)
+		info, err := pbh.makeInfo(i+1, key.Epoch)
 		if err != nil {
 			return nil, fmt.Errorf("failed to make token info #%d: %w", i, err)
 		}
 
 		// Create request and request state.
-		requester, err := pblind.CreateRequester(*pbh.publicKey, *info, token)
+		requester, err := pblind.CreateRequester(*key.publicKey, *info, token)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create request state #%d: %w", i, err)
 		}
@@ -346,7 +529,12 @@ func (pbh *PBlindHandler) ProcessIssuedTokens(issuedTokens *IssuedPBlindTokens)
 	defer pbh.requestStateLock.Unlock()
 	defer func() {
 		pbh.requestState = make([]RequestState, pbh.opts.BatchSize)
+		pbh.requestKey = nil
 	}()
+	key := pbh.requestKey
+	if key == nil {
+		return errors.New("no pending request")
+	}
 	finalizedTokens := make([]*PBlindToken, pbh.opts.BatchSize)
 
 	// Go through the batch.
@@ -362,16 +550,17 @@ func (pbh *PBlindHandler) ProcessIssuedTokens(issuedTokens *IssuedPBlindTokens)
 		if err != nil {
 			return fmt.Errorf("failed to create final signature #%d: %w", i, err)
 		}
-		info, err := pbh.makeInfo(i + 1)
+		info, err := pbh.makeInfo(i+1, key.Epoch)
 		if err != nil {
 			return fmt.Errorf("failed to make token info #%d: %w", i, err)
 		}
-		if !pbh.publicKey.Check(signature, *info, pbh.requestState[i].Token) {
+		if !key.publicKey.Check(signature, *info, pbh.requestState[i].Token) {
 			return fmt.Errorf("invalid signature on #%d", i)
 		}
 
 		// Save to temporary slice.
 		newToken := &PBlindToken{
+			Epoch:     key.Epoch,
 			Token:     pbh.requestState[i].Token,
 			Signature: &signature,
 		}
@@ -415,6 +604,7 @@ func (pbh *PBlindHandler) GetToken() (token *Token, err error) {
 	defer pbh.storageLock.Unlock()
 
 	// Check if we have supply.
+	pbh.dropExpiredTokens()
 	if len(pbh.Storage) == 0 {
 		return nil, ErrEmpty
 	}
@@ -462,20 +652,26 @@ func (pbh *PBlindHandler) Verify(token *Token) error {
 		return fmt.Errorf("%w: invalid serial", ErrTokenMalformed)
 	}
 
+	// Get key of the token epoch.
+	key := pbh.getValidKey(t.Epoch)
+	if key == nil {
+		return fmt.Errorf("%w: unknown or expired epoch", ErrTokenInvalid)
+	}
+
 	// Build info for checking signature.
-	info, err := pbh.makeInfo(t.Serial)
+	info, err := pbh.makeInfo(t.Serial, t.Epoch)
 	if err != nil {
 		return fmt.Errorf("%w: %s", ErrTokenMalformed, err)
 	}
 
 	// Check signature.
-	if !pbh.publicKey.Check(*t.Signature, *info, t.Token) {
+	if t.Signature == nil || !key.publicKey.Check(*t.Signature, *info, t.Token) {
 		return ErrTokenInvalid
 	}
 
 	// Check for double spending.
 	if pbh.opts.DoubleSpendProtection != nil {
-		if err := pbh.opts.DoubleSpendProtection(t.Token); err != nil {
+		if err := pbh.opts.DoubleSpendProtection(t); err != nil {
 			return fmt.Errorf("%w: %s", ErrTokenUsed, err)
 		}
 	}
@@ -514,21 +710,30 @@ func (pbh *PBlindHandler) Load(data []byte) error {
 		return err
 	}
 
-	// Check signatures on load.
+	// Check signatures on load and drop tokens of expired epochs.
+	now := time.Now()
+	loaded := make([]*PBlindToken, 0, len(s.Storage))
 	for _, t := range s.Storage {
+		key := pbh.getKey(t.Epoch)
+		if key == nil || key.isExpired(now) {
+			continue
+		}
+
 		// Build info for checking signature.
-		info, err := pbh.makeInfo(t.Serial)
+		info, err := pbh.makeInfo(t.Serial, t.Epoch)
 		if err != nil {
 			return err
 		}
 
 		// Check signature.
-		if !pbh.publicKey.Check(*t.Signature, *info, t.Token) {
+		if t.Signature == nil || !key.publicKey.Check(*t.Signature, *info, t.Token) {
 			return ErrTokenInvalid
 		}
+		loaded = append(loaded, t)
 	}
 
-	pbh.Storage = s.Storage
+	pbh.Storage = loaded
+	pbh.checkExpiryAt = time.Time{}
 	return nil
 }
 
@@ -3,9 +3,11 @@ package token
 import (
 	"crypto/elliptic"
 	"encoding/asn1"
+	"errors"
 	"testing"
 	"time"
 
+	"github.com/mr-tron/base58"
 	"github.com/rot256/pblind"
 )
 
@@ -95,6 +97,110 @@ func TestPBlind(t *testing.T) {
 	}
 }
 
+func TestPBlindEpochs(t *testing.T) {
+	// Generate keys.
+	oldKey, _ := pblind.NewSecretKey(elliptic.P256())
+	newKey, _ := pblind.NewSecretKey(elliptic.P256())
+	oldPublicKey := oldKey.GetPublicKey()
+	newPublicKey := newKey.GetPublicKey()
+
+	opts := &PBlindOptions{
+		Zone:       PBlindTestZone,
+		Curve:      elliptic.P256(),
+		UseSerials: true,
+		BatchSize:  10,
+	}
+	issuerKeys := []*PBlindKey{
+		{
+			Epoch:      "old",
+			PrivateKey: base58.Encode(oldKey.Bytes()),
+			ValidUntil: time.Now().Add(time.Hour),
+		},
+		{
+			Epoch:      "new",
+			PrivateKey: base58.Encode(newKey.Bytes()),
+			ValidFrom:  time.Now().Add(-time.Minute),
+		},
+	}
+	clientKeys := []*PBlindKey{
+		{
+			Epoch:      "old",
+			PublicKey:  base58.Encode(oldPublicKey.Bytes()),
+			ValidUntil: time.Now().Add(time.Hour),
+		},
+		{
+			Epoch:     "new",
+			PublicKey: base58.Encode(newPublicKey.Bytes()),
+			ValidFrom: time.Now().Add(-time.Minute),
+		},
+	}
+
+	// Issuer
+	opts.Keys = issuerKeys
+	issuer, err := NewPBlindHandler(*opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Client
+	opts.Keys = clientKeys
+	client, err := NewPBlindHandler(*opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Issue tokens with the newest key.
+	signerState, setupResponse, err := issuer.CreateSetup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if setupResponse.Epoch != "new" {
+		t.Fatalf("expected tokens to be issued with the new key, got epoch %q", setupResponse.Epoch)
+	}
+	request, err := client.CreateTokenRequest(setupResponse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuedTokens, err := issuer.IssueTokens(signerState, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.ProcessIssuedTokens(issuedTokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify token with a verifier that knows both keys.
+	token, err := client.GetToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify token with a verifier that only knows the old key.
+	opts.Keys = clientKeys[:1]
+	oldVerifier, err := NewPBlindHandler(*opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := oldVerifier.Verify(token); !errors.Is(err, ErrTokenInvalid) {
+		t.Fatalf("expected token of unknown epoch to be invalid, got %v", err)
+	}
+
+	// Expire the new key and check that its tokens are dropped and rejected.
+	clientKeys[1].ValidUntil = time.Now().Add(-time.Second)
+	client.checkExpiryAt = time.Time{}
+	if amount := client.Amount(); amount != 0 {
+		t.Fatalf("expected tokens of expired epoch to be dropped, got %d tokens", amount)
+	}
+	if err := client.Verify(token); !errors.Is(err, ErrTokenInvalid) {
+		t.Fatalf("expected token of expired epoch to be invalid, got %v", err)
+	}
+}
+
 func TestPBlindLibrary(t *testing.T) {
 	// generate a key-pair
 
@@ -1,7 +1,9 @@
 package access
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/safing/spn/conf"
 
@@ -20,8 +22,80 @@ var (
 		"alpha2":    terminal.AddPermissions(terminal.MayExpand, terminal.MayConnect),
 		"fallback1": terminal.AddPermissions(terminal.MayExpand, terminal.MayConnect),
 	}
+
+	// pblind1Keys holds the keys of the token issuer of pblind1.
+	// In order to rotate the key, add a new key with an epoch and let the
+	// validity windows of the keys overlap.
+	pblind1Keys = []*token.PBlindKey{
+		{PublicKey: "eXoJXzXbM66UEsM2eVi9HwyBPLMfVnNrC7gNrsfMUJDs"},
+	}
+
+	// privateZones holds the zones of a private network, if configured.
+	privateZones []*PrivateZone
 )
 
+// PrivateZone defines a token zone of a private network.
+type PrivateZone struct {
+	// Zone is the name of the zone.
+	Zone string
+
+	// PublicKey is the public key of the token issuer for this zone.
+	// It is used without epoch and validity window.
+	PublicKey string
+
+	// Keys holds the public keys of the token issuer for this zone with their
+	// epochs and validity windows. Use them in order to rotate keys.
+	Keys []*token.PBlindKey
+}
+
+// pblindKeys returns all keys of the zone.
+func (zone *PrivateZone) pblindKeys() []*token.PBlindKey {
+	if zone.PublicKey == "" {
+		return zone.Keys
+	}
+	return append([]*token.PBlindKey{{PublicKey: zone.PublicKey}}, zone.Keys...)
+}
+
+// UsePrivateZones replaces the default zones with the given zones of a
+// private network. If a token issuer is given, it replaces the default
+// account server. It must be called before the module is started.
+func UsePrivateZones(zones []*PrivateZone, tokenIssuer string) error {
+	if len(zones) == 0 {
+		return errors.New("no zones defined")
+	}
+
+	zoneNames := make([]string, 0, len(zones))
+	permissions := make(map[string]terminal.Permission, len(zones))
+	for _, zone := range zones {
+		switch {
+		case zone.Zone == "":
+			return errors.New("zone is missing a name")
+		case zone.PublicKey == "" && len(zone.Keys) == 0:
+			return fmt.Errorf("zone %s is missing a public key", zone.Zone)
+		}
+		for _, key := range zone.Keys {
+			switch {
+			case key.Epoch == "" || strings.Contains(key.Epoch, "/"):
+				return fmt.Errorf("zone %s has a key with an invalid epoch %q", zone.Zone, key.Epoch)
+			case key.PublicKey == "":
+				return fmt.Errorf("zone %s is missing a public key for epoch %s", zone.Zone, key.Epoch)
+			}
+		}
+		zoneNames = append(zoneNames, zone.Zone)
+		permissions[zone.Zone] = terminal.AddPermissions(terminal.MayExpand, terminal.MayConnect)
+	}
+
+	privateZones = zones
+	ExpandAndConnectZones = zoneNames
+	persistentZones = zoneNames
+	zonePermissions = permissions
+	if tokenIssuer != "" {
+		AccountServer = tokenIssuer
+	}
+
+	return nil
+}
+
 func initializeZones() error {
 	// Special client zone config.
 	var requestSignalHandler func(token.Handler)
@@ -29,15 +103,21 @@ func initializeZones() error {
 		requestSignalHandler = shouldRequestTokensHandler
 	}
 
+	// Only register the zones of the private network, if configured.
+	if len(privateZones) > 0 {
+		return initializePrivateZones(requestSignalHandler)
+	}
+
 	// Register pblind1 as the first primary zone.
 	ph, err := token.NewPBlindHandler(token.PBlindOptions{
-		Zone:                "pblind1",
-		CurveName:           "P-256",
-		PublicKey:           "eXoJXzXbM66UEsM2eVi9HwyBPLMfVnNrC7gNrsfMUJDs",
-		UseSerials:          true,
-		BatchSize:           1000,
-		RandomizeOrder:      true,
-		SignalShouldRequest: requestSignalHandler,
+		Zone:                  "pblind1",
+		CurveName:             "P-256",
+		Keys:                  pblind1Keys,
+		UseSerials:            true,
+		BatchSize:             1000,
+		RandomizeOrder:        true,
+		SignalShouldRequest:   requestSignalHandler,
+		DoubleSpendProtection: getDoubleSpendProtection("pblind1", pblind1Keys),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create pblind1 token handler: %w", err)
@@ -79,8 +159,44 @@ func initializeZones() error {
 	return nil
 }
 
+func initializePrivateZones(requestSignalHandler func(token.Handler)) error {
+	for _, zone := range privateZones {
+		keys := zone.pblindKeys()
+		ph, err := token.NewPBlindHandler(token.PBlindOptions{
+			Zone:                  zone.Zone,
+			CurveName:             "P-256",
+			Keys:                  keys,
+			UseSerials:            true,
+			BatchSize:             1000,
+			RandomizeOrder:        true,
+			SignalShouldRequest:   requestSignalHandler,
+			DoubleSpendProtection: getDoubleSpendProtection(zone.Zone, keys),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create %s token handler: %w", zone.Zone, err)
+		}
+		err = token.RegisterPBlindHandler(ph)
+		if err != nil {
+			return fmt.Errorf("failed to register %s token handler: %w", zone.Zone, err)
+		}
+	}
+
+	return nil
+}
+
+// getDoubleSpendProtection returns the double spend protection for the given
+// pblind zone. Only Hubs verify tokens, so clients do not need it.
+func getDoubleSpendProtection(zone string, keys []*token.PBlindKey) func(*token.PBlindToken) error {
+	if !conf.PublicHub() {
+		return nil
+	}
+
+	return pblindDoubleSpendProtection(zone, keys)
+}
+
 func resetZones() {
 	token.ResetRegistry()
+	resetSpentTokenZones()
 }
 
 func shouldRequestTokensHandler(_ token.Handler) {
@@ -84,6 +84,13 @@ var (
 	publicCfgOptionExit        config.StringArrayOption
 	publicCfgOptionExitDefault = []string{"- * TCP/25"}
 	publicCfgOptionExitOrder   = 522
+
+	// Identity Signer
+	// Path to the unix socket of an external signer agent for the identity key.
+	publicCfgOptionIdentitySignerKey     = "spn/publicHub/identitySigner"
+	publicCfgOptionIdentitySigner        config.StringOption
+	publicCfgOptionIdentitySignerDefault = ""
+	publicCfgOptionIdentitySignerOrder   = 523
 )
 
 func prepPublicHubConfig() error {
@@ -267,12 +274,43 @@ func prepPublicHubConfig() error {
 	}
 	publicCfgOptionExit = config.GetAsStringArray(publicCfgOptionExitKey, publicCfgOptionExitDefault)
 
+	err = config.Register(&config.Option{
+		Name:            "Identity Signer Agent",
+		Key:             publicCfgOptionIdentitySignerKey,
+		Description:     "Path to the unix socket of an external agent that signs with the identity key of this Hub. If set, the identity key is not loaded into the Hub.",
+		OptType:         config.OptTypeString,
+		ExpertiseLevel:  config.ExpertiseLevelDeveloper,
+		RequiresRestart: true,
+		DefaultValue:    publicCfgOptionIdentitySignerDefault,
+		Annotations: config.Annotations{
+			config.DisplayOrderAnnotation: publicCfgOptionIdentitySignerOrder,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	publicCfgOptionIdentitySigner = config.GetAsString(publicCfgOptionIdentitySignerKey, publicCfgOptionIdentitySignerDefault)
+
 	// update defaults from system
 	setDynamicPublicDefaults()
 
 	return nil
 }
 
+// PublicHubSigner returns the configured external signer for the identity key
+// of the public Hub. Returns nil if the identity key is used in-process.
+func PublicHubSigner() hub.Signer {
+	if publicCfgOptionIdentitySigner == nil {
+		return nil
+	}
+
+	socketPath := publicCfgOptionIdentitySigner()
+	if socketPath == "" {
+		return nil
+	}
+	return NewAgentSigner(socketPath)
+}
+
 func getPublicHubInfo() *hub.Announcement {
 	// get configuration
 	info := &hub.Announcement{
@@ -13,6 +13,13 @@ var db = database.NewInterface(nil)
 
 // LoadIdentity loads an identify with the given key.
 func LoadIdentity(key string) (id *Identity, changed bool, err error) {
+	return LoadIdentityWithSigner(key, nil)
+}
+
+// LoadIdentityWithSigner loads an identity with the given key, which uses the
+// given external signer for the identity key. If no signer is given, the
+// identity key must be part of the identity.
+func LoadIdentityWithSigner(key string, signer hub.Signer) (id *Identity, changed bool, err error) {
 	r, err := db.Get(key)
 	if err != nil {
 		return nil, false, err
@@ -26,7 +33,7 @@ func LoadIdentity(key string) (id *Identity, changed bool, err error) {
 	switch {
 	case id.Hub == nil:
 		return nil, false, errors.New("missing id.Hub")
-	case id.Signet == nil:
+	case id.Signet == nil && signer == nil:
 		return nil, false, errors.New("missing id.Signet")
 	case id.Hub.Info == nil:
 		return nil, false, errors.New("missing hub.Info")
@@ -46,6 +53,17 @@ func LoadIdentity(key string) (id *Identity, changed bool, err error) {
 		return nil, false, errors.New("missing hub.Info.Timestamp")
 	case id.Hub.Status.Timestamp == 0:
 		return nil, false, errors.New("missing hub.Status.Timestamp")
+	case id.Hub.Retired():
+		// Retirement is permanent, a retired identity must never be used again.
+		return nil, false, fmt.Errorf("identity %s: %w", id.ID, hub.ErrHubRetired)
+	}
+
+	// Use external signer, if given.
+	if signer != nil {
+		err = id.SetSigner(signer)
+		if err != nil {
+			return nil, false, err
+		}
 	}
 
 	// Run a initial maintenance routine.
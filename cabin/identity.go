@@ -24,6 +24,10 @@ const (
 
 	// DefaultIDKeySecurityLevel is the default security level for creating ID keys
 	DefaultIDKeySecurityLevel = 256 // Ed25519 security level is fixed, setting is ignored
+
+	// fullStatusInterval defines how often a full status is published instead
+	// of a status delta, so that Hubs that missed a delta can catch up.
+	fullStatusInterval = 1 * time.Hour
 )
 
 // Identity holds the identity of a Hub.
@@ -37,8 +41,14 @@ type Identity struct {
 
 	ExchKeys map[string]*ExchKey
 
-	infoExportCache   []byte
-	statusExportCache []byte
+	// signer optionally holds an external signer for the identity key.
+	// If not set, the Signet is used in-process.
+	signer hub.Signer
+
+	infoExportCache        []byte
+	statusExportCache      []byte
+	statusDeltaExportCache []byte
+	fullStatusExported     time.Time
 }
 
 // Lock locks the Identity through the Hub lock.
@@ -80,18 +90,70 @@ func CreateIdentity(ctx context.Context, mapName string) (*Identity, error) {
 	}
 
 	// initial maintenance routine
-	_, err = id.MaintainAnnouncement(true)
+	err = id.initialize()
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize announcement: %w", err)
+		return nil, err
 	}
-	_, err = id.MaintainStatus([]*hub.Lane{}, new(int), true)
+
+	return id, nil
+}
+
+// CreateIdentityWithSigner creates a new identity for the identity key of the
+// given external signer. The private identity key is not part of the
+// identity.
+func CreateIdentityWithSigner(ctx context.Context, mapName string, signer hub.Signer) (*Identity, error) {
+	// get public key from signer
+	keys, err := signer.PublicKeys()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get public key from signer: %w", err)
+	}
+	if len(keys) != 1 {
+		return nil, fmt.Errorf("signer must sign with exactly one key, not %d", len(keys))
+	}
+	recipient := keys[0]
+	recipient.ID, err = hub.IDFromPublicKey(recipient)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key from signer: %w", err)
+	}
+
+	id := &Identity{
+		ID:       recipient.ID,
+		Map:      mapName,
+		ExchKeys: make(map[string]*ExchKey),
+		Hub: &hub.Hub{
+			ID:        recipient.ID,
+			Map:       mapName,
+			PublicKey: recipient,
+		},
+	}
+	err = id.SetSigner(signer)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize status: %w", err)
+		return nil, err
+	}
+
+	// initial maintenance routine
+	err = id.initialize()
+	if err != nil {
+		return nil, err
 	}
 
 	return id, nil
 }
 
+// initialize runs the initial maintenance routine of a new identity.
+func (id *Identity) initialize() error {
+	_, err := id.MaintainAnnouncement(true)
+	if err != nil {
+		return fmt.Errorf("failed to initialize announcement: %w", err)
+	}
+	_, err = id.MaintainStatus([]*hub.Lane{}, new(int), true)
+	if err != nil {
+		return fmt.Errorf("failed to initialize status: %w", err)
+	}
+
+	return nil
+}
+
 // MaintainAnnouncement maintains the Hub's Announcenemt and returns whether there was a change that should be communicated to other Hubs.
 func (id *Identity) MaintainAnnouncement(selfcheck bool) (changed bool, err error) {
 	id.Lock()
@@ -114,7 +176,7 @@ func (id *Identity) MaintainAnnouncement(selfcheck bool) (changed bool, err erro
 
 	if changed || selfcheck {
 		// Export new data.
-		newInfoData, err := newInfo.Export(id.signingEnvelope())
+		newInfoData, err := newInfo.Export(id.getSigner())
 		if err != nil {
 			return false, fmt.Errorf("failed to export: %w", err)
 		}
@@ -143,6 +205,7 @@ func (id *Identity) MaintainStatus(lanes []*hub.Lane, load *int, selfcheck bool)
 
 	// Create a new status or make a copy of the status for editing.
 	var newStatus *hub.Status
+	baseStatus := id.Hub.Status
 	if id.Hub.Status != nil {
 		newStatus, err = id.Hub.Status.Copy()
 		if err != nil {
@@ -186,7 +249,7 @@ func (id *Identity) MaintainStatus(lanes []*hub.Lane, load *int, selfcheck bool)
 
 	if changed || selfcheck {
 		// Export new data.
-		newStatusData, err := newStatus.Export(id.signingEnvelope())
+		newStatusData, err := newStatus.Export(id.getSigner())
 		if err != nil {
 			return false, fmt.Errorf("failed to export: %w", err)
 		}
@@ -198,6 +261,21 @@ func (id *Identity) MaintainStatus(lanes []*hub.Lane, load *int, selfcheck bool)
 		}
 		id.statusExportCache = newStatusData
 
+		// Create a status delta, if possible and worthwhile.
+		id.statusDeltaExportCache = nil
+		if changed && baseStatus != nil && time.Since(id.fullStatusExported) < fullStatusInterval {
+			deltaData, err := hub.MakeStatusDelta(baseStatus, newStatus).Export(id.getSigner())
+			switch {
+			case err != nil:
+				log.Warningf("spn/cabin: failed to export status delta: %s", err)
+			case len(deltaData) < len(newStatusData):
+				id.statusDeltaExportCache = deltaData
+			}
+		}
+		if changed && id.statusDeltaExportCache == nil {
+			id.fullStatusExported = time.Now()
+		}
+
 		// Save message to hub message storage.
 		err = hub.SaveHubMsg(id.ID, conf.MainMapName, hub.MsgTypeStatus, newStatusData)
 		if err != nil {
@@ -217,7 +295,7 @@ func (id *Identity) MakeOfflineStatus() (offlineStatusExport []byte, err error)
 	}
 
 	// Export new data.
-	newStatusData, err := newStatus.Export(id.signingEnvelope())
+	newStatusData, err := newStatus.Export(id.getSigner())
 	if err != nil {
 		return nil, fmt.Errorf("failed to export: %w", err)
 	}
@@ -225,12 +303,209 @@ func (id *Identity) MakeOfflineStatus() (offlineStatusExport []byte, err error)
 	return newStatusData, nil
 }
 
-func (id *Identity) signingEnvelope() *jess.Envelope {
-	env := jess.NewUnconfiguredEnvelope()
-	env.SuiteID = jess.SuiteSignV1
-	env.Senders = []*jess.Signet{id.Signet}
+// MakeRetirement creates and signs a retirement message, which permanently
+// retires the Hub. The successor is optional.
+func (id *Identity) MakeRetirement(successor, reason string) (retirementExport []byte, err error) {
+	id.Lock()
+	defer id.Unlock()
+
+	// Make retirement.
+	retirement := &hub.Retirement{
+		ID:        id.Hub.ID,
+		Timestamp: time.Now().Unix(),
+		Successor: successor,
+		Reason:    reason,
+	}
+
+	// Export new data.
+	retirementData, err := retirement.Export(id.getSigner())
+	if err != nil {
+		return nil, fmt.Errorf("failed to export: %w", err)
+	}
+
+	// Apply the retirement as all other Hubs would in order to check if it's valid.
+	_, _, err = hub.ApplyRetirement(id.Hub, retirementData, conf.MainMapName, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply retirement: %w", err)
+	}
+
+	// Save message to hub message storage.
+	err = hub.SaveHubMsg(id.ID, conf.MainMapName, hub.MsgTypeRetirement, retirementData)
+	if err != nil {
+		log.Warningf("spn/cabin: failed to save own retirement: %s", err)
+	}
+
+	return retirementData, nil
+}
+
+// MakeTreeHead creates and signs a tree head of the given transparency log.
+// Returns nil if the log did not change since the last tree head.
+func (id *Identity) MakeTreeHead(tl *hub.TransparencyLog) (treeHeadExport []byte, err error) {
+	id.Lock()
+	defer id.Unlock()
+
+	// Check if the log changed.
+	previous := id.Hub.TreeHead
+	switch {
+	case previous == nil:
+	case previous.Size == tl.Size():
+		return nil, nil
+	case previous.Size > tl.Size():
+		// Publishing a smaller tree would be seen as equivocation.
+		return nil, fmt.Errorf("transparency log size %d is smaller than published tree size %d", tl.Size(), previous.Size)
+	}
+
+	// Persist the log before publishing, so that it cannot lose published leaves.
+	err = tl.Save()
+	if err != nil {
+		return nil, fmt.Errorf("failed to save transparency log: %w", err)
+	}
+
+	// Make tree head.
+	treeHead, err := tl.MakeTreeHead(previous)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tree head: %w", err)
+	}
+
+	// Export new data.
+	treeHeadData, err := treeHead.Export(id.getSigner())
+	if err != nil {
+		return nil, fmt.Errorf("failed to export: %w", err)
+	}
+
+	// Apply the tree head as all other Hubs would in order to check if it's valid.
+	_, _, err = hub.ApplyTreeHead(id.Hub, treeHeadData, conf.MainMapName, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply tree head: %w", err)
+	}
+
+	// Save message to hub message storage.
+	err = hub.SaveHubMsg(id.ID, conf.MainMapName, hub.MsgTypeTreeHead, treeHeadData)
+	if err != nil {
+		log.Warningf("spn/cabin: failed to save own tree head: %s", err)
+	}
+
+	return treeHeadData, nil
+}
+
+// RotateKey replaces the identity key with a new one. The Hub continues under
+// a new ID and the Hub with the previous ID is retired. The returned
+// succession is signed by both keys and must be distributed together with the
+// new announcement and status.
+func (id *Identity) RotateKey() (predecessor *hub.Hub, successionData []byte, err error) {
+	// The succession needs to be signed with both keys at once.
+	if id.signer != nil {
+		return nil, nil, errors.New("key rotation is not supported with an external signer")
+	}
+
+	// Create new signet.
+	signet, recipient, err := hub.CreateHubSignet(DefaultIDKeyScheme, DefaultIDKeySecurityLevel)
+	if err != nil {
+		return nil, nil, err
+	}
+	successor := &hub.Hub{
+		ID:        signet.ID,
+		Map:       conf.MainMapName,
+		PublicKey: recipient,
+	}
+
+	// Retire the current Hub in favor of the successor.
+	predecessor, load, successionData, err := id.makeSuccession(signet, successor)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	return env
+	// Initialize the successor.
+	// Lanes need to be established again with the new identity.
+	_, err = id.MaintainAnnouncement(true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize announcement: %w", err)
+	}
+	_, err = id.MaintainStatus([]*hub.Lane{}, &load, true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize status: %w", err)
+	}
+
+	// Save message to hub message storage.
+	err = hub.SaveHubMsg(predecessor.ID, conf.MainMapName, hub.MsgTypeSuccession, successionData)
+	if err != nil {
+		log.Warningf("spn/cabin: failed to save own succession: %s", err)
+	}
+
+	return predecessor, successionData, nil
+}
+
+// makeSuccession creates and signs the succession of the current Hub and
+// switches the identity to the given signet and successor Hub.
+func (id *Identity) makeSuccession(signet *jess.Signet, successor *hub.Hub) (predecessor *hub.Hub, load int, successionData []byte, err error) {
+	predecessor = id.Hub
+	predecessor.Lock()
+	defer predecessor.Unlock()
+
+	// Make succession.
+	succession := &hub.Succession{
+		ID:        id.ID,
+		Successor: signet.ID,
+		Timestamp: time.Now().Unix(),
+	}
+
+	// Export new data.
+	successionData, err = succession.Export(id.Signet, signet)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("failed to export succession: %w", err)
+	}
+
+	// Apply the succession as all other Hubs would in order to check if it's valid.
+	_, _, _, err = hub.ApplySuccession(predecessor, successor, successionData, conf.MainMapName, true)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("failed to apply succession: %w", err)
+	}
+
+	// Switch to the new identity.
+	if predecessor.Status != nil {
+		load = predecessor.Status.Load
+	}
+	id.Signet = signet
+	id.ID = signet.ID
+	id.Hub = successor
+	id.infoExportCache = nil
+	id.statusExportCache = nil
+	id.statusDeltaExportCache = nil
+	id.fullStatusExported = time.Time{}
+
+	return predecessor, load, successionData, nil
+}
+
+// getSigner returns the signer for the identity key.
+func (id *Identity) getSigner() hub.Signer {
+	if id.signer != nil {
+		return id.signer
+	}
+
+	return hub.NewSigner(id.Signet)
+}
+
+// SetSigner sets an external signer for the identity key, so that the private
+// key does not need to be loaded into the Hub process. The signer must sign
+// with the identity key of this identity.
+func (id *Identity) SetSigner(signer hub.Signer) error {
+	keys, err := signer.PublicKeys()
+	if err != nil {
+		return fmt.Errorf("failed to get public key from signer: %w", err)
+	}
+	if len(keys) != 1 {
+		return fmt.Errorf("signer must sign with exactly one key, not %d", len(keys))
+	}
+	keyID, err := hub.IDFromPublicKey(keys[0])
+	if err != nil {
+		return fmt.Errorf("invalid public key from signer: %w", err)
+	}
+	if keyID != id.ID {
+		return fmt.Errorf("signer key %s does not match identity %s", keyID, id.ID)
+	}
+
+	id.signer = signer
+	return nil
 }
 
 // ExportAnnouncement serializes and signs the Announcement.
@@ -257,9 +532,18 @@ func (id *Identity) ExportStatus() ([]byte, error) {
 	return id.statusExportCache, nil
 }
 
+// ExportStatusDelta returns the signed delta of the last status change, if
+// one should be published instead of the full Status. Returns nil otherwise.
+func (id *Identity) ExportStatusDelta() []byte {
+	id.Lock()
+	defer id.Unlock()
+
+	return id.statusDeltaExportCache
+}
+
 // SignHubMsg signs a data blob with the identity's private key.
 func (id *Identity) SignHubMsg(data []byte) ([]byte, error) {
-	return hub.SignHubMsg(data, id.signingEnvelope(), false)
+	return hub.SignHubMsg(data, id.getSigner(), false)
 }
 
 // GetSignet returns the private exchange key with the given ID.
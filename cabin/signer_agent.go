@@ -0,0 +1,239 @@
+package cabin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/safing/jess"
+	"github.com/safing/portbase/formats/dsd"
+	"github.com/safing/portbase/log"
+	"github.com/safing/spn/hub"
+)
+
+const (
+	signerAgentNetwork = "unix"
+	signerAgentTimeout = 10 * time.Second
+
+	signerAgentRequestKeys = "keys"
+	signerAgentRequestSign = "sign"
+)
+
+// signerAgentRequest is a request to a signer agent.
+// Every connection carries exactly one request and response.
+type signerAgentRequest struct {
+	Type string
+	Data []byte `json:",omitempty"`
+}
+
+// signerAgentResponse is the response of a signer agent.
+type signerAgentResponse struct {
+	Keys   []*jess.Signet `json:",omitempty"`
+	Letter []byte         `json:",omitempty"`
+	Error  string         `json:",omitempty"`
+}
+
+// AgentSigner is a Signer that delegates signing to an external agent process
+// over a local socket, so that the identity key is never loaded into the Hub
+// process.
+type AgentSigner struct {
+	address string
+
+	keysLock sync.Mutex
+	keys     []*jess.Signet
+}
+
+// NewAgentSigner returns a new signer that uses the signer agent listening on
+// the unix socket at the given path.
+func NewAgentSigner(socketPath string) *AgentSigner {
+	return &AgentSigner{
+		address: socketPath,
+	}
+}
+
+// Sign signs the given data and returns the signed letter.
+func (s *AgentSigner) Sign(data []byte) (*jess.Letter, error) {
+	resp, err := s.request(&signerAgentRequest{
+		Type: signerAgentRequestSign,
+		Data: data,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	letter, err := jess.LetterFromDSD(resp.Letter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse letter from signer agent: %w", err)
+	}
+	// Make sure the agent signed the requested data.
+	if !bytes.Equal(letter.Data, data) {
+		return nil, errors.New("signer agent signed different data")
+	}
+
+	return letter, nil
+}
+
+// PublicKeys returns the stored public keys of all signing keys.
+func (s *AgentSigner) PublicKeys() ([]*jess.Signet, error) {
+	s.keysLock.Lock()
+	defer s.keysLock.Unlock()
+
+	// Return cached keys.
+	if s.keys != nil {
+		return s.keys, nil
+	}
+
+	resp, err := s.request(&signerAgentRequest{
+		Type: signerAgentRequestKeys,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Keys) == 0 {
+		return nil, errors.New("signer agent did not return any keys")
+	}
+	for _, key := range resp.Keys {
+		if key == nil || !key.Public {
+			return nil, errors.New("signer agent returned a non-public key")
+		}
+		if err := key.LoadKey(); err != nil {
+			return nil, fmt.Errorf("failed to load public key from signer agent: %w", err)
+		}
+	}
+
+	s.keys = resp.Keys
+	return s.keys, nil
+}
+
+func (s *AgentSigner) request(req *signerAgentRequest) (*signerAgentResponse, error) {
+	conn, err := net.DialTimeout(signerAgentNetwork, s.address, signerAgentTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to signer agent: %w", err)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(signerAgentTimeout)); err != nil {
+		return nil, err
+	}
+
+	// Send request and receive response.
+	err = json.NewEncoder(conn).Encode(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to signer agent: %w", err)
+	}
+	resp := &signerAgentResponse{}
+	err = json.NewDecoder(conn).Decode(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to receive response from signer agent: %w", err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("signer agent failed: %s", resp.Error)
+	}
+
+	return resp, nil
+}
+
+// SignerAgent serves signing requests for an identity key on a local socket.
+// It is the counterpart of the AgentSigner and can be run in a separate
+// process that has access to the identity key.
+type SignerAgent struct {
+	signer *hub.EnvelopeSigner
+}
+
+// NewSignerAgent returns a new signer agent for the given private identity key.
+func NewSignerAgent(signet *jess.Signet) (*SignerAgent, error) {
+	if signet == nil || signet.Public {
+		return nil, errors.New("signer agent requires a private key")
+	}
+	if err := signet.LoadKey(); err != nil {
+		return nil, fmt.Errorf("failed to load identity key: %w", err)
+	}
+
+	return &SignerAgent{
+		signer: hub.NewSigner(signet),
+	}, nil
+}
+
+// NewSignerAgentFromFile returns a new signer agent for the private identity
+// key stored in the given file.
+func NewSignerAgentFromFile(keyFile string) (*SignerAgent, error) {
+	data, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identity key file: %w", err)
+	}
+
+	signet := &jess.Signet{}
+	_, err = dsd.Load(data, signet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse identity key file: %w", err)
+	}
+
+	return NewSignerAgent(signet)
+}
+
+// Serve serves signing requests on the given listener until the context is
+// canceled or the listener fails.
+func (agent *SignerAgent) Serve(ctx context.Context, ln net.Listener) error {
+	// Close listener when done.
+	go func() {
+		<-ctx.Done()
+		_ = ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go agent.handle(conn)
+	}
+}
+
+func (agent *SignerAgent) handle(conn net.Conn) {
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(signerAgentTimeout)); err != nil {
+		return
+	}
+
+	// Receive request.
+	req := &signerAgentRequest{}
+	err := json.NewDecoder(conn).Decode(req)
+	if err != nil {
+		log.Warningf("spn/cabin: signer agent failed to receive request: %s", err)
+		return
+	}
+
+	// Handle request.
+	resp := &signerAgentResponse{}
+	switch req.Type {
+	case signerAgentRequestKeys:
+		resp.Keys, err = agent.signer.PublicKeys()
+	case signerAgentRequestSign:
+		var letter *jess.Letter
+		letter, err = agent.signer.Sign(req.Data)
+		if err == nil {
+			resp.Letter, err = letter.ToDSD(dsd.JSON)
+		}
+	default:
+		err = fmt.Errorf("unknown request type %q", req.Type)
+	}
+	if err != nil {
+		resp = &signerAgentResponse{
+			Error: err.Error(),
+		}
+	}
+
+	// Send response.
+	err = json.NewEncoder(conn).Encode(resp)
+	if err != nil {
+		log.Warningf("spn/cabin: signer agent failed to send response: %s", err)
+	}
+}
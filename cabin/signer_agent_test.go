@@ -0,0 +1,109 @@
+package cabin
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/safing/portbase/formats/dsd"
+	"github.com/safing/spn/hub"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignerAgent(t *testing.T) {
+	t.Parallel()
+
+	// Create identity key and store it in a key file.
+	signet, recipient, err := hub.CreateHubSignet(DefaultIDKeyScheme, DefaultIDKeySecurityLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpDir, err := ioutil.TempDir("", "spn-signer-agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir) //nolint:errcheck
+	keyFile := filepath.Join(tmpDir, "identity.key")
+	keyData, err := dsd.Dump(signet, dsd.JSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, keyData, 0o0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Start agent.
+	agent, err := NewSignerAgentFromFile(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	socketPath := filepath.Join(tmpDir, "agent.sock")
+	ln, err := net.Listen(signerAgentNetwork, socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = agent.Serve(ctx, ln)
+	}()
+
+	// Create identity without the private key.
+	id := &Identity{
+		ID:  recipient.ID,
+		Map: "test",
+		Hub: &hub.Hub{
+			ID:        recipient.ID,
+			Map:       "test",
+			PublicKey: recipient,
+		},
+	}
+	signer := NewAgentSigner(socketPath)
+	if !assert.NoError(t, id.SetSigner(signer)) {
+		return
+	}
+
+	// Signer must match the identity.
+	_, other, err := hub.CreateHubSignet(DefaultIDKeyScheme, DefaultIDKeySecurityLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherID := &Identity{
+		ID:  other.ID,
+		Hub: &hub.Hub{ID: other.ID},
+	}
+	assert.Error(t, otherID.SetSigner(signer), "signer with another key should be rejected")
+
+	// Sign Hub messages through the agent.
+	statusData, err := (&hub.Status{Version: "0.1.0"}).Export(id.getSigner())
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotEmpty(t, statusData)
+	announcementData, err := (&hub.Announcement{ID: id.ID}).Export(id.getSigner())
+	if !assert.NoError(t, err) {
+		return
+	}
+	knownHub := &hub.Hub{
+		ID:        recipient.ID,
+		Map:       "test",
+		PublicKey: recipient,
+	}
+	_, _, _, err = hub.OpenHubMsg(knownHub, announcementData, "test", false)
+	assert.NoError(t, err, "announcement signed by agent should verify")
+	signed, err := id.SignHubMsg([]byte("test"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	msg, _, _, err := hub.OpenHubMsg(knownHub, signed, "test", false)
+	if assert.NoError(t, err, "msg signed by agent should verify") {
+		assert.Equal(t, []byte("test"), msg)
+	}
+
+	// Key rotation requires the key in-process.
+	_, _, err = id.RotateKey()
+	assert.Error(t, err)
+}
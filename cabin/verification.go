@@ -14,7 +14,6 @@ import (
 var (
 	verificationChallengeSize    = 32
 	verificationChallengeMinSize = 16
-	verificationSigningSuite     = jess.SuiteSignV1
 	verificationRequirements     = jess.NewRequirements().
 					Remove(jess.Confidentiality).
 					Remove(jess.Integrity).
@@ -86,14 +85,7 @@ func (id *Identity) SignVerificationRequest(request []byte, purpose, clientRefer
 	}
 
 	// Sign response.
-	e := jess.NewUnconfiguredEnvelope()
-	e.SuiteID = verificationSigningSuite
-	e.Senders = []*jess.Signet{id.Signet}
-	jession, err := e.Correspondence(nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to setup signer: %w", err)
-	}
-	letter, err := jession.Close(dataToSign)
+	letter, err := id.getSigner().Sign(dataToSign)
 	if err != nil {
 		return nil, fmt.Errorf("failed to sign: %w", err)
 	}
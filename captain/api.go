@@ -0,0 +1,93 @@
+package captain
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/safing/portbase/api"
+)
+
+// registerAPIEndpoints registers the API endpoints for operating the public
+// Hub. The API of public Hubs is only accessible from localhost, see
+// apiAuthenticator.
+func registerAPIEndpoints() error {
+	if err := api.RegisterEndpoint(api.Endpoint{
+		Path:        `spn/publicHub/retire`,
+		Write:       api.PermitUser,
+		WriteMethod: http.MethodPost,
+		BelongsTo:   module,
+		ActionFunc:  handleRetireHub,
+		Name:        "Retire Public Hub",
+		Description: "Permanently retires the public Hub and broadcasts the signed retirement to the network. This cannot be undone.",
+		Parameters: []api.Parameter{
+			{
+				Method:      http.MethodPost,
+				Field:       "successor",
+				Value:       "",
+				Description: "Optional ID of the Hub that replaces this Hub.",
+			},
+			{
+				Method:      http.MethodPost,
+				Field:       "reason",
+				Value:       "",
+				Description: "Optional human readable reason for the retirement.",
+			},
+			{
+				Method:      http.MethodPost,
+				Field:       "confirm",
+				Value:       "",
+				Description: "Must be set to the ID of this Hub in order to confirm the retirement.",
+			},
+		},
+	}); err != nil {
+		return err
+	}
+
+	if err := api.RegisterEndpoint(api.Endpoint{
+		Path:        `spn/publicHub/rotateIdentityKey`,
+		Write:       api.PermitUser,
+		WriteMethod: http.MethodPost,
+		BelongsTo:   module,
+		ActionFunc:  handleRotateIdentityKey,
+		Name:        "Rotate Public Hub Identity Key",
+		Description: "Replaces the identity key of the public Hub. The Hub continues under a new ID and all lanes are replaced.",
+	}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func handleRetireHub(ar *api.Request) (msg string, err error) {
+	if publicIdentity == nil {
+		return "", errors.New("not a public hub")
+	}
+
+	// Require confirmation, as retirement is permanent.
+	if ar.Request.FormValue("confirm") != publicIdentity.ID {
+		return "", api.ErrorWithStatus(
+			errors.New("confirm the retirement by setting confirm to the ID of this Hub"),
+			http.StatusBadRequest,
+		)
+	}
+
+	err = RetireHub(ar.Request.FormValue("successor"), ar.Request.FormValue("reason"))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Retired Hub %s.", publicIdentity.ID), nil
+}
+
+func handleRotateIdentityKey(_ *api.Request) (msg string, err error) {
+	if publicIdentity == nil {
+		return "", errors.New("not a public hub")
+	}
+
+	previousID := publicIdentity.ID
+	err = RotateIdentityKey()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Rotated identity key from %s to %s.", previousID, publicIdentity.ID), nil
+}
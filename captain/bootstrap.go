@@ -69,6 +69,11 @@ func processBootstrapFileFlag() error {
 
 // bootstrapWithUpdates loads bootstrap hubs from the updates server and imports them.
 func bootstrapWithUpdates() error {
+	// Private networks bootstrap with their own bootstrap Hubs and intel.
+	if conf.PrivateNetwork() {
+		return bootstrapPrivateNetwork()
+	}
+
 	if bootstrapFileFlag != "" {
 		return errors.New("using the bootstrap-file argument disables bootstrapping via the update system")
 	}
@@ -1,6 +1,9 @@
 package captain
 
-import "github.com/safing/portbase/config"
+import (
+	"github.com/safing/portbase/config"
+	"github.com/safing/spn/conf"
+)
 
 var (
 	CfgOptionEnableSPNKey   = "spn/enable"
@@ -11,8 +14,47 @@ var (
 	cfgOptionSpecialAccessCodeDefault = "none"
 	cfgOptionSpecialAccessCode        config.StringOption
 	cfgOptionSpecialAccessCodeOrder   = 144
+
+	// Intel Trust Roots
+	cfgOptionIntelTrustRootsKey   = "spn/intelTrustRoots"
+	cfgOptionIntelTrustRoots      config.StringArrayOption
+	cfgOptionIntelTrustRootsOrder = 145
+
+	// Allow Unsigned Intel
+	cfgOptionAllowUnsignedIntelKey   = "spn/allowUnsignedIntel"
+	cfgOptionAllowUnsignedIntel      config.BoolOption
+	cfgOptionAllowUnsignedIntelOrder = 147
+
+	// Link Capacity of the public Hub in Mbit/s, used for the load.
+	cfgOptionLinkCapacityKey     = "spn/publicHub/linkCapacity"
+	cfgOptionLinkCapacity        config.IntOption
+	cfgOptionLinkCapacityDefault int64 = 0
+	cfgOptionLinkCapacityOrder         = 530
+
+	// Uplink Interface of the public Hub, used for the load.
+	cfgOptionUplinkInterfaceKey     = "spn/publicHub/uplinkInterface"
+	cfgOptionUplinkInterface        config.StringOption
+	cfgOptionUplinkInterfaceDefault = ""
+	cfgOptionUplinkInterfaceOrder   = 533
+
+	// Terminal Capacity of the public Hub, used for the load.
+	cfgOptionTerminalCapacityKey     = "spn/publicHub/terminalCapacity"
+	cfgOptionTerminalCapacity        config.IntOption
+	cfgOptionTerminalCapacityDefault int64 = 0
+	cfgOptionTerminalCapacityOrder         = 531
+
+	// Spent Token Peers of the public Hub, used for double-spend protection.
+	cfgOptionSpentTokenPeersKey   = "spn/publicHub/spentTokenPeers"
+	cfgOptionSpentTokenPeers      config.StringArrayOption
+	cfgOptionSpentTokenPeersOrder = 532
 )
 
+// DefaultIntelTrustRoots holds the keys that are trusted to sign SPN intel by
+// default. The format is described in hub.ParseIntelTrustRoot.
+// As long as it is empty, SPN intel is only used if trust roots are configured
+// or unsigned intel is explicitly allowed.
+var DefaultIntelTrustRoots = []string{}
+
 func prepConfig() error {
 	err := config.Register(&config.Option{
 		Name:         "Special Access Code",
@@ -31,5 +73,112 @@ func prepConfig() error {
 
 	cfgOptionSpecialAccessCode = config.Concurrent.GetAsString(cfgOptionSpecialAccessCodeKey, "")
 
+	err = config.Register(&config.Option{
+		Name:           "Intel Trust Roots",
+		Key:            cfgOptionIntelTrustRootsKey,
+		Description:    "Keys that are trusted to sign SPN intel data. Unsigned intel is rejected, unless explicitly allowed. Format: <ID>:<Scheme>:<Base58 Public Key>[:<Expiry Date YYYY-MM-DD>]",
+		OptType:        config.OptTypeStringArray,
+		ExpertiseLevel: config.ExpertiseLevelDeveloper,
+		DefaultValue:   DefaultIntelTrustRoots,
+		Annotations: config.Annotations{
+			config.DisplayOrderAnnotation: cfgOptionIntelTrustRootsOrder,
+			config.CategoryAnnotation:     "Advanced",
+		},
+	})
+	if err != nil {
+		return err
+	}
+	cfgOptionIntelTrustRoots = config.Concurrent.GetAsStringArray(cfgOptionIntelTrustRootsKey, DefaultIntelTrustRoots)
+
+	err = config.Register(&config.Option{
+		Name:           "Allow Unsigned Intel",
+		Key:            cfgOptionAllowUnsignedIntelKey,
+		Description:    "Use unsigned SPN intel data, as long as no intel trust roots are set and signed intel was never accepted. Unsigned intel is not protected against tampering.",
+		OptType:        config.OptTypeBool,
+		ExpertiseLevel: config.ExpertiseLevelDeveloper,
+		DefaultValue:   false,
+		Annotations: config.Annotations{
+			config.DisplayOrderAnnotation: cfgOptionAllowUnsignedIntelOrder,
+			config.CategoryAnnotation:     "Advanced",
+		},
+	})
+	if err != nil {
+		return err
+	}
+	cfgOptionAllowUnsignedIntel = config.Concurrent.GetAsBool(cfgOptionAllowUnsignedIntelKey, false)
+
+	// Load options are only relevant for public Hubs.
+	if !conf.PublicHub() {
+		cfgOptionLinkCapacity = func() int64 { return cfgOptionLinkCapacityDefault }
+		cfgOptionUplinkInterface = func() string { return cfgOptionUplinkInterfaceDefault }
+		cfgOptionTerminalCapacity = func() int64 { return cfgOptionTerminalCapacityDefault }
+		cfgOptionSpentTokenPeers = func() []string { return nil }
+		return nil
+	}
+
+	err = config.Register(&config.Option{
+		Name:           "Link Capacity",
+		Key:            cfgOptionLinkCapacityKey,
+		Description:    "Capacity of the network link of this Hub in Mbit/s. The network usage is included in the published load in relation to this capacity. Set to 0 to disable.",
+		OptType:        config.OptTypeInt,
+		ExpertiseLevel: config.ExpertiseLevelExpert,
+		DefaultValue:   cfgOptionLinkCapacityDefault,
+		Annotations: config.Annotations{
+			config.DisplayOrderAnnotation: cfgOptionLinkCapacityOrder,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	cfgOptionLinkCapacity = config.Concurrent.GetAsInt(cfgOptionLinkCapacityKey, cfgOptionLinkCapacityDefault)
+
+	err = config.Register(&config.Option{
+		Name:           "Uplink Interface",
+		Key:            cfgOptionUplinkInterfaceKey,
+		Description:    "Name of the network interface that the Link Capacity applies to. Only the traffic of this interface is included in the published load. Leave empty to use the interface of the default route.",
+		OptType:        config.OptTypeString,
+		ExpertiseLevel: config.ExpertiseLevelExpert,
+		DefaultValue:   cfgOptionUplinkInterfaceDefault,
+		Annotations: config.Annotations{
+			config.DisplayOrderAnnotation: cfgOptionUplinkInterfaceOrder,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	cfgOptionUplinkInterface = config.Concurrent.GetAsString(cfgOptionUplinkInterfaceKey, cfgOptionUplinkInterfaceDefault)
+
+	err = config.Register(&config.Option{
+		Name:           "Terminal Capacity",
+		Key:            cfgOptionTerminalCapacityKey,
+		Description:    "Amount of active terminals this Hub can handle. The amount of active terminals is included in the published load in relation to this capacity. Set to 0 to disable.",
+		OptType:        config.OptTypeInt,
+		ExpertiseLevel: config.ExpertiseLevelExpert,
+		DefaultValue:   cfgOptionTerminalCapacityDefault,
+		Annotations: config.Annotations{
+			config.DisplayOrderAnnotation: cfgOptionTerminalCapacityOrder,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	cfgOptionTerminalCapacity = config.Concurrent.GetAsInt(cfgOptionTerminalCapacityKey, cfgOptionTerminalCapacityDefault)
+
+	err = config.Register(&config.Option{
+		Name:           "Spent Token Peers",
+		Key:            cfgOptionSpentTokenPeersKey,
+		Description:    "IDs of the Hubs of the same operator to share spent access tokens with. Spent tokens are only shared with and accepted from connected Hubs in this list. Sharing must be configured on both Hubs.",
+		OptType:        config.OptTypeStringArray,
+		ExpertiseLevel: config.ExpertiseLevelExpert,
+		DefaultValue:   []string{},
+		Annotations: config.Annotations{
+			config.DisplayOrderAnnotation: cfgOptionSpentTokenPeersOrder,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	cfgOptionSpentTokenPeers = config.Concurrent.GetAsStringArray(cfgOptionSpentTokenPeersKey, []string{})
+
 	return nil
 }
@@ -2,6 +2,9 @@ package captain
 
 import (
 	"sync"
+
+	"github.com/safing/spn/conf"
+	"github.com/safing/spn/docks"
 )
 
 var (
@@ -23,16 +26,79 @@ func deleteGossipOp(craneID string) {
 	delete(gossipOps, craneID)
 }
 
+// gossipMapName returns the map of the network that the Hub connected via the
+// given crane belongs to.
+func gossipMapName(crane *docks.Crane) string {
+	if conf.PrivateMapName != "" &&
+		crane.ConnectedHub != nil &&
+		crane.ConnectedHub.Map == conf.PrivateMapName {
+		return conf.PrivateMapName
+	}
+	return conf.MainMapName
+}
+
+// gossipPeerSupportsStatusDeltas returns whether the Hub connected via the
+// given crane announced support for status deltas.
+func gossipPeerSupportsStatusDeltas(craneID string) bool {
+	gossipOpsLock.RLock()
+	defer gossipOpsLock.RUnlock()
+
+	gossipOp, ok := gossipOps[craneID]
+	return ok && gossipOp.statusDeltas.IsSet()
+}
+
 func gossipRelayMsg(receivedFrom string, msgType GossipMsgType, data []byte) {
+	// Remember relayed msgs in order to drop them when they are sent back.
+	markGossipMsgSeen(msgType, data)
+
 	gossipOpsLock.RLock()
 	defer gossipOpsLock.RUnlock()
 
+	// Get the map the msg belongs to.
+	// Own msgs always belong to the main map.
+	mapName := conf.MainMapName
+	if sender, ok := gossipOps[receivedFrom]; ok {
+		mapName = sender.mapName
+	}
+
 	for craneID, gossipOp := range gossipOps {
 		// Don't return same msg back to sender.
 		if craneID == receivedFrom {
 			continue
 		}
 
+		// Only relay msgs within the same network.
+		if gossipOp.mapName != mapName {
+			continue
+		}
+
+		// Only send status deltas to Hubs that support them.
+		if msgType == GossipHubStatusDeltaMsg && !gossipOp.statusDeltas.IsSet() {
+			continue
+		}
+
 		gossipOp.sendMsg(msgType, data)
 	}
 }
+
+// gossipRelayStatus relays an own status update to all connected Hubs. Hubs
+// that support status deltas receive the delta, all others the full status.
+func gossipRelayStatus(deltaData, statusData []byte) {
+	// Remember relayed msgs in order to drop them when they are sent back.
+	markGossipMsgSeen(GossipHubStatusDeltaMsg, deltaData)
+	markGossipMsgSeen(GossipHubStatusMsg, statusData)
+
+	gossipOpsLock.RLock()
+	defer gossipOpsLock.RUnlock()
+
+	for _, gossipOp := range gossipOps {
+		switch {
+		case gossipOp.mapName != conf.MainMapName:
+			// Only relay msgs within the same network.
+		case gossipOp.statusDeltas.IsSet():
+			gossipOp.sendMsg(GossipHubStatusDeltaMsg, deltaData)
+		default:
+			gossipOp.sendMsg(GossipHubStatusMsg, statusData)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package captain
+
+import (
+	"crypto/sha256"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/safing/jess"
+	"github.com/safing/portbase/log"
+	"github.com/safing/spn/docks"
+)
+
+const (
+	// gossipSeenTTL defines how long msgs are remembered in order to drop
+	// duplicates received via other neighbors.
+	gossipSeenTTL = 10 * time.Minute
+
+	// gossipCleanInterval defines how often expired state is removed.
+	gossipCleanInterval = 1 * time.Minute
+
+	// gossipNeighborRate and gossipNeighborBurst define the relay budget of a
+	// neighbor in msgs per second.
+	gossipNeighborRate  = 5
+	gossipNeighborBurst = 200
+
+	// Penalties of neighbors for misbehavior.
+	gossipPenaltyInvalid   = 1
+	gossipPenaltyRateLimit = 0.2
+	gossipPenaltyBudget    = 0.1
+
+	// gossipPenaltyThreshold defines the penalty at which a neighbor is muted.
+	gossipPenaltyThreshold = 20
+	// gossipPenaltyHalfLife defines after how long a penalty only counts half.
+	gossipPenaltyHalfLife = 10 * time.Minute
+	// gossipMuteDuration defines how long msgs of a muted neighbor are dropped.
+	gossipMuteDuration = 15 * time.Minute
+)
+
+// gossipOriginLimits defines the rate limits per origin Hub and msg type.
+var gossipOriginLimits = map[GossipMsgType]struct {
+	rate  float64 // Per second.
+	burst float64
+}{
+	// Announcements only change with the configuration.
+	GossipHubAnnouncementMsg: {rate: 1.0 / (5 * 60), burst: 3},
+	// Statuses are updated regularly and when lanes change.
+	GossipHubStatusMsg:      {rate: 1.0 / 60, burst: 10},
+	GossipHubStatusDeltaMsg: {rate: 1.0 / 60, burst: 10},
+	// Retirements and successions are only sent once.
+	GossipHubRetirementMsg: {rate: 1.0 / 3600, burst: 2},
+	GossipHubSuccessionMsg: {rate: 1.0 / 3600, burst: 2},
+	// Tree heads are published regularly.
+	GossipTreeHeadMsg: {rate: 1.0 / (5 * 60), burst: 3},
+}
+
+var (
+	gossipSeen          = make(map[[16]byte]time.Time)
+	gossipOrigins       = make(map[gossipOriginKey]*tokenBucket)
+	gossipNeighbors     = make(map[string]*gossipNeighbor)
+	gossipLimitsLock    sync.Mutex
+	gossipLimitsCleaned time.Time
+)
+
+type gossipOriginKey struct {
+	hubID   string
+	msgType GossipMsgType
+}
+
+// gossipNeighbor holds the relay budget and penalty of a neighbor Hub.
+// It is kept by Hub ID in order to survive reconnects.
+type gossipNeighbor struct {
+	budget         *tokenBucket
+	penalty        float64
+	penaltyUpdated time.Time
+	mutedUntil     time.Time
+}
+
+// tokenBucket is a simple token bucket rate limiter.
+type tokenBucket struct {
+	tokens  float64
+	rate    float64
+	burst   float64
+	updated time.Time
+}
+
+func newTokenBucket(rate, burst float64, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		tokens:  burst,
+		rate:    rate,
+		burst:   burst,
+		updated: now,
+	}
+}
+
+func (tb *tokenBucket) refill(now time.Time) {
+	tb.tokens = math.Min(tb.burst, tb.tokens+now.Sub(tb.updated).Seconds()*tb.rate)
+	tb.updated = now
+}
+
+// available returns whether a token is available without taking it.
+func (tb *tokenBucket) available(now time.Time) bool {
+	tb.refill(now)
+	return tb.tokens >= 1
+}
+
+// take takes a token and returns whether one was available.
+func (tb *tokenBucket) take(now time.Time) bool {
+	if !tb.available(now) {
+		return false
+	}
+	tb.tokens--
+	return true
+}
+
+// full returns whether the bucket is full and can be discarded.
+func (tb *tokenBucket) full(now time.Time) bool {
+	tb.refill(now)
+	return tb.tokens >= tb.burst
+}
+
+// gossipMsgID returns the ID of a gossip msg for deduplication.
+func gossipMsgID(msgType GossipMsgType, data []byte) (id [16]byte) {
+	sum := sha256.Sum256(append([]byte{byte(msgType)}, data...))
+	copy(id[:], sum[:])
+	return id
+}
+
+// gossipMsgOrigin returns the ID of the Hub that claims to have signed the
+// msg. The claim is not verified, so it may only be used for checking limits
+// that are consumed after verification.
+func gossipMsgOrigin(data []byte) string {
+	letter, err := jess.LetterFromDSD(data)
+	if err != nil || len(letter.Signatures) == 0 {
+		return ""
+	}
+	return letter.Signatures[0].ID
+}
+
+// gossipMsgSeen returns whether the msg was marked as seen.
+func gossipMsgSeen(msgType GossipMsgType, data []byte) (seen bool) {
+	id := gossipMsgID(msgType, data)
+
+	gossipLimitsLock.Lock()
+	defer gossipLimitsLock.Unlock()
+
+	expires, ok := gossipSeen[id]
+	return ok && time.Now().Before(expires)
+}
+
+// markGossipMsgSeen marks a msg as seen and returns whether it was seen before.
+func markGossipMsgSeen(msgType GossipMsgType, data []byte) (seen bool) {
+	id := gossipMsgID(msgType, data)
+	now := time.Now()
+
+	gossipLimitsLock.Lock()
+	defer gossipLimitsLock.Unlock()
+
+	cleanGossipLimits(now)
+
+	if expires, ok := gossipSeen[id]; ok && now.Before(expires) {
+		return true
+	}
+	gossipSeen[id] = now.Add(gossipSeenTTL)
+	return false
+}
+
+// checkGossipOriginLimit returns whether the origin may still publish a msg of
+// the given type. The limit is only consumed by consumeGossipOriginLimit, so
+// that forged msgs cannot exhaust the limit of another Hub.
+func checkGossipOriginLimit(origin string, msgType GossipMsgType) bool {
+	if origin == "" {
+		return true
+	}
+	key := gossipOriginKey{hubID: origin, msgType: msgType}
+	now := time.Now()
+
+	gossipLimitsLock.Lock()
+	defer gossipLimitsLock.Unlock()
+
+	bucket, ok := gossipOrigins[key]
+	if !ok {
+		return true
+	}
+	return bucket.available(now)
+}
+
+// consumeGossipOriginLimit consumes the limit of the origin for a verified msg.
+func consumeGossipOriginLimit(origin string, msgType GossipMsgType) {
+	limit, ok := gossipOriginLimits[msgType]
+	if !ok || origin == "" {
+		return
+	}
+	key := gossipOriginKey{hubID: origin, msgType: msgType}
+	now := time.Now()
+
+	gossipLimitsLock.Lock()
+	defer gossipLimitsLock.Unlock()
+
+	bucket, ok := gossipOrigins[key]
+	if !ok {
+		bucket = newTokenBucket(limit.rate, limit.burst, now)
+		gossipOrigins[key] = bucket
+	}
+	bucket.take(now)
+}
+
+// acceptFromGossipNeighbor returns whether a msg from the given neighbor
+// should be processed. It takes from the relay budget of the neighbor.
+func acceptFromGossipNeighbor(neighborID string) bool {
+	now := time.Now()
+
+	gossipLimitsLock.Lock()
+	defer gossipLimitsLock.Unlock()
+
+	neighbor := getGossipNeighbor(neighborID, now)
+	if now.Before(neighbor.mutedUntil) {
+		return false
+	}
+	if !neighbor.budget.take(now) {
+		neighbor.penalize(neighborID, gossipPenaltyBudget, now)
+		return false
+	}
+	return true
+}
+
+// penalizeGossipNeighbor adds a penalty to the given neighbor. Neighbors that
+// exceed the penalty threshold are muted.
+func penalizeGossipNeighbor(neighborID string, penalty float64) {
+	now := time.Now()
+
+	gossipLimitsLock.Lock()
+	defer gossipLimitsLock.Unlock()
+
+	getGossipNeighbor(neighborID, now).penalize(neighborID, penalty, now)
+}
+
+// getGossipNeighbor returns the state of the given neighbor.
+// The gossip limits must be locked.
+func getGossipNeighbor(neighborID string, now time.Time) *gossipNeighbor {
+	neighbor, ok := gossipNeighbors[neighborID]
+	if !ok {
+		neighbor = &gossipNeighbor{
+			budget:         newTokenBucket(gossipNeighborRate, gossipNeighborBurst, now),
+			penaltyUpdated: now,
+		}
+		gossipNeighbors[neighborID] = neighbor
+	}
+	return neighbor
+}
+
+// decayPenalty applies the exponential decay to the penalty.
+func (neighbor *gossipNeighbor) decayPenalty(now time.Time) {
+	elapsed := now.Sub(neighbor.penaltyUpdated)
+	if elapsed > 0 {
+		neighbor.penalty *= math.Pow(0.5, float64(elapsed)/float64(gossipPenaltyHalfLife))
+		neighbor.penaltyUpdated = now
+	}
+}
+
+func (neighbor *gossipNeighbor) penalize(neighborID string, penalty float64, now time.Time) {
+	neighbor.decayPenalty(now)
+	neighbor.penalty += penalty
+
+	if neighbor.penalty >= gossipPenaltyThreshold && !now.Before(neighbor.mutedUntil) {
+		neighbor.mutedUntil = now.Add(gossipMuteDuration)
+		neighbor.penalty = 0
+		log.Warningf(
+			"spn/captain: muting gossip from %s for %s because of misbehavior",
+			neighborID, gossipMuteDuration,
+		)
+	}
+}
+
+// cleanGossipLimits removes expired gossip state.
+// The gossip limits must be locked.
+func cleanGossipLimits(now time.Time) {
+	if now.Sub(gossipLimitsCleaned) < gossipCleanInterval {
+		return
+	}
+	gossipLimitsCleaned = now
+
+	for id, expires := range gossipSeen {
+		if now.After(expires) {
+			delete(gossipSeen, id)
+		}
+	}
+	for key, bucket := range gossipOrigins {
+		if bucket.full(now) {
+			delete(gossipOrigins, key)
+		}
+	}
+	for neighborID, neighbor := range gossipNeighbors {
+		neighbor.decayPenalty(now)
+		if neighbor.budget.full(now) && neighbor.penalty < 0.01 && now.After(neighbor.mutedUntil) {
+			delete(gossipNeighbors, neighborID)
+		}
+	}
+}
+
+// gossipNeighborID returns the ID of the neighbor connected via the crane.
+func gossipNeighborID(crane *docks.Crane) string {
+	if crane.ConnectedHub != nil {
+		return crane.ConnectedHub.ID
+	}
+	return crane.ID
+}
@@ -0,0 +1,190 @@
+package captain
+
+import (
+	"context"
+	"time"
+
+	"github.com/safing/portbase/container"
+	"github.com/safing/portbase/formats/dsd"
+	"github.com/safing/portbase/formats/varint"
+	"github.com/safing/portbase/log"
+	"github.com/safing/spn/hub"
+	"github.com/safing/spn/terminal"
+)
+
+// Gossip sync reconciles the msgs of a gossip query instead of sending all
+// msgs. The flow is:
+// 1. The client sends a summary of its msgs when starting the query.
+// 2. The server sends its entries of all buckets that differ.
+// 3. The client sends the msgs the server lacks or has outdated, followed by
+//    the entries of the msgs it wants from the server.
+// 4. The server sends the wanted msgs and ends the query.
+// Servers that do not support syncing ignore the summary and send all msgs.
+
+const (
+	gossipSyncEntriesMsg GossipMsgType = 16
+	gossipSyncWantMsg    GossipMsgType = 17
+
+	// gossipSyncTimeout defines how long the server waits for the client to
+	// respond with the wanted msgs.
+	gossipSyncTimeout = 1 * time.Minute
+
+	// maxGossipSyncEntries defines the maximum amount of entries accepted in
+	// a single sync msg.
+	maxGossipSyncEntries = hub.MaxSyncBuckets * 64
+)
+
+// gossipSyncEntries holds the entries of the mismatching buckets.
+type gossipSyncEntries struct {
+	Buckets []int
+	Entries []*hub.SyncEntry
+}
+
+// gossipSyncWant holds the entries of the msgs the client wants.
+type gossipSyncWant struct {
+	Entries []*hub.SyncEntry
+}
+
+// syncHandler handles the server side of a gossip sync.
+func (op *GossipQueryOp) syncHandler(summary *hub.SyncSummary) {
+	// Find mismatching buckets.
+	set, err := hub.LoadSyncSet(op.mapName)
+	if err != nil {
+		op.t.OpEnd(op, terminal.ErrInternalError.With("failed to load sync set: %w", err))
+		return
+	}
+	buckets, err := set.MismatchingBuckets(summary)
+	if err != nil {
+		op.t.OpEnd(op, terminal.ErrMalformedData.With("%w", err))
+		return
+	}
+	if len(buckets) == 0 {
+		op.t.OpEnd(op, nil)
+		return
+	}
+
+	// Send entries of mismatching buckets.
+	tErr := op.sendSyncMsg(gossipSyncEntriesMsg, &gossipSyncEntries{
+		Buckets: buckets,
+		Entries: set.Entries(buckets, len(summary.Buckets)),
+	})
+	if tErr != nil {
+		op.t.OpEnd(op, tErr)
+		return
+	}
+
+	// Wait for the client to tell us what it wants.
+	var want []*hub.SyncEntry
+	select {
+	case want = <-op.syncWant:
+	case <-time.After(gossipSyncTimeout):
+		op.t.OpEnd(op, terminal.ErrTimeout.With("client did not respond to gossip sync"))
+		return
+	case <-op.ctx.Done():
+		return
+	}
+
+	// Send wanted msgs.
+	for _, entry := range want {
+		hubMsg, err := hub.GetHubMsg(op.mapName, entry.Type, entry.ID)
+		if err != nil {
+			// The msg might have been removed in the meantime.
+			continue
+		}
+		tErr := op.sendMsg(hubMsg)
+		if tErr != nil {
+			op.t.OpEnd(op, tErr)
+			return
+		}
+	}
+
+	op.t.OpEnd(op, nil)
+}
+
+// deliverSyncEntries handles the entries of the mismatching buckets sent by
+// the server.
+func (op *GossipQueryOp) deliverSyncEntries(data []byte) *terminal.Error {
+	if !op.client || op.syncSet == nil {
+		return terminal.ErrUnexpectedMsgType.With("unexpected gossip sync entries")
+	}
+
+	syncEntries := &gossipSyncEntries{}
+	_, err := dsd.Load(data, syncEntries)
+	if err != nil {
+		return terminal.ErrMalformedData.With("failed to parse gossip sync entries: %w", err)
+	}
+	if len(syncEntries.Entries) > maxGossipSyncEntries {
+		return terminal.ErrMalformedData.With("too many gossip sync entries")
+	}
+
+	// Compare with our msgs.
+	want, offer := op.syncSet.Reconcile(syncEntries.Entries, syncEntries.Buckets, op.syncSize)
+
+	module.StartWorker("gossip sync responder", func(_ context.Context) error {
+		// Send the msgs the server lacks.
+		for _, entry := range offer {
+			hubMsg, err := hub.GetHubMsg(op.mapName, entry.Type, entry.ID)
+			if err != nil {
+				continue
+			}
+			tErr := op.sendMsg(hubMsg)
+			if tErr != nil {
+				op.t.OpEnd(op, tErr)
+				return nil // Clean worker exit.
+			}
+		}
+
+		// Tell the server what we want.
+		tErr := op.sendSyncMsg(gossipSyncWantMsg, &gossipSyncWant{
+			Entries: want,
+		})
+		if tErr != nil {
+			op.t.OpEnd(op, tErr)
+			return nil // Clean worker exit.
+		}
+
+		log.Debugf("spn/captain: gossip sync sent %d msgs and requested %d msgs", len(offer), len(want))
+		return nil // Clean worker exit.
+	})
+
+	return nil
+}
+
+// deliverSyncWant handles the entries of the msgs the client wants.
+func (op *GossipQueryOp) deliverSyncWant(data []byte) *terminal.Error {
+	if op.client || op.syncWant == nil {
+		return terminal.ErrUnexpectedMsgType.With("unexpected gossip sync want")
+	}
+
+	syncWant := &gossipSyncWant{}
+	_, err := dsd.Load(data, syncWant)
+	if err != nil {
+		return terminal.ErrMalformedData.With("failed to parse gossip sync want: %w", err)
+	}
+	if len(syncWant.Entries) > maxGossipSyncEntries {
+		return terminal.ErrMalformedData.With("too many gossip sync want entries")
+	}
+
+	select {
+	case op.syncWant <- syncWant.Entries:
+	default:
+		return terminal.ErrUnexpectedMsgType.With("duplicate gossip sync want")
+	}
+	return nil
+}
+
+func (op *GossipQueryOp) sendSyncMsg(msgType GossipMsgType, msg interface{}) *terminal.Error {
+	data, err := dsd.Dump(msg, dsd.CBOR)
+	if err != nil {
+		return terminal.ErrInternalError.With("failed to pack %s: %w", msgType, err)
+	}
+
+	tErr := op.t.OpSendWithTimeout(op, container.New(
+		varint.Pack8(uint8(msgType)),
+		data,
+	), 1*time.Second)
+	if tErr != nil {
+		return tErr.Wrap("failed to send %s", msgType)
+	}
+	return nil
+}
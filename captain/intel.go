@@ -2,10 +2,12 @@ package captain
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"sync"
 
+	"github.com/safing/portbase/log"
 	"github.com/safing/portbase/updater"
 	"github.com/safing/portmaster/updates"
 	"github.com/safing/spn/conf"
@@ -19,6 +21,14 @@ var (
 	intelResourcePath       = "intel/spn/main-intel.yaml"
 	intelResourceMapName    = "main"
 	intelResourceUpdateLock sync.Mutex
+
+	// intelVerifier verifies intel updates and keeps track of the last
+	// accepted intel.
+	intelVerifier = hub.NewIntelVerifier(nil)
+
+	// unsignedIntelWarning makes sure the warning about using unsigned intel
+	// is only logged once.
+	unsignedIntelWarning sync.Once
 )
 
 func registerIntelUpdateHook() error {
@@ -34,6 +44,19 @@ func updateSPNIntel(ctx context.Context, _ interface{}) (err error) {
 	intelResourceUpdateLock.Lock()
 	defer intelResourceUpdateLock.Unlock()
 
+	// Private networks have their own intel.
+	if conf.PrivateNetwork() {
+		return updatePrivateNetworkIntel()
+	}
+
+	// Private networks used alongside the public network have their own intel
+	// in addition to the SPN intel.
+	if conf.PrivateMapName != "" {
+		if err := updatePrivateNetworkIntel(); err != nil {
+			log.Warningf("spn/captain: %s", err)
+		}
+	}
+
 	// Only update SPN intel when using the matching map.
 	if conf.MainMapName != intelResourceMapName {
 		return nil
@@ -45,25 +68,134 @@ func updateSPNIntel(ctx context.Context, _ interface{}) (err error) {
 	}
 
 	// Get intel file and load it from disk.
-	intelResource, err = updates.GetFile(intelResourcePath)
+	newIntelResource, err := updates.GetFile(intelResourcePath)
 	if err != nil {
 		return fmt.Errorf("failed to get SPN intel update: %w", err)
 	}
-	intelData, err := ioutil.ReadFile(intelResource.Path())
+	intelData, err := ioutil.ReadFile(newIntelResource.Path())
 	if err != nil {
 		return fmt.Errorf("failed to load SPN intel update: %w", err)
 	}
 
-	// Parse and apply intel data.
-	intel, err := hub.ParseIntel(intelData)
+	// Verify and parse intel data.
+	// On failure, the last known good intel stays in use.
+	intelVerifier.SetTrustRoots(getIntelTrustRoots())
+	var intel *hub.Intel
+	if useUnsignedIntel() {
+		intel, err = intelVerifier.ParseUnsigned(intelData)
+		if err != nil {
+			return fmt.Errorf("failed to parse SPN intel update: %w", err)
+		}
+		warnUnsignedIntel()
+	} else {
+		// Get detached signature and load it from disk.
+		sigResource, err := updates.GetFile(intelResourcePath + hub.IntelSignatureSuffix)
+		if err != nil {
+			return fmt.Errorf("failed to get SPN intel signature: %w", err)
+		}
+		sigData, err := ioutil.ReadFile(sigResource.Path())
+		if err != nil {
+			return fmt.Errorf("failed to load SPN intel signature: %w", err)
+		}
+
+		intel, err = intelVerifier.Verify(intelData, sigData)
+		if err != nil {
+			return fmt.Errorf("failed to verify SPN intel update: %w", err)
+		}
+	}
+
+	// Apply intel data.
+	setVirtualNetworkConfig(intel.VirtualNetworks)
+	if err := navigator.Main.UpdateIntel(intel); err != nil {
+		return err
+	}
+
+	// Only mark the intel as applied when successful, so that failed updates
+	// are retried.
+	intelResource = newIntelResource
+	return nil
+}
+
+// useUnsignedIntel returns whether unsigned intel may be used. Unsigned intel
+// must be explicitly allowed and is only used as long as no trust roots are
+// configured and signed intel was never accepted.
+func useUnsignedIntel() bool {
+	return cfgOptionAllowUnsignedIntel() &&
+		intelVerifier.AcceptsUnsigned()
+}
+
+func warnUnsignedIntel() {
+	unsignedIntelWarning.Do(func() {
+		log.Warningf("spn/captain: using unsigned SPN intel, as allowed by configuration")
+	})
+}
+
+// activeIntelVerifier returns the intel verifier of the network in use.
+func activeIntelVerifier() *hub.IntelVerifier {
+	if conf.PrivateNetwork() {
+		return privateNetworkIntelVerifier
+	}
+	return intelVerifier
+}
+
+// enableIntelPersistence makes the intel verifier remember the last accepted
+// intel across restarts.
+func enableIntelPersistence() error {
+	return activeIntelVerifier().EnablePersistence(
+		fmt.Sprintf("core:spn/intel/%s/last-accepted", conf.MainMapName),
+	)
+}
+
+// restoreSPNIntel applies the last accepted intel from the database. It is
+// used when the current intel cannot be loaded or verified, so that the last
+// known good intel is also used after a restart.
+func restoreSPNIntel() error {
+	intelResourceUpdateLock.Lock()
+	defer intelResourceUpdateLock.Unlock()
+
+	verifier := activeIntelVerifier()
+	data, signature, ok := verifier.LastAccepted()
+	if !ok {
+		return errors.New("no previously accepted intel available")
+	}
+
+	// Verify the intel again, as the trust roots might have changed.
+	var (
+		intel *hub.Intel
+		err   error
+	)
+	if len(signature) == 0 {
+		if conf.PrivateNetwork() || !useUnsignedIntel() {
+			return errors.New("previously accepted intel is unsigned, which is not allowed anymore")
+		}
+		intel, err = verifier.ParseUnsigned(data)
+		warnUnsignedIntel()
+	} else {
+		intel, err = verifier.Verify(data, signature)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to parse SPN intel update: %w", err)
+		return fmt.Errorf("failed to verify previously accepted intel: %w", err)
 	}
 
+	// Apply intel data.
 	setVirtualNetworkConfig(intel.VirtualNetworks)
 	return navigator.Main.UpdateIntel(intel)
 }
 
+func getIntelTrustRoots() []*hub.IntelTrustRoot {
+	configured := cfgOptionIntelTrustRoots()
+	trustRoots := make([]*hub.IntelTrustRoot, 0, len(configured))
+	for _, entry := range configured {
+		root, err := hub.ParseIntelTrustRoot(entry)
+		if err != nil {
+			log.Warningf("spn/captain: ignoring invalid intel trust root %q: %s", entry, err)
+			continue
+		}
+		trustRoots = append(trustRoots, root)
+	}
+	return trustRoots
+}
+
 func resetSPNIntel() {
 	intelResourceUpdateLock.Lock()
 	defer intelResourceUpdateLock.Unlock()
@@ -0,0 +1,382 @@
+package captain
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/safing/portbase/log"
+	"github.com/safing/portbase/modules"
+	"github.com/safing/spn/docks"
+)
+
+const (
+	// loadSampleInterval defines how often the Hub load is sampled.
+	loadSampleInterval = 1 * time.Minute
+
+	// loadAverageSamples defines over how many samples the Hub load is
+	// averaged. Together with the sample interval, this is 15 minutes.
+	loadAverageSamples = 15
+
+	// loadHysteresis defines by how many percent the averaged load must fall
+	// below a load step in order to return to the step below. This prevents
+	// flapping between steps, which would cause a status update every time.
+	loadHysteresis = 5
+
+	// loadUnknown is published when the load is not known.
+	loadUnknown = -1
+)
+
+// loadSteps defines the fixed steps the Hub load is published in.
+// They match the thresholds used for the Hub cost by the navigator.
+var loadSteps = []int{100, 95, 80, 0}
+
+// loadSampler samples the load of the Hub and calculates the load to publish.
+type loadSampler struct {
+	sync.Mutex
+
+	// samples holds the last load samples in percent.
+	samples []int
+	// published holds the last returned load step.
+	published int
+
+	// lastCPU holds the last read CPU counters.
+	lastCPU *cpuCounters
+	// lastNet holds the last read network interface counters.
+	lastNet *netCounters
+}
+
+type cpuCounters struct {
+	total uint64
+	idle  uint64
+}
+
+type netCounters struct {
+	rxBytes uint64
+	txBytes uint64
+	readAt  time.Time
+}
+
+var (
+	hubLoad = &loadSampler{
+		published: loadUnknown,
+	}
+
+	hubLoadSampleTask *modules.Task
+)
+
+// startLoadSampler starts sampling the load of the Hub.
+func startLoadSampler() {
+	hubLoadSampleTask = module.NewTask(
+		"sample hub load",
+		sampleHubLoad,
+	).Repeat(loadSampleInterval).Queue()
+}
+
+func sampleHubLoad(_ context.Context, _ *modules.Task) error {
+	load, err := hubLoad.sample()
+	if err != nil {
+		log.Debugf("spn/captain: failed to sample hub load: %s", err)
+		return nil
+	}
+	log.Tracef("spn/captain: sampled hub load of %d%%", load)
+	return nil
+}
+
+// sample takes a new sample of the load of the Hub. The load is the maximum
+// of the CPU, memory, network and terminal usage in percent.
+func (ls *loadSampler) sample() (load int, err error) {
+	ls.Lock()
+	defer ls.Unlock()
+
+	var available bool
+
+	// Get CPU usage.
+	cpu, err := readCPUCounters()
+	if err == nil {
+		if ls.lastCPU != nil {
+			load = maxInt(load, cpuUsage(ls.lastCPU, cpu))
+			available = true
+		}
+		ls.lastCPU = cpu
+	}
+
+	// Get memory usage.
+	memUsage, err := readMemoryUsage()
+	if err == nil {
+		load = maxInt(load, memUsage)
+		available = true
+	}
+
+	// Get network usage in relation to the configured link capacity.
+	if linkCapacity := cfgOptionLinkCapacity(); linkCapacity > 0 {
+		netCounters, err := readNetCounters(cfgOptionUplinkInterface())
+		if err == nil {
+			if ls.lastNet != nil {
+				load = maxInt(load, netUsage(ls.lastNet, netCounters, linkCapacity*1000000))
+				available = true
+			}
+			ls.lastNet = netCounters
+		}
+	}
+
+	// Get terminal usage in relation to the configured terminal capacity.
+	if terminalCapacity := cfgOptionTerminalCapacity(); terminalCapacity > 0 {
+		var terminals int
+		for _, crane := range docks.GetAllAssignedCranes() {
+			terminals += crane.TerminalCount()
+		}
+		load = maxInt(load, int(int64(terminals)*100/terminalCapacity))
+		available = true
+	}
+
+	if !available {
+		return 0, errors.New("no load metrics available")
+	}
+
+	// Add sample.
+	ls.samples = append(ls.samples, load)
+	if len(ls.samples) > loadAverageSamples {
+		ls.samples = ls.samples[len(ls.samples)-loadAverageSamples:]
+	}
+	return load, nil
+}
+
+// Load returns the averaged load of the Hub in fixed steps.
+// If there are no samples, the load is unknown.
+func (ls *loadSampler) Load() (load int, average int) {
+	ls.Lock()
+	defer ls.Unlock()
+
+	if len(ls.samples) == 0 {
+		return loadUnknown, loadUnknown
+	}
+
+	// Calculate average.
+	var sum int
+	for _, sample := range ls.samples {
+		sum += sample
+	}
+	average = sum / len(ls.samples)
+
+	ls.published = quantizeLoad(average, ls.published)
+	return ls.published, average
+}
+
+// quantizeLoad converts the given average load to a load step. In order to
+// step down from the previous step, the load must fall below a step by the
+// hysteresis margin. This applies to all steps up to the previous step, so
+// that stepping down multiple steps does not skip a step that the load is
+// still within the margin of.
+func quantizeLoad(average, previous int) int {
+	for _, step := range loadSteps {
+		if average >= step {
+			return step
+		}
+		// Stay on the step, if within the hysteresis margin.
+		if step <= previous && average >= step-loadHysteresis {
+			return step
+		}
+	}
+	return 0
+}
+
+func cpuUsage(last, current *cpuCounters) int {
+	total := current.total - last.total
+	idle := current.idle - last.idle
+	if current.total <= last.total || idle > total {
+		return 0
+	}
+	return int((total - idle) * 100 / total)
+}
+
+func netUsage(last, current *netCounters, linkCapacity int64) int {
+	duration := current.readAt.Sub(last.readAt).Seconds()
+	if duration <= 0 || current.rxBytes < last.rxBytes || current.txBytes < last.txBytes {
+		return 0
+	}
+
+	// Links are full duplex, so use the more utilized direction.
+	bytes := current.rxBytes - last.rxBytes
+	if tx := current.txBytes - last.txBytes; tx > bytes {
+		bytes = tx
+	}
+	bitsPerSecond := float64(bytes*8) / duration
+	return int(bitsPerSecond * 100 / float64(linkCapacity))
+}
+
+// readCPUCounters reads the aggregated CPU counters from /proc/stat.
+func readCPUCounters() (*cpuCounters, error) {
+	data, err := ioutil.ReadFile("/proc/stat")
+	if err != nil {
+		return nil, err
+	}
+	return parseCPUCounters(string(data))
+}
+
+func parseCPUCounters(data string) (*cpuCounters, error) {
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || fields[0] != "cpu" {
+			continue
+		}
+
+		// Fields: user nice system idle iowait irq softirq steal guest guest_nice
+		// Guest time is already included in user time.
+		counters := &cpuCounters{}
+		for i, field := range fields[1:] {
+			if i >= 8 {
+				break
+			}
+			value, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse cpu counter: %w", err)
+			}
+			counters.total += value
+			if i == 3 || i == 4 {
+				counters.idle += value
+			}
+		}
+		return counters, nil
+	}
+	return nil, errors.New("no cpu counters found")
+}
+
+// readMemoryUsage reads the memory usage in percent from /proc/meminfo.
+func readMemoryUsage() (int, error) {
+	data, err := ioutil.ReadFile("/proc/meminfo")
+	if err != nil {
+		return 0, err
+	}
+	return parseMemoryUsage(string(data))
+}
+
+func parseMemoryUsage(data string) (int, error) {
+	var total, available uint64
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		var target *uint64
+		switch fields[0] {
+		case "MemTotal:":
+			target = &total
+		case "MemAvailable:":
+			target = &available
+		default:
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse %s: %w", fields[0], err)
+		}
+		*target = value
+	}
+
+	if total == 0 || available > total {
+		return 0, errors.New("no memory info found")
+	}
+	return int((total - available) * 100 / total), nil
+}
+
+// readNetCounters reads the byte counters of the uplink network interface
+// from /proc/net/dev. If no interface is given, the interface of the default
+// route is used. Only a single interface is counted, as traffic that is
+// forwarded between interfaces would otherwise be counted multiple times.
+func readNetCounters(iface string) (*netCounters, error) {
+	if iface == "" {
+		data, err := ioutil.ReadFile("/proc/net/route")
+		if err != nil {
+			return nil, err
+		}
+		iface, err = parseDefaultRouteInterface(string(data))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := ioutil.ReadFile("/proc/net/dev")
+	if err != nil {
+		return nil, err
+	}
+	return parseNetCounters(string(data), iface, time.Now())
+}
+
+// parseDefaultRouteInterface returns the interface of the IPv4 default route
+// with the lowest metric from the format of /proc/net/route.
+func parseDefaultRouteInterface(data string) (string, error) {
+	var (
+		iface      string
+		bestMetric uint64
+	)
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		// Fields: Iface Destination Gateway Flags RefCnt Use Metric Mask ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 || fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+		metric, err := strconv.ParseUint(fields[6], 10, 64)
+		if err != nil {
+			continue
+		}
+		if iface == "" || metric < bestMetric {
+			iface = fields[0]
+			bestMetric = metric
+		}
+	}
+
+	if iface == "" {
+		return "", errors.New("no default route found")
+	}
+	return iface, nil
+}
+
+func parseNetCounters(data, iface string, readAt time.Time) (*netCounters, error) {
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		// Interface lines have the format "iface: rx-fields(8) tx-fields(8)".
+		splitted := strings.SplitN(scanner.Text(), ":", 2)
+		if len(splitted) != 2 || strings.TrimSpace(splitted[0]) != iface {
+			continue
+		}
+		fields := strings.Fields(splitted[1])
+		if len(fields) < 9 {
+			return nil, fmt.Errorf("invalid counters of %s", iface)
+		}
+
+		rx, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse rx bytes of %s: %w", iface, err)
+		}
+		tx, err := strconv.ParseUint(fields[8], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse tx bytes of %s: %w", iface, err)
+		}
+		return &netCounters{
+			rxBytes: rx,
+			txBytes: tx,
+			readAt:  readAt,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("network interface %s not found", iface)
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
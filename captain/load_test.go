@@ -0,0 +1,194 @@
+package captain
+
+import (
+	"testing"
+	"time"
+)
+
+const testProcStat = `cpu  4705 356 584 3699 23 0 23 0 0 0
+cpu0 1393 280 283 1893 12 0 12 0 0 0
+cpu1 3312 76 301 1806 11 0 11 0 0 0
+intr 114930548 113199788 3 0 5 263 0 4 [... lots more numbers ...]
+ctxt 1990473
+btime 1062191376
+processes 2915
+procs_running 1
+procs_blocked 0
+`
+
+const testProcMeminfo = `MemTotal:        8048832 kB
+MemFree:          412324 kB
+MemAvailable:    2012208 kB
+Buffers:          226536 kB
+Cached:          2118756 kB
+SwapTotal:       2097148 kB
+SwapFree:        2097148 kB
+`
+
+const testProcNetDev = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo: 1000000    1000    0    0    0     0          0         0  1000000    1000    0    0    0     0       0          0
+  eth0: 5000000    4000    0    0    0     0          0         0  3000000    2000    0    0    0     0       0          0
+docker0: 700000     500    0    0    0     0          0         0   900000     600    0    0    0     0       0          0
+`
+
+const testProcNetRoute = `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+wlan0	00000000	0102A8C0	0003	0	0	600	00000000	0	0	0
+eth0	00000000	0101A8C0	0003	0	0	100	00000000	0	0	0
+eth0	0001A8C0	00000000	0001	0	0	100	00FFFFFF	0	0	0
+docker0	000011AC	00000000	0001	0	0	0	0000FFFF	0	0	0
+`
+
+func TestParseCPUCounters(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		data  string
+		total uint64
+		idle  uint64
+		ok    bool
+	}{
+		{"proc stat", testProcStat, 4705 + 356 + 584 + 3699 + 23 + 0 + 23 + 0, 3699 + 23, true},
+		{"short kernel format", "cpu  100 0 50 800 50\n", 1000, 850, true},
+		{"guest time is ignored", "cpu  100 0 0 100 0 0 0 0 100 100\n", 200, 100, true},
+		{"missing aggregate", "cpu0 1 2 3 4 5\n", 0, 0, false},
+		{"invalid counter", "cpu  1 2 x 4 5\n", 0, 0, false},
+		{"empty", "", 0, 0, false},
+	}
+	for _, test := range tests {
+		counters, err := parseCPUCounters(test.data)
+		switch {
+		case !test.ok:
+			if err == nil {
+				t.Errorf("%s: expected error", test.name)
+			}
+		case err != nil:
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+		case counters.total != test.total || counters.idle != test.idle:
+			t.Errorf("%s: got total=%d idle=%d, expected total=%d idle=%d", test.name, counters.total, counters.idle, test.total, test.idle)
+		}
+	}
+}
+
+func TestParseMemoryUsage(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		data  string
+		usage int
+		ok    bool
+	}{
+		{"proc meminfo", testProcMeminfo, 75, true},
+		{"full", "MemTotal: 1000 kB\nMemAvailable: 0 kB\n", 100, true},
+		{"empty memory", "MemTotal: 1000 kB\nMemAvailable: 1000 kB\n", 0, true},
+		{"missing total", "MemAvailable: 1000 kB\n", 0, false},
+		{"available exceeds total", "MemTotal: 1000 kB\nMemAvailable: 2000 kB\n", 0, false},
+		{"invalid value", "MemTotal: x kB\nMemAvailable: 1000 kB\n", 0, false},
+	}
+	for _, test := range tests {
+		usage, err := parseMemoryUsage(test.data)
+		switch {
+		case !test.ok:
+			if err == nil {
+				t.Errorf("%s: expected error", test.name)
+			}
+		case err != nil:
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+		case usage != test.usage:
+			t.Errorf("%s: got usage of %d%%, expected %d%%", test.name, usage, test.usage)
+		}
+	}
+}
+
+func TestParseNetCounters(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	tests := []struct {
+		name  string
+		iface string
+		rx    uint64
+		tx    uint64
+		ok    bool
+	}{
+		{"uplink", "eth0", 5000000, 3000000, true},
+		{"bridge without leading space", "docker0", 700000, 900000, true},
+		{"unknown interface", "eth1", 0, 0, false},
+		{"header", "face", 0, 0, false},
+	}
+	for _, test := range tests {
+		counters, err := parseNetCounters(testProcNetDev, test.iface, now)
+		switch {
+		case !test.ok:
+			if err == nil {
+				t.Errorf("%s: expected error", test.name)
+			}
+		case err != nil:
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+		case counters.rxBytes != test.rx || counters.txBytes != test.tx || !counters.readAt.Equal(now):
+			t.Errorf("%s: got rx=%d tx=%d, expected rx=%d tx=%d", test.name, counters.rxBytes, counters.txBytes, test.rx, test.tx)
+		}
+	}
+}
+
+func TestParseDefaultRouteInterface(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		data  string
+		iface string
+		ok    bool
+	}{
+		{"lowest metric", testProcNetRoute, "eth0", true},
+		{"no default route", "Iface\tDestination\tGateway\tFlags\tRefCnt\tUse\tMetric\tMask\neth0\t0001A8C0\t00000000\t0001\t0\t0\t100\t00FFFFFF\n", "", false},
+		{"empty", "", "", false},
+	}
+	for _, test := range tests {
+		iface, err := parseDefaultRouteInterface(test.data)
+		switch {
+		case !test.ok:
+			if err == nil {
+				t.Errorf("%s: expected error", test.name)
+			}
+		case err != nil:
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+		case iface != test.iface:
+			t.Errorf("%s: got %s, expected %s", test.name, iface, test.iface)
+		}
+	}
+}
+
+func TestQuantizeLoad(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		average  int
+		previous int
+		expected int
+	}{
+		{"unknown to low", 10, loadUnknown, 0},
+		{"unknown to high", 85, loadUnknown, 80},
+		{"unknown to full", 100, loadUnknown, 100},
+		{"step up", 81, 0, 80},
+		{"step up twice", 96, 0, 95},
+		{"stay on step", 82, 80, 80},
+		{"stay within hysteresis", 76, 80, 80},
+		{"stay at hysteresis boundary", 75, 80, 80},
+		{"step down below hysteresis", 74, 80, 0},
+		{"stay below full", 96, 100, 100},
+		{"step down from full", 94, 100, 95},
+		{"step down from full to step within hysteresis", 90, 95, 95},
+		{"step down two steps", 85, 100, 80},
+		{"step down to zero", 50, 95, 0},
+	}
+	for _, test := range tests {
+		load := quantizeLoad(test.average, test.previous)
+		if load != test.expected {
+			t.Errorf("%s: quantizeLoad(%d, %d) = %d, expected %d", test.name, test.average, test.previous, load, test.expected)
+		}
+	}
+}
@@ -47,6 +47,11 @@ func init() {
 }
 
 func prep() error {
+	// Load private network definition, as it changes the main map.
+	if err := prepPrivateNetwork(); err != nil {
+		return err
+	}
+
 	// Check if we can parse the bootstrap hub flag.
 	if err := prepBootstrapHubFlag(); err != nil {
 		return err
@@ -62,6 +67,11 @@ func prep() error {
 		if err := api.SetAuthenticator(apiAuthenticator); err != nil {
 			return err
 		}
+
+		// Register API endpoints for operating the public Hub.
+		if err := registerAPIEndpoints(); err != nil {
+			return err
+		}
 	}
 
 	return prepConfig()
@@ -81,12 +91,27 @@ func start() error {
 	if err := registerIntelUpdateHook(); err != nil {
 		return err
 	}
+	if err := enableIntelPersistence(); err != nil {
+		log.Warningf("spn/captain: %s", err)
+	}
 	if err := updateSPNIntel(module.Ctx, nil); err != nil {
 		log.Errorf("spn/captain: failed to update SPN intel: %s", err)
+
+		// Fall back to the last known good intel.
+		if err := restoreSPNIntel(); err != nil {
+			log.Warningf("spn/captain: failed to restore SPN intel: %s", err)
+		} else {
+			log.Infof("spn/captain: using previously accepted SPN intel")
+		}
 	}
 
 	// identity and piers
 	if conf.PublicHub() {
+		// Log all seen announcements and statuses, including our own.
+		if err := enableTransparencyLog(); err != nil {
+			return err
+		}
+
 		// load identity
 		if err := loadPublicIdentity(); err != nil {
 			return err
@@ -94,6 +119,8 @@ func start() error {
 		if err := prepPublicIdentityMgmt(); err != nil {
 			return err
 		}
+		startLoadSampler()
+		startSpentTokensSharing()
 		if err := startPierMgmt(); err != nil {
 			return err
 		}
@@ -109,6 +136,9 @@ func start() error {
 	if err := processBootstrapHubFlag(); err != nil {
 		return err
 	}
+	if err := processPrivateNetworkBootstrapHubs(); err != nil {
+		return err
+	}
 	if err := processBootstrapFileFlag(); err != nil {
 		return err
 	}
@@ -125,6 +155,11 @@ func start() error {
 		module.StartServiceWorker("client manager", 0, clientManager)
 	}
 
+	// private network running alongside the public network
+	if conf.Client() && conf.PrivateMapName != "" {
+		module.StartServiceWorker("private home hub manager", 0, privateHomeHubManager)
+	}
+
 	return nil
 }
 
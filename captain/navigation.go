@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/safing/portbase/log"
@@ -20,6 +21,10 @@ import (
 
 const stopCraneAfterBeingUnsuggestedFor = 6 * time.Hour
 
+// homeHubConnectLock serializes connecting to home Hubs of the main and the
+// private map.
+var homeHubConnectLock sync.Mutex
+
 func homeHubManager(ctx context.Context) (err error) {
 	defer ready.UnSet()
 	defer netenv.ConnectedToSPN.UnSet()
@@ -46,7 +51,7 @@ managing:
 			}
 
 			resetSPNStatus(StatusConnecting)
-			err = establishHomeHub(ctx)
+			err = establishHomeHub(ctx, navigator.Main, bootstrapWithUpdates)
 			if err != nil {
 				log.Warningf("failed to establish connection to home hub: %s", err)
 				notifications.NotifyWarn(
@@ -117,7 +122,9 @@ managing:
 	return nil
 }
 
-func establishHomeHub(ctx context.Context) error {
+// establishHomeHub connects to a new home Hub of the given map. If the map is
+// empty, it is bootstrapped with the given function first.
+func establishHomeHub(ctx context.Context, m *navigator.Map, bootstrap func() error) error {
 	// Get own IP.
 	locations, ok := netenv.GetInternetLocation()
 	if !ok {
@@ -131,7 +138,7 @@ func establishHomeHub(ctx context.Context) error {
 
 	// Find nearby hubs.
 findCandidates:
-	candidates, err := navigator.Main.FindNearestHubs(
+	candidates, err := m.FindNearestHubs(
 		locations.BestV4().LocationOrNil(),
 		locations.BestV6().LocationOrNil(),
 		nil, navigator.HomeHub, 10,
@@ -139,7 +146,7 @@ findCandidates:
 	if err != nil {
 		if errors.Is(err, navigator.ErrEmptyMap) {
 			// bootstrap to the network!
-			err := bootstrapWithUpdates()
+			err := bootstrap()
 			if err != nil {
 				return err
 			}
@@ -153,13 +160,15 @@ findCandidates:
 	var tries int
 	var candidate *hub.Hub
 	for tries, candidate = range candidates {
-		err = connectToHomeHub(ctx, candidate)
+		err = connectToHomeHub(ctx, m, candidate)
 		if err != nil {
 			if errors.Is(err, terminal.ErrStopping) {
 				return err
 			}
+			m.ReportEstablishmentFailure(candidate.ID)
 			log.Debugf("spn/captain: failed to connect to %s as new home: %s", candidate, err)
 		} else {
+			m.ReportSessionSuccess(candidate.ID)
 			log.Infof("spn/captain: established connection to %s as new home with %d failed tries", candidate, tries)
 			return nil
 		}
@@ -170,7 +179,11 @@ findCandidates:
 	return fmt.Errorf("no home hub candidates available")
 }
 
-func connectToHomeHub(ctx context.Context, dst *hub.Hub) error {
+func connectToHomeHub(ctx context.Context, m *navigator.Map, dst *hub.Hub) error {
+	// Connect to one home Hub at a time, as the exceptions are shared.
+	homeHubConnectLock.Lock()
+	defer homeHubConnectLock.Unlock()
+
 	// Set and clean up exceptions.
 	setExceptions(dst.Info.IPv4, dst.Info.IPv6)
 	defer setExceptions(nil, nil)
@@ -189,6 +202,9 @@ func connectToHomeHub(ctx context.Context, dst *hub.Hub) error {
 		}
 	}()
 
+	// Remember the current tree head of the transparency log of the Hub.
+	previousTreeHead := dst.GetTreeHead()
+
 	// Query all gossip msgs on first connection.
 	gossipQuery, tErr := NewGossipQueryOp(crane.Controller)
 	if tErr != nil {
@@ -200,6 +216,13 @@ func connectToHomeHub(ctx context.Context, dst *hub.Hub) error {
 	case <-ctx.Done():
 	}
 
+	// Check that the Hub does not show us a different announcement than it
+	// logged and that its log is consistent with what we knew before.
+	err = verifyHubTransparency(crane.Controller, dst, previousTreeHead)
+	if err != nil {
+		return fmt.Errorf("failed to verify transparency log: %w", err)
+	}
+
 	// Create communication terminal.
 	homeTerminal, initData, tErr := docks.NewLocalCraneTerminal(crane, nil, &terminal.TerminalOpts{}, nil)
 	if tErr != nil {
@@ -227,7 +250,7 @@ func connectToHomeHub(ctx context.Context, dst *hub.Hub) error {
 	}
 
 	// Set new home on map.
-	ok := navigator.Main.SetHome(dst.ID, homeTerminal)
+	ok := m.SetHome(dst.ID, homeTerminal)
 	if !ok {
 		return fmt.Errorf("failed to set home hub on map")
 	}
@@ -237,7 +260,8 @@ func connectToHomeHub(ctx context.Context, dst *hub.Hub) error {
 }
 
 func optimizeNetwork(ctx context.Context, task *modules.Task) error {
-	if publicIdentity == nil {
+	// Retired Hubs do not create new lanes.
+	if publicIdentity == nil || publicIdentity.Hub.Retired() {
 		return nil
 	}
 
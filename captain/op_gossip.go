@@ -1,6 +1,7 @@
 package captain
 
 import (
+	"errors"
 	"time"
 
 	"github.com/safing/portbase/container"
@@ -10,6 +11,7 @@ import (
 	"github.com/safing/spn/docks"
 	"github.com/safing/spn/hub"
 	"github.com/safing/spn/terminal"
+	"github.com/tevino/abool"
 )
 
 const GossipOpType string = "gossip"
@@ -19,6 +21,24 @@ type GossipMsgType uint8
 const (
 	GossipHubAnnouncementMsg GossipMsgType = 1
 	GossipHubStatusMsg       GossipMsgType = 2
+	GossipHubRetirementMsg   GossipMsgType = 3
+	GossipHubSuccessionMsg   GossipMsgType = 4
+	GossipHubStatusDeltaMsg  GossipMsgType = 5
+	GossipTreeHeadMsg        GossipMsgType = 6
+
+	// gossipFeaturesMsg tells the neighbor which optional gossip features are
+	// supported. It is only sent to neighbors that announced their features
+	// themselves, as older Hubs do not know it.
+	gossipFeaturesMsg GossipMsgType = 18
+)
+
+// Optional gossip features are announced as flags when starting the gossip op.
+const (
+	// gossipFeatureStatusDeltas signifies support for GossipHubStatusDeltaMsg.
+	gossipFeatureStatusDeltas uint64 = 1 << iota
+
+	// localGossipFeatures holds the supported gossip features.
+	localGossipFeatures = gossipFeatureStatusDeltas
 )
 
 func (msgType GossipMsgType) String() string {
@@ -27,6 +47,20 @@ func (msgType GossipMsgType) String() string {
 		return "hub announcement"
 	case GossipHubStatusMsg:
 		return "hub status"
+	case GossipHubRetirementMsg:
+		return "hub retirement"
+	case GossipHubSuccessionMsg:
+		return "hub succession"
+	case GossipHubStatusDeltaMsg:
+		return "hub status delta"
+	case GossipTreeHeadMsg:
+		return "transparency log tree head"
+	case gossipSyncEntriesMsg:
+		return "gossip sync entries"
+	case gossipSyncWantMsg:
+		return "gossip sync want"
+	case gossipFeaturesMsg:
+		return "gossip features"
 	default:
 		return "unknown gossip msg"
 	}
@@ -36,6 +70,11 @@ type GossipOp struct {
 	terminal.OpBase
 
 	controller *docks.CraneControllerTerminal
+	// mapName is the map of the network the neighbor belongs to.
+	mapName string
+
+	// statusDeltas is set when the neighbor supports status deltas.
+	statusDeltas *abool.AtomicBool
 }
 
 func (op *GossipOp) Type() string {
@@ -53,10 +92,13 @@ func init() {
 func NewGossipOp(controller *docks.CraneControllerTerminal) (*GossipOp, *terminal.Error) {
 	// Create and init.
 	op := &GossipOp{
-		controller: controller,
+		controller:   controller,
+		mapName:      gossipMapName(controller.Crane),
+		statusDeltas: abool.New(),
 	}
 	op.OpBase.Init()
-	err := controller.OpInit(op, nil)
+	// Announce our features. Older Hubs ignore them.
+	err := controller.OpInit(op, container.New(varint.Pack64(localGossipFeatures)))
 	if err != nil {
 		return nil, err
 	}
@@ -73,16 +115,39 @@ func runGossipOp(t terminal.OpTerminal, opID uint32, data *container.Container)
 		return nil, terminal.ErrIncorrectUsage.With("gossip op may only be started by a crane controller terminal, but was started by %T", t)
 	}
 
-	// Create, init, register and return.
+	// Create and init.
 	op := &GossipOp{
-		controller: controller,
+		controller:   controller,
+		mapName:      gossipMapName(controller.Crane),
+		statusDeltas: abool.New(),
 	}
 	op.OpBase.Init()
 	op.OpBase.SetID(opID)
+
+	// Check the features of the neighbor.
+	// Older Hubs do not announce any.
+	announcedFeatures := data != nil && data.HoldsData()
+	if announcedFeatures {
+		features, err := data.GetNextN64()
+		if err != nil {
+			return nil, terminal.ErrMalformedData.With("failed to parse gossip features: %w", err)
+		}
+		op.setFeatures(features)
+	}
+
+	// Register and return.
 	registerGossipOp(controller.Crane.ID, op)
+	if announcedFeatures {
+		op.sendMsg(gossipFeaturesMsg, varint.Pack64(localGossipFeatures))
+	}
 	return op, nil
 }
 
+// setFeatures sets the features supported by the neighbor.
+func (op *GossipOp) setFeatures(features uint64) {
+	op.statusDeltas.SetTo(features&gossipFeatureStatusDeltas != 0)
+}
+
 func (op *GossipOp) sendMsg(msgType GossipMsgType, data []byte) {
 	c := container.New(
 		varint.Pack8(uint8(msgType)),
@@ -103,24 +168,52 @@ func (op *GossipOp) Deliver(c *container.Container) *terminal.Error {
 
 	// Prepare data.
 	data := c.CompileData()
-	var announcementData, statusData []byte
-	switch gossipMsgType {
-	case GossipHubAnnouncementMsg:
-		announcementData = data
-	case GossipHubStatusMsg:
-		statusData = data
-	default:
-		log.Warningf("spn/captain: received unknown gossip message type from %s: %d", op.controller.Crane.ID, gossipMsgType)
+
+	// Handle the features of the neighbor.
+	if gossipMsgType == gossipFeaturesMsg {
+		features, _, err := varint.Unpack64(data)
+		if err != nil {
+			return terminal.ErrMalformedData.With("failed to parse gossip features: %w", err)
+		}
+		op.setFeatures(features)
+		return nil
+	}
+
+	// Check if the neighbor is within its relay budget and not muted.
+	neighborID := gossipNeighborID(op.controller.Crane)
+	if !acceptFromGossipNeighbor(neighborID) {
+		log.Debugf("spn/captain: dropping %s from %s: relay budget exceeded or muted", gossipMsgType, op.controller.Crane.ID)
+		return nil
+	}
+
+	// Drop msgs that we have already received via another neighbor.
+	if gossipMsgSeen(gossipMsgType, data) {
+		return nil
+	}
+
+	// Check if the origin publishes too often.
+	origin := gossipMsgOrigin(data)
+	if !checkGossipOriginLimit(origin, gossipMsgType) {
+		log.Debugf("spn/captain: dropping %s of %s from %s: origin rate limit exceeded", gossipMsgType, origin, op.controller.Crane.ID)
+		penalizeGossipNeighbor(neighborID, gossipPenaltyRateLimit)
 		return nil
 	}
 
 	// Import and verify.
-	h, forward, tErr := docks.ImportAndVerifyHubInfo(module.Ctx, "", announcementData, statusData, conf.MainMapName, conf.MainMapScope)
+	h, forward, tErr := importGossipMsg(gossipMsgType, data, op.mapName)
+
+	// Only remember msgs that were imported or are old, so that a msg that
+	// failed to import may still be accepted from another neighbor.
+	if tErr == nil || errors.Is(tErr, hub.ErrOldData) {
+		markGossipMsgSeen(gossipMsgType, data)
+	}
+
 	if tErr != nil {
-		if tErr.Is(hub.ErrOldData) {
-			log.Debugf("spn/captain: ignoring old %s from %s", gossipMsgType, op.controller.Crane.ID)
-		} else {
-			log.Warningf("spn/captain: failed to import %s from %s: %s", gossipMsgType, op.controller.Crane.ID, tErr)
+		handleGossipImportError(neighborID, gossipMsgType, tErr)
+
+		// Catch up with the neighbor, if we cannot apply a status delta.
+		if errors.Is(tErr, hub.ErrMissingStatusBase) && h != nil {
+			requestMissingStatusBase(op.controller, h.ID, op.mapName)
 		}
 	} else if forward {
 		// Only log if we received something to save/forward.
@@ -129,11 +222,58 @@ func (op *GossipOp) Deliver(c *container.Container) *terminal.Error {
 
 	// Relay data.
 	if forward {
+		consumeGossipOriginLimit(origin, gossipMsgType)
 		gossipRelayMsg(op.controller.Crane.ID, gossipMsgType, data)
 	}
 	return nil
 }
 
+// handleGossipImportError logs the import error of a gossip msg and penalizes
+// the neighbor it was received from, if the msg was invalid. Old data and
+// duplicates are expected, as msgs travel via multiple paths, unknown msg types
+// may be sent by newer Hubs and other errors may be caused by the local state,
+// so they are not penalized.
+func handleGossipImportError(neighborID string, gossipMsgType GossipMsgType, tErr *terminal.Error) {
+	switch {
+	case errors.Is(tErr, hub.ErrOldData):
+		log.Debugf("spn/captain: ignoring old %s from %s", gossipMsgType, neighborID)
+	case errors.Is(tErr, terminal.ErrUnexpectedMsgType):
+		log.Warningf("spn/captain: received unknown gossip message type from %s: %d", neighborID, gossipMsgType)
+	case errors.Is(tErr, hub.ErrMissingStatusBase):
+		log.Debugf("spn/captain: cannot apply %s from %s: %s", gossipMsgType, neighborID, tErr)
+	case errors.Is(tErr, hub.ErrEquivocation):
+		// The neighbor only relayed the proof of another Hub's misbehavior.
+		log.Warningf("spn/captain: received %s from %s: %s", gossipMsgType, neighborID, tErr)
+	case errors.Is(tErr, hub.ErrInvalidHubMsg), errors.Is(tErr, terminal.ErrMalformedData):
+		log.Warningf("spn/captain: received invalid %s from %s: %s", gossipMsgType, neighborID, tErr)
+		penalizeGossipNeighbor(neighborID, gossipPenaltyInvalid)
+	default:
+		log.Warningf("spn/captain: failed to import %s from %s: %s", gossipMsgType, neighborID, tErr)
+	}
+}
+
+// importGossipMsg imports and verifies the given gossip message into the given
+// map and returns whether it should be forwarded.
+func importGossipMsg(gossipMsgType GossipMsgType, data []byte, mapName string) (h *hub.Hub, forward bool, tErr *terminal.Error) {
+	scope := conf.MapScope(mapName)
+	switch gossipMsgType {
+	case GossipHubAnnouncementMsg:
+		return docks.ImportAndVerifyHubInfo(module.Ctx, "", data, nil, mapName, scope)
+	case GossipHubStatusMsg:
+		return docks.ImportAndVerifyHubInfo(module.Ctx, "", nil, data, mapName, scope)
+	case GossipHubRetirementMsg:
+		return docks.ImportHubRetirement(data, mapName)
+	case GossipHubSuccessionMsg:
+		return docks.ImportHubSuccession(data, mapName)
+	case GossipHubStatusDeltaMsg:
+		return docks.ImportHubStatusDelta(data, mapName, scope)
+	case GossipTreeHeadMsg:
+		return docks.ImportTreeHead(data, mapName)
+	default:
+		return nil, false, terminal.ErrUnexpectedMsgType.With("unknown gossip message type %d", gossipMsgType)
+	}
+}
+
 func (op *GossipOp) End(err *terminal.Error) {
 	deleteGossipOp(op.controller.Crane.ID)
 }
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/safing/portbase/container"
+	"github.com/safing/portbase/formats/dsd"
 	"github.com/safing/portbase/formats/varint"
 	"github.com/safing/portbase/log"
 	"github.com/safing/spn/conf"
@@ -22,6 +23,15 @@ type GossipQueryOp struct {
 	t         terminal.OpTerminal
 	client    bool
 	importCnt int
+	// mapName is the map of the network the neighbor belongs to.
+	mapName string
+
+	// syncSet holds the sync entries of the stored msgs, if syncing.
+	syncSet *hub.SyncSet
+	// syncSize holds the bucket count of the client's sync summary.
+	syncSize int
+	// syncWant receives the msgs the client wants from the server.
+	syncWant chan []*hub.SyncEntry
 
 	ctx       context.Context
 	cancelCtx context.CancelFunc
@@ -42,26 +52,64 @@ func init() {
 func NewGossipQueryOp(t terminal.OpTerminal) (*GossipQueryOp, *terminal.Error) {
 	// Create and init.
 	op := &GossipQueryOp{
-		t:      t,
-		client: true,
+		t:       t,
+		client:  true,
+		mapName: gossipQueryMapName(t),
 	}
 	op.ctx, op.cancelCtx = context.WithCancel(context.Background())
 	op.OpBase.Init()
-	err := t.OpInit(op, nil)
+
+	// Send a summary of our msgs in order to only receive what we lack.
+	// Fall back to querying all msgs if the summary cannot be created.
+	var initData *container.Container
+	syncSet, err := hub.LoadSyncSet(op.mapName)
 	if err != nil {
-		return nil, err
+		log.Warningf("spn/captain: failed to load gossip sync set, querying all msgs: %s", err)
+	} else {
+		summary := syncSet.Summary()
+		summaryData, err := dsd.Dump(summary, dsd.CBOR)
+		if err != nil {
+			return nil, terminal.ErrInternalError.With("failed to pack sync summary: %w", err)
+		}
+		op.syncSet = syncSet
+		op.syncSize = len(summary.Buckets)
+		initData = container.New(summaryData)
+	}
+
+	tErr := t.OpInit(op, initData)
+	if tErr != nil {
+		return nil, tErr
 	}
 	return op, nil
 }
 
 func runGossipQueryOp(t terminal.OpTerminal, opID uint32, data *container.Container) (terminal.Operation, *terminal.Error) {
 	// Create, init, register and return.
-	op := &GossipQueryOp{t: t}
+	op := &GossipQueryOp{
+		t:       t,
+		mapName: gossipQueryMapName(t),
+	}
 	op.ctx, op.cancelCtx = context.WithCancel(context.Background())
 	op.OpBase.Init()
 	op.OpBase.SetID(opID)
 
-	module.StartWorker("gossip query handler", op.handler)
+	// Clients that do not send a summary receive all msgs.
+	if data == nil || !data.HoldsData() {
+		module.StartWorker("gossip query handler", op.handler)
+		return op, nil
+	}
+
+	// Parse the summary of the client.
+	summary := &hub.SyncSummary{}
+	_, err := dsd.Load(data.CompileData(), summary)
+	if err != nil {
+		return nil, terminal.ErrMalformedData.With("failed to parse sync summary: %w", err)
+	}
+	op.syncWant = make(chan []*hub.SyncEntry, 1)
+	module.StartWorker("gossip sync handler", func(_ context.Context) error {
+		op.syncHandler(summary)
+		return nil // Clean worker exit.
+	})
 
 	return op, nil
 }
@@ -79,12 +127,30 @@ func (op *GossipQueryOp) handler(_ context.Context) error {
 		return nil // Clean worker exit.
 	}
 
+	tErr = op.sendMsgs(hub.MsgTypeRetirement)
+	if tErr != nil {
+		op.t.OpEnd(op, tErr)
+		return nil // Clean worker exit.
+	}
+
+	tErr = op.sendMsgs(hub.MsgTypeSuccession)
+	if tErr != nil {
+		op.t.OpEnd(op, tErr)
+		return nil // Clean worker exit.
+	}
+
+	tErr = op.sendMsgs(hub.MsgTypeTreeHead)
+	if tErr != nil {
+		op.t.OpEnd(op, tErr)
+		return nil // Clean worker exit.
+	}
+
 	op.t.OpEnd(op, nil)
 	return nil // Clean worker exit.
 }
 
 func (op *GossipQueryOp) sendMsgs(msgType hub.MsgType) *terminal.Error {
-	it, err := hub.QueryRawGossipMsgs(conf.MainMapName, msgType)
+	it, err := hub.QueryRawGossipMsgs(op.mapName, msgType)
 	if err != nil {
 		return terminal.ErrInternalError.With("failed to query: %w", err)
 	}
@@ -106,29 +172,10 @@ iterating:
 				continue iterating
 			}
 
-			// Create gossip msg.
-			var c *container.Container
-			switch hubMsg.Type {
-			case hub.MsgTypeAnnouncement:
-				c = container.New(
-					varint.Pack8(uint8(GossipHubAnnouncementMsg)),
-					hubMsg.Data,
-				)
-			case hub.MsgTypeStatus:
-				c = container.New(
-					varint.Pack8(uint8(GossipHubStatusMsg)),
-					hubMsg.Data,
-				)
-			default:
-				log.Warningf("spn/captain: unknown hub msg for gossip query at %q: %s", hubMsg.Key(), hubMsg.Type)
-			}
-
 			// Send msg.
-			if c != nil {
-				tErr := op.t.OpSendWithTimeout(op, c, 100*time.Millisecond)
-				if tErr != nil {
-					return tErr.Wrap("failed to send msg")
-				}
+			tErr := op.sendMsg(hubMsg)
+			if tErr != nil {
+				return tErr
 			}
 
 		case <-op.ctx.Done():
@@ -137,6 +184,68 @@ iterating:
 	}
 }
 
+// sendMsg sends the given hub msg as a gossip msg.
+func (op *GossipQueryOp) sendMsg(hubMsg *hub.HubMsg) *terminal.Error {
+	// Create gossip msg.
+	var c *container.Container
+	switch hubMsg.Type {
+	case hub.MsgTypeAnnouncement:
+		c = container.New(
+			varint.Pack8(uint8(GossipHubAnnouncementMsg)),
+			hubMsg.Data,
+		)
+	case hub.MsgTypeStatus:
+		c = container.New(
+			varint.Pack8(uint8(GossipHubStatusMsg)),
+			hubMsg.Data,
+		)
+	case hub.MsgTypeRetirement:
+		c = container.New(
+			varint.Pack8(uint8(GossipHubRetirementMsg)),
+			hubMsg.Data,
+		)
+	case hub.MsgTypeSuccession:
+		c = container.New(
+			varint.Pack8(uint8(GossipHubSuccessionMsg)),
+			hubMsg.Data,
+		)
+	case hub.MsgTypeTreeHead:
+		c = container.New(
+			varint.Pack8(uint8(GossipTreeHeadMsg)),
+			hubMsg.Data,
+		)
+	default:
+		log.Warningf("spn/captain: unknown hub msg for gossip query at %q: %s", hubMsg.Key(), hubMsg.Type)
+		return nil
+	}
+
+	// Send msg.
+	tErr := op.t.OpSendWithTimeout(op, c, 100*time.Millisecond)
+	if tErr != nil {
+		return tErr.Wrap("failed to send msg")
+	}
+
+	// Send the status deltas applied to a status, if the neighbor supports
+	// them. Otherwise, the neighbor needs to wait for the next full status.
+	if hubMsg.Type == hub.MsgTypeStatus && gossipPeerSupportsStatusDeltas(op.craneID()) {
+		deltas, err := hub.GetHubStatusDeltas(op.mapName, hubMsg.ID)
+		if err != nil {
+			log.Warningf("spn/captain: failed to get status deltas of %s for gossip query: %s", hubMsg.ID, err)
+			return nil
+		}
+		for _, deltaData := range deltas {
+			tErr := op.t.OpSendWithTimeout(op, container.New(
+				varint.Pack8(uint8(GossipHubStatusDeltaMsg)),
+				deltaData,
+			), 100*time.Millisecond)
+			if tErr != nil {
+				return tErr.Wrap("failed to send msg")
+			}
+		}
+	}
+	return nil
+}
+
 func (op *GossipQueryOp) Deliver(c *container.Container) *terminal.Error {
 	gossipMsgTypeN, err := c.GetNextN8()
 	if err != nil {
@@ -146,19 +255,17 @@ func (op *GossipQueryOp) Deliver(c *container.Container) *terminal.Error {
 
 	// Prepare data.
 	data := c.CompileData()
-	var announcementData, statusData []byte
+
+	// Handle sync msgs.
 	switch gossipMsgType {
-	case GossipHubAnnouncementMsg:
-		announcementData = data
-	case GossipHubStatusMsg:
-		statusData = data
-	default:
-		log.Warningf("spn/captain: received unknown gossip message type from gossip query: %d", gossipMsgType)
-		return nil
+	case gossipSyncEntriesMsg:
+		return op.deliverSyncEntries(data)
+	case gossipSyncWantMsg:
+		return op.deliverSyncWant(data)
 	}
 
 	// Import and verify.
-	h, forward, tErr := docks.ImportAndVerifyHubInfo(module.Ctx, "", announcementData, statusData, conf.MainMapName, conf.MainMapScope)
+	h, forward, tErr := importGossipMsg(gossipMsgType, data, op.mapName)
 	if tErr != nil {
 		log.Warningf("spn/captain: failed to import %s from gossip query: %s", gossipMsgType, tErr)
 	} else {
@@ -168,13 +275,26 @@ func (op *GossipQueryOp) Deliver(c *container.Container) *terminal.Error {
 
 	// Relay data.
 	if forward {
-		// FIXME: Find better way to get craneID.
-		craneID := strings.SplitN(op.t.FmtID(), "#", 2)[0]
-		gossipRelayMsg(craneID, gossipMsgType, data)
+		gossipRelayMsg(op.craneID(), gossipMsgType, data)
 	}
 	return nil
 }
 
+// gossipQueryMapName returns the map of the network that the Hub at the other
+// end of the given terminal belongs to.
+func gossipQueryMapName(t terminal.OpTerminal) string {
+	if controller, ok := t.(*docks.CraneControllerTerminal); ok {
+		return gossipMapName(controller.Crane)
+	}
+	return conf.MainMapName
+}
+
+// craneID returns the ID of the crane the op runs on.
+func (op *GossipQueryOp) craneID() string {
+	// FIXME: Find better way to get craneID.
+	return strings.SplitN(op.t.FmtID(), "#", 2)[0]
+}
+
 func (op *GossipQueryOp) End(err *terminal.Error) {
 	if op.client {
 		log.Infof("spn/captain: gossip query imported %d entries", op.importCnt)
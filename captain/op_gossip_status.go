@@ -0,0 +1,241 @@
+package captain
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/safing/portbase/container"
+	"github.com/safing/portbase/database"
+	"github.com/safing/portbase/formats/dsd"
+	"github.com/safing/portbase/log"
+	"github.com/safing/spn/conf"
+	"github.com/safing/spn/docks"
+	"github.com/safing/spn/hub"
+	"github.com/safing/spn/terminal"
+)
+
+const (
+	GossipStatusOpType string = "gossip/status"
+
+	// gossipStatusTimeout defines how long the client waits for the status.
+	gossipStatusTimeout = 10 * time.Second
+
+	// gossipStatusRequestInterval defines how often the status of a Hub may be
+	// requested because of a missing status delta base.
+	gossipStatusRequestInterval = 5 * time.Minute
+)
+
+var (
+	gossipStatusRequests     = make(map[string]time.Time)
+	gossipStatusRequestsLock sync.Mutex
+)
+
+// GossipStatusOp requests the stored full status and the status deltas
+// applied to it of a Hub from a neighbor. It is used to catch up when a status
+// delta cannot be applied, because its base is missing.
+type GossipStatusOp struct {
+	terminal.OpBase
+
+	t terminal.OpTerminal
+	// msgs holds the msgs to send by the server.
+	msgs *HubStatusMsgs
+
+	// result is used by the client to receive the msgs.
+	result chan *HubStatusMsgs
+	// ended is used by the client to receive the end error.
+	ended chan *terminal.Error
+}
+
+// HubStatusMsgs holds the raw status msgs of a Hub.
+type HubStatusMsgs struct {
+	// Status is the stored full status.
+	Status []byte `json:",omitempty"`
+
+	// Deltas are the stored status deltas that were applied to the status.
+	Deltas [][]byte `json:",omitempty"`
+}
+
+func (op *GossipStatusOp) Type() string {
+	return GossipStatusOpType
+}
+
+func init() {
+	terminal.RegisterOpType(terminal.OpParams{
+		Type:     GossipStatusOpType,
+		Requires: terminal.IsCraneController,
+		RunOp:    runGossipStatusOp,
+	})
+}
+
+// requestMissingStatusBase requests the status of the given Hub from the
+// neighbor at the other end of the given controller, if it was not requested
+// recently.
+func requestMissingStatusBase(controller *docks.CraneControllerTerminal, hubID, mapName string) {
+	now := time.Now()
+
+	gossipStatusRequestsLock.Lock()
+	for id, requested := range gossipStatusRequests {
+		if now.Sub(requested) > gossipStatusRequestInterval {
+			delete(gossipStatusRequests, id)
+		}
+	}
+	if _, ok := gossipStatusRequests[hubID]; ok {
+		gossipStatusRequestsLock.Unlock()
+		return
+	}
+	gossipStatusRequests[hubID] = now
+	gossipStatusRequestsLock.Unlock()
+
+	module.StartWorker("request missing status base", func(_ context.Context) error {
+		imported, tErr := RequestHubStatus(controller, hubID, mapName)
+		if tErr != nil {
+			log.Debugf("spn/captain: failed to request status of %s from %s: %s", hubID, controller.Crane.ID, tErr)
+			return nil
+		}
+		log.Debugf("spn/captain: imported %d status msgs of %s from %s", imported, hubID, controller.Crane.ID)
+		return nil
+	})
+}
+
+// RequestHubStatus requests the stored status msgs of the given Hub from the
+// Hub at the other end of the given terminal and imports them into the given
+// map. It returns the amount of imported msgs.
+func RequestHubStatus(t terminal.OpTerminal, hubID, mapName string) (imported int, tErr *terminal.Error) {
+	// Create new op.
+	op := &GossipStatusOp{
+		t:      t,
+		result: make(chan *HubStatusMsgs, 1),
+		ended:  make(chan *terminal.Error, 1),
+	}
+	op.OpBase.Init()
+
+	// Initialize.
+	tErr = t.OpInit(op, container.New([]byte(hubID)))
+	if tErr != nil {
+		return 0, tErr
+	}
+	t.Flush()
+
+	// Wait for result.
+	var msgs *HubStatusMsgs
+	select {
+	case msgs = <-op.result:
+	case tErr := <-op.ended:
+		// The result is delivered before the op ends, so check again.
+		select {
+		case msgs = <-op.result:
+		default:
+			if tErr.IsOK() {
+				return 0, terminal.ErrIncorrectUsage.With("gossip status op ended without result")
+			}
+			return 0, tErr
+		}
+	case <-time.After(gossipStatusTimeout):
+		t.OpEnd(op, terminal.ErrTimeout.With("timed out waiting for status"))
+		return 0, terminal.ErrTimeout.With("timed out waiting for status")
+	}
+
+	// Import the full status and then the deltas in order.
+	// Msgs that are older than what we have are skipped.
+	if len(msgs.Status) > 0 {
+		_, _, tErr := docks.ImportAndVerifyHubInfo(module.Ctx, hubID, nil, msgs.Status, mapName, conf.MapScope(mapName))
+		switch {
+		case tErr == nil:
+			imported++
+		case !errors.Is(tErr, hub.ErrOldData):
+			return imported, tErr.Wrap("failed to import status")
+		}
+	}
+	for _, deltaData := range msgs.Deltas {
+		_, _, tErr := docks.ImportHubStatusDelta(deltaData, mapName, conf.MapScope(mapName))
+		switch {
+		case tErr == nil:
+			imported++
+		case !errors.Is(tErr, hub.ErrOldData):
+			return imported, tErr.Wrap("failed to import status delta")
+		}
+	}
+
+	return imported, nil
+}
+
+func runGossipStatusOp(t terminal.OpTerminal, opID uint32, data *container.Container) (terminal.Operation, *terminal.Error) {
+	hubID := string(data.CompileData())
+	if hubID == "" {
+		return nil, terminal.ErrMalformedData.With("missing hub ID")
+	}
+
+	// Get stored status msgs.
+	msgs := &HubStatusMsgs{}
+	statusMsg, err := hub.GetHubMsg(conf.MainMapName, hub.MsgTypeStatus, hubID)
+	switch {
+	case err == nil:
+		msgs.Status = statusMsg.Data
+	case !errors.Is(err, database.ErrNotFound):
+		return nil, terminal.ErrInternalError.With("failed to get status: %w", err)
+	}
+	msgs.Deltas, err = hub.GetHubStatusDeltas(conf.MainMapName, hubID)
+	if err != nil {
+		return nil, terminal.ErrInternalError.With("failed to get status deltas: %w", err)
+	}
+
+	// Create and initialize operation.
+	op := &GossipStatusOp{
+		t:    t,
+		msgs: msgs,
+	}
+	op.OpBase.Init()
+	op.OpBase.SetID(opID)
+
+	// Send msgs in a separate worker in order to not block the terminal.
+	module.StartWorker("gossip status op", op.sendMsgs)
+
+	return op, nil
+}
+
+func (op *GossipStatusOp) sendMsgs(_ context.Context) error {
+	data, err := dsd.Dump(op.msgs, dsd.CBOR)
+	if err != nil {
+		op.t.OpEnd(op, terminal.ErrInternalError.With("failed to pack status msgs: %w", err))
+		return nil
+	}
+	tErr := op.t.OpSend(op, container.New(data))
+	if tErr != nil {
+		op.t.OpEnd(op, tErr.Wrap("failed to send status msgs"))
+		return nil
+	}
+	op.t.Flush()
+
+	op.t.OpEnd(op, nil)
+	return nil
+}
+
+func (op *GossipStatusOp) Deliver(c *container.Container) *terminal.Error {
+	// Only the client receives msgs.
+	if op.result == nil {
+		return terminal.ErrIncorrectUsage
+	}
+
+	msgs := &HubStatusMsgs{}
+	_, err := dsd.Load(c.CompileData(), msgs)
+	if err != nil {
+		return terminal.ErrMalformedData.With("failed to parse status msgs: %w", err)
+	}
+
+	select {
+	case op.result <- msgs:
+	default:
+	}
+	return nil
+}
+
+func (op *GossipStatusOp) End(err *terminal.Error) {
+	if op.ended != nil {
+		select {
+		case op.ended <- err:
+		default:
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package captain
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/safing/spn/hub"
+	"github.com/safing/spn/terminal"
+)
+
+func TestGossipImportErrorPenalty(t *testing.T) {
+	testCases := []struct {
+		name      string
+		tErr      *terminal.Error
+		penalized bool
+	}{
+		{
+			name:      "old status",
+			tErr:      terminal.ErrInternalError.With("failed to apply status: %w", fmt.Errorf("%w: status is older", hub.ErrOldData)),
+			penalized: false,
+		},
+		{
+			name:      "equivocation",
+			tErr:      terminal.ErrIntegrity.With("%w", fmt.Errorf("%w: conflicting statuses", hub.ErrEquivocation)),
+			penalized: false,
+		},
+		{
+			name:      "missing status base",
+			tErr:      terminal.ErrInternalError.With("cannot apply status delta: %w", fmt.Errorf("%w: status delta requires base", hub.ErrMissingStatusBase)),
+			penalized: false,
+		},
+		{
+			name:      "unknown msg type",
+			tErr:      terminal.ErrUnexpectedMsgType.With("unknown gossip message type 99"),
+			penalized: false,
+		},
+		{
+			name:      "local failure",
+			tErr:      terminal.ErrInternalError.With("failed to save hub"),
+			penalized: false,
+		},
+		{
+			name:      "invalid signature",
+			tErr:      terminal.ErrInternalError.With("failed to apply status: %w", fmt.Errorf("%w: signature mismatch", hub.ErrInvalidHubMsg)),
+			penalized: true,
+		},
+		{
+			name:      "malformed data",
+			tErr:      terminal.ErrMalformedData.With("failed to parse gossip message"),
+			penalized: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		neighborID := "test-neighbor-" + tc.name
+		handleGossipImportError(neighborID, GossipHubStatusMsg, tc.tErr)
+
+		gossipLimitsLock.Lock()
+		neighbor, ok := gossipNeighbors[neighborID]
+		penalized := ok && neighbor.penalty > 0
+		gossipLimitsLock.Unlock()
+
+		if penalized != tc.penalized {
+			t.Errorf("%s: expected penalized=%v, got %v", tc.name, tc.penalized, penalized)
+		}
+	}
+}
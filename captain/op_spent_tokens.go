@@ -0,0 +1,167 @@
+package captain
+
+import (
+	"context"
+	"time"
+
+	"github.com/safing/portbase/container"
+	"github.com/safing/portbase/formats/dsd"
+	"github.com/safing/portbase/log"
+	"github.com/safing/portbase/modules"
+	"github.com/safing/spn/access"
+	"github.com/safing/spn/docks"
+	"github.com/safing/spn/terminal"
+)
+
+const (
+	SpentTokensShareOpType string = "spenttokens/share"
+
+	// spentTokensShareInterval defines how often spent tokens are shared with
+	// the configured peers.
+	spentTokensShareInterval = 1 * time.Minute
+
+	// spentTokensShareTimeout defines how long to wait for a peer to accept
+	// the shared spent tokens.
+	spentTokensShareTimeout = 10 * time.Second
+)
+
+// SpentTokensShareOp shares spent access tokens with another Hub of the same
+// operator, so that tokens cannot be spent again at the other Hub.
+type SpentTokensShareOp struct {
+	terminal.OpBaseRequest
+}
+
+// SpentTokensBatch is a batch of shared spent tokens.
+type SpentTokensBatch struct {
+	Tokens []*access.SpentToken
+}
+
+func (op *SpentTokensShareOp) Type() string {
+	return SpentTokensShareOpType
+}
+
+func init() {
+	terminal.RegisterOpType(terminal.OpParams{
+		Type:     SpentTokensShareOpType,
+		Requires: terminal.IsCraneController,
+		RunOp:    runSpentTokensShareOp,
+	})
+}
+
+// startSpentTokensSharing starts sharing spent tokens with the configured
+// peers.
+func startSpentTokensSharing() {
+	module.NewTask(
+		"share spent tokens",
+		shareSpentTokens,
+	).Repeat(spentTokensShareInterval).Queue()
+}
+
+func shareSpentTokens(ctx context.Context, _ *modules.Task) error {
+	peers := cfgOptionSpentTokenPeers()
+	access.SpentTokens.EnableSharing(len(peers) > 0)
+	if len(peers) == 0 {
+		return nil
+	}
+
+	// Get spent tokens to share.
+	spentTokens := access.SpentTokens.TakePending()
+	if len(spentTokens) == 0 {
+		return nil
+	}
+
+	// Share with all connected peers.
+	// Peers that are not connected will not receive these spent tokens.
+	for _, peerID := range peers {
+		crane := docks.GetAssignedCrane(peerID)
+		if crane == nil || crane.Controller == nil {
+			log.Debugf("spn/captain: cannot share spent tokens with %s: not connected", peerID)
+			continue
+		}
+
+		tErr := ShareSpentTokens(crane.Controller, spentTokens)
+		if tErr != nil {
+			log.Warningf("spn/captain: failed to share spent tokens with %s: %s", peerID, tErr)
+			continue
+		}
+		log.Debugf("spn/captain: shared %d spent tokens with %s", len(spentTokens), peerID)
+	}
+
+	return nil
+}
+
+// ShareSpentTokens shares the given spent tokens with the Hub at the other
+// end of the given crane controller.
+func ShareSpentTokens(controller *docks.CraneControllerTerminal, spentTokens []*access.SpentToken) *terminal.Error {
+	op := &SpentTokensShareOp{}
+	op.Init(0)
+
+	// Prepare init msg.
+	data, err := dsd.Dump(&SpentTokensBatch{Tokens: spentTokens}, dsd.CBOR)
+	if err != nil {
+		return terminal.ErrInternalError.With("failed to pack spent tokens: %w", err)
+	}
+
+	// Initialize.
+	tErr := controller.OpInit(op, container.New(data))
+	if tErr != nil {
+		return tErr
+	}
+	controller.Flush()
+
+	// Wait for the peer to accept the spent tokens.
+	select {
+	case tErr := <-op.Ended:
+		if tErr.IsOK() {
+			return nil
+		}
+		return tErr
+	case <-time.After(spentTokensShareTimeout):
+		controller.OpEnd(op, terminal.ErrTimeout.With("timed out waiting for spent tokens to be accepted"))
+		return terminal.ErrTimeout.With("timed out waiting for spent tokens to be accepted")
+	}
+}
+
+func runSpentTokensShareOp(t terminal.OpTerminal, opID uint32, data *container.Container) (terminal.Operation, *terminal.Error) {
+	// Check if we are run by a controller.
+	controller, ok := t.(*docks.CraneControllerTerminal)
+	if !ok {
+		return nil, terminal.ErrIncorrectUsage.With("spent tokens share op may only be started by a crane controller terminal, but was started by %T", t)
+	}
+
+	// Only accept spent tokens from configured peers.
+	if controller.Crane.ConnectedHub == nil ||
+		!stringInSlice(controller.Crane.ConnectedHub.ID, cfgOptionSpentTokenPeers()) {
+		return nil, terminal.ErrPermissinDenied.With("not a spent token peer")
+	}
+
+	// Parse spent tokens.
+	batch := &SpentTokensBatch{}
+	_, err := dsd.Load(data.CompileData(), batch)
+	if err != nil {
+		return nil, terminal.ErrMalformedData.With("failed to parse spent tokens: %w", err)
+	}
+
+	// Import spent tokens.
+	imported, err := access.SpentTokens.Import(batch.Tokens)
+	if err != nil {
+		return nil, terminal.ErrInvalidOptions.With("failed to import spent tokens: %w", err)
+	}
+	log.Debugf(
+		"spn/captain: imported %d of %d spent tokens from %s",
+		imported,
+		len(batch.Tokens),
+		controller.Crane.ConnectedHub.ID,
+	)
+
+	return nil, terminal.ErrExplicitAck
+}
+
+func stringInSlice(s string, slice []string) bool {
+	for _, entry := range slice {
+		if entry == s {
+			return true
+		}
+	}
+	return false
+}
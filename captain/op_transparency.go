@@ -0,0 +1,227 @@
+package captain
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/safing/portbase/container"
+	"github.com/safing/portbase/formats/dsd"
+	"github.com/safing/spn/conf"
+	"github.com/safing/spn/hub"
+	"github.com/safing/spn/terminal"
+)
+
+const (
+	TransparencyProofOpType string = "transparency/proof"
+
+	// transparencyProofTimeout defines how long the client waits for the proof.
+	transparencyProofTimeout = 10 * time.Second
+)
+
+// TransparencyProofOp requests inclusion and consistency proofs from the
+// transparency log of a Hub.
+type TransparencyProofOp struct {
+	terminal.OpBase
+
+	t terminal.OpTerminal
+	// proof holds the proof to send by the server.
+	proof *TransparencyProof
+
+	// result is used by the client to receive the proof.
+	result chan *TransparencyProof
+	// ended is used by the client to receive the end error.
+	ended chan *terminal.Error
+}
+
+// TransparencyProofRequest is a request for transparency log proofs.
+type TransparencyProofRequest struct {
+	// LeafHash is the hash of the leaf to prove inclusion of. Optional.
+	LeafHash []byte `json:",omitempty"`
+
+	// Size is the tree size to prove inclusion and consistency with.
+	Size uint64
+
+	// OldSize is the tree size to prove consistency of. Optional.
+	OldSize uint64 `json:",omitempty"`
+}
+
+// TransparencyProof holds the requested transparency log proofs.
+type TransparencyProof struct {
+	// TreeHead is a fresh signed tree head. It is only set if the leaf was
+	// appended after the requested tree size. The proofs are then created for
+	// the tree size of this tree head instead.
+	TreeHead []byte `json:",omitempty"`
+
+	// Index is the index of the leaf.
+	Index uint64
+	// Inclusion is the inclusion proof of the leaf.
+	Inclusion [][]byte `json:",omitempty"`
+
+	// Consistency is the consistency proof from the old to the new tree size.
+	Consistency [][]byte `json:",omitempty"`
+}
+
+func (op *TransparencyProofOp) Type() string {
+	return TransparencyProofOpType
+}
+
+func init() {
+	terminal.RegisterOpType(terminal.OpParams{
+		Type:     TransparencyProofOpType,
+		Requires: terminal.IsCraneController,
+		RunOp:    runTransparencyProofOp,
+	})
+}
+
+// RequestTransparencyProof requests proofs from the transparency log of the
+// Hub at the other end of the given terminal.
+func RequestTransparencyProof(t terminal.OpTerminal, request *TransparencyProofRequest) (*TransparencyProof, *terminal.Error) {
+	// Create new op.
+	op := &TransparencyProofOp{
+		t:      t,
+		result: make(chan *TransparencyProof, 1),
+		ended:  make(chan *terminal.Error, 1),
+	}
+	op.OpBase.Init()
+
+	// Prepare init msg.
+	data, err := dsd.Dump(request, dsd.CBOR)
+	if err != nil {
+		return nil, terminal.ErrInternalError.With("failed to pack transparency proof request: %w", err)
+	}
+
+	// Initialize.
+	tErr := t.OpInit(op, container.New(data))
+	if tErr != nil {
+		return nil, tErr
+	}
+	t.Flush()
+
+	// Wait for result.
+	select {
+	case proof := <-op.result:
+		return proof, nil
+	case tErr := <-op.ended:
+		// The result is delivered before the op ends, so check again.
+		select {
+		case proof := <-op.result:
+			return proof, nil
+		default:
+		}
+		if tErr.IsOK() {
+			return nil, terminal.ErrIncorrectUsage.With("transparency proof op ended without result")
+		}
+		return nil, tErr
+	case <-time.After(transparencyProofTimeout):
+		t.OpEnd(op, terminal.ErrTimeout.With("timed out waiting for transparency proof"))
+		return nil, terminal.ErrTimeout.With("timed out waiting for transparency proof")
+	}
+}
+
+func runTransparencyProofOp(t terminal.OpTerminal, opID uint32, data *container.Container) (terminal.Operation, *terminal.Error) {
+	// Check if we have a transparency log.
+	tl := hub.GetTransparencyLog(conf.MainMapName)
+	if tl == nil {
+		return nil, terminal.ErrPermissinDenied.With("no transparency log available")
+	}
+
+	// Parse request.
+	request := &TransparencyProofRequest{}
+	_, err := dsd.Load(data.CompileData(), request)
+	if err != nil {
+		return nil, terminal.ErrMalformedData.With("failed to parse transparency proof request: %w", err)
+	}
+
+	// Create proofs.
+	proof := &TransparencyProof{}
+	size := request.Size
+	if len(request.LeafHash) > 0 {
+		proof.Index, proof.Inclusion, err = tl.InclusionProof(request.LeafHash, size)
+		if err != nil {
+			if errors.Is(err, hub.ErrLeafNotFound) {
+				return nil, terminal.ErrIntegrity.With("%w", err)
+			}
+			return nil, terminal.ErrInvalidOptions.With("failed to create inclusion proof: %w", err)
+		}
+
+		// If the leaf was appended after the requested tree size, provide a
+		// fresh tree head that includes it.
+		if proof.Index >= size {
+			var treeHead *hub.TreeHead
+			proof.TreeHead, treeHead, err = freshTreeHead()
+			if err != nil {
+				return nil, terminal.ErrInternalError.With("failed to create fresh tree head: %w", err)
+			}
+			size = treeHead.Size
+			proof.Index, proof.Inclusion, err = tl.InclusionProof(request.LeafHash, size)
+			if err != nil {
+				return nil, terminal.ErrInternalError.With("failed to create inclusion proof: %w", err)
+			}
+		}
+	}
+	if request.OldSize > 0 {
+		proof.Consistency, err = tl.ConsistencyProof(request.OldSize, size)
+		if err != nil {
+			return nil, terminal.ErrInvalidOptions.With("failed to create consistency proof: %w", err)
+		}
+	}
+
+	// Create and initialize operation.
+	op := &TransparencyProofOp{
+		t:     t,
+		proof: proof,
+	}
+	op.OpBase.Init()
+	op.OpBase.SetID(opID)
+
+	// Send proof in a separate worker in order to not block the terminal.
+	module.StartWorker("transparency proof op", op.sendProof)
+
+	return op, nil
+}
+
+func (op *TransparencyProofOp) sendProof(_ context.Context) error {
+	data, err := dsd.Dump(op.proof, dsd.CBOR)
+	if err != nil {
+		op.t.OpEnd(op, terminal.ErrInternalError.With("failed to pack transparency proof: %w", err))
+		return nil
+	}
+	tErr := op.t.OpSend(op, container.New(data))
+	if tErr != nil {
+		op.t.OpEnd(op, tErr.Wrap("failed to send transparency proof"))
+		return nil
+	}
+	op.t.Flush()
+
+	op.t.OpEnd(op, nil)
+	return nil
+}
+
+func (op *TransparencyProofOp) Deliver(c *container.Container) *terminal.Error {
+	// Only the client receives msgs.
+	if op.result == nil {
+		return terminal.ErrIncorrectUsage
+	}
+
+	proof := &TransparencyProof{}
+	_, err := dsd.Load(c.CompileData(), proof)
+	if err != nil {
+		return terminal.ErrMalformedData.With("failed to parse transparency proof: %w", err)
+	}
+
+	select {
+	case op.result <- proof:
+	default:
+	}
+	return nil
+}
+
+func (op *TransparencyProofOp) End(err *terminal.Error) {
+	if op.ended != nil {
+		select {
+		case op.ended <- err:
+		default:
+		}
+	}
+}
@@ -101,6 +101,13 @@ func checkDockingPermission(ship ships.Ship) (ok bool) {
 }
 
 func handleDockingRequest(ship ships.Ship) {
+	// Retired Hubs do not accept new lanes.
+	if publicIdentity.Hub.Retired() {
+		log.Infof("spn/captain: denying %s to dock, as this Hub is retired", ship)
+		ship.Sink()
+		return
+	}
+
 	log.Infof("spn/captain: pemitting %s to dock", ship)
 
 	crane, err := docks.NewCrane(context.Background(), ship, nil, publicIdentity)
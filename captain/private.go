@@ -0,0 +1,315 @@
+package captain
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"github.com/ghodss/yaml"
+
+	"github.com/safing/portbase/log"
+	"github.com/safing/portmaster/netenv"
+	"github.com/safing/spn/access"
+	"github.com/safing/spn/conf"
+	"github.com/safing/spn/hub"
+	"github.com/safing/spn/navigator"
+)
+
+// PrivateNetworkConfig defines a private SPN network, which is isolated from
+// the public network. It is loaded from a YAML or JSON file.
+//
+// By default, a private network replaces the public network: its map becomes
+// the main map and the public map is not used at all. Clients may instead run
+// it alongside the public network, see AlongsidePublicNetwork.
+type PrivateNetworkConfig struct {
+	// Map is the name of the map of the private network.
+	// It must differ from the map of the public network.
+	Map string
+
+	// Scope is the scope of the Hub IP addresses: "public" or "local".
+	// Local private networks only accept Hubs with LAN addresses.
+	Scope string
+
+	// Intel is the path to the intel file of the private network. The intel
+	// file must be signed by one of the intel trust roots with a detached
+	// signature next to it.
+	Intel string
+	// IntelTrustRoots holds the keys that are trusted to sign the intel.
+	// The format is described in hub.ParseIntelTrustRoot.
+	IntelTrustRoots []string
+
+	// BootstrapFile is the path to the bootstrap file of the private network.
+	// It is used when the bootstrap-file argument is not given.
+	BootstrapFile string
+	// BootstrapHubs holds bootstrap Hub transports with the Hub ID in the
+	// fragment.
+	BootstrapHubs []string
+
+	// TokenIssuer is the address of the token issuer of the private network.
+	TokenIssuer string
+	// TokenZones holds the token zones and their issuer public keys.
+	TokenZones []*access.PrivateZone
+
+	// TrustedHubs holds the IDs of the Hubs that are part of the private
+	// network. Messages of other Hubs are rejected.
+	TrustedHubs []string
+
+	// AlongsidePublicNetwork uses the private network in addition to the
+	// public network. Connections to the Destinations are routed through the
+	// private network, all others through the public network.
+	// This is only supported on clients. As clients only authenticate with
+	// the token zones of the public network, the private network must accept
+	// these and may not define its own token zones or issuer.
+	AlongsidePublicNetwork bool
+	// Destinations holds the networks in CIDR notation that are reached via
+	// the private network when running alongside the public network.
+	Destinations []string
+}
+
+var (
+	privateNetworkFlag string
+	privateNetwork     *PrivateNetworkConfig
+
+	// privateNetworkIntelVerifier verifies the intel of the private network.
+	// It is separate from the intel verifier of the public network, so that
+	// the trust roots and last accepted intel version do not mix.
+	privateNetworkIntelVerifier *hub.IntelVerifier
+)
+
+func init() {
+	flag.StringVar(&privateNetworkFlag, "spn-private-network", "", "private SPN network definition file - uses a private network instead of the public network")
+}
+
+// prepPrivateNetwork loads the private network definition, if configured, and
+// switches the SPN to the private network.
+func prepPrivateNetwork() error {
+	if privateNetworkFlag == "" {
+		return nil
+	}
+
+	// Load and check private network definition.
+	data, err := ioutil.ReadFile(privateNetworkFlag)
+	if err != nil {
+		return fmt.Errorf("failed to load private network definition: %w", err)
+	}
+	network := &PrivateNetworkConfig{}
+	err = yaml.Unmarshal(data, network)
+	if err != nil {
+		return fmt.Errorf("failed to parse private network definition: %w", err)
+	}
+	scope, err := network.check()
+	if err != nil {
+		return fmt.Errorf("invalid private network definition: %w", err)
+	}
+	destinations, err := network.parseDestinations()
+	if err != nil {
+		return fmt.Errorf("invalid private network definition: %w", err)
+	}
+
+	// Apply private network definition.
+	trustRoots := make([]*hub.IntelTrustRoot, 0, len(network.IntelTrustRoots))
+	for _, entry := range network.IntelTrustRoots {
+		root, err := hub.ParseIntelTrustRoot(entry)
+		if err != nil {
+			return fmt.Errorf("invalid intel trust root %q: %w", entry, err)
+		}
+		trustRoots = append(trustRoots, root)
+	}
+	privateNetworkIntelVerifier = hub.NewIntelVerifier(trustRoots)
+	hub.SetTrustedHubs(network.Map, network.TrustedHubs)
+	privateNetwork = network
+
+	// Use the private network in addition to the public network.
+	if network.AlongsidePublicNetwork {
+		conf.EnablePrivateNetworkAlongside(network.Map, scope)
+		navigator.SetPrivateDestinations(destinations)
+		log.Infof("spn/captain: using private network %s alongside the public network", network.Map)
+		return nil
+	}
+
+	// Replace the public network with the private network.
+	conf.EnablePrivateNetwork(network.Map, scope)
+	if len(network.TokenZones) > 0 {
+		err = access.UsePrivateZones(network.TokenZones, network.TokenIssuer)
+		if err != nil {
+			return fmt.Errorf("invalid token zones of private network: %w", err)
+		}
+	}
+	if bootstrapFileFlag == "" {
+		bootstrapFileFlag = network.BootstrapFile
+	}
+
+	log.Infof("spn/captain: using private network %s", network.Map)
+	return nil
+}
+
+func (network *PrivateNetworkConfig) check() (hub.Scope, error) {
+	// Check map.
+	switch network.Map {
+	case "":
+		return hub.ScopeInvalid, errors.New("map is missing")
+	case intelResourceMapName, conf.MainMapName:
+		return hub.ScopeInvalid, fmt.Errorf("map %s is reserved for the public network", network.Map)
+	}
+
+	// Check alongside mode.
+	switch {
+	case network.AlongsidePublicNetwork:
+		if conf.PublicHub() {
+			return hub.ScopeInvalid, errors.New("only clients can run a private network alongside the public network")
+		}
+		if len(network.TokenZones) > 0 || network.TokenIssuer != "" {
+			return hub.ScopeInvalid, errors.New("private networks running alongside the public network use the token zones of the public network")
+		}
+		if len(network.Destinations) == 0 {
+			return hub.ScopeInvalid, errors.New("private networks running alongside the public network require destinations")
+		}
+		if _, err := network.parseDestinations(); err != nil {
+			return hub.ScopeInvalid, err
+		}
+	case len(network.Destinations) > 0:
+		return hub.ScopeInvalid, errors.New("destinations are only used when running alongside the public network")
+	}
+
+	// Check bootstrap Hubs.
+	for _, bootstrapHub := range network.BootstrapHubs {
+		_, err := hub.ParseBootstrapHub(bootstrapHub, network.Map)
+		if err != nil {
+			return hub.ScopeInvalid, fmt.Errorf("invalid bootstrap hub %q: %w", bootstrapHub, err)
+		}
+	}
+
+	// Check intel trust roots.
+	if network.Intel != "" && len(network.IntelTrustRoots) == 0 {
+		return hub.ScopeInvalid, errors.New("intel requires intel trust roots")
+	}
+	for _, entry := range network.IntelTrustRoots {
+		_, err := hub.ParseIntelTrustRoot(entry)
+		if err != nil {
+			return hub.ScopeInvalid, fmt.Errorf("invalid intel trust root %q: %w", entry, err)
+		}
+	}
+
+	// Parse scope.
+	switch network.Scope {
+	case "", "public":
+		return hub.ScopePublic, nil
+	case "local":
+		return hub.ScopeLocal, nil
+	default:
+		return hub.ScopeInvalid, fmt.Errorf("unknown scope %q", network.Scope)
+	}
+}
+
+// parseDestinations parses the destinations of the private network.
+func (network *PrivateNetworkConfig) parseDestinations() ([]*net.IPNet, error) {
+	destinations := make([]*net.IPNet, 0, len(network.Destinations))
+	for _, entry := range network.Destinations {
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid destination %q: %w", entry, err)
+		}
+		destinations = append(destinations, ipNet)
+	}
+	return destinations, nil
+}
+
+// privateNetworkMap returns the map of the private network.
+func privateNetworkMap() *navigator.Map {
+	if conf.PrivateNetwork() {
+		return navigator.Main
+	}
+	return navigator.Private
+}
+
+// processPrivateNetworkBootstrapHubs adds the bootstrap Hubs of the private
+// network to the map.
+func processPrivateNetworkBootstrapHubs() error {
+	if privateNetwork == nil || len(privateNetwork.BootstrapHubs) == 0 {
+		return nil
+	}
+	return privateNetworkMap().AddBootstrapHubs(privateNetwork.BootstrapHubs)
+}
+
+// bootstrapPrivateNetwork adds the bootstrap Hubs and intel of the private
+// network to the map.
+func bootstrapPrivateNetwork() error {
+	if len(privateNetwork.BootstrapHubs) == 0 && privateNetwork.Intel == "" {
+		return errors.New("private network has no bootstrap hubs or intel to bootstrap from")
+	}
+
+	if err := processPrivateNetworkBootstrapHubs(); err != nil {
+		return err
+	}
+	if privateNetwork.AlongsidePublicNetwork {
+		intelResourceUpdateLock.Lock()
+		defer intelResourceUpdateLock.Unlock()
+
+		return updatePrivateNetworkIntel()
+	}
+	return updateSPNIntel(module.Ctx, nil)
+}
+
+// updatePrivateNetworkIntel loads, verifies and applies the intel of the
+// private network.
+// The intel resource update lock must be held.
+func updatePrivateNetworkIntel() error {
+	if privateNetwork.Intel == "" {
+		return nil
+	}
+
+	// Load intel file and detached signature from disk.
+	intelData, err := ioutil.ReadFile(privateNetwork.Intel)
+	if err != nil {
+		return fmt.Errorf("failed to load private network intel: %w", err)
+	}
+	sigData, err := ioutil.ReadFile(privateNetwork.Intel + hub.IntelSignatureSuffix)
+	if err != nil {
+		return fmt.Errorf("failed to load private network intel signature: %w", err)
+	}
+
+	// Verify and parse intel data with the trust roots of the private network.
+	intel, err := privateNetworkIntelVerifier.Verify(intelData, sigData)
+	if err != nil {
+		return fmt.Errorf("failed to verify private network intel: %w", err)
+	}
+
+	// Apply intel data.
+	// Virtual networks are only configured by the network in use for the
+	// main map.
+	if conf.PrivateNetwork() {
+		setVirtualNetworkConfig(intel.VirtualNetworks)
+	}
+	return privateNetworkMap().UpdateIntel(intel)
+}
+
+// privateHomeHubManager keeps a home Hub of a private network that is used
+// alongside the public network connected.
+func privateHomeHubManager(ctx context.Context) error {
+	for {
+		// Check if we are online enough for connecting.
+		switch netenv.GetOnlineStatus() {
+		case netenv.StatusOffline,
+			netenv.StatusLimited:
+		default:
+			home, homeTerminal := navigator.Private.GetHome()
+			if home == nil || homeTerminal == nil || homeTerminal.IsAbandoned() {
+				err := establishHomeHub(ctx, navigator.Private, bootstrapPrivateNetwork)
+				if err != nil {
+					log.Warningf("spn/captain: failed to establish connection to home hub of private network %s: %s", privateNetwork.Map, err)
+				}
+			}
+		}
+
+		// Check again after a short break.
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package captain
+
+import "testing"
+
+func TestPrivateNetworkCheck(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		network *PrivateNetworkConfig
+		ok      bool
+	}{
+		{"minimal", &PrivateNetworkConfig{Map: "private"}, true},
+		{"local scope", &PrivateNetworkConfig{Map: "private", Scope: "local"}, true},
+		{"missing map", &PrivateNetworkConfig{}, false},
+		{"public map", &PrivateNetworkConfig{Map: intelResourceMapName}, false},
+		{"unknown scope", &PrivateNetworkConfig{Map: "private", Scope: "global"}, false},
+		{"intel without trust roots", &PrivateNetworkConfig{Map: "private", Intel: "intel.yaml"}, false},
+		{"alongside public network", &PrivateNetworkConfig{Map: "private", AlongsidePublicNetwork: true, Destinations: []string{"10.0.0.0/8"}}, true},
+		{"alongside without destinations", &PrivateNetworkConfig{Map: "private", AlongsidePublicNetwork: true}, false},
+		{"alongside with invalid destination", &PrivateNetworkConfig{Map: "private", AlongsidePublicNetwork: true, Destinations: []string{"10.0.0.0"}}, false},
+		{"alongside with token issuer", &PrivateNetworkConfig{Map: "private", AlongsidePublicNetwork: true, Destinations: []string{"10.0.0.0/8"}, TokenIssuer: "issuer"}, false},
+		{"destinations without alongside", &PrivateNetworkConfig{Map: "private", Destinations: []string{"10.0.0.0/8"}}, false},
+	}
+	for _, test := range tests {
+		_, err := test.network.check()
+		if test.ok && err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+		}
+		if !test.ok && err == nil {
+			t.Errorf("%s: expected error", test.name)
+		}
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/safing/spn/docks"
 	"github.com/safing/spn/hub"
 	"github.com/safing/spn/navigator"
+	"github.com/safing/spn/terminal"
 
 	"github.com/safing/portbase/database"
 	"github.com/safing/portbase/log"
@@ -35,14 +36,21 @@ var (
 func loadPublicIdentity() (err error) {
 	var changed bool
 
-	publicIdentity, changed, err = cabin.LoadIdentity(publicIdentityKey)
+	// Use an external signer for the identity key, if configured.
+	signer := cabin.PublicHubSigner()
+
+	publicIdentity, changed, err = cabin.LoadIdentityWithSigner(publicIdentityKey, signer)
 	switch err {
 	case nil:
 		// load was successful
 		log.Infof("spn/captain: loaded public hub identity %s", publicIdentity.Hub.ID)
 	case database.ErrNotFound:
 		// does not exist, create new
-		publicIdentity, err = cabin.CreateIdentity(module.Ctx, conf.MainMapName)
+		if signer != nil {
+			publicIdentity, err = cabin.CreateIdentityWithSigner(module.Ctx, conf.MainMapName, signer)
+		} else {
+			publicIdentity, err = cabin.CreateIdentity(module.Ctx, conf.MainMapName)
+		}
 		if err != nil {
 			return fmt.Errorf("failed to create new identity: %w", err)
 		}
@@ -111,6 +119,11 @@ func prepPublicIdentityMgmt() error {
 }
 
 func maintainPublicIdentity(ctx context.Context, task *modules.Task) error {
+	// Retired Hubs do not publish anymore.
+	if publicIdentity.Hub.Retired() {
+		return nil
+	}
+
 	changed, err := publicIdentity.MaintainAnnouncement(false)
 	if err != nil {
 		return fmt.Errorf("failed to maintain announcement: %w", err)
@@ -142,6 +155,11 @@ func maintainPublicIdentity(ctx context.Context, task *modules.Task) error {
 }
 
 func maintainPublicStatus(ctx context.Context, task *modules.Task) error {
+	// Retired Hubs do not publish anymore.
+	if publicIdentity.Hub.Retired() {
+		return nil
+	}
+
 	// Get current lanes.
 	cranes := docks.GetAllAssignedCranes()
 	lanes := make([]*hub.Lane, 0, len(cranes))
@@ -151,38 +169,29 @@ func maintainPublicStatus(ctx context.Context, task *modules.Task) error {
 			continue
 		}
 
-		// Get measurements.
+		// Get smoothed measurements, as lanes are used for route costs.
 		measurements := crane.ConnectedHub.GetMeasurements()
-		latency, _ := measurements.GetLatency()
-		capacity, _ := measurements.GetCapacity()
 
 		// Add crane lane.
 		lanes = append(lanes, &hub.Lane{
 			ID:       crane.ConnectedHub.ID,
-			Latency:  latency,
-			Capacity: capacity,
+			Latency:  measurements.GetSmoothedLatency(),
+			Capacity: measurements.GetSmoothedCapacity(),
+			LossRate: hub.RoundLaneLossRate(measurements.GetSmoothedLossRate()),
+			Jitter:   measurements.GetSmoothedJitter().Round(time.Millisecond),
+			Age:      hub.RoundLaneAge(crane.NetState.Age()),
 		})
 	}
 	// Sort Lanes for comparing.
 	hub.SortLanes(lanes)
 
-	// Get system load and convert to fixed steps.
-	var load int
-	loadAvg, ok := metrics.LoadAvg15()
-	switch {
-	case !ok:
-		load = -1
-	case loadAvg >= 1:
-		load = 100
-	case loadAvg >= 0.95:
-		load = 95
-	case loadAvg >= 0.8:
-		load = 80
-	default:
-		load = 0
-	}
-	if loadAvg >= 0.8 {
-		log.Warningf("spn/captain: publishing 15m system load average of %.2f as %d", loadAvg, load)
+	// Get sampled Hub load in fixed steps.
+	load, loadAvg := hubLoad.Load()
+	if load == loadUnknown {
+		// Fall back to the system load average.
+		load = systemLoadAvg15()
+	} else if load >= 80 {
+		log.Warningf("spn/captain: publishing 15m hub load average of %d%% as %d", loadAvg, load)
 	}
 
 	// Run maintenance with the new data.
@@ -199,14 +208,17 @@ func maintainPublicStatus(ctx context.Context, task *modules.Task) error {
 	navigator.Main.UpdateHub(publicIdentity.Hub)
 	log.Debug("spn/captain: updated own hub on map after status change")
 
-	// export status
+	// Forward to other connected Hubs.
+	// Prefer the compact status delta, if available and supported.
 	statusData, err := publicIdentity.ExportStatus()
 	if err != nil {
 		return fmt.Errorf("failed to export status: %w", err)
 	}
-
-	// forward to other connected Hubs
-	gossipRelayMsg("", GossipHubStatusMsg, statusData)
+	if deltaData := publicIdentity.ExportStatusDelta(); deltaData != nil {
+		gossipRelayStatus(deltaData, statusData)
+	} else {
+		gossipRelayMsg("", GossipHubStatusMsg, statusData)
+	}
 
 	log.Infof(
 		"spn/captain: updated status with load %d and current lanes: %v",
@@ -216,7 +228,33 @@ func maintainPublicStatus(ctx context.Context, task *modules.Task) error {
 	return nil
 }
 
+// systemLoadAvg15 returns the 15m system load average in fixed steps.
+func systemLoadAvg15() (load int) {
+	loadAvg, ok := metrics.LoadAvg15()
+	switch {
+	case !ok:
+		load = loadUnknown
+	case loadAvg >= 1:
+		load = 100
+	case loadAvg >= 0.95:
+		load = 95
+	case loadAvg >= 0.8:
+		load = 80
+	default:
+		load = 0
+	}
+	if loadAvg >= 0.8 {
+		log.Warningf("spn/captain: publishing 15m system load average of %.2f as %d", loadAvg, load)
+	}
+	return load
+}
+
 func publishShutdownStatus() {
+	// Retired Hubs do not publish anymore.
+	if publicIdentity.Hub.Retired() {
+		return
+	}
+
 	// Create offline status.
 	offlineStatusData, err := publicIdentity.MakeOfflineStatus()
 	if err != nil {
@@ -232,3 +270,101 @@ func publishShutdownStatus() {
 
 	log.Infof("spn/captain: broadcasted offline status")
 }
+
+// RetireHub permanently retires the public Hub and broadcasts the signed
+// retirement to all connected Hubs. Other Hubs will remove this Hub from the
+// network and will reject any further messages of it. The successor is the
+// optional ID of the Hub that replaces this Hub.
+func RetireHub(successor, reason string) error {
+	if publicIdentity == nil {
+		return errors.New("not a public hub")
+	}
+
+	// Create retirement.
+	retirementData, err := publicIdentity.MakeRetirement(successor, reason)
+	if err != nil {
+		return fmt.Errorf("failed to create retirement: %w", err)
+	}
+
+	// Persist the retirement, so that the identity is never used again.
+	err = publicIdentity.Save()
+	if err != nil {
+		return fmt.Errorf("failed to save retired identity: %w", err)
+	}
+	err = publicIdentity.Hub.Save()
+	if err != nil {
+		log.Warningf("spn/captain: failed to save retired Hub: %s", err)
+	}
+
+	// Remove own Hub from the map.
+	navigator.Main.RemoveHub(publicIdentity.ID)
+
+	// Forward to other connected Hubs.
+	gossipRelayMsg("", GossipHubRetirementMsg, retirementData)
+
+	// Stop all cranes after the retirement had some time to broadcast.
+	module.StartWorker("stop cranes of retired hub", func(_ context.Context) error {
+		time.Sleep(2 * time.Second)
+		for _, crane := range docks.GetAllAssignedCranes() {
+			crane.Stop(terminal.ErrStopping.With("hub retired"))
+		}
+		return nil
+	})
+
+	log.Warningf("spn/captain: retired Hub %s, successor: %q", publicIdentity.ID, successor)
+	return nil
+}
+
+// RotateIdentityKey replaces the identity key of the public Hub. The Hub
+// continues under a new ID and broadcasts a succession signed by both keys, so
+// that other Hubs migrate the history of the Hub to the new ID. All lanes are
+// gracefully replaced with lanes using the new identity.
+func RotateIdentityKey() error {
+	if publicIdentity == nil {
+		return errors.New("not a public hub")
+	}
+
+	// Rotate key and save new identity.
+	predecessor, successionData, err := publicIdentity.RotateKey()
+	if err != nil {
+		return fmt.Errorf("failed to rotate identity key: %w", err)
+	}
+	err = publicIdentity.Save()
+	if err != nil {
+		return fmt.Errorf("failed to save rotated identity: %w", err)
+	}
+
+	// Update own Hubs on map.
+	navigator.Main.UpdateHub(publicIdentity.Hub)
+	navigator.Main.SetHome(publicIdentity.ID, nil)
+	err = predecessor.Save()
+	if err != nil {
+		log.Warningf("spn/captain: failed to save retired predecessor %s: %s", predecessor.ID, err)
+	}
+
+	// Forward succession and new identity to other connected Hubs.
+	announcementData, err := publicIdentity.ExportAnnouncement()
+	if err != nil {
+		return fmt.Errorf("failed to export announcement: %w", err)
+	}
+	statusData, err := publicIdentity.ExportStatus()
+	if err != nil {
+		return fmt.Errorf("failed to export status: %w", err)
+	}
+	gossipRelayMsg("", GossipHubSuccessionMsg, successionData)
+	gossipRelayMsg("", GossipHubAnnouncementMsg, announcementData)
+	gossipRelayMsg("", GossipHubStatusMsg, statusData)
+
+	// Replace all lanes, as they were established with the previous identity.
+	// Only cranes owned by this Hub can be stopped, the peers replace their
+	// cranes when they receive the succession.
+	for _, crane := range docks.GetAllAssignedCranes() {
+		crane.MarkStopping()
+	}
+	if managePiersTask != nil {
+		managePiersTask.Queue()
+	}
+
+	log.Warningf("spn/captain: rotated identity key from %s to %s", predecessor.ID, publicIdentity.ID)
+	return nil
+}
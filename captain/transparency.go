@@ -0,0 +1,166 @@
+package captain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/safing/portbase/log"
+	"github.com/safing/portbase/modules"
+	"github.com/safing/spn/conf"
+	"github.com/safing/spn/docks"
+	"github.com/safing/spn/hub"
+	"github.com/safing/spn/terminal"
+)
+
+const publishTreeHeadInterval = 10 * time.Minute
+
+var publishTreeHeadTask *modules.Task
+
+// enableTransparencyLog enables the transparency log of the public Hub and
+// starts publishing tree heads.
+func enableTransparencyLog() error {
+	_, err := hub.EnableTransparencyLog(conf.MainMapName)
+	if err != nil {
+		return fmt.Errorf("failed to enable transparency log: %w", err)
+	}
+
+	publishTreeHeadTask = module.NewTask(
+		"publish transparency log tree head",
+		publishTreeHead,
+	).Repeat(publishTreeHeadInterval)
+
+	return nil
+}
+
+func publishTreeHead(ctx context.Context, task *modules.Task) error {
+	// Retired Hubs do not publish anymore.
+	if publicIdentity.Hub.Retired() {
+		return nil
+	}
+
+	// Create tree head, if the log changed.
+	treeHeadData, err := publicIdentity.MakeTreeHead(hub.GetTransparencyLog(conf.MainMapName))
+	if err != nil {
+		return fmt.Errorf("failed to create tree head: %w", err)
+	}
+	if treeHeadData == nil {
+		return nil
+	}
+
+	// Forward to other connected Hubs.
+	gossipRelayMsg("", GossipTreeHeadMsg, treeHeadData)
+
+	treeHead := publicIdentity.Hub.GetTreeHead()
+	log.Infof("spn/captain: published transparency log tree head with size %d", treeHead.Size)
+	return nil
+}
+
+// freshTreeHead publishes a new tree head, if the transparency log changed
+// since the last one, and returns the current signed tree head.
+func freshTreeHead() (treeHeadData []byte, treeHead *hub.TreeHead, err error) {
+	if publicIdentity == nil || publicIdentity.Hub.Retired() {
+		return nil, nil, errors.New("not an active public hub")
+	}
+
+	// Create tree head, if the log changed.
+	treeHeadData, err = publicIdentity.MakeTreeHead(hub.GetTransparencyLog(conf.MainMapName))
+	if err != nil {
+		return nil, nil, err
+	}
+	if treeHeadData != nil {
+		// Forward to other connected Hubs.
+		gossipRelayMsg("", GossipTreeHeadMsg, treeHeadData)
+	} else {
+		// Use the current tree head.
+		msg, err := hub.GetHubMsg(conf.MainMapName, hub.MsgTypeTreeHead, publicIdentity.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get current tree head: %w", err)
+		}
+		treeHeadData = msg.Data
+	}
+
+	treeHead = publicIdentity.Hub.GetTreeHead()
+	if treeHead == nil {
+		return nil, nil, errors.New("no tree head available")
+	}
+	return treeHeadData, treeHead, nil
+}
+
+// verifyHubTransparency verifies that the transparency log of the Hub at the
+// other end of the terminal includes the announcement of the Hub we know and
+// that its current tree head is consistent with the previous one.
+// Hubs that do not publish tree heads are not checked.
+func verifyHubTransparency(t terminal.OpTerminal, h *hub.Hub, previous *hub.TreeHead) error {
+	treeHead := h.GetTreeHead()
+	if treeHead == nil {
+		log.Debugf("spn/captain: %s does not publish a transparency log", h)
+		return nil
+	}
+
+	// Prepare request.
+	// Consistency is proven from the oldest tree head we know.
+	request := &TransparencyProofRequest{
+		Size:    treeHead.Size,
+		OldSize: treeHead.Size,
+	}
+	base := treeHead
+	if previous != nil && previous.Size > 0 && previous.Size < treeHead.Size {
+		base = previous
+		request.OldSize = previous.Size
+	}
+	announcementMsg, err := hub.GetHubMsg(h.Map, hub.MsgTypeAnnouncement, h.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get announcement of %s: %w", h, err)
+	}
+	leafHash := hub.TransparencyLeafHash(announcementMsg.Data)
+	request.LeafHash = leafHash
+
+	// Request proofs.
+	proof, tErr := RequestTransparencyProof(t, request)
+	if tErr != nil {
+		if tErr.Is(terminal.ErrUnknownOperationType) {
+			log.Debugf("spn/captain: %s does not support transparency proofs", h)
+			return nil
+		}
+		return fmt.Errorf("failed to get transparency proof: %w", tErr)
+	}
+
+	// Import the fresh tree head, if the announcement is newer than the tree
+	// head we know.
+	if len(proof.TreeHead) > 0 {
+		signer, _, tErr := docks.ImportTreeHead(proof.TreeHead, h.Map)
+		if tErr != nil && !errors.Is(tErr, hub.ErrOldData) {
+			return fmt.Errorf("failed to import fresh tree head of %s: %w", h, tErr)
+		}
+		if signer == nil || signer.ID != h.ID {
+			return fmt.Errorf("fresh tree head is not from %s", h)
+		}
+		freshTreeHead := signer.GetTreeHead()
+		if freshTreeHead == nil || freshTreeHead.Size <= treeHead.Size {
+			return fmt.Errorf("fresh tree head of %s does not extend its tree", h)
+		}
+		treeHead = freshTreeHead
+	}
+
+	// Verify inclusion.
+	if proof.Index >= treeHead.Size {
+		return fmt.Errorf("announcement of %s is not included in its transparency log at tree size %d", h, treeHead.Size)
+	}
+	err = hub.VerifyInclusionProof(leafHash, proof.Index, treeHead.Size, proof.Inclusion, treeHead.RootHash)
+	if err != nil {
+		return fmt.Errorf("announcement of %s is not included in its transparency log: %w", h, err)
+	}
+
+	// Verify consistency.
+	if base.Size < treeHead.Size {
+		err = hub.VerifyConsistencyProof(base.Size, treeHead.Size, base.RootHash, treeHead.RootHash, proof.Consistency)
+		if err != nil {
+			return fmt.Errorf("transparency log of %s is inconsistent: %w", h, err)
+		}
+	}
+
+	log.Debugf("spn/captain: verified transparency log of %s at tree size %d", h, treeHead.Size)
+	return nil
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/safing/spn/navigator"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "run":
+		err = run(os.Args[2:])
+	case "diff":
+		err = diff(os.Args[2:])
+	default:
+		usage()
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Simulates the optimization of the SPN network.")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Usage:")
+	fmt.Fprintln(os.Stderr, "  simulator run [flags]             run a simulation on a snapshot or generated topology")
+	fmt.Fprintln(os.Stderr, "  simulator diff <report> <report>  compare two simulation reports")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "In order to compare two optimizer versions, run the same simulation with")
+	fmt.Fprintln(os.Stderr, "both builds, save the reports with -report and compare them with diff.")
+	os.Exit(2)
+}
+
+func run(args []string) error {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	snapshotFile := flags.String("snapshot", "", "load map snapshot from `file` instead of generating a topology")
+	size := flags.Int("size", 100, "amount of Hubs in the generated topology")
+	seed := flags.Int64("seed", 1, "seed for generating the topology")
+	maxRuns := flags.Int("max-runs", 0, "stop simulation after this many runs (default 100)")
+	reportFile := flags.String("report", "", "save report as JSON to `file`")
+	resultFile := flags.String("result", "", "save resulting map snapshot to `file`")
+	quiet := flags.Bool("quiet", false, "do not print the report")
+	_ = flags.Parse(args)
+
+	// Load or generate snapshot.
+	var snapshot *navigator.MapSnapshot
+	if *snapshotFile != "" {
+		data, err := ioutil.ReadFile(*snapshotFile)
+		if err != nil {
+			return fmt.Errorf("failed to read snapshot: %w", err)
+		}
+		snapshot, err = navigator.ParseSnapshot(data)
+		if err != nil {
+			return err
+		}
+	} else {
+		snapshot = navigator.GenerateSimulationSnapshot(*seed, *size)
+	}
+
+	// Setup simulation.
+	sim, err := navigator.NewSimulation(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to load map: %w", err)
+	}
+	if *maxRuns > 0 {
+		sim.MaxRuns = *maxRuns
+	}
+
+	// Run simulation.
+	report, err := sim.Run(func(run *navigator.SimulationRun) {
+		if !*quiet {
+			fmt.Fprintf(os.Stderr, "finished run #%d with %d new lanes\n", run.Run, run.NewLanes)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("simulation failed: %w", err)
+	}
+
+	// Output results.
+	if !*quiet {
+		fmt.Println(report)
+	}
+	if *reportFile != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to serialize report: %w", err)
+		}
+		err = ioutil.WriteFile(*reportFile, data, 0o0644) //nolint:gosec // Report is not sensitive.
+		if err != nil {
+			return fmt.Errorf("failed to save report: %w", err)
+		}
+	}
+	if *resultFile != "" {
+		data, err := sim.Map().ExportSnapshot()
+		if err != nil {
+			return fmt.Errorf("failed to export resulting map: %w", err)
+		}
+		err = ioutil.WriteFile(*resultFile, data, 0o0644) //nolint:gosec // Snapshot is public data.
+		if err != nil {
+			return fmt.Errorf("failed to save resulting map: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func diff(args []string) error {
+	if len(args) != 2 {
+		usage()
+	}
+
+	a, err := loadReport(args[0])
+	if err != nil {
+		return err
+	}
+	b, err := loadReport(args[1])
+	if err != nil {
+		return err
+	}
+
+	// Compare convergence.
+	fmt.Printf("Comparing %s (a) to %s (b):\n\n", args[0], args[1])
+	fmt.Printf("Converged:   %v -> %v\n", a.Converged, b.Converged)
+	fmt.Printf("Runs:        %d -> %d\n", len(a.Runs), len(b.Runs))
+	fmt.Printf("New Lanes:   %d -> %d\n", a.NewLanes, b.NewLanes)
+	fmt.Printf("Unreachable: %d -> %d\n", a.Unreachable, b.Unreachable)
+
+	// Compare hop distances.
+	fmt.Println("\nHop Distances:")
+	fmt.Printf("Average: %.2f -> %.2f\n", averageHopDistance(a), averageHopDistance(b))
+	for _, distance := range mergedKeys(a.HopDistances, b.HopDistances) {
+		fmt.Printf("%d Hops: %d -> %d\n", distance, a.HopDistances[distance], b.HopDistances[distance])
+	}
+
+	// Compare regions.
+	fmt.Println("\nRegions:")
+	regionsA := make(map[string]*navigator.RegionReport)
+	for _, region := range a.Regions {
+		regionsA[region.ID] = region
+	}
+	for _, regionB := range b.Regions {
+		regionA, ok := regionsA[regionB.ID]
+		if !ok {
+			fmt.Printf("%s: only in b\n", regionB.ID)
+			continue
+		}
+		delete(regionsA, regionB.ID)
+		fmt.Printf(
+			"%s: internal lanes %d -> %d, external lanes %d -> %d\n",
+			regionB.ID,
+			regionA.InternalLanes, regionB.InternalLanes,
+			regionA.ExternalLanes, regionB.ExternalLanes,
+		)
+	}
+	for id := range regionsA {
+		fmt.Printf("%s: only in a\n", id)
+	}
+
+	// Compare Hub lanes.
+	fmt.Println("\nChanged Hub Lanes:")
+	lanesA := make(map[string]int)
+	for _, h := range a.Hubs {
+		lanesA[h.ID] = h.Lanes
+	}
+	var changed int
+	for _, h := range b.Hubs {
+		if lanesA[h.ID] != h.Lanes {
+			fmt.Printf("%s (%s): %d -> %d\n", h.Name, h.ID, lanesA[h.ID], h.Lanes)
+			changed++
+		}
+	}
+	fmt.Printf("%d of %d Hubs changed\n", changed, len(b.Hubs))
+
+	return nil
+}
+
+func loadReport(filename string) (*navigator.SimulationReport, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report: %w", err)
+	}
+
+	report := &navigator.SimulationReport{}
+	err = json.Unmarshal(data, report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse report %s: %w", filename, err)
+	}
+	return report, nil
+}
+
+func averageHopDistance(report *navigator.SimulationReport) float64 {
+	var sum, cnt int
+	for distance, pairs := range report.HopDistances {
+		sum += distance * pairs
+		cnt += pairs
+	}
+	if cnt == 0 {
+		return 0
+	}
+	return float64(sum) / float64(cnt)
+}
+
+func mergedKeys(a, b map[int]int) []int {
+	keys := make([]int, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Ints(keys)
+	return keys
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/mr-tron/base58"
+
+	"github.com/safing/portbase/log"
+	"github.com/safing/spn/access/issuer"
+)
+
+// Config is the configuration of the token issuer.
+// It is loaded from a YAML or JSON file.
+type Config struct {
+	// Listen is the address to listen on.
+	Listen string
+
+	// AuthSecret is the base58 encoded secret used to create auth tokens.
+	// If not set, all devices need to log in again after a restart.
+	AuthSecret string
+	// AuthTokenMaxAge defines how long auth tokens are valid, eg. "720h".
+	// Devices that are inactive for longer need to log in again.
+	// Defaults to 30 days.
+	AuthTokenMaxAge string
+
+	// Zones holds the pblind zones to issue tokens for.
+	Zones []*issuer.Zone
+
+	// Users holds the users that may request tokens.
+	Users []*issuer.StaticUser
+
+	// QuotaRequests is the amount of token batches a user may request per
+	// quota period. If zero, the amount is not limited.
+	QuotaRequests int
+	// QuotaPeriod is the quota period, eg. "24h".
+	QuotaPeriod string
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "serve":
+		err = serve(os.Args[2:])
+	case "genkey":
+		err = genKey(os.Args[2:])
+	case "hashpw":
+		err = hashPassword()
+	default:
+		usage()
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Issues SPN access tokens for private networks.")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Usage:")
+	fmt.Fprintln(os.Stderr, "  tokenissuer serve -config <file>  serve the token issuer API")
+	fmt.Fprintln(os.Stderr, "  tokenissuer genkey <zone> [epoch] generate a key pair for a zone")
+	fmt.Fprintln(os.Stderr, "  tokenissuer hashpw                hash a password read from stdin")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Set the public key of a zone and the address of the issuer in the")
+	fmt.Fprintln(os.Stderr, "TokenZones and TokenIssuer of the private network definition.")
+	fmt.Fprintln(os.Stderr, "In order to rotate the key of a zone, generate keys with an epoch and")
+	fmt.Fprintln(os.Stderr, "set overlapping validity windows with ValidFrom and ValidUntil.")
+	os.Exit(2)
+}
+
+func serve(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	configFile := flags.String("config", "", "load configuration from `file`")
+	_ = flags.Parse(args)
+
+	if *configFile == "" {
+		return errors.New("no configuration file given")
+	}
+
+	// Load configuration.
+	data, err := ioutil.ReadFile(*configFile)
+	if err != nil {
+		return fmt.Errorf("failed to read configuration: %w", err)
+	}
+	cfg := &Config{}
+	err = yaml.Unmarshal(data, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to parse configuration: %w", err)
+	}
+	if cfg.Listen == "" {
+		return errors.New("no listen address configured")
+	}
+
+	// Parse options.
+	opts := issuer.Options{
+		Zones: cfg.Zones,
+		Quota: issuer.Quota{
+			Requests: cfg.QuotaRequests,
+		},
+	}
+	if cfg.QuotaPeriod != "" {
+		opts.Quota.Period, err = time.ParseDuration(cfg.QuotaPeriod)
+		if err != nil {
+			return fmt.Errorf("invalid quota period: %w", err)
+		}
+	}
+	if cfg.AuthTokenMaxAge != "" {
+		opts.AuthTokenMaxAge, err = time.ParseDuration(cfg.AuthTokenMaxAge)
+		if err != nil {
+			return fmt.Errorf("invalid auth token max age: %w", err)
+		}
+	}
+	if cfg.AuthSecret != "" {
+		opts.AuthSecret, err = base58.Decode(cfg.AuthSecret)
+		if err != nil {
+			return fmt.Errorf("invalid auth secret: %w", err)
+		}
+	}
+	opts.Users, err = issuer.NewStaticUsers(cfg.Users)
+	if err != nil {
+		return fmt.Errorf("invalid users: %w", err)
+	}
+
+	// Create issuer.
+	iss, err := issuer.New(opts)
+	if err != nil {
+		return fmt.Errorf("failed to create issuer: %w", err)
+	}
+
+	// Start logging.
+	err = log.Start()
+	if err != nil {
+		return fmt.Errorf("failed to start logging: %w", err)
+	}
+	defer log.Shutdown()
+
+	// Serve.
+	log.Infof("issuer: listening on %s", cfg.Listen)
+	server := &http.Server{
+		Addr:         cfg.Listen,
+		Handler:      iss,
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+	return server.ListenAndServe()
+}
+
+func genKey(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errors.New("no zone given")
+	}
+	zone := args[0]
+
+	privateKey, publicKey, err := issuer.GenerateZoneKey()
+	if err != nil {
+		return err
+	}
+
+	// Print key without epoch.
+	if len(args) == 1 {
+		fmt.Println("# Issuer configuration:")
+		fmt.Println("Zones:")
+		fmt.Printf("  - Zone: %s\n", zone)
+		fmt.Printf("    PrivateKey: %s\n", privateKey)
+		fmt.Println("")
+		fmt.Println("# Private network definition:")
+		fmt.Println("TokenZones:")
+		fmt.Printf("  - Zone: %s\n", zone)
+		fmt.Printf("    PublicKey: %s\n", publicKey)
+		return nil
+	}
+
+	// Print key with epoch.
+	epoch := args[1]
+	if strings.Contains(epoch, "/") {
+		return errors.New("epoch may not contain a slash")
+	}
+	fmt.Println("# Issuer configuration:")
+	fmt.Println("Zones:")
+	fmt.Printf("  - Zone: %s\n", zone)
+	fmt.Println("    Keys:")
+	fmt.Printf("      - Epoch: %s\n", epoch)
+	fmt.Printf("        PrivateKey: %s\n", privateKey)
+	fmt.Println("")
+	fmt.Println("# Private network definition:")
+	fmt.Println("TokenZones:")
+	fmt.Printf("  - Zone: %s\n", zone)
+	fmt.Println("    Keys:")
+	fmt.Printf("      - Epoch: %s\n", epoch)
+	fmt.Printf("        PublicKey: %s\n", publicKey)
+	return nil
+}
+
+func hashPassword() error {
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		return fmt.Errorf("failed to read password: %w", err)
+	}
+	password = strings.TrimRight(password, "\r\n")
+	if password == "" {
+		return errors.New("empty password")
+	}
+
+	hash, err := issuer.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	fmt.Println(hash)
+	return nil
+}
@@ -3,14 +3,52 @@ package conf
 import (
 	"flag"
 
+	"github.com/tevino/abool"
+
 	"github.com/safing/spn/hub"
 )
 
 var (
 	MainMapName  = "main"
 	MainMapScope = hub.ScopePublic
+
+	// PrivateMapName and PrivateMapScope define the map of a private network
+	// that is used alongside the main map. The name is empty if there is none.
+	PrivateMapName  string
+	PrivateMapScope = hub.ScopeInvalid
+
+	privateNetwork = abool.New()
 )
 
 func init() {
 	flag.StringVar(&MainMapName, "spn-map", "main", "set main SPN map - use only for testing")
 }
+
+// PrivateNetwork returns whether the SPN is used as a private network.
+func PrivateNetwork() bool {
+	return privateNetwork.IsSet()
+}
+
+// EnablePrivateNetwork switches the main map to the given map of a private
+// network. It must be called before any module is started.
+func EnablePrivateNetwork(mapName string, scope hub.Scope) {
+	MainMapName = mapName
+	MainMapScope = scope
+	privateNetwork.Set()
+}
+
+// EnablePrivateNetworkAlongside adds the given map of a private network, which
+// is used alongside the main map. It must be called before any module is
+// started.
+func EnablePrivateNetworkAlongside(mapName string, scope hub.Scope) {
+	PrivateMapName = mapName
+	PrivateMapScope = scope
+}
+
+// MapScope returns the scope of the given map.
+func MapScope(mapName string) hub.Scope {
+	if PrivateMapName != "" && mapName == PrivateMapName {
+		return PrivateMapScope
+	}
+	return MainMapScope
+}
@@ -44,10 +44,21 @@ type Tunnel struct {
 }
 
 func (t *Tunnel) handle(ctx context.Context) (err error) {
+	// Select the map of the network the destination is reached by.
+	m := navigator.MapFor(t.connInfo.Entity.IP)
+
+	// Get tunnel options.
+	// Use the default options, which include the configured settings, if the
+	// connection has none.
+	opts := t.connInfo.TunnelOpts
+	if opts == nil {
+		opts = m.DefaultOptions()
+	}
+
 	// Find possible routes.
-	routes, err := navigator.Main.FindRoutes(
+	routes, err := m.FindRoutes(
 		t.connInfo.Entity.IP,
-		t.connInfo.TunnelOpts,
+		opts,
 		10,
 	)
 	if err != nil {
@@ -62,18 +73,25 @@ func (t *Tunnel) handle(ctx context.Context) (err error) {
 		return nil
 	}
 
+	// Probe the destination in the background to improve future routing.
+	if opts.ProbeDestinations &&
+		packet.IPProtocol(t.connInfo.Entity.Protocol) == packet.TCP &&
+		m.StartDestinationProbe(t.connInfo.Entity.IP) {
+		startDestinationProbes(m, t.connInfo.Entity.IP, t.connInfo.Entity.Port, routes)
+	}
+
 	// Try routes until one succeeds.
 	var tries int
 	var route *navigator.Route
 	var dstPin *navigator.Pin
 	var dstTerminal terminal.OpTerminal
 	for tries, route = range routes.All {
-		dstPin, dstTerminal, err = establishRoute(route)
+		dstPin, dstTerminal, err = establishRoute(m, route)
 		if err == nil {
 			break
 		}
 	}
-	navigator.Main.PushPinChanges()
+	m.PushPinChanges()
 
 	if err != nil {
 		log.Warningf("spn/crew: failed to establish route for %s - tried %d routes: %s", t.connInfo, tries+1, err)
@@ -118,6 +136,54 @@ func (t *Tunnel) handle(ctx context.Context) (err error) {
 	return nil
 }
 
+// startDestinationProbes lets the Destination Hubs of the best routes probe
+// the destination and reports the results to the navigator.
+func startDestinationProbes(m *navigator.Map, ip net.IP, port uint16, routes *navigator.Routes) {
+	// Select routes to distinct Destination Hubs.
+	candidates := make([]*navigator.Route, 0, navigator.DestinationProbeCandidates)
+	selected := make(map[string]struct{}, navigator.DestinationProbeCandidates)
+	for _, route := range routes.All {
+		dstHubID := route.Path[len(route.Path)-1].HubID
+		if _, ok := selected[dstHubID]; ok {
+			continue
+		}
+		selected[dstHubID] = struct{}{}
+		candidates = append(candidates, route)
+
+		if len(candidates) >= navigator.DestinationProbeCandidates {
+			break
+		}
+	}
+
+	request := &ProbeRequest{
+		IP:   ip,
+		Port: port,
+	}
+	for _, route := range candidates {
+		route := route
+		module.StartWorker("destination probe", func(_ context.Context) error {
+			dstPin, dstTerminal, err := establishRoute(m, route)
+			if err != nil {
+				log.Debugf("spn/crew: failed to establish route for probing %s: %s", request.Address(), err)
+				return nil
+			}
+
+			latency, tErr := ProbeDestination(dstTerminal, request)
+			switch {
+			case tErr == nil:
+				m.ReportDestinationProbe(ip, dstPin.Hub.ID, latency, false)
+				log.Debugf("spn/crew: %s reached %s in %s", dstPin.Hub, request.Address(), latency)
+			case tErr.Is(terminal.ErrConnectionError):
+				m.ReportDestinationProbe(ip, dstPin.Hub.ID, 0, true)
+				log.Debugf("spn/crew: %s failed to reach %s: %s", dstPin.Hub, request.Address(), tErr)
+			default:
+				log.Debugf("spn/crew: failed to probe %s via %s: %s", request.Address(), dstPin.Hub, tErr)
+			}
+			return nil
+		})
+	}
+}
+
 type hopCheck struct {
 	pin       *navigator.Pin
 	route     *navigator.Route
@@ -125,7 +191,7 @@ type hopCheck struct {
 	authOp    *access.AuthorizeOp
 }
 
-func establishRoute(route *navigator.Route) (dstPin *navigator.Pin, dstTerminal terminal.OpTerminal, err error) {
+func establishRoute(m *navigator.Map, route *navigator.Route) (dstPin *navigator.Pin, dstTerminal terminal.OpTerminal, err error) {
 	connectLock.Lock()
 	defer connectLock.Unlock()
 
@@ -137,7 +203,7 @@ func establishRoute(route *navigator.Route) (dstPin *navigator.Pin, dstTerminal
 	// Get home hub.
 	var previousHop *navigator.Pin
 	var previousTerminal terminal.OpTerminal
-	previousHop, previousTerminal = navigator.Main.GetHome()
+	previousHop, previousTerminal = m.GetHome()
 	if previousHop == nil || previousTerminal == nil {
 		return nil, nil, navigator.ErrHomeHubUnset
 	}
@@ -168,6 +234,7 @@ func establishRoute(route *navigator.Route) (dstPin *navigator.Pin, dstTerminal
 		// Expand to next Hub.
 		expansion, authOp, tErr := expand(previousTerminal, previousHop, hop.Pin())
 		if tErr != nil {
+			m.ReportEstablishmentFailure(hop.HubID)
 			return nil, nil, tErr.Wrap("failed to expand to %s", hop.Pin())
 		}
 
@@ -190,11 +257,14 @@ func establishRoute(route *navigator.Route) (dstPin *navigator.Pin, dstTerminal
 		select {
 		case tErr := <-check.authOp.Ended:
 			if !tErr.Is(terminal.ErrExplicitAck) {
+				m.ReportEstablishmentFailure(check.pin.Hub.ID)
 				return nil, nil, tErr.Wrap("failed to authenticate to %s", check.pin.Hub)
 			}
 		case <-time.After(3 * time.Second):
+			m.ReportEstablishmentFailure(check.pin.Hub.ID)
 			return nil, nil, terminal.ErrTimeout.With("timed out waiting for auth to %s", check.pin.Hub)
 		}
+		m.ReportSessionSuccess(check.pin.Hub.ID)
 
 		// Add terminal extension to the map.
 		check.pin.SetActiveTerminal(&navigator.PinConnection{
@@ -0,0 +1,216 @@
+package crew
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/safing/portbase/container"
+	"github.com/safing/portbase/formats/dsd"
+	"github.com/safing/portmaster/network/netutils"
+	"github.com/safing/portmaster/network/packet"
+	"github.com/safing/spn/conf"
+	"github.com/safing/spn/terminal"
+)
+
+const (
+	ProbeOpType string = "probe"
+
+	// probeDialTimeout defines how long the Hub waits for a connection to the
+	// probed destination.
+	probeDialTimeout = 3 * time.Second
+
+	// probeOpTimeout defines how long the client waits for the probe result.
+	probeOpTimeout = probeDialTimeout + 5*time.Second
+)
+
+// ProbeOp measures the latency from a Hub to a destination by connecting to
+// it via TCP.
+type ProbeOp struct {
+	terminal.OpBase
+
+	t       terminal.OpTerminal
+	request *ProbeRequest
+
+	// result is used by the client to receive the probe result.
+	result chan *ProbeResult
+	// ended is used by the client to receive the end error.
+	ended chan *terminal.Error
+}
+
+// ProbeRequest is the request to probe a destination.
+type ProbeRequest struct {
+	IP   net.IP
+	Port uint16
+}
+
+// ProbeResult is the result of probing a destination.
+type ProbeResult struct {
+	// Latency holds the time it took to establish a TCP connection to the
+	// destination.
+	Latency time.Duration
+}
+
+func (r *ProbeRequest) Address() string {
+	return net.JoinHostPort(r.IP.String(), strconv.Itoa(int(r.Port)))
+}
+
+func (op *ProbeOp) Type() string {
+	return ProbeOpType
+}
+
+func init() {
+	terminal.RegisterOpType(terminal.OpParams{
+		Type:     ProbeOpType,
+		Requires: terminal.MayConnect,
+		RunOp:    runProbeOp,
+	})
+}
+
+// ProbeDestination probes the destination from the Hub at the other end of
+// the given terminal and returns the measured connect latency.
+func ProbeDestination(t terminal.OpTerminal, request *ProbeRequest) (time.Duration, *terminal.Error) {
+	// Create new op.
+	op := &ProbeOp{
+		t:       t,
+		request: request,
+		result:  make(chan *ProbeResult, 1),
+		ended:   make(chan *terminal.Error, 1),
+	}
+	op.OpBase.Init()
+
+	// Prepare init msg.
+	data, err := dsd.Dump(request, dsd.JSON)
+	if err != nil {
+		return 0, terminal.ErrInternalError.With("failed to pack probe request: %w", err)
+	}
+
+	// Initialize.
+	tErr := t.OpInit(op, container.New(data))
+	if tErr != nil {
+		return 0, tErr
+	}
+	t.Flush()
+
+	// Wait for result.
+	select {
+	case result := <-op.result:
+		return result.Latency, nil
+	case tErr := <-op.ended:
+		// The result is delivered before the op ends, so check again.
+		select {
+		case result := <-op.result:
+			return result.Latency, nil
+		default:
+		}
+		if tErr.IsOK() {
+			return 0, terminal.ErrIncorrectUsage.With("probe ended without result")
+		}
+		return 0, tErr
+	case <-time.After(probeOpTimeout):
+		t.OpEnd(op, terminal.ErrTimeout.With("timed out waiting for probe result"))
+		return 0, terminal.ErrTimeout.With("timed out waiting for probe result")
+	}
+}
+
+func runProbeOp(t terminal.OpTerminal, opID uint32, data *container.Container) (terminal.Operation, *terminal.Error) {
+	// Check if we are running a public hub.
+	if !conf.PublicHub() {
+		return nil, terminal.ErrPermissinDenied.With("probing is only allowed on public hubs")
+	}
+
+	// Parse probe request.
+	request := &ProbeRequest{}
+	_, err := dsd.Load(data.CompileData(), request)
+	if err != nil {
+		return nil, terminal.ErrMalformedData.With("failed to parse probe request: %w", err)
+	}
+
+	// Check if probe target is in global scope.
+	ipScope := netutils.GetIPScope(request.IP)
+	if ipScope != netutils.Global {
+		return nil, terminal.ErrPermissinDenied.With("denied request to probe non-global IP %s", request.IP)
+	}
+
+	// Check exit policy.
+	if tErr := checkExitPolicy(&ConnectRequest{
+		IP:       request.IP,
+		Protocol: packet.TCP,
+		Port:     request.Port,
+	}); tErr != nil {
+		return nil, tErr
+	}
+
+	// Create and initialize operation.
+	op := &ProbeOp{
+		t:       t,
+		request: request,
+	}
+	op.OpBase.Init()
+	op.OpBase.SetID(opID)
+
+	// Probe in a separate worker in order to not block the terminal.
+	module.StartWorker("probe op", op.probe)
+
+	return op, nil
+}
+
+func (op *ProbeOp) probe(_ context.Context) error {
+	// Connect to destination and measure how long it takes.
+	started := time.Now()
+	conn, err := net.DialTimeout("tcp", op.request.Address(), probeDialTimeout)
+	if err != nil {
+		op.t.OpEnd(op, terminal.ErrConnectionError.With("failed to connect to %s: %w", op.request.Address(), err))
+		return nil
+	}
+	result := &ProbeResult{
+		Latency: time.Since(started),
+	}
+	_ = conn.Close()
+
+	// Send result.
+	data, err := dsd.Dump(result, dsd.JSON)
+	if err != nil {
+		op.t.OpEnd(op, terminal.ErrInternalError.With("failed to pack probe result: %w", err))
+		return nil
+	}
+	tErr := op.t.OpSend(op, container.New(data))
+	if tErr != nil {
+		op.t.OpEnd(op, tErr.Wrap("failed to send probe result"))
+		return nil
+	}
+	op.t.Flush()
+
+	op.t.OpEnd(op, nil)
+	return nil
+}
+
+func (op *ProbeOp) Deliver(c *container.Container) *terminal.Error {
+	// Only the client receives data.
+	if op.result == nil {
+		return terminal.ErrIncorrectUsage
+	}
+
+	// Parse result.
+	result := &ProbeResult{}
+	_, err := dsd.Load(c.CompileData(), result)
+	if err != nil {
+		return terminal.ErrMalformedData.With("failed to parse probe result: %w", err)
+	}
+
+	select {
+	case op.result <- result:
+	default:
+	}
+	return nil
+}
+
+func (op *ProbeOp) End(tErr *terminal.Error) {
+	if op.ended != nil {
+		select {
+		case op.ended <- tErr:
+		default:
+		}
+	}
+}
@@ -13,7 +13,7 @@ import (
 func TestEffectiveBandwidth(t *testing.T) {
 	var (
 		bwTestDelay            = 50 * time.Millisecond
-		bwTestQueueSize uint16 = 1000
+		bwTestQueueSize uint32 = 1000
 		bwTestVolume           = 10000000 // 10MB
 		beTestTime             = 20 * time.Second
 	)
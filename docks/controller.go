@@ -45,6 +45,7 @@ func initCraneController(
 ) *CraneControllerTerminal {
 	// Create Flow Queue.
 	dfq := terminal.NewDuplexFlowQueue(t, initMsg.QueueSize, t.SubmitAsDataMsg(crane.submitImportantTerminalMsg))
+	dfq.SetRTTReporter(crane.NetState.ReportRTT)
 
 	// Create Crane Terminal and assign it as the extended Terminal.
 	cct := &CraneControllerTerminal{
@@ -262,7 +262,8 @@ func (crane *Crane) getNextTerminalID() uint32 {
 	}
 }
 
-func (crane *Crane) terminalCount() int {
+// TerminalCount returns the amount of active terminals of the crane.
+func (crane *Crane) TerminalCount() int {
 	crane.terminalsLock.Lock()
 	defer crane.terminalsLock.Unlock()
 
@@ -322,7 +323,7 @@ func (crane *Crane) AbandonTerminal(id uint32, err *terminal.Error) {
 	// We can stop when all terminals are abandoned or after a timeout.
 	// FYI: The crane controller will always take up one slot.
 	if crane.stopping.IsSet() &&
-		(crane.terminalCount() <= 1 ||
+		(crane.TerminalCount() <= 1 ||
 			time.Now().Add(-maxCraneStoppingTime).After(crane.NetState.MarkedStoppingAt())) {
 		// Stop the crane in worker, so the caller can do some work.
 		module.StartWorker("retire crane", func(_ context.Context) error {
@@ -588,6 +589,7 @@ func (crane *Crane) loader(ctx context.Context) (err error) {
 	shipment := container.New()
 	var newSegment, partialShipment *container.Container
 	var loadingTimer *time.Timer
+	var loaded int
 
 	// Return the loading wait channel if waiting.
 	loadNow := func() <-chan time.Time {
@@ -660,12 +662,19 @@ func (crane *Crane) loader(ctx context.Context) (err error) {
 			}
 
 			// Load shipment.
-			err = crane.load(shipment)
+			loaded, err = crane.load(shipment)
 			if err != nil {
 				crane.Stop(terminal.ErrShipSunk.With("failed to load shipment: %w", err))
 				return nil
 			}
 
+			// Report loading for passive delivery rate estimation.
+			// Loading is busy if there is more data waiting to be loaded.
+			crane.NetState.ReportLoading(
+				loaded,
+				partialShipment != nil || len(crane.terminalMsgs) > 0 || len(crane.importantMsgs) > 0,
+			)
+
 			// Reset loading timer.
 			loadingTimer = nil
 
@@ -689,7 +698,7 @@ func (crane *Crane) loader(ctx context.Context) (err error) {
 	}
 }
 
-func (crane *Crane) load(c *container.Container) error {
+func (crane *Crane) load(c *container.Container) (loaded int, err error) {
 	if crane.opts.Padding > 0 {
 		// Add Padding if needed.
 		paddingNeeded := int(crane.opts.Padding) -
@@ -725,9 +734,9 @@ func (crane *Crane) load(c *container.Container) error {
 	}
 
 	// Encrypt shipment.
-	c, err := crane.encrypt(c)
+	c, err = crane.encrypt(c)
 	if err != nil {
-		return fmt.Errorf("failed to encrypt: %w", err)
+		return 0, fmt.Errorf("failed to encrypt: %w", err)
 	}
 
 	// Finalize data.
@@ -741,10 +750,10 @@ func (crane *Crane) load(c *container.Container) error {
 	// Load onto ship.
 	err = crane.ship.Load(readyToSend)
 	if err != nil {
-		return fmt.Errorf("failed to load ship: %w", err)
+		return 0, fmt.Errorf("failed to load ship: %w", err)
 	}
 
-	return nil
+	return len(readyToSend), nil
 }
 
 func (crane *Crane) Stop(err *terminal.Error) {
@@ -111,7 +111,7 @@ func (crane *Crane) startLocal() *terminal.Error {
 		h, _, tErr := ImportAndVerifyHubInfo(
 			crane.ctx,
 			crane.ConnectedHub.ID,
-			announcementData, statusData, conf.MainMapName, conf.MainMapScope,
+			announcementData, statusData, crane.ConnectedHub.Map, conf.MapScope(crane.ConnectedHub.Map),
 		)
 		if tErr != nil {
 			return tErr.Wrap("failed to import and verify hub")
@@ -6,7 +6,26 @@ import (
 	"time"
 )
 
-const NetStatePeriodInterval = 15 * time.Minute
+const (
+	NetStatePeriodInterval = 15 * time.Minute
+
+	// PassiveEstimationWindow defines the window over which passive RTT and
+	// delivery rate samples are filtered.
+	PassiveEstimationWindow = 10 * time.Minute
+	// passiveRTTMinSamples defines how many RTT samples are needed within the
+	// window in order to provide an estimate.
+	passiveRTTMinSamples = 3
+
+	// deliveryRateMinSampleDuration and deliveryRateMinSampleBytes define the
+	// minimum duration and amount of data of a busy loading streak in order to
+	// be used as a delivery rate sample.
+	deliveryRateMinSampleDuration = 100 * time.Millisecond
+	deliveryRateMinSampleBytes    = 65536
+
+	// passiveSampleInterval defines the interval in which samples are merged
+	// in order to limit the amount of stored samples.
+	passiveSampleInterval = time.Second
+)
 
 type NetworkOptimizationState struct {
 	lock sync.Mutex
@@ -23,6 +42,24 @@ type NetworkOptimizationState struct {
 	periodBytesIn    *uint64
 	periodBytesOut   *uint64
 	periodStarted    time.Time
+
+	// rttSamples holds passive round trip time samples within the estimation
+	// window.
+	rttSamples []passiveSample
+	// deliveryRateSamples holds passive delivery rate samples within the
+	// estimation window.
+	deliveryRateSamples []passiveSample
+	// busyLoadingStarted holds when the current busy loading streak started.
+	busyLoadingStarted time.Time
+	// busyLoadingBytes holds the amount of data loaded in the current busy
+	// loading streak.
+	busyLoadingBytes int
+}
+
+// passiveSample is a passively measured value.
+type passiveSample struct {
+	at    time.Time
+	value int64
 }
 
 func newNetworkOptimizationState() *NetworkOptimizationState {
@@ -104,3 +141,137 @@ func (netState *NetworkOptimizationState) GetTrafficStats() (
 		atomic.LoadUint64(netState.periodBytesOut),
 		netState.periodStarted
 }
+
+// Age returns how long the crane has been running.
+func (netState *NetworkOptimizationState) Age() time.Duration {
+	netState.lock.Lock()
+	defer netState.lock.Unlock()
+
+	return time.Since(netState.lifetimeStarted)
+}
+
+// ReportRTT reports a passively measured round trip time.
+func (netState *NetworkOptimizationState) ReportRTT(rtt time.Duration) {
+	netState.lock.Lock()
+	defer netState.lock.Unlock()
+
+	netState.rttSamples = addPassiveSample(netState.rttSamples, int64(rtt), true)
+}
+
+// ReportLoading reports that data was loaded onto the ship. Busy signifies
+// that more data was waiting to be loaded, which means that the loading speed
+// is limited by the connection and not by the application.
+func (netState *NetworkOptimizationState) ReportLoading(bytes int, busy bool) {
+	netState.lock.Lock()
+	defer netState.lock.Unlock()
+
+	now := time.Now()
+
+	// Start a new streak.
+	if netState.busyLoadingStarted.IsZero() {
+		if busy {
+			netState.busyLoadingStarted = now
+			netState.busyLoadingBytes = 0
+		}
+		return
+	}
+
+	// Continue the current streak.
+	// The data of the first load of the streak is not counted, as the streak
+	// starts when it was loaded.
+	netState.busyLoadingBytes += bytes
+	duration := now.Sub(netState.busyLoadingStarted)
+
+	// End the streak when loading is no longer busy or the streak is long
+	// enough for a good sample.
+	if busy && duration < time.Second {
+		return
+	}
+	if duration >= deliveryRateMinSampleDuration &&
+		netState.busyLoadingBytes >= deliveryRateMinSampleBytes {
+		rate := float64(netState.busyLoadingBytes*8) / duration.Seconds()
+		netState.deliveryRateSamples = addPassiveSample(netState.deliveryRateSamples, int64(rate), false)
+	}
+	netState.busyLoadingStarted = time.Time{}
+	if busy {
+		netState.busyLoadingStarted = now
+		netState.busyLoadingBytes = 0
+	}
+}
+
+// GetPassiveRTT returns the minimum of the passively measured round trip times
+// within the estimation window and when the last sample was taken.
+// The minimum is used in order to filter out delays from queuing and
+// processing. If there are not enough samples, zero values are returned.
+func (netState *NetworkOptimizationState) GetPassiveRTT() (rtt time.Duration, sampledAt time.Time) {
+	netState.lock.Lock()
+	defer netState.lock.Unlock()
+
+	netState.rttSamples = expirePassiveSamples(netState.rttSamples)
+	if len(netState.rttSamples) < passiveRTTMinSamples {
+		return 0, time.Time{}
+	}
+
+	rtt = time.Duration(netState.rttSamples[0].value)
+	for _, sample := range netState.rttSamples[1:] {
+		if time.Duration(sample.value) < rtt {
+			rtt = time.Duration(sample.value)
+		}
+	}
+	return rtt, netState.rttSamples[len(netState.rttSamples)-1].at
+}
+
+// GetPassiveDeliveryRate returns the maximum of the passively measured
+// delivery rates in bit/s within the estimation window and when the last
+// sample was taken. The maximum is used, as samples can only underestimate
+// the available capacity. If there are no samples, zero values are returned.
+func (netState *NetworkOptimizationState) GetPassiveDeliveryRate() (rate int, sampledAt time.Time) {
+	netState.lock.Lock()
+	defer netState.lock.Unlock()
+
+	netState.deliveryRateSamples = expirePassiveSamples(netState.deliveryRateSamples)
+	if len(netState.deliveryRateSamples) == 0 {
+		return 0, time.Time{}
+	}
+
+	for _, sample := range netState.deliveryRateSamples {
+		if int(sample.value) > rate {
+			rate = int(sample.value)
+		}
+	}
+	return rate, netState.deliveryRateSamples[len(netState.deliveryRateSamples)-1].at
+}
+
+// addPassiveSample adds a new sample to the given samples. Samples within the
+// sample interval are merged by keeping the lower or higher value.
+func addPassiveSample(samples []passiveSample, value int64, keepLower bool) []passiveSample {
+	samples = expirePassiveSamples(samples)
+
+	// Merge with last sample, if within interval.
+	if len(samples) > 0 {
+		last := &samples[len(samples)-1]
+		if time.Since(last.at) < passiveSampleInterval {
+			if (keepLower && value < last.value) || (!keepLower && value > last.value) {
+				last.value = value
+			}
+			return samples
+		}
+	}
+
+	return append(samples, passiveSample{
+		at:    time.Now(),
+		value: value,
+	})
+}
+
+func expirePassiveSamples(samples []passiveSample) []passiveSample {
+	cutoff := time.Now().Add(-PassiveEstimationWindow)
+	var remove int
+	for remove < len(samples) && samples[remove].at.Before(cutoff) {
+		remove++
+	}
+	if remove > 0 {
+		return append(samples[:0:0], samples[remove:]...)
+	}
+	return samples
+}
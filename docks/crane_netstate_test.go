@@ -0,0 +1,52 @@
+package docks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPassiveEstimation(t *testing.T) {
+	netState := newNetworkOptimizationState()
+
+	// RTT needs a minimum amount of samples.
+	rtt, _ := netState.GetPassiveRTT()
+	assert.Zero(t, rtt)
+
+	// Samples within the sample interval are merged by keeping the lowest.
+	netState.ReportRTT(30 * time.Millisecond)
+	netState.ReportRTT(20 * time.Millisecond)
+	netState.ReportRTT(25 * time.Millisecond)
+	assert.Len(t, netState.rttSamples, 1)
+	rtt, _ = netState.GetPassiveRTT()
+	assert.Zero(t, rtt)
+
+	// Add older samples.
+	netState.rttSamples[0].at = time.Now().Add(-2 * time.Second)
+	netState.ReportRTT(40 * time.Millisecond)
+	netState.rttSamples[1].at = time.Now().Add(-time.Second)
+	netState.ReportRTT(50 * time.Millisecond)
+	rtt, sampledAt := netState.GetPassiveRTT()
+	assert.Equal(t, 20*time.Millisecond, rtt)
+	assert.WithinDuration(t, time.Now(), sampledAt, time.Second)
+
+	// Expired samples are removed.
+	netState.rttSamples[0].at = time.Now().Add(-2 * PassiveEstimationWindow)
+	rtt, _ = netState.GetPassiveRTT()
+	assert.Zero(t, rtt)
+
+	// Delivery rate is only sampled from busy loading.
+	netState.ReportLoading(100000, false)
+	netState.ReportLoading(100000, false)
+	rate, _ := netState.GetPassiveDeliveryRate()
+	assert.Zero(t, rate)
+
+	netState.ReportLoading(100000, true)
+	netState.busyLoadingStarted = time.Now().Add(-200 * time.Millisecond)
+	netState.ReportLoading(100000, true)
+	netState.ReportLoading(100000, false)
+	rate, _ = netState.GetPassiveDeliveryRate()
+	assert.InDelta(t, 200000*8/0.2, rate, 200000*8/0.2*0.1)
+	assert.True(t, netState.busyLoadingStarted.IsZero())
+}
@@ -76,6 +76,7 @@ func initCraneTerminal(
 ) *CraneTerminal {
 	// Create Flow Queue.
 	dfq := terminal.NewDuplexFlowQueue(t, initMsg.QueueSize, t.SubmitAsDataMsg(crane.submitTerminalMsg))
+	dfq.SetRTTReporter(crane.NetState.ReportRTT)
 
 	// Create Crane Terminal and assign it as the extended Terminal.
 	ct := &CraneTerminal{
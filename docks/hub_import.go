@@ -2,6 +2,7 @@ package docks
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"sync"
@@ -157,6 +158,182 @@ func ImportAndVerifyHubInfo(ctx context.Context, hubID string, announcementData,
 	return h, true, firstErr
 }
 
+// ImportHubStatusDelta imports and verifies a Hub status delta. Deltas are
+// only accepted for known Hubs. Applied deltas are saved to the delta chain of
+// the stored full status, so that they can be passed on to Hubs that missed
+// them. Authentic deltas are forwarded even if they cannot be applied, as
+// neighbors might have the base. In that case, an error wrapping
+// hub.ErrMissingStatusBase is returned.
+func ImportHubStatusDelta(deltaData []byte, mapName string, scope hub.Scope) (h *hub.Hub, forward bool, tErr *terminal.Error) {
+	// Synchronize import with other hub info imports.
+	hubImportLock.Lock()
+	defer hubImportLock.Unlock()
+
+	// Import status delta.
+	h, _, changed, err := hub.ApplyStatus(nil, deltaData, mapName, scope, false)
+	switch {
+	case errors.Is(err, hub.ErrMissingStatusBase):
+		return h, true, terminal.ErrInternalError.With("cannot apply status delta: %w", err)
+	case err != nil && h == nil:
+		return nil, false, terminal.ErrInternalError.With("failed to apply status delta: %w", err)
+	case err != nil && !changed:
+		return h, false, terminal.ErrInternalError.With("failed to apply status delta: %w", err)
+	case !changed:
+		return h, false, nil
+	}
+
+	// Save the Hub to the database.
+	// The Hub is also saved if the status is invalid, in order to propagate the
+	// invalid state.
+	saveErr := h.Save()
+	if saveErr != nil {
+		log.Errorf("spn/docks: failed to persist %s: %s", h, saveErr)
+	}
+
+	if err != nil {
+		return h, true, terminal.ErrInternalError.With("failed to apply status delta: %w", err)
+	}
+
+	// Save the raw delta to the database.
+	err = hub.SaveHubStatusDelta(h.ID, h.Map, deltaData)
+	if err != nil {
+		log.Errorf("spn/docks: failed to save raw status delta msg of %s: %s", h, err)
+	}
+
+	return h, true, nil
+}
+
+// ImportTreeHead imports and verifies a transparency log tree head. Tree heads
+// are only accepted for known Hubs. Tree heads that prove equivocation are
+// forwarded, so that all Hubs learn about it.
+func ImportTreeHead(treeHeadData []byte, mapName string) (h *hub.Hub, forward bool, tErr *terminal.Error) {
+	// Synchronize import with other hub info imports.
+	hubImportLock.Lock()
+	defer hubImportLock.Unlock()
+
+	// Import tree head.
+	h, changed, err := hub.ApplyTreeHead(nil, treeHeadData, mapName, false)
+	if !changed {
+		if err != nil {
+			return h, false, terminal.ErrInternalError.With("failed to apply tree head: %w", err)
+		}
+		return h, false, nil
+	}
+
+	// Save the Hub to the database.
+	saveErr := h.Save()
+	if saveErr != nil {
+		log.Errorf("spn/docks: failed to persist %s: %s", h, saveErr)
+	}
+
+	// Report equivocation.
+	// The raw message is not saved, in order to keep the consistent tree head.
+	if err != nil {
+		log.Warningf("spn/docks: detected equivocation of %s: %s", h, err)
+		return h, true, terminal.ErrIntegrity.With("%w", err)
+	}
+
+	// Save the raw message to the database.
+	err = hub.SaveHubMsg(h.ID, h.Map, hub.MsgTypeTreeHead, treeHeadData)
+	if err != nil {
+		log.Errorf("spn/docks: failed to save raw tree head msg of %s: %s", h, err)
+	}
+
+	return h, true, nil
+}
+
+// ImportHubRetirement imports and verifies a Hub retirement. Retirements are
+// only accepted for known Hubs. Returns whether the retirement should be
+// forwarded.
+func ImportHubRetirement(retirementData []byte, mapName string) (h *hub.Hub, forward bool, tErr *terminal.Error) {
+	// Synchronize import with other hub info imports.
+	hubImportLock.Lock()
+	defer hubImportLock.Unlock()
+
+	// Import retirement.
+	h, changed, err := hub.ApplyRetirement(nil, retirementData, mapName, false)
+	switch {
+	case errors.Is(err, hub.ErrOldData):
+		// The retirement was already received. This is expected while the
+		// retirement is spreading through the network.
+		return h, false, nil
+	case err != nil:
+		return h, false, terminal.ErrInternalError.With("failed to apply retirement: %w", err)
+	}
+	if !changed {
+		return h, false, nil
+	}
+
+	// Save the Hub to the database.
+	// This removes the Hub from the navigator, but keeps the record in order to
+	// reject further messages of the Hub.
+	err = h.Save()
+	if err != nil {
+		log.Errorf("spn/docks: failed to persist %s: %s", h, err)
+	}
+
+	// Save the raw message to the database and remove the other messages.
+	err = hub.SaveHubMsg(h.ID, h.Map, hub.MsgTypeRetirement, retirementData)
+	if err != nil {
+		log.Errorf("spn/docks: failed to save raw retirement msg of %s: %s", h, err)
+	}
+	err = hub.RemoveRetiredHubMsgs(h.Map, h.ID)
+	if err != nil {
+		log.Warningf("spn/docks: failed to remove msgs of retired %s: %s", h, err)
+	}
+
+	return h, true, nil
+}
+
+// ImportHubSuccession imports and verifies a Hub identity succession. The
+// predecessor is retired and the successor is created, if it is unknown.
+// Returns whether the succession should be forwarded.
+func ImportHubSuccession(successionData []byte, mapName string) (successor *hub.Hub, forward bool, tErr *terminal.Error) {
+	// Synchronize import with other hub info imports.
+	hubImportLock.Lock()
+	defer hubImportLock.Unlock()
+
+	// Import succession.
+	predecessor, successor, changed, err := hub.ApplySuccession(nil, nil, successionData, mapName, false)
+	if err != nil {
+		return successor, false, terminal.ErrInternalError.With("failed to apply succession: %w", err)
+	}
+	if !changed {
+		return successor, false, nil
+	}
+
+	// Save the successor first, so that the navigator can migrate the Pin
+	// when the predecessor is retired.
+	// The successor is only added to the navigator when its announcement and
+	// status are received.
+	err = successor.Save()
+	if err != nil {
+		log.Errorf("spn/docks: failed to persist successor %s: %s", successor, err)
+	}
+	err = predecessor.Save()
+	if err != nil {
+		log.Errorf("spn/docks: failed to persist retired %s: %s", predecessor, err)
+	}
+
+	// Save the raw message to the database and remove the other messages.
+	err = hub.SaveHubMsg(predecessor.ID, predecessor.Map, hub.MsgTypeSuccession, successionData)
+	if err != nil {
+		log.Errorf("spn/docks: failed to save raw succession msg of %s: %s", predecessor, err)
+	}
+	err = hub.RemoveRetiredHubMsgs(predecessor.Map, predecessor.ID)
+	if err != nil {
+		log.Warningf("spn/docks: failed to remove msgs of retired %s: %s", predecessor, err)
+	}
+
+	// Gracefully replace our lane to the predecessor, as the lane needs to be
+	// established with the successor's identity.
+	if crane := GetAssignedCrane(predecessor.ID); crane != nil {
+		crane.MarkStopping()
+	}
+
+	return successor, true, nil
+}
+
 func verifyHubIP(ctx context.Context, h *hub.Hub, ip net.IP) error {
 	// Create connection.
 	ship, err := ships.Launch(ctx, h, nil, ip)
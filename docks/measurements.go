@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/safing/portbase/log"
 	"github.com/safing/spn/hub"
 	"github.com/safing/spn/ships"
 	"github.com/safing/spn/terminal"
@@ -33,6 +34,11 @@ func MeasureHub(ctx context.Context, h *hub.Hub, checkExpiryWith time.Duration)
 
 	// Check if we have a connection to this Hub.
 	crane := GetAssignedCrane(h.ID)
+	if crane != nil && checkExpiryWith != 0 {
+		// Use passive estimates from live traffic where available, so that
+		// active tests are only needed when the passive data is stale.
+		applyPassiveEstimates(crane, h.GetMeasurements(), checkExpiryWith)
+	}
 	if crane == nil {
 		// Connect to Hub.
 		var err error
@@ -105,3 +111,33 @@ func establishCraneForMeasuring(ctx context.Context, dst *hub.Hub) (*Crane, erro
 
 	return crane, nil
 }
+
+// applyPassiveEstimates applies the passive estimates of the crane to the
+// measurements, if they have expired.
+func applyPassiveEstimates(crane *Crane, measurements *hub.Measurements, checkExpiryWith time.Duration) {
+	expiry := time.Now().Add(-checkExpiryWith)
+
+	// Apply passive RTT estimate.
+	_, latencyMeasuredAt := measurements.GetLatency()
+	if expiry.After(latencyMeasuredAt) {
+		rtt, _ := crane.NetState.GetPassiveRTT()
+		if rtt > 0 {
+			measurements.SetLatency(rtt)
+			log.Infof("docks: estimated latency to %s from traffic: %s", crane.ConnectedHub, rtt)
+		}
+	}
+
+	// Apply passive delivery rate estimate.
+	_, capacityMeasuredAt := measurements.GetCapacity()
+	if expiry.After(capacityMeasuredAt) {
+		rate, _ := crane.NetState.GetPassiveDeliveryRate()
+		if rate > 0 {
+			measurements.SetCapacity(rate)
+			log.Infof(
+				"docks: estimated capacity to %s from traffic: %.2f Mbit/s",
+				crane.ConnectedHub,
+				float64(rate)/1000000,
+			)
+		}
+	}
+}
@@ -33,7 +33,7 @@ func TestCapacityOp(t *testing.T) {
 func testCapacityOp(t *testing.T, opts *CapacityTestOptions) {
 	var (
 		capTestDelay            = 1 * time.Millisecond
-		capTestQueueSize uint16 = 10
+		capTestQueueSize uint32 = 10
 	)
 
 	// Create test terminal pair.
@@ -116,6 +116,9 @@ func expand(t terminal.OpTerminal, opID uint32, data *container.Container) (term
 	// Create flow queues.
 	op.DuplexFlowQueue = terminal.NewDuplexFlowQueue(op, opts.QueueSize, op.submitBackstream)
 	op.relayTerminal.DuplexFlowQueue = terminal.NewDuplexFlowQueue(op, opts.QueueSize, op.submitForwardstream)
+	// The relay terminal spans a single hop, so it can be used for passive RTT
+	// estimation of the relay crane.
+	op.relayTerminal.DuplexFlowQueue.SetRTTReporter(relayCrane.NetState.ReportRTT)
 
 	// Establish terminal on destination.
 	newInitData, tErr := opts.Pack()
@@ -23,7 +23,8 @@ const (
 	latencyTestNonceSize     = 16
 	latencyTestRuns          = 10
 	latencyTestPauseDuration = 1 * time.Second
-	latencyTestOpTimeout     = latencyTestRuns * latencyTestPauseDuration * 3
+	latencyPingTimeout       = 2 * time.Second
+	latencyTestOpTimeout     = latencyTestRuns*(latencyTestPauseDuration+latencyPingTimeout) + 10*time.Second
 )
 
 type LatencyTestOp struct {
@@ -36,9 +37,12 @@ type LatencyTestClientOp struct {
 
 	lastPingSentAt    time.Time
 	lastPingNonce     []byte
+	timedOutNonces    map[string]struct{}
 	measuredLatencies []time.Duration
 	responses         chan *container.Container
 	testResult        time.Duration
+	testJitter        time.Duration
+	testLossRate      float32
 
 	result chan *terminal.Error
 }
@@ -61,6 +65,7 @@ func NewLatencyTestOp(t terminal.OpTerminal) (*LatencyTestClientOp, *terminal.Er
 		LatencyTestOp: LatencyTestOp{
 			t: t,
 		},
+		timedOutNonces:    make(map[string]struct{}),
 		responses:         make(chan *container.Container),
 		measuredLatencies: make([]time.Duration, 0, latencyTestRuns),
 		result:            make(chan *terminal.Error, 1),
@@ -90,6 +95,7 @@ func (op *LatencyTestClientOp) handler(ctx context.Context) error {
 	defer op.t.OpEnd(op, returnErr)
 
 	var nextTest <-chan time.Time
+	pingTimeout := time.After(latencyPingTimeout)
 	opTimeout := time.After(latencyTestOpTimeout)
 
 	for {
@@ -115,6 +121,23 @@ func (op *LatencyTestClientOp) handler(ctx context.Context) error {
 			op.t.Flush()
 
 			nextTest = nil
+			pingTimeout = time.After(latencyPingTimeout)
+
+		case <-pingTimeout:
+			// Count the ping as timed out and ignore a late response.
+			// As lanes use reliable transports, the response will still arrive.
+			op.timedOutNonces[string(op.lastPingNonce)] = struct{}{}
+			op.lastPingNonce = nil
+			pingTimeout = nil
+
+			// Check if we have enough latency tests.
+			if op.completedPings() >= latencyTestRuns {
+				op.reportMeasuredLatencies()
+				return nil
+			}
+
+			// Schedule next latency test.
+			nextTest = time.After(latencyTestPauseDuration)
 
 		case data := <-op.responses:
 			// Check if the op ended.
@@ -123,14 +146,18 @@ func (op *LatencyTestClientOp) handler(ctx context.Context) error {
 			}
 
 			// Handle response
-			tErr := op.handleResponse(data)
+			answered, tErr := op.handleResponse(data)
 			if tErr != nil {
 				returnErr = tErr
 				return nil
 			}
+			if !answered {
+				continue
+			}
+			pingTimeout = nil
 
 			// Check if we have enough latency tests.
-			if len(op.measuredLatencies) >= latencyTestRuns {
+			if op.completedPings() >= latencyTestRuns {
 				op.reportMeasuredLatencies()
 				return nil
 			}
@@ -160,25 +187,37 @@ func (op *LatencyTestClientOp) createPingRequest() (*container.Container, error)
 	), nil
 }
 
-func (op *LatencyTestClientOp) handleResponse(data *container.Container) *terminal.Error {
+// completedPings returns the amount of pings that were answered or timed out.
+func (op *LatencyTestClientOp) completedPings() int {
+	return len(op.measuredLatencies) + len(op.timedOutNonces)
+}
+
+// handleResponse handles a ping response and returns whether it answered the
+// current ping. Late responses to timed out pings are ignored.
+func (op *LatencyTestClientOp) handleResponse(data *container.Container) (answered bool, tErr *terminal.Error) {
 	rType, err := data.GetNextN8()
 	if err != nil {
-		return terminal.ErrMalformedData.With("failed to get response type: %w", err)
+		return false, terminal.ErrMalformedData.With("failed to get response type: %w", err)
 	}
 
 	switch rType {
 	case latencyPingResponse:
+		nonce := data.CompileData()
+		// Ignore late responses to timed out pings.
+		if _, ok := op.timedOutNonces[string(nonce)]; ok {
+			return false, nil
+		}
 		// Check if the ping nonce matches.
-		if !bytes.Equal(op.lastPingNonce, data.CompileData()) {
-			return terminal.ErrIntegrity.With("ping nonce mismatch")
+		if !bytes.Equal(op.lastPingNonce, nonce) {
+			return false, terminal.ErrIntegrity.With("ping nonce mismatch")
 		}
 		op.lastPingNonce = nil
 		// Save latency.
 		op.measuredLatencies = append(op.measuredLatencies, time.Since(op.lastPingSentAt))
 
-		return nil
+		return true, nil
 	default:
-		return terminal.ErrIncorrectUsage.With("unknown response type")
+		return false, terminal.ErrIncorrectUsage.With("unknown response type")
 	}
 }
 
@@ -190,13 +229,40 @@ func (op *LatencyTestClientOp) reportMeasuredLatencies() *terminal.Error {
 			lowestLatency = latency
 		}
 	}
+	if len(op.measuredLatencies) == 0 {
+		lowestLatency = 0
+	}
 	op.testResult = lowestLatency
 
+	// Calculate jitter as the mean difference between consecutive latencies.
+	var jitterSum time.Duration
+	for i := 1; i < len(op.measuredLatencies); i++ {
+		diff := op.measuredLatencies[i] - op.measuredLatencies[i-1]
+		if diff < 0 {
+			diff = -diff
+		}
+		jitterSum += diff
+	}
+	if len(op.measuredLatencies) > 1 {
+		op.testJitter = jitterSum / time.Duration(len(op.measuredLatencies)-1)
+	}
+
+	// Calculate loss rate as the rate of timed out pings.
+	if completed := op.completedPings(); completed > 0 {
+		op.testLossRate = float32(len(op.timedOutNonces)) / float32(completed)
+	}
+
 	// Save the result to the crane.
 	if controller, ok := op.t.(*CraneControllerTerminal); ok {
 		if controller.Crane.ConnectedHub != nil {
-			controller.Crane.ConnectedHub.GetMeasurements().SetLatency(op.testResult)
-			log.Infof("docks: measured latency to %s: %s", controller.Crane.ConnectedHub, op.testResult)
+			controller.Crane.ConnectedHub.GetMeasurements().SetLatencyTestResult(op.testResult, op.testJitter, op.testLossRate)
+			log.Infof(
+				"docks: measured latency to %s: %s (jitter %s, loss %.0f%%)",
+				controller.Crane.ConnectedHub,
+				op.testResult,
+				op.testJitter,
+				op.testLossRate*100,
+			)
 			return nil
 		} else if controller.Crane.IsMine() {
 			return terminal.ErrInternalError.With("latency operation was run on %s without a connected hub set", controller.Crane)
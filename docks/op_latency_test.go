@@ -4,13 +4,15 @@ import (
 	"testing"
 	"time"
 
+	"github.com/safing/portbase/container"
+	"github.com/safing/portbase/formats/varint"
 	"github.com/safing/spn/terminal"
 )
 
 func TestLatencyOp(t *testing.T) {
 	var (
 		latTestDelay            = 10 * time.Millisecond
-		latTestQueueSize uint16 = 10
+		latTestQueueSize uint32 = 10
 	)
 
 	// Create test terminal pair.
@@ -50,3 +52,42 @@ func TestLatencyOp(t *testing.T) {
 		t.Fatal("measured latency too low")
 	}
 }
+
+func TestLatencyOpLateResponses(t *testing.T) {
+	op := &LatencyTestClientOp{
+		lastPingNonce: []byte("current-ping"),
+		timedOutNonces: map[string]struct{}{
+			"timed-out-ping-1": {},
+			"timed-out-ping-2": {},
+		},
+	}
+	respond := func(nonce string) (answered bool, tErr *terminal.Error) {
+		return op.handleResponse(container.New(varint.Pack8(latencyPingResponse), []byte(nonce)))
+	}
+
+	// Late responses to all timed out pings are ignored.
+	for _, nonce := range []string{"timed-out-ping-2", "timed-out-ping-1"} {
+		answered, tErr := respond(nonce)
+		if tErr != nil {
+			t.Fatalf("late response failed: %s", tErr)
+		}
+		if answered {
+			t.Fatal("late response answered current ping")
+		}
+	}
+
+	// The current ping is answered.
+	answered, tErr := respond("current-ping")
+	if tErr != nil || !answered {
+		t.Fatalf("current ping was not answered: %s", tErr)
+	}
+	if op.completedPings() != 3 {
+		t.Fatalf("expected 3 completed pings, got %d", op.completedPings())
+	}
+
+	// Unknown nonces are still rejected.
+	_, tErr = respond("unknown-ping")
+	if tErr == nil {
+		t.Fatal("unknown nonce was accepted")
+	}
+}
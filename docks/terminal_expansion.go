@@ -22,6 +22,9 @@ type ExpansionTerminal struct {
 
 	changeNotifyFuncReady *abool.AtomicBool
 	changeNotifyFunc      func()
+
+	// abandonErr holds the error the terminal was abandoned with.
+	abandonErr *terminal.Error
 }
 
 func ExpandTo(t terminal.OpTerminal, routeTo string, encryptFor *hub.Hub) (*ExpansionTerminal, *terminal.Error) {
@@ -108,6 +111,8 @@ func (t *ExpansionTerminal) Abandon(err *terminal.Error) {
 
 func (t *ExpansionTerminal) stop(err *terminal.Error) {
 	if t.Abandoned.SetToIf(false, true) {
+		t.abandonErr = err
+
 		switch {
 		case err == nil:
 			log.Debugf("spn/docks: expansion terminal %s is being abandoned", t.FmtID())
@@ -134,6 +139,12 @@ func (t *ExpansionTerminal) IsAbandoned() bool {
 	return t.Abandoned.IsSet()
 }
 
+// AbandonError returns the error the terminal was abandoned with.
+// It must only be called after the terminal was abandoned.
+func (t *ExpansionTerminal) AbandonError() *terminal.Error {
+	return t.abandonErr
+}
+
 func (t *ExpansionTerminal) SetChangeNotifyFunc(f func()) {
 	if t.changeNotifyFuncReady.IsSet() {
 		return
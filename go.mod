@@ -15,4 +15,5 @@ require (
 	github.com/stretchr/testify v1.7.0
 	github.com/tevino/abool v1.2.0
 	github.com/xtaci/kcp-go/v5 v5.6.1
+	golang.org/x/crypto v0.0.0-20220112180741-5e0467b6c7ce
 )
@@ -129,6 +129,54 @@ func RemoveHubAndMsgs(mapName string, hubID string) (err error) {
 		return fmt.Errorf("failed to delete hub status data: %w", err)
 	}
 
+	err = deleteHubStatusDeltas(mapName, hubID)
+	if err != nil {
+		return fmt.Errorf("failed to delete hub status delta data: %w", err)
+	}
+
+	err = db.Delete(MakeHubMsgDBKey(mapName, MsgTypeRetirement, hubID))
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return fmt.Errorf("failed to delete hub retirement data: %w", err)
+	}
+
+	err = db.Delete(MakeHubMsgDBKey(mapName, MsgTypeSuccession, hubID))
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return fmt.Errorf("failed to delete hub succession data: %w", err)
+	}
+
+	err = db.Delete(MakeHubMsgDBKey(mapName, MsgTypeTreeHead, hubID))
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return fmt.Errorf("failed to delete hub tree head data: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveRetiredHubMsgs deletes the announcement, status and tree head messages
+// of a retired Hub from the database, so that they are not gossiped anymore.
+// The Hub and its retirement message are kept, in order to reject replayed
+// messages and to inform other Hubs.
+func RemoveRetiredHubMsgs(mapName string, hubID string) (err error) {
+	err = db.Delete(MakeHubMsgDBKey(mapName, MsgTypeAnnouncement, hubID))
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return fmt.Errorf("failed to delete hub announcement data: %w", err)
+	}
+
+	err = db.Delete(MakeHubMsgDBKey(mapName, MsgTypeStatus, hubID))
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return fmt.Errorf("failed to delete hub status data: %w", err)
+	}
+
+	err = deleteHubStatusDeltas(mapName, hubID)
+	if err != nil {
+		return fmt.Errorf("failed to delete hub status delta data: %w", err)
+	}
+
+	err = db.Delete(MakeHubMsgDBKey(mapName, MsgTypeTreeHead, hubID))
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return fmt.Errorf("failed to delete hub tree head data: %w", err)
+	}
+
 	return nil
 }
 
@@ -158,7 +206,38 @@ func SaveHubMsg(id string, mapName string, msgType MsgType, data []byte) error {
 	// set key
 	msg.SetKey(MakeHubMsgDBKey(msg.Map, msg.Type, msg.ID))
 	// save
-	return db.PutNew(msg)
+	err := db.PutNew(msg)
+	if err != nil {
+		return err
+	}
+
+	// A new full status replaces the deltas applied to the previous one.
+	if msgType == MsgTypeStatus {
+		if err := deleteHubStatusDeltas(mapName, id); err != nil {
+			return fmt.Errorf("failed to delete status deltas: %w", err)
+		}
+	}
+
+	// Add to transparency log, if enabled.
+	if tl := GetTransparencyLog(mapName); tl != nil {
+		switch msgType {
+		case MsgTypeAnnouncement:
+			tl.Append(data)
+		case MsgTypeStatus:
+			tl.AppendStatus(id, data)
+		}
+	}
+	return nil
+}
+
+// GetHubMsg returns the stored raw message of the given type of a Hub.
+func GetHubMsg(mapName string, msgType MsgType, hubID string) (*HubMsg, error) {
+	r, err := db.Get(MakeHubMsgDBKey(mapName, msgType, hubID))
+	if err != nil {
+		return nil, err
+	}
+
+	return EnsureHubMsg(r)
 }
 
 func QueryRawGossipMsgs(mapName string, msgType MsgType) (it *iterator.Iterator, err error) {
@@ -15,6 +15,14 @@ var (
 	// ErrTemporaryValidationError is returned when a validation error might be temporary.
 	ErrTemporaryValidationError = errors.New("temporary validation error")
 
+	// ErrMissingStatusBase is returned when a status delta cannot be applied,
+	// because the status it is based on is missing.
+	ErrMissingStatusBase = errors.New("missing base of status delta")
+
+	// ErrInvalidHubMsg is returned when a Hub msg is malformed or its signature
+	// is invalid.
+	ErrInvalidHubMsg = errors.New("invalid hub msg")
+
 	// ErrOldData is returned when received data is outdated.
 	ErrOldData = errors.New("")
 )
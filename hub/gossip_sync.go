@@ -0,0 +1,283 @@
+package hub
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/safing/jess"
+	"github.com/safing/portbase/formats/dsd"
+)
+
+// Gossip sync reconciles the stored Hub messages of two Hubs, so that only the
+// messages that one side lacks or has outdated need to be transferred.
+// Both sides summarize their messages in buckets by Hub ID. Only the entries
+// of mismatching buckets are exchanged and compared.
+
+const (
+	// MinSyncBuckets and MaxSyncBuckets define the bounds of the number of
+	// buckets of a sync summary.
+	MinSyncBuckets = 16
+	MaxSyncBuckets = 4096
+
+	// syncEntriesPerBucket defines how many entries a bucket should hold on
+	// average. Fewer entries per bucket reduce the amount of entries that need
+	// to be exchanged, but increase the size of the summary.
+	syncEntriesPerBucket = 4
+)
+
+// SyncMsgTypes holds the Hub message types that are reconciled.
+var SyncMsgTypes = []MsgType{
+	MsgTypeAnnouncement,
+	MsgTypeStatus,
+	MsgTypeRetirement,
+	MsgTypeSuccession,
+	MsgTypeTreeHead,
+}
+
+// SyncEntry identifies a version of a stored Hub message.
+type SyncEntry struct {
+	Type      MsgType
+	ID        string
+	Timestamp int64
+}
+
+// SyncSummary summarizes all entries of a SyncSet in buckets.
+type SyncSummary struct {
+	// Buckets holds the combined hashes of all entries in a bucket.
+	Buckets []uint64
+}
+
+type syncKey struct {
+	Type MsgType
+	ID   string
+}
+
+// SyncSet holds the sync entries of stored Hub messages.
+type SyncSet struct {
+	entries map[syncKey]int64
+}
+
+// NewSyncSet returns a new and empty sync set.
+func NewSyncSet() *SyncSet {
+	return &SyncSet{
+		entries: make(map[syncKey]int64),
+	}
+}
+
+// LoadSyncSet loads the sync entries of all stored Hub messages of the given
+// map from the database.
+func LoadSyncSet(mapName string) (*SyncSet, error) {
+	set := NewSyncSet()
+
+	for _, msgType := range SyncMsgTypes {
+		it, err := QueryRawGossipMsgs(mapName, msgType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query %s msgs: %w", msgType, err)
+		}
+		for r := range it.Next {
+			hubMsg, err := EnsureHubMsg(r)
+			if err != nil {
+				continue
+			}
+			timestamp, err := hubMsg.msgTimestamp()
+			if err != nil {
+				continue
+			}
+			// The version of a status includes the deltas applied to it, so
+			// that Hubs with different delta chains do not appear in sync.
+			if hubMsg.Type == MsgTypeStatus {
+				timestamp = statusChainTimestamp(mapName, hubMsg.ID, timestamp)
+			}
+			set.Add(&SyncEntry{
+				Type:      hubMsg.Type,
+				ID:        hubMsg.ID,
+				Timestamp: timestamp,
+			})
+		}
+		if err := it.Err(); err != nil {
+			return nil, fmt.Errorf("failed to iterate %s msgs: %w", msgType, err)
+		}
+	}
+
+	return set, nil
+}
+
+// statusChainTimestamp returns the timestamp of the last stored status delta
+// of the Hub, or the given status timestamp if there are none.
+func statusChainTimestamp(mapName, hubID string, statusTimestamp int64) int64 {
+	deltas, err := GetHubStatusDeltas(mapName, hubID)
+	if err != nil || len(deltas) == 0 {
+		return statusTimestamp
+	}
+
+	timestamp, err := signedMsgTimestamp(deltas[len(deltas)-1])
+	if err != nil || timestamp < statusTimestamp {
+		return statusTimestamp
+	}
+	return timestamp
+}
+
+// msgTimestamp returns the timestamp of the Hub message.
+// The message is not verified, as it was verified when it was stored.
+func (msg *HubMsg) msgTimestamp() (int64, error) {
+	return signedMsgTimestamp(msg.Data)
+}
+
+// signedMsgTimestamp returns the timestamp of a signed Hub message.
+func signedMsgTimestamp(data []byte) (int64, error) {
+	letter, err := jess.LetterFromDSD(data)
+	if err != nil {
+		return 0, err
+	}
+
+	timestamped := &struct {
+		Timestamp int64
+	}{}
+	_, err = dsd.Load(letter.Data, timestamped)
+	if err != nil {
+		return 0, err
+	}
+
+	return timestamped.Timestamp, nil
+}
+
+// Add adds an entry to the set. If the set already holds an entry for the
+// same message, the newer one is kept.
+func (set *SyncSet) Add(entry *SyncEntry) {
+	key := syncKey{Type: entry.Type, ID: entry.ID}
+	if existing, ok := set.entries[key]; ok && existing >= entry.Timestamp {
+		return
+	}
+	set.entries[key] = entry.Timestamp
+}
+
+// Len returns the amount of entries in the set.
+func (set *SyncSet) Len() int {
+	return len(set.entries)
+}
+
+// Summary returns a summary of the set with a bucket count suitable for the
+// size of the set.
+func (set *SyncSet) Summary() *SyncSummary {
+	size := MinSyncBuckets
+	for size < MaxSyncBuckets && size*syncEntriesPerBucket < len(set.entries) {
+		size *= 2
+	}
+
+	return set.summary(size)
+}
+
+func (set *SyncSet) summary(size int) *SyncSummary {
+	summary := &SyncSummary{
+		Buckets: make([]uint64, size),
+	}
+	for key, timestamp := range set.entries {
+		summary.Buckets[syncBucket(key.ID, size)] ^= syncEntryHash(key, timestamp)
+	}
+	return summary
+}
+
+// MismatchingBuckets returns the buckets in which the given remote summary
+// differs from the set.
+func (set *SyncSet) MismatchingBuckets(remote *SyncSummary) ([]int, error) {
+	size := len(remote.Buckets)
+	if size < MinSyncBuckets || size > MaxSyncBuckets {
+		return nil, fmt.Errorf("invalid sync summary size %d", size)
+	}
+
+	local := set.summary(size)
+	var mismatching []int
+	for i := range local.Buckets {
+		if local.Buckets[i] != remote.Buckets[i] {
+			mismatching = append(mismatching, i)
+		}
+	}
+	return mismatching, nil
+}
+
+// Entries returns all entries in the given buckets of a summary with the
+// given bucket count.
+func (set *SyncSet) Entries(buckets []int, size int) []*SyncEntry {
+	selected := make(map[int]struct{}, len(buckets))
+	for _, bucket := range buckets {
+		selected[bucket] = struct{}{}
+	}
+
+	var entries []*SyncEntry
+	for key, timestamp := range set.entries {
+		if _, ok := selected[syncBucket(key.ID, size)]; ok {
+			entries = append(entries, &SyncEntry{
+				Type:      key.Type,
+				ID:        key.ID,
+				Timestamp: timestamp,
+			})
+		}
+	}
+	sortSyncEntries(entries)
+
+	return entries
+}
+
+// Reconcile compares the given remote entries of the given buckets with the
+// set. It returns the entries that the remote has newer or the set lacks, and
+// the entries that the set has newer or the remote lacks.
+func (set *SyncSet) Reconcile(remote []*SyncEntry, buckets []int, size int) (want, offer []*SyncEntry) {
+	remoteSet := NewSyncSet()
+	for _, entry := range remote {
+		remoteSet.Add(entry)
+	}
+
+	// Check which remote entries are newer or missing locally.
+	for key, remoteTimestamp := range remoteSet.entries {
+		if localTimestamp, ok := set.entries[key]; !ok || localTimestamp < remoteTimestamp {
+			want = append(want, &SyncEntry{
+				Type:      key.Type,
+				ID:        key.ID,
+				Timestamp: remoteTimestamp,
+			})
+		}
+	}
+
+	// Check which local entries of the buckets are newer or missing remotely.
+	for _, entry := range set.Entries(buckets, size) {
+		key := syncKey{Type: entry.Type, ID: entry.ID}
+		if remoteTimestamp, ok := remoteSet.entries[key]; !ok || remoteTimestamp < entry.Timestamp {
+			offer = append(offer, entry)
+		}
+	}
+
+	sortSyncEntries(want)
+	return want, offer
+}
+
+func syncBucket(hubID string, size int) int {
+	sum := sha256.Sum256([]byte(hubID))
+	return int(binary.BigEndian.Uint64(sum[:8]) % uint64(size))
+}
+
+func syncEntryHash(key syncKey, timestamp int64) uint64 {
+	h := sha256.New()
+	_, _ = h.Write([]byte(key.Type))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(key.ID))
+	_ = binary.Write(h, binary.BigEndian, timestamp)
+	return binary.BigEndian.Uint64(h.Sum(nil)[:8])
+}
+
+// sortSyncEntries sorts entries by type and ID in order to send announcements
+// before the statuses and to make results deterministic.
+func sortSyncEntries(entries []*SyncEntry) {
+	typeOrder := make(map[MsgType]int, len(SyncMsgTypes))
+	for i, msgType := range SyncMsgTypes {
+		typeOrder[msgType] = i
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Type != entries[j].Type {
+			return typeOrder[entries[i].Type] < typeOrder[entries[j].Type]
+		}
+		return entries[i].ID < entries[j].ID
+	})
+}
@@ -0,0 +1,121 @@
+package hub
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGossipSync(t *testing.T) {
+	t.Parallel()
+
+	for _, size := range []int{10, 1000, 20000} {
+		testGossipSync(t, size)
+	}
+}
+
+func testGossipSync(t *testing.T, size int) {
+	t.Helper()
+
+	rng := rand.New(rand.NewSource(int64(size))) //nolint:gosec
+	changes := size/100 + 1
+
+	// Create identical sets.
+	client := NewSyncSet()
+	server := NewSyncSet()
+	for i := 0; i < size; i++ {
+		for _, msgType := range []MsgType{MsgTypeAnnouncement, MsgTypeStatus} {
+			entry := &SyncEntry{
+				Type:      msgType,
+				ID:        fmt.Sprintf("hub-%d", i),
+				Timestamp: rng.Int63n(1000000),
+			}
+			client.Add(entry)
+			server.Add(entry)
+		}
+	}
+
+	// Identical sets do not need any exchange.
+	buckets, err := server.MismatchingBuckets(client.Summary())
+	if assert.NoError(t, err) {
+		assert.Empty(t, buckets, "identical sets should not have mismatching buckets")
+	}
+
+	// Introduce differences.
+	expectedWant := make(map[string]struct{})
+	expectedOffer := make(map[string]struct{})
+	for i := 0; i < changes; i++ {
+		// Server has a newer status.
+		id := fmt.Sprintf("hub-%d", rng.Intn(size))
+		server.Add(&SyncEntry{Type: MsgTypeStatus, ID: id, Timestamp: 2000000 + int64(i)})
+		expectedWant[string(MsgTypeStatus)+id] = struct{}{}
+
+		// Server knows a new Hub.
+		id = fmt.Sprintf("server-hub-%d", i)
+		server.Add(&SyncEntry{Type: MsgTypeAnnouncement, ID: id, Timestamp: 1})
+		expectedWant[string(MsgTypeAnnouncement)+id] = struct{}{}
+
+		// Client knows a new Hub.
+		id = fmt.Sprintf("client-hub-%d", i)
+		client.Add(&SyncEntry{Type: MsgTypeAnnouncement, ID: id, Timestamp: 1})
+		expectedOffer[string(MsgTypeAnnouncement)+id] = struct{}{}
+	}
+	// Client has a newer announcement, which the server has newer as well.
+	id := fmt.Sprintf("hub-%d", size-1)
+	client.Add(&SyncEntry{Type: MsgTypeAnnouncement, ID: id, Timestamp: 3000000})
+	expectedOffer[string(MsgTypeAnnouncement)+id] = struct{}{}
+
+	// Run sync.
+	summary := client.Summary()
+	buckets, err = server.MismatchingBuckets(summary)
+	if !assert.NoError(t, err) {
+		return
+	}
+	mismatching := len(buckets)
+	entries := server.Entries(buckets, len(summary.Buckets))
+	want, offer := client.Reconcile(entries, buckets, len(summary.Buckets))
+
+	// Check results.
+	toSet := func(entries []*SyncEntry) map[string]struct{} {
+		set := make(map[string]struct{}, len(entries))
+		for _, entry := range entries {
+			set[string(entry.Type)+entry.ID] = struct{}{}
+		}
+		return set
+	}
+	assert.Equal(t, expectedWant, toSet(want), "size %d: wanted msgs", size)
+	assert.Equal(t, expectedOffer, toSet(offer), "size %d: offered msgs", size)
+
+	// Apply results and check that the sets are now equal.
+	for _, entry := range want {
+		client.Add(entry)
+	}
+	for _, entry := range offer {
+		server.Add(entry)
+	}
+	buckets, err = server.MismatchingBuckets(client.Summary())
+	if assert.NoError(t, err) {
+		assert.Empty(t, buckets, "size %d: sets should be equal after sync", size)
+	}
+
+	// Check that the sync exchanged far less than a full dump on larger sets.
+	if size >= 1000 {
+		assert.Less(t, len(entries), server.Len()/4, "size %d: too many entries exchanged", size)
+	}
+	t.Logf(
+		"size %d: summary with %d buckets, %d mismatching, %d entries exchanged, %d msgs wanted, %d msgs offered",
+		size, len(summary.Buckets), mismatching, len(entries), len(want), len(offer),
+	)
+}
+
+func TestGossipSyncSummaryValidation(t *testing.T) {
+	t.Parallel()
+
+	set := NewSyncSet()
+	_, err := set.MismatchingBuckets(&SyncSummary{Buckets: make([]uint64, 1)})
+	assert.Error(t, err, "too small summary should be rejected")
+	_, err = set.MismatchingBuckets(&SyncSummary{Buckets: make([]uint64, MaxSyncBuckets+1)})
+	assert.Error(t, err, "too large summary should be rejected")
+}
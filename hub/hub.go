@@ -37,6 +37,7 @@ type MsgType string
 const (
 	MsgTypeAnnouncement = "announcement"
 	MsgTypeStatus       = "status"
+	MsgTypeRetirement   = "retirement"
 )
 
 // Hub represents a network node in the SPN.
@@ -48,8 +49,11 @@ type Hub struct {
 	PublicKey *jess.Signet
 	Map       string
 
-	Info   *Announcement
-	Status *Status
+	Info       *Announcement
+	Status     *Status
+	Retirement *Retirement
+	Succession *Succession
+	TreeHead   *TreeHead
 
 	Measurements            *Measurements
 	measurementsInitialized bool
@@ -58,6 +62,9 @@ type Hub struct {
 	VerifiedIPs   bool
 	InvalidInfo   bool
 	InvalidStatus bool
+	// Equivocated is set when the Hub published inconsistent tree heads of
+	// its transparency log. It is never reset.
+	Equivocated bool
 }
 
 // Announcement is the main message type to publish Hub Information. This only changes if updated manually.
@@ -79,9 +86,9 @@ type Announcement struct {
 	ContactAddress string // contact possibility  (recommended, but optional)
 	ContactService string // type of service of the contact address, if not email
 
-	// currently unused, but collected for later use
+	// Supply chain, used for avoiding multiple hops with the same hoster.
 	Hosters    []string // hoster supply chain (reseller, hosting provider, datacenter operator, ...)
-	Datacenter string   // datacenter will be bullshit checked
+	Datacenter string   // datacenter is checked against the geoip country
 	// Format: CC-COMPANY-INTERNALCODE
 	// Eg: DE-Hetzner-FSN1-DC5
 
@@ -218,6 +225,7 @@ func (h *Hub) Obsolete() bool {
 	switch {
 	case h.InvalidInfo:
 	case h.InvalidStatus:
+	case h.Equivocated:
 	case h.Status.Version == VersionOffline:
 		// Treat offline as invalid.
 	default:
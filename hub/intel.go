@@ -12,6 +12,13 @@ import (
 
 // Intel holds a collection of various security related data collections on Hubs.
 type Intel struct {
+	// Version is the version of the intel data. It must increase with every
+	// release, as older intel is rejected.
+	Version uint64
+	// Timestamp is the Unix timestamp in seconds of when the intel data was
+	// released.
+	Timestamp int64
+
 	// BootstrapHubs is list of transports that also contain an IP and the Hub's ID.
 	BootstrapHubs []string
 	// TrustedHubs is a list of Hub IDs that are specially designated for more sensitive tasls, such as handling unencrypted traffic.
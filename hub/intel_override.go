@@ -14,4 +14,8 @@ type InfoOverride struct {
 	ASN uint
 	// ASOrg overrides the Autonomous System Organization of the geoip data.
 	ASOrg string
+	// Hosters overrides the announced hoster supply chain.
+	Hosters []string
+	// Datacenter overrides the announced datacenter.
+	Datacenter string
 }
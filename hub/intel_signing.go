@@ -0,0 +1,375 @@
+package hub
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mr-tron/base58"
+
+	"github.com/safing/jess"
+	"github.com/safing/portbase/database"
+	"github.com/safing/portbase/database/record"
+	"github.com/safing/portbase/formats/dsd"
+	"github.com/safing/portbase/log"
+)
+
+// IntelSignatureSuffix is appended to the path of an intel file to get the
+// path of its detached signature.
+const IntelSignatureSuffix = ".sig"
+
+// Intel verification errors.
+var (
+	ErrIntelUnsigned     = errors.New("intel is not signed")
+	ErrIntelUntrusted    = errors.New("intel is not signed by a trusted key")
+	ErrIntelStale        = errors.New("intel is older than the current intel")
+	ErrIntelNoTrustRoots = errors.New("no intel trust roots configured")
+)
+
+// intelSignatureRequirements defines which security attributes intel
+// signatures need to have.
+var intelSignatureRequirements = jess.NewRequirements().
+	Remove(jess.RecipientAuthentication). // Recipients don't need a private key.
+	Remove(jess.Confidentiality).         // Intel is public.
+	Remove(jess.Integrity)                // Only applies to decryption.
+// SenderAuthentication provides pre-decryption integrity. That is all we need.
+
+// IntelTrustRoot is a key that is trusted to sign intel data.
+type IntelTrustRoot struct {
+	// Signet holds the public key.
+	Signet *jess.Signet
+
+	// Expires specifies when the key stops being trusted. This enables key
+	// rotation: Intel is signed with both the old and the new key until the
+	// old key expires. A zero value means that the key does not expire.
+	Expires time.Time
+}
+
+// ParseIntelTrustRoot parses an intel trust root.
+// The format is <ID>:<Scheme>:<Base58 Public Key>[:<Expiry Date YYYY-MM-DD>].
+func ParseIntelTrustRoot(s string) (*IntelTrustRoot, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 3 || len(parts) > 4 {
+		return nil, errors.New("trust root must have the format <ID>:<Scheme>:<Key>[:<Expires>]")
+	}
+
+	key, err := base58.Decode(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode key: %w", err)
+	}
+	root := &IntelTrustRoot{
+		Signet: &jess.Signet{
+			Version: 1,
+			ID:      parts[0],
+			Scheme:  parts[1],
+			Key:     key,
+			Public:  true,
+		},
+	}
+	if err := root.Signet.LoadKey(); err != nil {
+		return nil, fmt.Errorf("failed to load key: %w", err)
+	}
+
+	if len(parts) == 4 {
+		root.Expires, err = time.Parse("2006-01-02", parts[3])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse expiry date: %w", err)
+		}
+	}
+
+	return root, nil
+}
+
+// String returns the trust root in the format parsed by ParseIntelTrustRoot.
+func (root *IntelTrustRoot) String() string {
+	s := root.Signet.ID + ":" + root.Signet.Scheme + ":" + base58.Encode(root.Signet.Key)
+	if !root.Expires.IsZero() {
+		s += ":" + root.Expires.Format("2006-01-02")
+	}
+	return s
+}
+
+// IntelVerifier verifies intel data against a set of trust roots and makes
+// sure that intel is only ever updated to newer versions.
+type IntelVerifier struct {
+	lock sync.Mutex
+
+	trustRoots map[string]*IntelTrustRoot
+
+	// lastVersion, lastTimestamp and lastHash describe the last accepted
+	// signed intel.
+	lastVersion   uint64
+	lastTimestamp int64
+	lastHash      []byte
+
+	// lastData and lastSignature hold the last accepted intel, so that it can
+	// be used again when newer intel cannot be loaded.
+	lastData      []byte
+	lastSignature []byte
+
+	// dbKey is the database key the last accepted intel is saved at.
+	dbKey string
+}
+
+// intelVerifierState is used to persist the last accepted intel, so that
+// older intel is also rejected after a restart and the last known good intel
+// is available when newer intel cannot be loaded.
+type intelVerifierState struct {
+	record.Base
+	sync.Mutex
+
+	Version   uint64
+	Timestamp int64
+	Hash      []byte
+
+	Data      []byte
+	Signature []byte
+}
+
+// NewIntelVerifier returns a new intel verifier with the given trust roots.
+func NewIntelVerifier(trustRoots []*IntelTrustRoot) *IntelVerifier {
+	v := &IntelVerifier{}
+	v.SetTrustRoots(trustRoots)
+	return v
+}
+
+// SetTrustRoots replaces the trust roots of the verifier.
+func (v *IntelVerifier) SetTrustRoots(trustRoots []*IntelTrustRoot) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	v.trustRoots = make(map[string]*IntelTrustRoot, len(trustRoots))
+	for _, root := range trustRoots {
+		v.trustRoots[root.Signet.ID] = root
+	}
+}
+
+// EnablePersistence loads the last accepted intel from the database and saves
+// newly accepted intel from then on.
+func (v *IntelVerifier) EnablePersistence(dbKey string) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	v.dbKey = dbKey
+
+	r, err := db.Get(dbKey)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to load last accepted intel: %w", err)
+	}
+	state, err := ensureIntelVerifierState(r)
+	if err != nil {
+		return fmt.Errorf("failed to load last accepted intel: %w", err)
+	}
+
+	// Only go forward.
+	if state.Version > v.lastVersion {
+		v.lastVersion = state.Version
+		v.lastTimestamp = state.Timestamp
+		v.lastHash = state.Hash
+	}
+	if state.Version >= v.lastVersion && v.lastData == nil {
+		v.lastData = state.Data
+		v.lastSignature = state.Signature
+	}
+	return nil
+}
+
+// LastAccepted returns the raw data and signature of the last accepted intel.
+// The signature is empty if the intel was accepted unsigned.
+func (v *IntelVerifier) LastAccepted() (data, signature []byte, ok bool) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	return v.lastData, v.lastSignature, len(v.lastData) > 0
+}
+
+// AcceptsUnsigned returns whether unsigned intel may be used. This is only the
+// case if no trust roots are configured and signed intel was never accepted.
+func (v *IntelVerifier) AcceptsUnsigned() bool {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	return len(v.trustRoots) == 0 && v.lastVersion == 0
+}
+
+// ParseUnsigned parses unsigned intel data and remembers it as the last
+// accepted intel. It fails if unsigned intel is not accepted, see
+// AcceptsUnsigned.
+func (v *IntelVerifier) ParseUnsigned(data []byte) (*Intel, error) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if len(v.trustRoots) > 0 || v.lastVersion > 0 {
+		return nil, ErrIntelUnsigned
+	}
+
+	intel, err := ParseIntel(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(data, v.lastData) {
+		v.lastData = data
+		v.lastSignature = nil
+		v.save()
+	}
+	return intel, nil
+}
+
+// Verify verifies the intel data with the given detached signature and parses
+// it. The intel must be signed by at least one trusted and unexpired key, and
+// it must be newer than previously verified intel. Verifying the same intel
+// again is allowed. Signatures by unknown keys are ignored, so that new keys
+// can be introduced before clients trust them.
+func (v *IntelVerifier) Verify(data, signature []byte) (*Intel, error) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if len(v.trustRoots) == 0 {
+		return nil, ErrIntelNoTrustRoots
+	}
+	if len(signature) == 0 {
+		return nil, ErrIntelUnsigned
+	}
+
+	// Load signature.
+	letter, err := jess.LetterFromDSD(signature)
+	if err != nil {
+		return nil, fmt.Errorf("malformed signature: %w", err)
+	}
+	if len(letter.Signatures) == 0 {
+		return nil, ErrIntelUnsigned
+	}
+
+	// Only regard signatures of trusted and unexpired keys.
+	now := time.Now()
+	trusted := make([]*jess.Seal, 0, len(letter.Signatures))
+	for _, seal := range letter.Signatures {
+		root, ok := v.trustRoots[seal.ID]
+		if !ok || (!root.Expires.IsZero() && now.After(root.Expires)) {
+			continue
+		}
+		trusted = append(trusted, seal)
+	}
+	if len(trusted) == 0 {
+		return nil, ErrIntelUntrusted
+	}
+	letter.Signatures = trusted
+
+	// Verify signatures.
+	letter.Data = data
+	if err := letter.Verify(intelSignatureRequirements, v); err != nil {
+		return nil, fmt.Errorf("failed to verify signature: %w", err)
+	}
+
+	// Parse intel.
+	intel, err := ParseIntel(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if the intel is newer.
+	hash := sha256.Sum256(data)
+	switch {
+	case intel.Version == 0 || intel.Timestamp == 0:
+		return nil, errors.New("signed intel is missing version or timestamp")
+	case time.Unix(intel.Timestamp, 0).After(now.Add(clockSkewTolerance)):
+		return nil, fmt.Errorf("intel timestamp %s is in the future", time.Unix(intel.Timestamp, 0))
+	case intel.Version == v.lastVersion && bytes.Equal(hash[:], v.lastHash):
+		// Same intel as before.
+	case intel.Version <= v.lastVersion || intel.Timestamp < v.lastTimestamp:
+		return nil, fmt.Errorf(
+			"%w: version %d (%s) is not newer than %d (%s)",
+			ErrIntelStale,
+			intel.Version, time.Unix(intel.Timestamp, 0),
+			v.lastVersion, time.Unix(v.lastTimestamp, 0),
+		)
+	}
+
+	if intel.Version != v.lastVersion || !bytes.Equal(data, v.lastData) {
+		v.lastVersion = intel.Version
+		v.lastTimestamp = intel.Timestamp
+		v.lastHash = hash[:]
+		v.lastData = data
+		v.lastSignature = signature
+		v.save()
+	}
+	return intel, nil
+}
+
+// save saves the last accepted intel, if persistence is enabled.
+// The verifier must be locked.
+func (v *IntelVerifier) save() {
+	if v.dbKey == "" {
+		return
+	}
+
+	state := &intelVerifierState{
+		Version:   v.lastVersion,
+		Timestamp: v.lastTimestamp,
+		Hash:      v.lastHash,
+		Data:      v.lastData,
+		Signature: v.lastSignature,
+	}
+	state.SetKey(v.dbKey)
+	if err := db.Put(state); err != nil {
+		log.Warningf("spn/hub: failed to save last accepted intel: %s", err)
+	}
+}
+
+// GetSignet implements the truststore interface.
+// The verifier must be locked.
+func (v *IntelVerifier) GetSignet(id string, recipient bool) (*jess.Signet, error) {
+	root, ok := v.trustRoots[id]
+	if !ok || !recipient {
+		return nil, jess.ErrSignetNotFound
+	}
+	return root.Signet, nil
+}
+
+// SignIntel creates a detached signature of the given intel data with the
+// given signing configuration.
+func SignIntel(data []byte, env *jess.Envelope) ([]byte, error) {
+	session, err := env.Correspondence(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initiate signing session: %w", err)
+	}
+	letter, err := session.Close(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign intel: %w", err)
+	}
+
+	// Detach data from signature.
+	letter.Data = nil
+
+	return letter.ToDSD(dsd.JSON)
+}
+
+// ensureIntelVerifierState makes sure a database record is an intel verifier
+// state.
+func ensureIntelVerifierState(r record.Record) (*intelVerifierState, error) {
+	// unwrap
+	if r.IsWrapped() {
+		// only allocate a new struct, if we need it
+		new := &intelVerifierState{}
+		err := record.Unwrap(r, new)
+		if err != nil {
+			return nil, err
+		}
+		return new, nil
+	}
+
+	// or adjust type
+	new, ok := r.(*intelVerifierState)
+	if !ok {
+		return nil, fmt.Errorf("record not of type *intelVerifierState, but %T", r)
+	}
+	return new, nil
+}
@@ -0,0 +1,142 @@
+package hub
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/safing/jess"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntelSigning(t *testing.T) {
+	t.Parallel()
+
+	// Generate signing keys and trust roots.
+	newKey := func(id string) (*jess.Signet, *IntelTrustRoot) {
+		signet, err := jess.GenerateSignet("Ed25519", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signet.ID = id
+		if err := signet.StoreKey(); err != nil {
+			t.Fatal(err)
+		}
+		public, err := signet.AsRecipient()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := public.StoreKey(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Check trust root parsing.
+		root, err := ParseIntelTrustRoot((&IntelTrustRoot{Signet: public}).String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signet, root
+	}
+	oldKey, oldRoot := newKey("old")
+	newKeySignet, newRoot := newKey("new")
+	unknownKey, _ := newKey("unknown")
+
+	sign := func(data string, keys ...*jess.Signet) []byte {
+		env := jess.NewUnconfiguredEnvelope()
+		env.SuiteID = jess.SuiteSignV1
+		env.Senders = keys
+		sig, err := SignIntel([]byte(data), env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+	intelData := func(version uint64, timestamp int64) string {
+		return fmt.Sprintf("Version: %d\nTimestamp: %d\n", version, timestamp)
+	}
+	now := time.Now().Unix()
+
+	// Unsigned intel is only accepted without trust roots.
+	assert.True(t, NewIntelVerifier(nil).AcceptsUnsigned())
+	v := NewIntelVerifier([]*IntelTrustRoot{oldRoot})
+	assert.False(t, v.AcceptsUnsigned())
+
+	// Unsigned and untrusted intel is rejected.
+	data := intelData(1, now-100)
+	_, err := v.Verify([]byte(data), nil)
+	assert.True(t, errors.Is(err, ErrIntelUnsigned), err)
+	_, err = v.Verify([]byte(data), sign(data, unknownKey))
+	assert.True(t, errors.Is(err, ErrIntelUntrusted), err)
+
+	// Tampered intel is rejected.
+	_, err = v.Verify([]byte(intelData(2, now-100)), sign(data, oldKey))
+	assert.Error(t, err, "tampered intel should be rejected")
+
+	// Valid intel is accepted, also again.
+	intel, err := v.Verify([]byte(data), sign(data, oldKey))
+	if assert.NoError(t, err) {
+		assert.Equal(t, uint64(1), intel.Version)
+	}
+	_, err = v.Verify([]byte(data), sign(data, oldKey))
+	assert.NoError(t, err, "same intel should be accepted again")
+
+	// Key rotation: Sign with both keys while clients still only trust the
+	// old key, then switch to the new key.
+	data = intelData(2, now-50)
+	_, err = v.Verify([]byte(data), sign(data, oldKey, newKeySignet))
+	assert.NoError(t, err, "intel signed by both keys should be accepted")
+	v.SetTrustRoots([]*IntelTrustRoot{newRoot})
+	data = intelData(3, now)
+	lastGoodData, lastGoodSig := data, sign(data, newKeySignet)
+	_, err = v.Verify([]byte(lastGoodData), lastGoodSig)
+	assert.NoError(t, err, "intel signed by the new key should be accepted")
+	_, err = v.Verify([]byte(data), sign(data, oldKey))
+	assert.True(t, errors.Is(err, ErrIntelUntrusted), err)
+
+	// Expired keys are not trusted.
+	oldRoot.Expires = time.Now().Add(-time.Hour)
+	v.SetTrustRoots([]*IntelTrustRoot{oldRoot, newRoot})
+	data = intelData(4, now)
+	_, err = v.Verify([]byte(data), sign(data, oldKey))
+	assert.True(t, errors.Is(err, ErrIntelUntrusted), err)
+
+	// Stale intel is rejected.
+	data = intelData(2, now)
+	_, err = v.Verify([]byte(data), sign(data, newKeySignet))
+	assert.True(t, errors.Is(err, ErrIntelStale), err)
+	data = intelData(5, now-1000)
+	_, err = v.Verify([]byte(data), sign(data, newKeySignet))
+	assert.True(t, errors.Is(err, ErrIntelStale), err)
+
+	// Intel without version is rejected.
+	data = intelData(0, now)
+	_, err = v.Verify([]byte(data), sign(data, newKeySignet))
+	assert.Error(t, err)
+
+	// The last accepted intel is kept and can be verified again.
+	lastData, lastSig, ok := v.LastAccepted()
+	if assert.True(t, ok) {
+		assert.Equal(t, lastGoodData, string(lastData))
+		assert.Equal(t, lastGoodSig, lastSig)
+		_, err = v.Verify(lastData, lastSig)
+		assert.NoError(t, err, "last accepted intel should be accepted again")
+	}
+
+	// Unsigned intel is not accepted anymore after signed intel was accepted.
+	v.SetTrustRoots(nil)
+	assert.False(t, v.AcceptsUnsigned())
+	_, err = v.ParseUnsigned([]byte(intelData(6, now)))
+	assert.True(t, errors.Is(err, ErrIntelUnsigned), err)
+
+	// Unsigned intel is kept as last accepted intel without a signature.
+	v = NewIntelVerifier(nil)
+	_, _, ok = v.LastAccepted()
+	assert.False(t, ok)
+	_, err = v.ParseUnsigned([]byte(intelData(1, now)))
+	assert.NoError(t, err)
+	lastData, lastSig, ok = v.LastAccepted()
+	assert.True(t, ok)
+	assert.Equal(t, intelData(1, now), string(lastData))
+	assert.Empty(t, lastSig)
+}
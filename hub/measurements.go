@@ -20,6 +20,16 @@ type Measurements struct {
 	// LatencyMeasuredAt holds when the latency was measured.
 	LatencyMeasuredAt time.Time
 
+	// Jitter designates the variation of the latency between these Hubs.
+	// It is measured together with the latency and specified in nanoseconds.
+	Jitter time.Duration
+	// LossRate designates the rate of pings between these Hubs that were not
+	// answered within the ping timeout of the latency test. As lanes use
+	// reliable transports, this measures stalls rather than packet loss.
+	// It is measured together with the latency and specified as a fraction
+	// between 0 and 1.
+	LossRate float32
+
 	// Capacity designates the available bandwidth between these Hubs.
 	// It is specified in bit/s.
 	Capacity int
@@ -34,6 +44,10 @@ type Measurements struct {
 	// The value is between 0 (other side of the world) and 100 (same location).
 	GeoProximity float32
 
+	// History holds a bounded history of measurement samples, which is used to
+	// calculate smoothed values and statistics.
+	History []*MeasurementSample
+
 	// persisted holds whether the Measurements have been persisted to the
 	// database.
 	persisted *abool.AtomicBool
@@ -52,9 +66,19 @@ func (m *Measurements) Copy() *Measurements {
 	copied := &Measurements{
 		Latency:            m.Latency,
 		LatencyMeasuredAt:  m.LatencyMeasuredAt,
+		Jitter:             m.Jitter,
+		LossRate:           m.LossRate,
 		Capacity:           m.Capacity,
 		CapacityMeasuredAt: m.CapacityMeasuredAt,
 		CalculatedCost:     m.CalculatedCost,
+		GeoProximity:       m.GeoProximity,
+	}
+	if len(m.History) > 0 {
+		copied.History = make([]*MeasurementSample, 0, len(m.History))
+		for _, sample := range m.History {
+			copiedSample := *sample
+			copied.History = append(copied.History, &copiedSample)
+		}
 	}
 	copied.check()
 	return copied
@@ -139,6 +163,35 @@ func (m *Measurements) SetLatency(latency time.Duration) {
 
 	m.Latency = latency
 	m.LatencyMeasuredAt = time.Now()
+	if latency > 0 {
+		m.addSample(&MeasurementSample{
+			Time:    m.LatencyMeasuredAt,
+			Latency: latency,
+		})
+	}
+	m.persisted.UnSet()
+}
+
+// SetLatencyTestResult sets the latency, jitter and loss rate from an active
+// latency test. If all pings timed out, the latency is zero and only the loss
+// rate is recorded.
+func (m *Measurements) SetLatencyTestResult(latency, jitter time.Duration, lossRate float32) {
+	m.Lock()
+	defer m.Unlock()
+
+	if latency > 0 {
+		m.Latency = latency
+	}
+	m.Jitter = jitter
+	m.LossRate = lossRate
+	m.LatencyMeasuredAt = time.Now()
+	m.addSample(&MeasurementSample{
+		Time:    m.LatencyMeasuredAt,
+		Latency: latency,
+		Jitter:  jitter,
+		Loss:    lossRate,
+		Active:  true,
+	})
 	m.persisted.UnSet()
 }
 
@@ -158,6 +211,12 @@ func (m *Measurements) SetCapacity(capacity int) {
 
 	m.Capacity = capacity
 	m.CapacityMeasuredAt = time.Now()
+	if capacity > 0 {
+		m.addSample(&MeasurementSample{
+			Time:     m.CapacityMeasuredAt,
+			Capacity: capacity,
+		})
+	}
 	m.persisted.UnSet()
 }
 
@@ -219,6 +278,18 @@ var (
 	measurementsRegistryLock sync.Mutex
 )
 
+// SetMeasurementsWithLockedHub sets the measurements of the Hub and shares
+// them with all users of the Hub. It is used to carry over measurements from
+// a predecessor Hub. The caller must hold the lock to Hub.
+func (h *Hub) SetMeasurementsWithLockedHub(m *Measurements) {
+	measurementsRegistryLock.Lock()
+	defer measurementsRegistryLock.Unlock()
+
+	measurementsRegistry[h.ID] = m
+	h.Measurements = m
+	h.measurementsInitialized = true
+}
+
 func getSharedMeasurements(hubID string, existing *Measurements) *Measurements {
 	measurementsRegistryLock.Lock()
 	defer measurementsRegistryLock.Unlock()
@@ -0,0 +1,281 @@
+package hub
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// MeasurementHistoryMaxSamples defines how many samples are kept in the
+	// measurement history at most.
+	MeasurementHistoryMaxSamples = 100
+
+	// MeasurementHistoryMaxAge defines how long samples are kept in the
+	// measurement history.
+	MeasurementHistoryMaxAge = 7 * 24 * time.Hour
+
+	// MeasurementSmoothingWindow defines the window that is used to calculate
+	// the smoothed latency and capacity.
+	MeasurementSmoothingWindow = 24 * time.Hour
+)
+
+// MeasurementHistoryWindows defines the rolling windows that are reported
+// for the measurement history.
+var MeasurementHistoryWindows = []time.Duration{
+	1 * time.Hour,
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+}
+
+// MeasurementSample is a single entry in the measurement history.
+// Only one of the values is set, as latency and capacity are measured
+// separately.
+type MeasurementSample struct {
+	// Time holds when the sample was taken.
+	Time time.Time
+
+	// Latency holds the measured latency, if measured.
+	Latency time.Duration `json:",omitempty"`
+
+	// Capacity holds the measured capacity in bit/s, if measured.
+	Capacity int `json:",omitempty"`
+
+	// Jitter holds the latency variation measured within a latency test.
+	Jitter time.Duration `json:",omitempty"`
+
+	// Loss holds the rate of lost pings within a latency test.
+	Loss float32 `json:",omitempty"`
+
+	// Active signifies that the sample is from an active latency test, which
+	// also measures jitter and loss.
+	Active bool `json:",omitempty"`
+
+	// Failed signifies that the measurement failed.
+	Failed bool `json:",omitempty"`
+}
+
+// MeasurementStats holds statistics of the measurement history within a
+// rolling window.
+type MeasurementStats struct {
+	// Window defines the time span the stats are calculated over.
+	Window time.Duration
+
+	// Samples and Failures count the measurements within the window.
+	Samples  int
+	Failures int
+
+	// LatencyP50 and LatencyP90 hold latency percentiles.
+	LatencyP50 time.Duration
+	LatencyP90 time.Duration
+
+	// LatencyJitter holds the mean difference between consecutive latency
+	// samples.
+	LatencyJitter time.Duration
+
+	// LatencyTests counts the active latency tests within the window.
+	LatencyTests int
+
+	// JitterP50 holds the median of the jitter measured within latency tests.
+	JitterP50 time.Duration
+
+	// LossRate holds the mean rate of timed out pings of the latency tests.
+	LossRate float32
+
+	// CapacityP50 and CapacityP10 hold capacity percentiles.
+	// The 10th percentile is the relevant one for capacity, as higher values
+	// are better.
+	CapacityP50 int
+	CapacityP10 int
+}
+
+// addSample adds a sample to the history and removes old samples.
+// The measurements must be locked.
+func (m *Measurements) addSample(sample *MeasurementSample) {
+	m.History = append(m.History, sample)
+
+	// Remove samples that are too old.
+	cutoff := sample.Time.Add(-MeasurementHistoryMaxAge)
+	var remove int
+	for remove < len(m.History) && m.History[remove].Time.Before(cutoff) {
+		remove++
+	}
+	// Remove samples that exceed the maximum.
+	if len(m.History)-remove > MeasurementHistoryMaxSamples {
+		remove = len(m.History) - MeasurementHistoryMaxSamples
+	}
+	if remove > 0 {
+		m.History = append(m.History[:0:0], m.History[remove:]...)
+	}
+}
+
+// AddFailure records a failed measurement in the history.
+func (m *Measurements) AddFailure() {
+	m.Lock()
+	defer m.Unlock()
+
+	m.addSample(&MeasurementSample{
+		Time:   time.Now(),
+		Failed: true,
+	})
+	m.persisted.UnSet()
+}
+
+// GetStats returns the statistics of the measurement history for the given
+// rolling window.
+func (m *Measurements) GetStats(window time.Duration) *MeasurementStats {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.getStats(window, time.Now())
+}
+
+// GetAllStats returns the statistics of the measurement history for all
+// default rolling windows.
+func (m *Measurements) GetAllStats() []*MeasurementStats {
+	m.Lock()
+	defer m.Unlock()
+
+	now := time.Now()
+	stats := make([]*MeasurementStats, 0, len(MeasurementHistoryWindows))
+	for _, window := range MeasurementHistoryWindows {
+		stats = append(stats, m.getStats(window, now))
+	}
+	return stats
+}
+
+func (m *Measurements) getStats(window time.Duration, now time.Time) *MeasurementStats {
+	stats := &MeasurementStats{
+		Window: window,
+	}
+	cutoff := now.Add(-window)
+
+	// Collect samples within window.
+	var (
+		latencies  []time.Duration
+		capacities []int
+		jitterSum  time.Duration
+		jitters    []time.Duration
+		lossSum    float32
+	)
+	for _, sample := range m.History {
+		if sample.Time.Before(cutoff) {
+			continue
+		}
+		stats.Samples++
+
+		// Collect jitter and loss of active latency tests.
+		if sample.Active {
+			jitters = append(jitters, sample.Jitter)
+			lossSum += sample.Loss
+		}
+
+		switch {
+		case sample.Failed:
+			stats.Failures++
+		case sample.Latency > 0:
+			if len(latencies) > 0 {
+				jitterSum += absDuration(sample.Latency - latencies[len(latencies)-1])
+			}
+			latencies = append(latencies, sample.Latency)
+		case sample.Capacity > 0:
+			capacities = append(capacities, sample.Capacity)
+		}
+	}
+
+	// Calculate latency stats.
+	if len(latencies) > 1 {
+		stats.LatencyJitter = jitterSum / time.Duration(len(latencies)-1)
+	}
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		stats.LatencyP50 = latencies[percentileIndex(len(latencies), 50)]
+		stats.LatencyP90 = latencies[percentileIndex(len(latencies), 90)]
+	}
+
+	// Calculate jitter and loss stats.
+	stats.LatencyTests = len(jitters)
+	if len(jitters) > 0 {
+		stats.LossRate = lossSum / float32(len(jitters))
+		sort.Slice(jitters, func(i, j int) bool { return jitters[i] < jitters[j] })
+		stats.JitterP50 = jitters[percentileIndex(len(jitters), 50)]
+	}
+
+	// Calculate capacity stats.
+	if len(capacities) > 0 {
+		sort.Ints(capacities)
+		stats.CapacityP50 = capacities[percentileIndex(len(capacities), 50)]
+		stats.CapacityP10 = capacities[percentileIndex(len(capacities), 10)]
+	}
+
+	return stats
+}
+
+// GetSmoothedLatency returns the median latency within the smoothing window.
+// If there is no history, the latest latency is returned.
+func (m *Measurements) GetSmoothedLatency() time.Duration {
+	m.Lock()
+	defer m.Unlock()
+
+	stats := m.getStats(MeasurementSmoothingWindow, time.Now())
+	if stats.LatencyP50 == 0 {
+		return m.Latency
+	}
+	return stats.LatencyP50
+}
+
+// GetSmoothedCapacity returns the median capacity within the smoothing window.
+// If there is no history, the latest capacity is returned.
+func (m *Measurements) GetSmoothedCapacity() int {
+	m.Lock()
+	defer m.Unlock()
+
+	stats := m.getStats(MeasurementSmoothingWindow, time.Now())
+	if stats.CapacityP50 == 0 {
+		return m.Capacity
+	}
+	return stats.CapacityP50
+}
+
+// GetSmoothedJitter returns the median jitter of the latency tests within the
+// smoothing window. If there is no history, the latest jitter is returned.
+func (m *Measurements) GetSmoothedJitter() time.Duration {
+	m.Lock()
+	defer m.Unlock()
+
+	stats := m.getStats(MeasurementSmoothingWindow, time.Now())
+	if stats.JitterP50 == 0 {
+		return m.Jitter
+	}
+	return stats.JitterP50
+}
+
+// GetSmoothedLossRate returns the mean loss rate of the latency tests within
+// the smoothing window. If there is no history, the latest loss rate is
+// returned.
+func (m *Measurements) GetSmoothedLossRate() float32 {
+	m.Lock()
+	defer m.Unlock()
+
+	stats := m.getStats(MeasurementSmoothingWindow, time.Now())
+	if stats.LatencyTests == 0 {
+		return m.LossRate
+	}
+	return stats.LossRate
+}
+
+// percentileIndex returns the index of the given percentile in a sorted
+// list with the given length, using the nearest-rank method.
+func percentileIndex(length, percentile int) int {
+	index := (length*percentile+99)/100 - 1
+	if index < 0 {
+		return 0
+	}
+	return index
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
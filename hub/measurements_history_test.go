@@ -0,0 +1,89 @@
+package hub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMeasurementHistory(t *testing.T) {
+	m := NewMeasurements()
+
+	// Check fallback without history.
+	m.Latency = 10 * time.Millisecond
+	m.Capacity = 1000
+	assert.Equal(t, 10*time.Millisecond, m.GetSmoothedLatency())
+	assert.Equal(t, 1000, m.GetSmoothedCapacity())
+
+	// Add samples with an outlier.
+	for _, latency := range []time.Duration{10, 12, 11, 100, 10} {
+		m.SetLatency(latency * time.Millisecond)
+	}
+	for _, capacity := range []int{100, 200, 300} {
+		m.SetCapacity(capacity)
+	}
+	m.AddFailure()
+	// Zero values are resets and not recorded.
+	m.SetLatency(0)
+	m.Latency = 10 * time.Millisecond
+
+	// Check smoothed values.
+	assert.Equal(t, 11*time.Millisecond, m.GetSmoothedLatency())
+	assert.Equal(t, 200, m.GetSmoothedCapacity())
+
+	// Check stats.
+	stats := m.GetStats(time.Hour)
+	assert.Equal(t, 9, stats.Samples)
+	assert.Equal(t, 1, stats.Failures)
+	assert.Equal(t, 11*time.Millisecond, stats.LatencyP50)
+	assert.Equal(t, 100*time.Millisecond, stats.LatencyP90)
+	assert.Equal(t, (2+1+89+90)*time.Millisecond/4, stats.LatencyJitter)
+	assert.Equal(t, 200, stats.CapacityP50)
+	assert.Equal(t, 100, stats.CapacityP10)
+	assert.Len(t, m.GetAllStats(), len(MeasurementHistoryWindows))
+
+	// Check that the copy is independent.
+	copied := m.Copy()
+	copied.History[0].Latency = 0
+	assert.Equal(t, 10*time.Millisecond, m.History[0].Latency)
+
+	// Check bounds.
+	for i := 0; i < MeasurementHistoryMaxSamples*2; i++ {
+		m.SetCapacity(i + 1)
+	}
+	assert.Len(t, m.History, MeasurementHistoryMaxSamples)
+	m.Lock()
+	m.History[0].Time = time.Now().Add(-2 * MeasurementHistoryMaxAge)
+	m.addSample(&MeasurementSample{Time: time.Now(), Capacity: 1})
+	m.Unlock()
+	assert.Len(t, m.History, MeasurementHistoryMaxSamples)
+	assert.True(t, m.History[0].Time.After(time.Now().Add(-time.Hour)))
+}
+
+func TestMeasurementLatencyTests(t *testing.T) {
+	m := NewMeasurements()
+
+	// Check fallback without history.
+	m.Jitter = 3 * time.Millisecond
+	m.LossRate = 0.5
+	assert.Equal(t, 3*time.Millisecond, m.GetSmoothedJitter())
+	assert.Equal(t, float32(0.5), m.GetSmoothedLossRate())
+
+	// Passive samples do not carry jitter and loss.
+	m.SetLatency(10 * time.Millisecond)
+	assert.Equal(t, float32(0.5), m.GetSmoothedLossRate())
+
+	// Add latency test results, including one where all pings were lost.
+	m.SetLatencyTestResult(10*time.Millisecond, 1*time.Millisecond, 0)
+	m.SetLatencyTestResult(12*time.Millisecond, 2*time.Millisecond, 0.1)
+	m.SetLatencyTestResult(0, 0, 1)
+	assert.Equal(t, 12*time.Millisecond, m.Latency)
+
+	stats := m.GetStats(time.Hour)
+	assert.Equal(t, 3, stats.LatencyTests)
+	assert.Equal(t, 1*time.Millisecond, stats.JitterP50)
+	assert.InDelta(t, 1.1/3, stats.LossRate, 0.0001)
+	assert.Equal(t, 1*time.Millisecond, m.GetSmoothedJitter())
+	assert.InDelta(t, 1.1/3, m.GetSmoothedLossRate(), 0.0001)
+}
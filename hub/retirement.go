@@ -0,0 +1,130 @@
+package hub
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/safing/jess/lhash"
+	"github.com/safing/portbase/formats/dsd"
+)
+
+// ErrHubRetired is returned when a message of a retired Hub is received.
+var ErrHubRetired = errors.New("hub is retired")
+
+// Retirement is a signed statement of a Hub that it is permanently retired.
+// Retired Hubs are removed from the network and further messages of them are
+// rejected.
+type Retirement struct {
+	// ID is the ID of the retiring Hub.
+	// It is part of the message in order to sign it.
+	ID string
+
+	// Timestamp is the Unix timestamp in seconds of the retirement.
+	Timestamp int64
+
+	// Successor optionally holds the ID of the Hub that replaces the retired
+	// Hub.
+	Successor string `json:",omitempty"`
+
+	// Reason optionally holds a human readable reason for the retirement.
+	Reason string `json:",omitempty"`
+}
+
+// Export exports the retirement signed by the given signer.
+func (r *Retirement) Export(signer Signer) ([]byte, error) {
+	// pack
+	msg, err := dsd.Dump(r, dsd.JSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack retirement: %w", err)
+	}
+
+	return SignHubMsg(msg, signer, false)
+}
+
+// Retired returns whether the Hub is retired.
+func (h *Hub) Retired() bool {
+	h.Lock()
+	defer h.Unlock()
+
+	return h.Retirement != nil
+}
+
+// ApplyRetirement applies a retirement if it passes all the checks.
+// Retirements are only accepted from known Hubs, as they must be verified
+// against the public key of the Hub.
+func ApplyRetirement(existingHub *Hub, data []byte, mapName string, selfcheck bool) (hub *Hub, changed bool, err error) {
+	// open and verify
+	var msg []byte
+	msg, hub, _, err = OpenHubMsg(existingHub, data, mapName, false)
+
+	// Lock hub if we have one.
+	if hub != nil && !selfcheck {
+		hub.Lock()
+		defer hub.Unlock()
+	}
+
+	// Check if there was an error with the Hub msg.
+	if err != nil {
+		return
+	}
+
+	// parse
+	retirement := &Retirement{}
+	_, err = dsd.Load(msg, retirement)
+	if err != nil {
+		err = fmt.Errorf("%w: failed to parse retirement: %s", ErrInvalidHubMsg, err)
+		return
+	}
+
+	// Check if the Hub is already retired.
+	// Retirement is permanent, so the first retirement stays.
+	if hub.Retirement != nil {
+		err = fmt.Errorf("%wretirement of %s was already received", ErrOldData, hub.ID)
+		return
+	}
+
+	// Validate the retirement.
+	err = hub.validateRetirement(retirement)
+	if err != nil {
+		err = fmt.Errorf("failed to validate retirement of %s: %w", hub.ID, err)
+		return
+	}
+
+	hub.Retirement = retirement
+	changed = true
+	return
+}
+
+func (hub *Hub) validateRetirement(retirement *Retirement) error {
+	// value formatting
+	if err := checkStringFormat("Reason", retirement.Reason, 255); err != nil {
+		return err
+	}
+
+	// integrity check
+	if hub.ID != retirement.ID {
+		return fmt.Errorf("retirement ID %q mismatches hub ID %q", retirement.ID, hub.ID)
+	}
+
+	// check timestamp
+	if retirement.Timestamp > time.Now().Add(clockSkewTolerance).Unix() {
+		return fmt.Errorf(
+			"retirement from %s @ %s is from the future",
+			hub.ID,
+			time.Unix(retirement.Timestamp, 0),
+		)
+	}
+
+	// check successor
+	if retirement.Successor != "" {
+		if retirement.Successor == hub.ID {
+			return errors.New("hub cannot be its own successor")
+		}
+		if _, err := lhash.FromBase58(retirement.Successor); err != nil {
+			return fmt.Errorf("successor hub ID is invalid: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package hub
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/safing/jess"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHubRetirement(t *testing.T) {
+	t.Parallel()
+
+	signet, h := createTestIdentity(t)
+	otherSignet, successor := createTestIdentity(t)
+
+	export := func(signet *jess.Signet, retirement *Retirement) []byte {
+		data, err := retirement.Export(NewSigner(signet))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	// Forged retirements are rejected.
+	_, _, err := ApplyRetirement(h, export(otherSignet, &Retirement{
+		ID:        h.ID,
+		Timestamp: time.Now().Unix(),
+	}), "test", false)
+	assert.Error(t, err, "retirement signed by another key should be rejected")
+	_, _, err = ApplyRetirement(h, export(signet, &Retirement{
+		ID:        successor.ID,
+		Timestamp: time.Now().Unix(),
+	}), "test", false)
+	assert.Error(t, err, "retirement with mismatching ID should be rejected")
+	_, _, err = ApplyRetirement(h, export(signet, &Retirement{
+		ID:        h.ID,
+		Timestamp: time.Now().Unix(),
+		Successor: h.ID,
+	}), "test", false)
+	assert.Error(t, err, "retirement naming itself as successor should be rejected")
+	assert.False(t, h.Retired())
+
+	// Valid retirement is applied.
+	_, changed, err := ApplyRetirement(h, export(signet, &Retirement{
+		ID:        h.ID,
+		Timestamp: time.Now().Unix(),
+		Successor: successor.ID,
+		Reason:    "moved to new server",
+	}), "test", false)
+	if assert.NoError(t, err) {
+		assert.True(t, changed)
+		assert.True(t, h.Retired())
+		assert.Equal(t, successor.ID, h.Retirement.Successor)
+	}
+
+	// Further retirements and status updates are rejected as old data.
+	_, changed, err = ApplyRetirement(h, export(signet, &Retirement{
+		ID:        h.ID,
+		Timestamp: time.Now().Unix(),
+	}), "test", false)
+	assert.True(t, errors.Is(err, ErrOldData), err)
+	assert.False(t, changed)
+
+	statusData, err := (&Status{Timestamp: time.Now().Unix()}).Export(NewSigner(signet))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, changed, err = ApplyStatus(h, statusData, "test", ScopePublic, false)
+	assert.True(t, errors.Is(err, ErrOldData), err)
+	assert.False(t, changed)
+}
+
+// createTestIdentity creates a new identity key and the matching Hub.
+func createTestIdentity(t *testing.T) (*jess.Signet, *Hub) {
+	t.Helper()
+
+	signet, err := jess.GenerateSignet("Ed25519", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := signet.StoreKey(); err != nil {
+		t.Fatal(err)
+	}
+	public, err := signet.AsRecipient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := public.StoreKey(); err != nil {
+		t.Fatal(err)
+	}
+	public.ID = createHubID(public.Scheme, public.Key)
+	signet.ID = public.ID
+
+	return signet, &Hub{
+		ID:        public.ID,
+		Map:       "test",
+		PublicKey: public,
+	}
+}
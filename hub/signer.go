@@ -0,0 +1,81 @@
+package hub
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/safing/jess"
+)
+
+// Signer signs messages with one or more Hub identity keys. It enables keeping
+// identity keys outside of the Hub process.
+type Signer interface {
+	// Sign signs the given data and returns the signed letter.
+	Sign(data []byte) (*jess.Letter, error)
+
+	// PublicKeys returns the stored public keys of all signing keys.
+	PublicKeys() ([]*jess.Signet, error)
+}
+
+// EnvelopeSigner is a Signer that signs in-process with the senders of a
+// jess envelope.
+type EnvelopeSigner struct {
+	Envelope *jess.Envelope
+}
+
+// NewSigner returns a new in-process Signer that signs with the given private
+// keys.
+func NewSigner(signets ...*jess.Signet) *EnvelopeSigner {
+	env := jess.NewUnconfiguredEnvelope()
+	env.SuiteID = jess.SuiteSignV1
+	env.Senders = signets
+
+	return &EnvelopeSigner{
+		Envelope: env,
+	}
+}
+
+// Sign signs the given data and returns the signed letter.
+func (s *EnvelopeSigner) Sign(data []byte) (*jess.Letter, error) {
+	// start session from envelope
+	session, err := s.Envelope.Correspondence(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initiate signing session: %w", err)
+	}
+	// sign the data
+	letter, err := session.Close(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign msg: %w", err)
+	}
+
+	return letter, nil
+}
+
+// PublicKeys returns the stored public keys of all signing keys.
+func (s *EnvelopeSigner) PublicKeys() ([]*jess.Signet, error) {
+	keys := make([]*jess.Signet, 0, len(s.Envelope.Senders))
+	for _, sender := range s.Envelope.Senders {
+		// get public key
+		public, err := sender.AsRecipient()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get public key of %s: %w", sender.ID, err)
+		}
+		// serialize key
+		err = public.StoreKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize public key %s: %w", sender.ID, err)
+		}
+		keys = append(keys, public)
+	}
+
+	return keys, nil
+}
+
+// IDFromPublicKey returns the Hub ID of the given stored public key.
+func IDFromPublicKey(key *jess.Signet) (string, error) {
+	if key == nil || key.Scheme == "" || len(key.Key) == 0 {
+		return "", errors.New("public key is not stored")
+	}
+
+	return createHubID(key.Scheme, key.Key), nil
+}
@@ -49,6 +49,18 @@ type Lane struct {
 	// Lateny designates the latency between these Hubs.
 	// It is specified in nanoseconds.
 	Latency time.Duration
+
+	// LossRate designates the rate of pings between these Hubs that timed out
+	// in latency tests. It is specified as a fraction between 0 and 1.
+	LossRate float32 `json:",omitempty"`
+
+	// Jitter designates the variation of the latency between these Hubs.
+	// It is specified in nanoseconds.
+	Jitter time.Duration `json:",omitempty"`
+
+	// Age designates how long the connection between these Hubs has been
+	// established. It is reported in coarse steps only.
+	Age time.Duration `json:",omitempty"`
 }
 
 // Copy returns a deep copy of the Status.
@@ -181,6 +193,12 @@ func (l *Lane) Equal(other *Lane) bool {
 		return false
 	case l.Latency != other.Latency:
 		return false
+	case l.LossRate != other.LossRate:
+		return false
+	case l.Jitter != other.Jitter:
+		return false
+	case l.Age != other.Age:
+		return false
 	}
 	return true
 }
@@ -211,13 +229,58 @@ func (s *Status) validateFormatting() (err error) {
 		if err = checkStringFormat("Lanes.ID", lanes.ID, 255); err != nil {
 			return err
 		}
+		if !(lanes.LossRate >= 0 && lanes.LossRate <= 1) {
+			return fmt.Errorf("field Lanes.LossRate with value %f is out of range", lanes.LossRate)
+		}
+		if lanes.Jitter < 0 {
+			return fmt.Errorf("field Lanes.Jitter with value %d is negative", lanes.Jitter)
+		}
+		if lanes.Age < 0 {
+			return fmt.Errorf("field Lanes.Age with value %d is negative", lanes.Age)
+		}
 	}
 
 	return nil
 }
 
 func (l *Lane) String() string {
-	return fmt.Sprintf("<%s cap=%d lat=%d>", l.ID, l.Capacity, l.Latency)
+	return fmt.Sprintf(
+		"<%s cap=%d lat=%d loss=%.3f jit=%d age=%s>",
+		l.ID, l.Capacity, l.Latency, l.LossRate, l.Jitter, l.Age,
+	)
+}
+
+// laneAgeSteps defines the steps lane ages are reported in. Reporting in
+// coarse steps prevents the status from changing on every publish.
+var laneAgeSteps = []time.Duration{
+	30 * 24 * time.Hour,
+	7 * 24 * time.Hour,
+	24 * time.Hour,
+	time.Hour,
+	10 * time.Minute,
+}
+
+// RoundLaneAge rounds the given lane age down to a reporting step.
+func RoundLaneAge(age time.Duration) time.Duration {
+	for _, step := range laneAgeSteps {
+		if age >= step {
+			return step
+		}
+	}
+	return 0
+}
+
+// RoundLaneLossRate rounds the given loss rate to whole percents in order to
+// prevent the status from changing on small variations.
+func RoundLaneLossRate(lossRate float32) float32 {
+	switch {
+	case lossRate <= 0:
+		return 0
+	case lossRate >= 1:
+		return 1
+	default:
+		return float32(int(lossRate*100+0.5)) / 100
+	}
 }
 
 // LanesEqual returns whether the given []*Lane are equal.
@@ -0,0 +1,318 @@
+package hub
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/safing/portbase/database"
+	"github.com/safing/portbase/database/record"
+	"github.com/safing/portbase/formats/dsd"
+)
+
+// MsgTypeStatusDelta is the message type of the stored status delta chain.
+const MsgTypeStatusDelta = "statusdelta"
+
+// maxStatusDeltaChainLength defines how many deltas are stored after a full
+// Status. Hubs publish a full Status at least every hour and deltas are rate
+// limited, so the chain should never get this long.
+const maxStatusDeltaChainLength = 100
+
+// StatusDelta is a compact update of a Status. It references the Status it is
+// based on by its timestamp and only carries the changes to it. Receivers
+// that do not have the referenced Status cannot apply the delta and need to
+// request the stored full Status and deltas from a neighbor or wait for the
+// next full Status.
+type StatusDelta struct {
+	// BaseTimestamp is the timestamp of the Status the delta is based on.
+	// It is always set and distinguishes a delta from a full Status.
+	BaseTimestamp int64
+
+	// Timestamp is the timestamp of the resulting Status.
+	Timestamp int64
+
+	// Version holds the new software version, if it changed.
+	Version string `json:",omitempty"`
+
+	// Load holds the new load, if it changed.
+	Load *int `json:",omitempty"`
+
+	// Keys holds the added and changed keys.
+	Keys map[string]*Key `json:",omitempty"`
+	// RemovedKeys holds the IDs of the removed keys.
+	RemovedKeys []string `json:",omitempty"`
+
+	// Lanes holds the added and changed lanes.
+	Lanes []*Lane `json:",omitempty"`
+	// RemovedLanes holds the Hub IDs of the removed lanes.
+	RemovedLanes []string `json:",omitempty"`
+}
+
+// MakeStatusDelta returns the changes from the base to the given status.
+func MakeStatusDelta(base, status *Status) *StatusDelta {
+	delta := &StatusDelta{
+		BaseTimestamp: base.Timestamp,
+		Timestamp:     status.Timestamp,
+	}
+
+	// Version and load.
+	if status.Version != base.Version {
+		delta.Version = status.Version
+	}
+	if status.Load != base.Load {
+		load := status.Load
+		delta.Load = &load
+	}
+
+	// Keys.
+	for keyID, key := range status.Keys {
+		if !key.Equal(base.Keys[keyID]) {
+			if delta.Keys == nil {
+				delta.Keys = make(map[string]*Key)
+			}
+			delta.Keys[keyID] = key
+		}
+	}
+	for keyID := range base.Keys {
+		if _, ok := status.Keys[keyID]; !ok {
+			delta.RemovedKeys = append(delta.RemovedKeys, keyID)
+		}
+	}
+
+	// Lanes.
+	baseLanes := make(map[string]*Lane, len(base.Lanes))
+	for _, lane := range base.Lanes {
+		baseLanes[lane.ID] = lane
+	}
+	for _, lane := range status.Lanes {
+		if !lane.Equal(baseLanes[lane.ID]) {
+			delta.Lanes = append(delta.Lanes, lane)
+		}
+		delete(baseLanes, lane.ID)
+	}
+	for _, lane := range base.Lanes {
+		if _, ok := baseLanes[lane.ID]; ok {
+			delta.RemovedLanes = append(delta.RemovedLanes, lane.ID)
+		}
+	}
+
+	return delta
+}
+
+// Apply applies the delta to a copy of the given base and returns it.
+func (d *StatusDelta) Apply(base *Status) (*Status, error) {
+	// Check if the delta references the given base.
+	if base == nil {
+		return nil, fmt.Errorf("%w: status delta @ %d requires base @ %d", ErrMissingStatusBase, d.Timestamp, d.BaseTimestamp)
+	}
+	if base.Timestamp != d.BaseTimestamp {
+		return nil, fmt.Errorf(
+			"%w: status delta @ %d requires base @ %d, but current status is @ %d",
+			ErrMissingStatusBase, d.Timestamp, d.BaseTimestamp, base.Timestamp,
+		)
+	}
+
+	status, err := base.Copy()
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy base status: %w", err)
+	}
+	status.Timestamp = d.Timestamp
+
+	// Version and load.
+	if d.Version != "" {
+		status.Version = d.Version
+	}
+	if d.Load != nil {
+		status.Load = *d.Load
+	}
+
+	// Keys.
+	for _, keyID := range d.RemovedKeys {
+		delete(status.Keys, keyID)
+	}
+	for keyID, key := range d.Keys {
+		if status.Keys == nil {
+			status.Keys = make(map[string]*Key)
+		}
+		status.Keys[keyID] = key
+	}
+
+	// Lanes.
+	lanes := make(map[string]*Lane, len(status.Lanes)+len(d.Lanes))
+	for _, lane := range status.Lanes {
+		lanes[lane.ID] = lane
+	}
+	for _, hubID := range d.RemovedLanes {
+		delete(lanes, hubID)
+	}
+	for _, lane := range d.Lanes {
+		lanes[lane.ID] = lane
+	}
+	status.Lanes = make([]*Lane, 0, len(lanes))
+	for _, lane := range lanes {
+		status.Lanes = append(status.Lanes, lane)
+	}
+	SortLanes(status.Lanes)
+
+	return status, nil
+}
+
+// validateFormatting check if all values conform to the basic format.
+func (d *StatusDelta) validateFormatting() error {
+	if d.BaseTimestamp == 0 {
+		return errors.New("missing base timestamp")
+	}
+	if d.Timestamp <= d.BaseTimestamp {
+		return fmt.Errorf("timestamp @ %d is not after base timestamp @ %d", d.Timestamp, d.BaseTimestamp)
+	}
+	if len(d.RemovedKeys) > 255 {
+		return fmt.Errorf("field RemovedKeys with array/slice length of %d exceeds max length of %d", len(d.RemovedKeys), 255)
+	}
+	if len(d.RemovedLanes) > 255 {
+		return fmt.Errorf("field RemovedLanes with array/slice length of %d exceeds max length of %d", len(d.RemovedLanes), 255)
+	}
+
+	// The added and changed keys and lanes are checked with the resulting status.
+	return nil
+}
+
+// Export exports the status delta signed by the given signer.
+func (d *StatusDelta) Export(signer Signer) ([]byte, error) {
+	// pack
+	msg, err := dsd.Dump(d, dsd.JSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack status delta: %s", err)
+	}
+
+	return SignHubMsg(msg, signer, false)
+}
+
+// loadStatusMsg parses a status msg, which is either a full Status or a
+// StatusDelta.
+func loadStatusMsg(msg []byte) (status *Status, delta *StatusDelta, err error) {
+	// Check if the msg is a delta.
+	// Full statuses do not have a base timestamp.
+	delta = &StatusDelta{}
+	_, err = dsd.Load(msg, delta)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: failed to parse status: %s", ErrInvalidHubMsg, err)
+	}
+	if delta.BaseTimestamp != 0 {
+		err = delta.validateFormatting()
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid status delta: %w", err)
+		}
+		return nil, delta, nil
+	}
+
+	// Parse full status.
+	status = &Status{}
+	_, err = dsd.Load(msg, status)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: failed to parse status: %s", ErrInvalidHubMsg, err)
+	}
+	return status, nil, nil
+}
+
+// statusDeltaChain stores the raw deltas that were applied after the stored
+// full Status of a Hub. As deltas are signed by the Hub, the stored full Status
+// and its deltas are the signed form of the current Status, which can be
+// passed on to other Hubs.
+type statusDeltaChain struct {
+	record.Base
+	sync.Mutex
+
+	Deltas [][]byte
+}
+
+// SaveHubStatusDelta appends a raw (and signed) status delta to the stored
+// delta chain of the Hub. It must only be called after the delta was applied.
+func SaveHubStatusDelta(id string, mapName string, data []byte) error {
+	key := MakeHubMsgDBKey(mapName, MsgTypeStatusDelta, id)
+
+	// Get existing chain.
+	chain := &statusDeltaChain{}
+	r, err := db.Get(key)
+	switch {
+	case err == nil:
+		chain, err = ensureStatusDeltaChain(r)
+		if err != nil {
+			return err
+		}
+	case !errors.Is(err, database.ErrNotFound):
+		return err
+	}
+
+	// If the chain gets too long, drop it. Hubs will then need to wait for
+	// the next full Status.
+	if len(chain.Deltas) >= maxStatusDeltaChainLength {
+		return deleteHubStatusDeltas(mapName, id)
+	}
+
+	chain.Deltas = append(chain.Deltas, data)
+	chain.SetKey(key)
+	return db.Put(chain)
+}
+
+// GetHubStatusDeltas returns the stored raw deltas that were applied after the
+// stored full Status of the Hub.
+func GetHubStatusDeltas(mapName string, id string) ([][]byte, error) {
+	r, err := db.Get(MakeHubMsgDBKey(mapName, MsgTypeStatusDelta, id))
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	chain, err := ensureStatusDeltaChain(r)
+	if err != nil {
+		return nil, err
+	}
+	return chain.Deltas, nil
+}
+
+func deleteHubStatusDeltas(mapName string, id string) error {
+	err := db.Delete(MakeHubMsgDBKey(mapName, MsgTypeStatusDelta, id))
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// ensureStatusDeltaChain makes sure a database record is a status delta chain.
+func ensureStatusDeltaChain(r record.Record) (*statusDeltaChain, error) {
+	// unwrap
+	if r.IsWrapped() {
+		// only allocate a new struct, if we need it
+		new := &statusDeltaChain{}
+		err := record.Unwrap(r, new)
+		if err != nil {
+			return nil, err
+		}
+		return new, nil
+	}
+
+	// or adjust type
+	new, ok := r.(*statusDeltaChain)
+	if !ok {
+		return nil, fmt.Errorf("record not of type *statusDeltaChain, but %T", r)
+	}
+	return new, nil
+}
+
+// Equal returns whether the Key is equal to the given one.
+func (k *Key) Equal(other *Key) bool {
+	switch {
+	case k == nil || other == nil:
+		return false
+	case k.Scheme != other.Scheme:
+		return false
+	case !bytes.Equal(k.Key, other.Key):
+		return false
+	case k.Expires != other.Expires:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,165 @@
+package hub
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/safing/portbase/formats/dsd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusDelta(t *testing.T) {
+	t.Parallel()
+
+	signet, h := createTestIdentity(t)
+	h.Info = &Announcement{Name: "test"}
+	signer := NewSigner(signet)
+	now := time.Now().Unix()
+
+	apply := func(data []byte) (changed bool, err error) {
+		_, _, changed, err = ApplyStatus(h, data, "test", ScopePublic, false)
+		return changed, err
+	}
+
+	// Apply base status.
+	base := &Status{
+		Timestamp: now - 10,
+		Version:   "0.1.0",
+		Keys: map[string]*Key{
+			"a": {Scheme: "ECDH-X25519", Key: []byte{1}, Expires: now + 100},
+			"b": {Scheme: "ECDH-X25519", Key: []byte{2}, Expires: now + 200},
+		},
+		Lanes: []*Lane{
+			{ID: "hub-1", Capacity: 1000, Latency: 10 * time.Millisecond},
+			{ID: "hub-2", Capacity: 2000, Latency: 20 * time.Millisecond},
+			{ID: "hub-3", Capacity: 3000, Latency: 30 * time.Millisecond},
+		},
+	}
+	baseData, err := base.Export(signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = apply(baseData)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Change a single lane, remove and add lanes and rotate a key.
+	updated, err := base.Copy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated.Timestamp = now - 5
+	updated.Load = 80
+	delete(updated.Keys, "a")
+	updated.Keys["c"] = &Key{Scheme: "ECDH-X25519", Key: []byte{3}, Expires: now + 300}
+	updated.Lanes = []*Lane{
+		{ID: "hub-0", Capacity: 500, Latency: 5 * time.Millisecond},
+		{ID: "hub-1", Capacity: 1000, Latency: 15 * time.Millisecond},
+		{ID: "hub-3", Capacity: 3000, Latency: 30 * time.Millisecond},
+	}
+	delta := MakeStatusDelta(base, updated)
+	assert.Equal(t, []string{"a"}, delta.RemovedKeys)
+	assert.Len(t, delta.Keys, 1)
+	assert.Equal(t, []string{"hub-2"}, delta.RemovedLanes)
+	assert.Len(t, delta.Lanes, 2)
+	assert.Empty(t, delta.Version)
+
+	deltaData, err := delta.Export(signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Less(t, len(deltaData), len(baseData), "delta should be smaller than full status")
+
+	// Apply delta and check the result.
+	changed, err := apply(deltaData)
+	if assert.NoError(t, err) {
+		assert.True(t, changed)
+		assert.False(t, h.InvalidStatus)
+		assert.Equal(t, updated.Timestamp, h.Status.Timestamp)
+		assert.Equal(t, 80, h.Status.Load)
+		assert.Equal(t, "0.1.0", h.Status.Version)
+		assert.Equal(t, updated.Keys, h.Status.Keys)
+		assert.True(t, LanesEqual(updated.Lanes, h.Status.Lanes), "lanes should match")
+	}
+
+	// Applying the same delta again does not change anything.
+	changed, err = apply(deltaData)
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	// A delta with a missing base is not applied.
+	skipped := MakeStatusDelta(&Status{Timestamp: now - 3}, &Status{Timestamp: now - 2})
+	skippedData, err := skipped.Export(signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = apply(skippedData)
+	assert.True(t, errors.Is(err, ErrMissingStatusBase), "delta without base should be rejected")
+	assert.False(t, h.InvalidStatus, "missing base should not mark status as invalid")
+	assert.Equal(t, updated.Timestamp, h.Status.Timestamp)
+
+	// The full status is applied as a fallback.
+	full, err := updated.Copy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	full.Timestamp = now
+	fullData, err := full.Export(signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed, err = apply(fullData)
+	if assert.NoError(t, err) {
+		assert.True(t, changed)
+		assert.Equal(t, now, h.Status.Timestamp)
+	}
+
+	// Old deltas are rejected as old data.
+	_, err = apply(deltaData)
+	assert.True(t, errors.Is(err, ErrOldData), "old delta should be rejected as old data")
+}
+
+func TestLaneQualityFormat(t *testing.T) {
+	t.Parallel()
+
+	// Lanes from Hubs without quality metrics are decoded with zero values.
+	status := &Status{}
+	_, err := dsd.Load([]byte(`J{"Version":"1.0.0","Lanes":[{"ID":"hub-1","Capacity":1000,"Latency":10000000}]}`), status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, &Lane{ID: "hub-1", Capacity: 1000, Latency: 10 * time.Millisecond}, status.Lanes[0])
+	assert.NoError(t, status.validateFormatting())
+
+	// Quality metrics survive a round trip and are part of equality.
+	lane := &Lane{
+		ID:       "hub-1",
+		Capacity: 1000,
+		Latency:  10 * time.Millisecond,
+		LossRate: RoundLaneLossRate(0.0234),
+		Jitter:   2 * time.Millisecond,
+		Age:      RoundLaneAge(3 * time.Hour),
+	}
+	assert.Equal(t, float32(0.02), lane.LossRate)
+	assert.Equal(t, time.Hour, lane.Age)
+	data, err := dsd.Dump(&Status{Version: "1.0.0", Lanes: []*Lane{lane}}, dsd.JSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status = &Status{}
+	_, err = dsd.Load(data, status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, lane.Equal(status.Lanes[0]))
+	assert.False(t, lane.Equal(&Lane{ID: "hub-1", Capacity: 1000, Latency: 10 * time.Millisecond}))
+
+	// Out of range values are rejected.
+	status.Lanes[0].LossRate = 1.5
+	assert.Error(t, status.validateFormatting())
+	status.Lanes[0].LossRate = 0
+	status.Lanes[0].Jitter = -1
+	assert.Error(t, status.validateFormatting())
+}
@@ -0,0 +1,207 @@
+package hub
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/safing/jess"
+	"github.com/safing/portbase/database"
+	"github.com/safing/portbase/formats/dsd"
+)
+
+// MsgTypeSuccession is the message type of identity successions.
+const MsgTypeSuccession = "succession"
+
+// successionReason is the retirement reason of Hubs that rotated their key.
+const successionReason = "identity key rotation"
+
+// Succession is a statement of a Hub that it continues under a new identity
+// key, and therefore a new ID. It is signed by both the old and the new key,
+// so that it can neither be forged by the old nor the new key alone.
+// The Hub with the old ID is retired and other Hubs migrate its history to
+// the Hub with the new ID.
+type Succession struct {
+	// ID is the ID of the predecessor Hub, ie. the old key.
+	ID string
+
+	// Successor is the ID of the successor Hub, ie. the new key.
+	Successor string
+
+	// Timestamp is the Unix timestamp in seconds of the succession.
+	Timestamp int64
+}
+
+// Export exports the succession signed with the old and the new identity
+// key. The public key of the successor is attached to the message.
+func (s *Succession) Export(predecessor, successor *jess.Signet) ([]byte, error) {
+	// pack
+	msg, err := dsd.Dump(s, dsd.JSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack succession: %w", err)
+	}
+
+	// Sign with both keys.
+	return SignHubMsg(msg, NewSigner(predecessor, successor), true)
+}
+
+// ApplySuccession applies a succession if it passes all the checks.
+// The predecessor must be known. If no successor is provided, it is loaded
+// from the database or created. The predecessor is retired, but the caller is
+// responsible for saving both Hubs.
+func ApplySuccession(existingHub, existingSuccessor *Hub, data []byte, mapName string, selfcheck bool) (predecessor, successor *Hub, changed bool, err error) {
+	letter, err := jess.LetterFromDSD(data)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("%w: malformed letter: %s", ErrInvalidHubMsg, err)
+	}
+
+	// Parse succession before verifying in order to get the involved IDs.
+	succession := &Succession{}
+	_, err = dsd.Load(letter.Data, succession)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("%w: failed to parse succession: %s", ErrInvalidHubMsg, err)
+	}
+
+	// Check signatures.
+	// The order of the signatures depends on the signing tools.
+	var predecessorSeal, successorSeal *jess.Seal
+	for _, seal := range letter.Signatures {
+		switch seal.ID {
+		case succession.ID:
+			predecessorSeal = seal
+		case succession.Successor:
+			successorSeal = seal
+		}
+	}
+	if len(letter.Signatures) != 2 || predecessorSeal == nil || successorSeal == nil {
+		return nil, nil, false, fmt.Errorf("%w: succession must be signed by the predecessor and the successor", ErrInvalidHubMsg)
+	}
+
+	// Get predecessor.
+	predecessor = existingHub
+	if predecessor == nil {
+		predecessor, err = GetHub(mapName, succession.ID)
+		if err != nil {
+			return nil, nil, false, fmt.Errorf("failed to get predecessor hub %s: %w", succession.ID, err)
+		}
+	}
+	if predecessor.ID != succession.ID {
+		return nil, nil, false, fmt.Errorf("succession ID %q mismatches hub ID %q", succession.ID, predecessor.ID)
+	}
+
+	// Lock predecessor.
+	if !selfcheck {
+		predecessor.Lock()
+		defer predecessor.Unlock()
+	}
+
+	// Check predecessor key.
+	if predecessor.PublicKey == nil {
+		return nil, nil, false, fmt.Errorf("predecessor hub %s has no known public key", succession.ID)
+	}
+	if !verifyHubID(predecessor.ID, predecessor.PublicKey.Scheme, predecessor.PublicKey.Key) {
+		return nil, nil, false, fmt.Errorf("ID integrity of %s violated with existing key", predecessor.ID)
+	}
+
+	// Check if the predecessor is already retired.
+	if predecessor.Retirement != nil {
+		if predecessor.Succession != nil && predecessor.Succession.Successor == succession.Successor {
+			return predecessor, nil, false, fmt.Errorf("%wsuccession of %s was already received", ErrOldData, predecessor.ID)
+		}
+		return predecessor, nil, false, fmt.Errorf("%s: %w", predecessor.ID, ErrHubRetired)
+	}
+
+	// Get successor key from the attached keys.
+	var successorKey *jess.Signet
+	for _, key := range letter.Keys {
+		if verifyHubID(succession.Successor, successorSeal.Scheme, key.Value) {
+			successorKey = &jess.Signet{
+				ID:     succession.Successor,
+				Scheme: successorSeal.Scheme,
+				Key:    key.Value,
+				Public: true,
+			}
+			break
+		}
+	}
+	if successorKey == nil {
+		return nil, nil, false, fmt.Errorf("%w: missing key of successor %s", ErrInvalidHubMsg, succession.Successor)
+	}
+	err = successorKey.LoadKey()
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	// Verify signatures of both keys.
+	truststore := jess.NewMemTrustStore()
+	if err := truststore.StoreSignet(predecessor.PublicKey); err != nil {
+		return nil, nil, false, err
+	}
+	if err := truststore.StoreSignet(successorKey); err != nil {
+		return nil, nil, false, err
+	}
+	letter.Keys = nil
+	err = letter.Verify(hubMsgRequirements, truststore)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("%w: %s", ErrInvalidHubMsg, err)
+	}
+
+	// Validate the succession.
+	err = validateSuccession(succession)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("failed to validate succession of %s: %w", predecessor.ID, err)
+	}
+
+	// Get or create successor.
+	successor = existingSuccessor
+	if successor == nil {
+		successor, err = GetHub(mapName, succession.Successor)
+	}
+	switch {
+	case err == nil && successor.ID != succession.Successor:
+		return nil, nil, false, fmt.Errorf("successor ID %q mismatches hub ID %q", succession.Successor, successor.ID)
+	case err == nil:
+		// The successor may already be known, if its announcement was received
+		// first. Check that it has the same key.
+		if successor.PublicKey != nil && !verifyHubID(successor.ID, successor.PublicKey.Scheme, successor.PublicKey.Key) {
+			return nil, nil, false, fmt.Errorf("ID integrity of successor %s violated with existing key", successor.ID)
+		}
+	case errors.Is(err, database.ErrNotFound):
+		successor = &Hub{
+			ID:        succession.Successor,
+			Map:       mapName,
+			PublicKey: successorKey,
+		}
+	default:
+		return nil, nil, false, fmt.Errorf("failed to get successor hub %s: %w", succession.Successor, err)
+	}
+
+	// Retire the predecessor.
+	predecessor.Succession = succession
+	predecessor.Retirement = &Retirement{
+		ID:        succession.ID,
+		Timestamp: succession.Timestamp,
+		Successor: succession.Successor,
+		Reason:    successionReason,
+	}
+
+	return predecessor, successor, true, nil
+}
+
+func validateSuccession(succession *Succession) error {
+	// check successor
+	if succession.Successor == succession.ID {
+		return errors.New("hub cannot be its own successor")
+	}
+
+	// check timestamp
+	if succession.Timestamp > time.Now().Add(clockSkewTolerance).Unix() {
+		return fmt.Errorf(
+			"succession from %s @ %s is from the future",
+			succession.ID,
+			time.Unix(succession.Timestamp, 0),
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package hub
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/safing/jess"
+	"github.com/safing/portbase/formats/dsd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHubSuccession(t *testing.T) {
+	t.Parallel()
+
+	signet, h := createTestIdentity(t)
+	successorSignet, successor := createTestIdentity(t)
+	otherSignet, _ := createTestIdentity(t)
+
+	export := func(predecessor, successor *jess.Signet, succession *Succession) []byte {
+		data, err := succession.Export(predecessor, successor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	newSuccession := func() *Succession {
+		return &Succession{
+			ID:        h.ID,
+			Successor: successor.ID,
+			Timestamp: time.Now().Unix(),
+		}
+	}
+
+	// Successions signed by only one of the keys are rejected.
+	msg, err := dsd.Dump(newSuccession(), dsd.JSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	singleSigned, err := SignHubMsg(msg, NewSigner(signet), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, _, err = ApplySuccession(h, successor, singleSigned, "test", false)
+	assert.Error(t, err, "succession signed only by the predecessor should be rejected")
+
+	// Successions signed by another key than the successor are rejected.
+	_, _, _, err = ApplySuccession(h, successor, export(signet, otherSignet, newSuccession()), "test", false)
+	assert.Error(t, err, "succession signed by a different successor key should be rejected")
+	_, _, _, err = ApplySuccession(h, successor, export(otherSignet, successorSignet, newSuccession()), "test", false)
+	assert.Error(t, err, "succession signed by a different predecessor key should be rejected")
+	assert.False(t, h.Retired())
+
+	// Valid succession is applied.
+	predecessor, newHub, changed, err := ApplySuccession(h, successor, export(signet, successorSignet, newSuccession()), "test", false)
+	if assert.NoError(t, err) {
+		assert.True(t, changed)
+		assert.Equal(t, h, predecessor)
+		assert.Equal(t, successor, newHub)
+		assert.True(t, h.Retired())
+		assert.Equal(t, successor.ID, h.Retirement.Successor)
+		assert.Equal(t, successor.ID, h.Succession.Successor)
+	}
+
+	// Repeated successions are old data, other successions are rejected.
+	_, _, changed, err = ApplySuccession(h, successor, export(signet, successorSignet, newSuccession()), "test", false)
+	assert.True(t, errors.Is(err, ErrOldData), err)
+	assert.False(t, changed)
+	_, _, changed, err = ApplySuccession(h, nil, export(signet, otherSignet, &Succession{
+		ID:        h.ID,
+		Successor: otherSignet.ID,
+		Timestamp: time.Now().Unix(),
+	}), "test", false)
+	assert.True(t, errors.Is(err, ErrHubRetired), err)
+	assert.False(t, changed)
+}
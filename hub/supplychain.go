@@ -0,0 +1,116 @@
+package hub
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// maxHosters defines how many hosters may be listed in the supply chain.
+	maxHosters = 10
+
+	// maxSupplyChainNameLength defines the maximum length of company names and
+	// datacenter codes.
+	maxSupplyChainNameLength = 64
+)
+
+var (
+	datacenterCountryFormat = regexp.MustCompile(`^[A-Z]{2}$`)
+	datacenterCompanyFormat = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._]*$`)
+	datacenterCodeFormat    = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	hosterFormat            = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 ._-]*$`)
+)
+
+// Datacenter is a parsed datacenter identifier.
+// Its string format is CC-COMPANY-INTERNALCODE, eg. DE-Hetzner-FSN1-DC5.
+type Datacenter struct {
+	// Country is the ISO country code of the datacenter.
+	Country string
+	// Company is the company operating the datacenter.
+	Company string
+	// Code is the internal code of the company for the datacenter.
+	Code string
+}
+
+// ParseDatacenter parses a datacenter identifier.
+func ParseDatacenter(s string) (*Datacenter, error) {
+	parts := strings.SplitN(s, "-", 3)
+	if len(parts) != 3 {
+		return nil, errors.New("datacenter must have the format CC-COMPANY-INTERNALCODE")
+	}
+
+	dc := &Datacenter{
+		Country: parts[0],
+		Company: parts[1],
+		Code:    parts[2],
+	}
+	switch {
+	case !datacenterCountryFormat.MatchString(dc.Country):
+		return nil, fmt.Errorf("datacenter country %q is not an uppercase two letter country code", dc.Country)
+	case len(dc.Company) > maxSupplyChainNameLength || !datacenterCompanyFormat.MatchString(dc.Company):
+		return nil, fmt.Errorf("datacenter company %q is invalid", dc.Company)
+	case len(dc.Code) > maxSupplyChainNameLength || !datacenterCodeFormat.MatchString(dc.Code):
+		return nil, fmt.Errorf("datacenter code %q is invalid", dc.Code)
+	}
+
+	return dc, nil
+}
+
+// String returns the datacenter identifier.
+func (dc *Datacenter) String() string {
+	return dc.Country + "-" + dc.Company + "-" + dc.Code
+}
+
+// Key returns a normalized identifier of the datacenter for comparison.
+func (dc *Datacenter) Key() string {
+	return strings.ToLower(dc.String())
+}
+
+// NormalizeCompany returns a normalized company name for comparison.
+func NormalizeCompany(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// ParseHosters checks the hoster supply chain and returns the normalized
+// company names.
+func ParseHosters(hosters []string) ([]string, error) {
+	if len(hosters) > maxHosters {
+		return nil, fmt.Errorf("too many hosters, only %d are allowed", maxHosters)
+	}
+
+	normalized := make([]string, 0, len(hosters))
+	for _, hoster := range hosters {
+		if len(hoster) > maxSupplyChainNameLength || !hosterFormat.MatchString(hoster) {
+			return nil, fmt.Errorf("hoster %q is invalid", hoster)
+		}
+
+		// Check for duplicates.
+		name := NormalizeCompany(hoster)
+		for _, existing := range normalized {
+			if name == existing {
+				return nil, fmt.Errorf("hoster %q is listed twice", hoster)
+			}
+		}
+		normalized = append(normalized, name)
+	}
+
+	return normalized, nil
+}
+
+// validateSupplyChain checks the format of the hoster supply chain and the
+// datacenter.
+func (a *Announcement) validateSupplyChain() error {
+	if _, err := ParseHosters(a.Hosters); err != nil {
+		return fmt.Errorf("invalid hosters: %w", err)
+	}
+
+	if a.Datacenter != "" {
+		if _, err := ParseDatacenter(a.Datacenter); err != nil {
+			return fmt.Errorf("invalid datacenter: %w", err)
+		}
+	}
+
+	return nil
+}
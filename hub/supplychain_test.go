@@ -0,0 +1,71 @@
+package hub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDatacenter(t *testing.T) {
+	t.Parallel()
+
+	dc, err := ParseDatacenter("DE-Hetzner-FSN1-DC5")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "DE", dc.Country)
+		assert.Equal(t, "Hetzner", dc.Company)
+		assert.Equal(t, "FSN1-DC5", dc.Code)
+		assert.Equal(t, "DE-Hetzner-FSN1-DC5", dc.String())
+		assert.Equal(t, "de-hetzner-fsn1-dc5", dc.Key())
+	}
+
+	for _, invalid := range []string{
+		"",
+		"DE",
+		"DE-Hetzner",
+		"de-Hetzner-FSN1",
+		"DEU-Hetzner-FSN1",
+		"DE--FSN1",
+		"DE-Hetzner-",
+		"DE-Het zner-FSN1",
+		"DE-Hetzner-FSN1 DC5",
+		"DE-Hetzner-" + string(make([]byte, 65)),
+	} {
+		_, err := ParseDatacenter(invalid)
+		assert.Error(t, err, "datacenter %q should be invalid", invalid)
+	}
+}
+
+func TestParseHosters(t *testing.T) {
+	t.Parallel()
+
+	hosters, err := ParseHosters([]string{"Some Reseller", "Hetzner Online"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"some reseller", "hetzner online"}, hosters)
+	}
+
+	_, err = ParseHosters([]string{"Hetzner", "hetzner"})
+	assert.Error(t, err, "duplicate hosters should be invalid")
+
+	_, err = ParseHosters([]string{"Hetzner;"})
+	assert.Error(t, err, "invalid characters should be invalid")
+
+	_, err = ParseHosters(make([]string, maxHosters+1))
+	assert.Error(t, err, "too many hosters should be invalid")
+}
+
+func TestInvalidSupplyChainIsIgnored(t *testing.T) {
+	t.Parallel()
+
+	// An invalid supply chain must not invalidate the announcement, as it
+	// may be fixed by intel overrides.
+	h := &Hub{ID: "test"}
+	err := h.validateAnnouncement(&Announcement{
+		ID:         "test",
+		Timestamp:  time.Now().Unix(),
+		Hosters:    []string{"Hetzner (Germany)"},
+		Datacenter: "Germany-Hetzner",
+		Transports: []string{"tcp:17"},
+	}, ScopeInvalid)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,502 @@
+package hub
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/bits"
+	"sync"
+	"time"
+
+	"github.com/safing/portbase/database"
+	"github.com/safing/portbase/database/record"
+)
+
+// The transparency log is an append-only Merkle tree of all announcements and
+// statuses seen by a Hub. The tree and its proofs follow RFC 9162 (Certificate
+// Transparency Version 2.0). Hubs publish signed tree heads of their log, so
+// that showing different announcements to different clients can be detected.
+//
+// As the log is append-only, it is fully kept in memory: Every leaf takes
+// roughly 200 bytes for its hash, the inner nodes and the index.
+// Announcements only change with the configuration of a Hub and are rate
+// limited in gossip, so they add little. Statuses are published at least every
+// hour and on every change, so they are sampled: Only one status per Hub is
+// logged within transparencyLogStatusInterval. With 1000 Hubs, this adds about
+// 1.5 million leaves or 300MB per year.
+
+const (
+	// transparencyLogChunkSize defines how many leaves are stored in a single
+	// database record.
+	transparencyLogChunkSize = 1024
+
+	// maxTransparencyProofLength defines the maximum length of a proof.
+	// Trees with up to 2^64 leaves need at most 64 hashes for inclusion and
+	// 128 for consistency proofs.
+	maxTransparencyProofLength = 128
+
+	// transparencyLogStatusInterval defines the minimum interval between two
+	// logged statuses of the same Hub.
+	transparencyLogStatusInterval = 6 * time.Hour
+)
+
+var (
+	// ErrLeafNotFound is returned when the requested leaf is not in the log.
+	ErrLeafNotFound = errors.New("leaf not found in transparency log")
+
+	// ErrInvalidProof is returned when a transparency proof does not verify.
+	ErrInvalidProof = errors.New("invalid transparency proof")
+
+	transparencyLogs     = make(map[string]*TransparencyLog)
+	transparencyLogsLock sync.Mutex
+)
+
+// TransparencyLog is an append-only Merkle tree log of Hub msgs.
+type TransparencyLog struct {
+	lock sync.Mutex
+
+	mapName string
+
+	// levels holds the hashes of all complete subtrees, with the leaf hashes
+	// on level 0.
+	levels [][][]byte
+	// index maps leaf hashes to their index.
+	index map[[sha256.Size]byte]uint64
+	// persisted holds the amount of leaves saved to the database.
+	persisted uint64
+
+	// statusLogged holds when the last status of a Hub was logged.
+	statusLogged map[string]time.Time
+}
+
+// transparencyLogChunk is used to store the leaves of the transparency log.
+type transparencyLogChunk struct {
+	record.Base
+	sync.Mutex
+
+	Leaves [][]byte
+}
+
+// The log is stored in the core scope, as it can never be rebuilt once tree
+// heads were published.
+func makeTransparencyLogChunkDBKey(mapName string, chunk uint64) string {
+	return fmt.Sprintf("core:spn/translog/%s/%016x", mapName, chunk)
+}
+
+// EnableTransparencyLog loads or creates the transparency log of the given
+// map. From then on, all announcements and sampled statuses saved via
+// SaveHubMsg are appended to the log.
+func EnableTransparencyLog(mapName string) (*TransparencyLog, error) {
+	transparencyLogsLock.Lock()
+	defer transparencyLogsLock.Unlock()
+
+	// Check if already enabled.
+	if tl, ok := transparencyLogs[mapName]; ok {
+		return tl, nil
+	}
+
+	// Load leaves from database.
+	tl := newTransparencyLog(mapName)
+	for chunk := uint64(0); ; chunk++ {
+		r, err := db.Get(makeTransparencyLogChunkDBKey(mapName, chunk))
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				break
+			}
+			return nil, fmt.Errorf("failed to load transparency log chunk %d: %w", chunk, err)
+		}
+		c, err := ensureTransparencyLogChunk(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load transparency log chunk %d: %w", chunk, err)
+		}
+		for _, leafHash := range c.Leaves {
+			if len(leafHash) != sha256.Size {
+				return nil, fmt.Errorf("transparency log chunk %d contains invalid leaf hash", chunk)
+			}
+			tl.appendLeafHash(leafHash)
+		}
+		// Stop at the first incomplete chunk.
+		if len(c.Leaves) < transparencyLogChunkSize {
+			break
+		}
+	}
+	tl.persisted = tl.size()
+
+	transparencyLogs[mapName] = tl
+	return tl, nil
+}
+
+// GetTransparencyLog returns the transparency log of the given map, if enabled.
+func GetTransparencyLog(mapName string) *TransparencyLog {
+	transparencyLogsLock.Lock()
+	defer transparencyLogsLock.Unlock()
+
+	return transparencyLogs[mapName]
+}
+
+func newTransparencyLog(mapName string) *TransparencyLog {
+	return &TransparencyLog{
+		mapName:      mapName,
+		levels:       [][][]byte{nil},
+		index:        make(map[[sha256.Size]byte]uint64),
+		statusLogged: make(map[string]time.Time),
+	}
+}
+
+// Append appends the given data to the log, if not already present, and
+// returns its index.
+func (tl *TransparencyLog) Append(data []byte) (index uint64, added bool) {
+	leafHash := TransparencyLeafHash(data)
+
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+
+	var key [sha256.Size]byte
+	copy(key[:], leafHash)
+	if index, ok := tl.index[key]; ok {
+		return index, false
+	}
+
+	return tl.appendLeafHash(leafHash), true
+}
+
+// AppendStatus appends the given status of the Hub with the given ID to the
+// log, if no other status of the Hub was logged within
+// transparencyLogStatusInterval.
+func (tl *TransparencyLog) AppendStatus(hubID string, data []byte) (index uint64, added bool) {
+	leafHash := TransparencyLeafHash(data)
+
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+
+	var key [sha256.Size]byte
+	copy(key[:], leafHash)
+	if index, ok := tl.index[key]; ok {
+		return index, false
+	}
+	if time.Since(tl.statusLogged[hubID]) < transparencyLogStatusInterval {
+		return 0, false
+	}
+
+	tl.statusLogged[hubID] = time.Now()
+	return tl.appendLeafHash(leafHash), true
+}
+
+// appendLeafHash appends the leaf hash and updates the complete subtrees.
+// The log must be locked.
+func (tl *TransparencyLog) appendLeafHash(leafHash []byte) (index uint64) {
+	index = tl.size()
+	var key [sha256.Size]byte
+	copy(key[:], leafHash)
+	tl.index[key] = index
+
+	tl.levels[0] = append(tl.levels[0], leafHash)
+	for level := 0; len(tl.levels[level])%2 == 0; level++ {
+		if len(tl.levels) == level+1 {
+			tl.levels = append(tl.levels, nil)
+		}
+		nodes := tl.levels[level]
+		tl.levels[level+1] = append(
+			tl.levels[level+1],
+			hashMerkleChildren(nodes[len(nodes)-2], nodes[len(nodes)-1]),
+		)
+	}
+
+	return index
+}
+
+// Size returns the amount of leaves in the log.
+func (tl *TransparencyLog) Size() uint64 {
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+
+	return tl.size()
+}
+
+func (tl *TransparencyLog) size() uint64 {
+	return uint64(len(tl.levels[0]))
+}
+
+// RootHash returns the root hash of the tree with the given size.
+func (tl *TransparencyLog) RootHash(size uint64) ([]byte, error) {
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+
+	if size > tl.size() {
+		return nil, fmt.Errorf("tree size %d exceeds log size %d", size, tl.size())
+	}
+	return tl.subtreeHash(0, size), nil
+}
+
+// InclusionProof returns the index of the given leaf hash and the proof that
+// it is included in the tree with the given size.
+func (tl *TransparencyLog) InclusionProof(leafHash []byte, size uint64) (index uint64, proof [][]byte, err error) {
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+
+	if size > tl.size() {
+		return 0, nil, fmt.Errorf("tree size %d exceeds log size %d", size, tl.size())
+	}
+	var key [sha256.Size]byte
+	copy(key[:], leafHash)
+	index, ok := tl.index[key]
+	if !ok {
+		return 0, nil, ErrLeafNotFound
+	}
+	if index >= size {
+		// The leaf was appended after the requested tree size.
+		return index, nil, nil
+	}
+
+	return index, tl.inclusionPath(index, 0, size), nil
+}
+
+// ConsistencyProof returns the proof that the tree with the new size is an
+// extension of the tree with the old size.
+func (tl *TransparencyLog) ConsistencyProof(oldSize, newSize uint64) ([][]byte, error) {
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+
+	switch {
+	case newSize > tl.size():
+		return nil, fmt.Errorf("tree size %d exceeds log size %d", newSize, tl.size())
+	case oldSize > newSize:
+		return nil, fmt.Errorf("old tree size %d exceeds new tree size %d", oldSize, newSize)
+	case oldSize == 0 || oldSize == newSize:
+		return nil, nil
+	}
+
+	return tl.consistencySubproof(oldSize, 0, newSize, true), nil
+}
+
+// Save saves all leaves that were appended since the last save.
+func (tl *TransparencyLog) Save() error {
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+
+	size := tl.size()
+	if tl.persisted == size {
+		return nil
+	}
+
+	for chunk := tl.persisted / transparencyLogChunkSize; chunk*transparencyLogChunkSize < size; chunk++ {
+		end := (chunk + 1) * transparencyLogChunkSize
+		if end > size {
+			end = size
+		}
+		c := &transparencyLogChunk{
+			Leaves: append([][]byte(nil), tl.levels[0][chunk*transparencyLogChunkSize:end]...),
+		}
+		c.SetKey(makeTransparencyLogChunkDBKey(tl.mapName, chunk))
+		err := db.Put(c)
+		if err != nil {
+			return fmt.Errorf("failed to save transparency log chunk %d: %w", chunk, err)
+		}
+	}
+
+	tl.persisted = size
+	return nil
+}
+
+// subtreeHash returns the Merkle tree hash of the leaves from lo to hi.
+// The log must be locked.
+func (tl *TransparencyLog) subtreeHash(lo, hi uint64) []byte {
+	n := hi - lo
+	switch {
+	case n == 0:
+		sum := sha256.Sum256(nil)
+		return sum[:]
+	case n&(n-1) == 0:
+		// All subtrees with a size of a power of two are complete and aligned.
+		level := bits.TrailingZeros64(n)
+		return tl.levels[level][lo>>level]
+	}
+
+	k := largestPowerOfTwoBelow(n)
+	return hashMerkleChildren(tl.subtreeHash(lo, lo+k), tl.subtreeHash(lo+k, hi))
+}
+
+// inclusionPath returns the audit path of leaf m in the leaves from lo to hi.
+// The log must be locked.
+func (tl *TransparencyLog) inclusionPath(m, lo, hi uint64) [][]byte {
+	n := hi - lo
+	if n == 1 {
+		return nil
+	}
+
+	k := largestPowerOfTwoBelow(n)
+	if m < k {
+		return append(tl.inclusionPath(m, lo, lo+k), tl.subtreeHash(lo+k, hi))
+	}
+	return append(tl.inclusionPath(m-k, lo+k, hi), tl.subtreeHash(lo, lo+k))
+}
+
+// consistencySubproof returns the consistency proof of the first m leaves in
+// the leaves from lo to hi.
+// The log must be locked.
+func (tl *TransparencyLog) consistencySubproof(m, lo, hi uint64, complete bool) [][]byte {
+	n := hi - lo
+	if m == n {
+		if complete {
+			return nil
+		}
+		return [][]byte{tl.subtreeHash(lo, hi)}
+	}
+
+	k := largestPowerOfTwoBelow(n)
+	if m <= k {
+		return append(tl.consistencySubproof(m, lo, lo+k, complete), tl.subtreeHash(lo+k, hi))
+	}
+	return append(tl.consistencySubproof(m-k, lo+k, hi, false), tl.subtreeHash(lo, lo+k))
+}
+
+// TransparencyLeafHash returns the leaf hash of the given data.
+func TransparencyLeafHash(data []byte) []byte {
+	h := sha256.New()
+	_, _ = h.Write([]byte{0x00})
+	_, _ = h.Write(data)
+	return h.Sum(nil)
+}
+
+func hashMerkleChildren(left, right []byte) []byte {
+	h := sha256.New()
+	_, _ = h.Write([]byte{0x01})
+	_, _ = h.Write(left)
+	_, _ = h.Write(right)
+	return h.Sum(nil)
+}
+
+// largestPowerOfTwoBelow returns the largest power of two smaller than n.
+// n must be greater than 1.
+func largestPowerOfTwoBelow(n uint64) uint64 {
+	return 1 << (bits.Len64(n-1) - 1)
+}
+
+// VerifyInclusionProof verifies that the leaf hash is included at the given
+// index in the tree with the given size and root hash.
+func VerifyInclusionProof(leafHash []byte, index, size uint64, proof [][]byte, rootHash []byte) error {
+	if index >= size {
+		return fmt.Errorf("%w: index %d is outside of tree size %d", ErrInvalidProof, index, size)
+	}
+	if len(proof) > maxTransparencyProofLength {
+		return fmt.Errorf("%w: proof too long", ErrInvalidProof)
+	}
+
+	fn := index
+	sn := size - 1
+	r := leafHash
+	for _, p := range proof {
+		if sn == 0 {
+			return fmt.Errorf("%w: proof too long", ErrInvalidProof)
+		}
+		if fn&1 == 1 || fn == sn {
+			r = hashMerkleChildren(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = hashMerkleChildren(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 {
+		return fmt.Errorf("%w: proof too short", ErrInvalidProof)
+	}
+	if !bytes.Equal(r, rootHash) {
+		return fmt.Errorf("%w: root hash mismatch", ErrInvalidProof)
+	}
+	return nil
+}
+
+// VerifyConsistencyProof verifies that the tree with the new size and root
+// hash is an extension of the tree with the old size and root hash.
+func VerifyConsistencyProof(oldSize, newSize uint64, oldRootHash, newRootHash []byte, proof [][]byte) error {
+	switch {
+	case oldSize > newSize:
+		return fmt.Errorf("%w: old tree size %d exceeds new tree size %d", ErrInvalidProof, oldSize, newSize)
+	case len(proof) > maxTransparencyProofLength:
+		return fmt.Errorf("%w: proof too long", ErrInvalidProof)
+	case oldSize == newSize:
+		if len(proof) > 0 || !bytes.Equal(oldRootHash, newRootHash) {
+			return fmt.Errorf("%w: trees of same size differ", ErrInvalidProof)
+		}
+		return nil
+	case oldSize == 0:
+		// Every tree is an extension of the empty tree.
+		if len(proof) > 0 {
+			return fmt.Errorf("%w: proof too long", ErrInvalidProof)
+		}
+		return nil
+	case len(proof) == 0:
+		return fmt.Errorf("%w: empty proof", ErrInvalidProof)
+	}
+
+	// If the old tree is a complete subtree, its root is the first node.
+	if oldSize&(oldSize-1) == 0 {
+		proof = append([][]byte{oldRootHash}, proof...)
+	}
+
+	fn := oldSize - 1
+	sn := newSize - 1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+
+	fr := proof[0]
+	sr := proof[0]
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return fmt.Errorf("%w: proof too long", ErrInvalidProof)
+		}
+		if fn&1 == 1 || fn == sn {
+			fr = hashMerkleChildren(c, fr)
+			sr = hashMerkleChildren(c, sr)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = hashMerkleChildren(sr, c)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 {
+		return fmt.Errorf("%w: proof too short", ErrInvalidProof)
+	}
+	if !bytes.Equal(fr, oldRootHash) {
+		return fmt.Errorf("%w: old root hash mismatch", ErrInvalidProof)
+	}
+	if !bytes.Equal(sr, newRootHash) {
+		return fmt.Errorf("%w: new root hash mismatch", ErrInvalidProof)
+	}
+	return nil
+}
+
+// ensureTransparencyLogChunk makes sure a database record is a transparency
+// log chunk.
+func ensureTransparencyLogChunk(r record.Record) (*transparencyLogChunk, error) {
+	// unwrap
+	if r.IsWrapped() {
+		// only allocate a new struct, if we need it
+		new := &transparencyLogChunk{}
+		err := record.Unwrap(r, new)
+		if err != nil {
+			return nil, err
+		}
+		return new, nil
+	}
+
+	// or adjust type
+	new, ok := r.(*transparencyLogChunk)
+	if !ok {
+		return nil, fmt.Errorf("record not of type *transparencyLogChunk, but %T", r)
+	}
+	return new, nil
+}
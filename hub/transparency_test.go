@@ -0,0 +1,205 @@
+package hub
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransparencyLogProofs(t *testing.T) {
+	t.Parallel()
+
+	tl := newTransparencyLog("test")
+	var leaves [][]byte
+	var roots [][]byte
+	for i := 0; i < 70; i++ {
+		data := []byte(fmt.Sprintf("msg-%d", i))
+		leaves = append(leaves, TransparencyLeafHash(data))
+		index, added := tl.Append(data)
+		assert.True(t, added)
+		assert.Equal(t, uint64(i), index)
+
+		root, err := tl.RootHash(tl.Size())
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+	}
+
+	// Duplicates are not added.
+	index, added := tl.Append([]byte("msg-5"))
+	assert.False(t, added)
+	assert.Equal(t, uint64(5), index)
+
+	// Check that the root hash matches a naive computation.
+	var naive func(leaves [][]byte) []byte
+	naive = func(leaves [][]byte) []byte {
+		if len(leaves) == 1 {
+			return leaves[0]
+		}
+		k := largestPowerOfTwoBelow(uint64(len(leaves)))
+		return hashMerkleChildren(naive(leaves[:k]), naive(leaves[k:]))
+	}
+	for size := 1; size <= len(leaves); size++ {
+		assert.Equal(t, naive(leaves[:size]), roots[size-1], "root hash of size %d", size)
+	}
+
+	for size := uint64(1); size <= tl.Size(); size++ {
+		root := roots[size-1]
+
+		// Check inclusion proofs.
+		for i := uint64(0); i < size; i++ {
+			index, proof, err := tl.InclusionProof(leaves[i], size)
+			if !assert.NoError(t, err) {
+				continue
+			}
+			assert.Equal(t, i, index)
+			assert.NoError(t, VerifyInclusionProof(leaves[i], index, size, proof, root), "inclusion of %d in size %d", i, size)
+
+			// Proofs must not verify for other leaves or indexes.
+			other := leaves[(i+1)%uint64(len(leaves))]
+			assert.Error(t, VerifyInclusionProof(other, index, size, proof, root), "inclusion of wrong leaf")
+			if size > 1 {
+				assert.Error(t, VerifyInclusionProof(leaves[i], (index+1)%size, size, proof, root), "inclusion at wrong index")
+			}
+		}
+
+		// Check consistency proofs.
+		for oldSize := uint64(1); oldSize <= size; oldSize++ {
+			oldRoot := roots[oldSize-1]
+			proof, err := tl.ConsistencyProof(oldSize, size)
+			if !assert.NoError(t, err) {
+				continue
+			}
+			assert.NoError(t, VerifyConsistencyProof(oldSize, size, oldRoot, root, proof), "consistency of %d with %d", oldSize, size)
+
+			// Proofs must not verify for other trees.
+			if oldSize < size {
+				assert.Error(t, VerifyConsistencyProof(oldSize, size, root, root, proof), "consistency with wrong old root")
+				assert.Error(t, VerifyConsistencyProof(oldSize, size, oldRoot, oldRoot, proof), "consistency with wrong new root")
+			}
+		}
+	}
+
+	// Leaves that are not in the log cannot be proven.
+	_, _, err := tl.InclusionProof(TransparencyLeafHash([]byte("unknown")), tl.Size())
+	assert.True(t, errors.Is(err, ErrLeafNotFound))
+}
+
+func TestTransparencyLogStatusSampling(t *testing.T) {
+	t.Parallel()
+
+	tl := newTransparencyLog("test")
+
+	// The first status of a Hub is logged.
+	_, added := tl.AppendStatus("hub-a", []byte("status-a-1"))
+	assert.True(t, added)
+	_, added = tl.AppendStatus("hub-b", []byte("status-b-1"))
+	assert.True(t, added)
+
+	// Further statuses within the interval are not logged.
+	_, added = tl.AppendStatus("hub-a", []byte("status-a-2"))
+	assert.False(t, added)
+	assert.Equal(t, uint64(2), tl.Size())
+
+	// Statuses are logged again after the interval.
+	tl.statusLogged["hub-a"] = time.Now().Add(-transparencyLogStatusInterval)
+	index, added := tl.AppendStatus("hub-a", []byte("status-a-3"))
+	assert.True(t, added)
+	assert.Equal(t, uint64(2), index)
+}
+
+func TestTreeHeadEquivocation(t *testing.T) {
+	t.Parallel()
+
+	signet, h := createTestIdentity(t)
+	signer := NewSigner(signet)
+
+	apply := func(treeHead *TreeHead) (changed bool, err error) {
+		data, err := treeHead.Export(signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, changed, err = ApplyTreeHead(h, data, "test", false)
+		return changed, err
+	}
+
+	// Create a log and a fork of it.
+	tl := newTransparencyLog("test")
+	fork := newTransparencyLog("test")
+	for i := 0; i < 10; i++ {
+		tl.Append([]byte(fmt.Sprintf("msg-%d", i)))
+		fork.Append([]byte(fmt.Sprintf("msg-%d", i)))
+	}
+	fork.Append([]byte("forked-msg"))
+	now := time.Now().Unix()
+
+	// Apply first tree head.
+	first, err := tl.MakeTreeHead(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Timestamp = now - 100
+	changed, err := apply(first)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+
+	// Consistent tree heads are accepted.
+	tl.Append([]byte("msg-10"))
+	tl.Append([]byte("msg-11"))
+	second, err := tl.MakeTreeHead(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second.Timestamp = now - 90
+	assert.Equal(t, first.Size, second.PreviousSize)
+	changed, err = apply(second)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.False(t, h.Equivocated)
+
+	// Old tree heads are ignored.
+	_, err = apply(first)
+	assert.True(t, errors.Is(err, ErrOldData), "old tree head should be rejected as old data")
+	assert.False(t, h.Equivocated)
+
+	// A fork that is not an extension of the current tree is detected.
+	forked, err := fork.MakeTreeHead(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		fork.Append([]byte(fmt.Sprintf("forked-msg-%d", i)))
+	}
+	forkedNext, err := fork.MakeTreeHead(&TreeHead{Size: second.Size})
+	if err != nil {
+		t.Fatal(err)
+	}
+	forkedNext.Timestamp = now - 80
+	changed, err = apply(forkedNext)
+	assert.True(t, errors.Is(err, ErrEquivocation), "forked tree head should be detected")
+	assert.True(t, changed)
+	assert.True(t, h.Equivocated)
+	assert.Equal(t, second, h.TreeHead, "consistent tree head should be kept")
+
+	// Different root hashes for the same size are detected.
+	h.Equivocated = false
+	forked.Size = second.Size
+	forked.Timestamp = now - 70
+	_, err = apply(forked)
+	assert.True(t, errors.Is(err, ErrEquivocation), "different root for same size should be detected")
+	assert.True(t, h.Equivocated)
+
+	// Shrinking logs are detected.
+	h.Equivocated = false
+	_, err = apply(&TreeHead{
+		Timestamp: now - 60,
+		Size:      first.Size,
+		RootHash:  first.RootHash,
+	})
+	assert.True(t, errors.Is(err, ErrEquivocation), "shrinking log should be detected")
+	assert.True(t, h.Equivocated)
+}
@@ -0,0 +1,212 @@
+package hub
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/safing/portbase/formats/dsd"
+)
+
+// MsgTypeTreeHead is the message type of signed transparency log tree heads.
+const MsgTypeTreeHead = "treehead"
+
+// ErrEquivocation is returned when a Hub published tree heads that are not
+// consistent with each other. This proves that the Hub showed different
+// versions of its transparency log to different parties.
+var ErrEquivocation = errors.New("hub published inconsistent transparency log tree heads")
+
+// TreeHead is a signed tree head of the transparency log of a Hub.
+type TreeHead struct {
+	// Timestamp is the time the tree head was created.
+	Timestamp int64
+
+	// Size is the amount of leaves in the tree.
+	Size uint64
+
+	// RootHash is the Merkle tree hash of the tree.
+	RootHash []byte
+
+	// PreviousSize is the size of the previously published tree head.
+	PreviousSize uint64 `json:",omitempty"`
+
+	// Consistency proves that the tree is an extension of the tree of the
+	// previously published tree head.
+	Consistency [][]byte `json:",omitempty"`
+}
+
+// GetTreeHead returns the latest tree head of the Hub.
+func (h *Hub) GetTreeHead() *TreeHead {
+	h.Lock()
+	defer h.Unlock()
+
+	return h.TreeHead
+}
+
+// Export exports the tree head signed by the given signer.
+func (th *TreeHead) Export(signer Signer) ([]byte, error) {
+	// pack
+	msg, err := dsd.Dump(th, dsd.JSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack tree head: %s", err)
+	}
+
+	return SignHubMsg(msg, signer, false)
+}
+
+// ApplyTreeHead applies a tree head if it passes all the checks. Tree heads
+// that are inconsistent with the current tree head mark the Hub as
+// equivocated and return ErrEquivocation.
+func ApplyTreeHead(existingHub *Hub, data []byte, mapName string, selfcheck bool) (hub *Hub, changed bool, err error) {
+	// open and verify
+	var msg []byte
+	msg, hub, _, err = OpenHubMsg(existingHub, data, mapName, false)
+
+	// Lock hub if we have one.
+	if hub != nil && !selfcheck {
+		hub.Lock()
+		defer hub.Unlock()
+	}
+
+	// Check if there was an error with the Hub msg.
+	if err != nil {
+		return
+	}
+
+	// Check if the Hub is retired.
+	if hub.Retirement != nil {
+		err = fmt.Errorf("%wtree head of %s: %s", ErrOldData, hub.ID, ErrHubRetired)
+		return
+	}
+
+	// parse
+	treeHead := &TreeHead{}
+	_, err = dsd.Load(msg, treeHead)
+	if err != nil {
+		err = fmt.Errorf("%w: failed to parse tree head: %s", ErrInvalidHubMsg, err)
+		return
+	}
+
+	// Validate the tree head.
+	err = hub.validateTreeHead(treeHead)
+	if err != nil {
+		err = fmt.Errorf("failed to validate tree head of %s: %w", hub.ID, err)
+		return
+	}
+
+	// Check consistency with the current tree head.
+	current := hub.TreeHead
+	if current != nil {
+		err = checkTreeHeadConsistency(current, treeHead)
+		switch {
+		case errors.Is(err, ErrEquivocation):
+			hub.Equivocated = true
+			changed = true
+			err = fmt.Errorf("tree head of %s: %w", hub.ID, err)
+			return
+		case err != nil:
+			return
+		}
+	}
+
+	hub.TreeHead = treeHead
+	changed = true
+	return
+}
+
+// checkTreeHeadConsistency checks if the new tree head is consistent with the
+// current one.
+func checkTreeHeadConsistency(current, treeHead *TreeHead) error {
+	switch {
+	case treeHead.Size == current.Size:
+		if !bytes.Equal(treeHead.RootHash, current.RootHash) {
+			return fmt.Errorf("%w: different root hashes for tree size %d", ErrEquivocation, treeHead.Size)
+		}
+		if treeHead.Timestamp <= current.Timestamp {
+			return fmt.Errorf("%wtree head @ %d is not newer than current tree head @ %d", ErrOldData, treeHead.Timestamp, current.Timestamp)
+		}
+		// A newer tree head for the same tree is accepted in order to show that
+		// the log is still maintained.
+		return nil
+
+	case treeHead.Timestamp <= current.Timestamp:
+		if treeHead.Size > current.Size {
+			return fmt.Errorf("%w: older tree head has larger tree size %d than current tree size %d", ErrEquivocation, treeHead.Size, current.Size)
+		}
+		return fmt.Errorf("%wtree head @ %d is older than current tree head @ %d", ErrOldData, treeHead.Timestamp, current.Timestamp)
+
+	case treeHead.Size < current.Size:
+		return fmt.Errorf("%w: tree size shrank from %d to %d", ErrEquivocation, current.Size, treeHead.Size)
+
+	case treeHead.PreviousSize == current.Size:
+		// The tree head references the current one, so we can verify it.
+		err := VerifyConsistencyProof(current.Size, treeHead.Size, current.RootHash, treeHead.RootHash, treeHead.Consistency)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrEquivocation, err)
+		}
+		return nil
+
+	default:
+		// We missed tree heads in between and cannot verify consistency with
+		// the included proof. Consistency can be verified by requesting a proof
+		// from the Hub.
+		return nil
+	}
+}
+
+func (hub *Hub) validateTreeHead(treeHead *TreeHead) error {
+	// value formatting
+	if len(treeHead.RootHash) != sha256.Size {
+		return fmt.Errorf("invalid root hash length of %d", len(treeHead.RootHash))
+	}
+	if len(treeHead.Consistency) > maxTransparencyProofLength {
+		return fmt.Errorf("field Consistency with array/slice length of %d exceeds max length of %d", len(treeHead.Consistency), maxTransparencyProofLength)
+	}
+	for _, proofHash := range treeHead.Consistency {
+		if len(proofHash) != sha256.Size {
+			return fmt.Errorf("invalid consistency proof hash length of %d", len(proofHash))
+		}
+	}
+	if treeHead.PreviousSize > treeHead.Size {
+		return fmt.Errorf("previous tree size %d exceeds tree size %d", treeHead.PreviousSize, treeHead.Size)
+	}
+
+	// check timestamp
+	if treeHead.Timestamp > time.Now().Add(clockSkewTolerance).Unix() {
+		return fmt.Errorf(
+			"tree head from %s @ %s is from the future",
+			hub.ID,
+			time.Unix(treeHead.Timestamp, 0),
+		)
+	}
+
+	return nil
+}
+
+// MakeTreeHead creates a tree head of the current state of the log. If a
+// previous tree head is given, the new tree head includes the proof that the
+// log is consistent with it.
+func (tl *TransparencyLog) MakeTreeHead(previous *TreeHead) (*TreeHead, error) {
+	size := tl.Size()
+	rootHash, err := tl.RootHash(size)
+	if err != nil {
+		return nil, err
+	}
+
+	treeHead := &TreeHead{
+		Timestamp: time.Now().Unix(),
+		Size:      size,
+		RootHash:  rootHash,
+	}
+	if previous != nil && previous.Size > 0 && previous.Size < size {
+		treeHead.PreviousSize = previous.Size
+		treeHead.Consistency, err = tl.ConsistencyProof(previous.Size, size)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create consistency proof: %w", err)
+		}
+	}
+
+	return treeHead, nil
+}
@@ -0,0 +1,47 @@
+package hub
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrUntrustedHub is returned when a Hub is not in the list of trusted Hubs
+// of a map.
+var ErrUntrustedHub = errors.New("hub is not trusted on this map")
+
+var (
+	trustedHubs     = make(map[string]map[string]struct{})
+	trustedHubsLock sync.RWMutex
+)
+
+// SetTrustedHubs restricts the given map to the given Hub IDs. Messages of
+// other Hubs are rejected on this map. An empty list removes the restriction.
+func SetTrustedHubs(mapName string, hubIDs []string) {
+	trustedHubsLock.Lock()
+	defer trustedHubsLock.Unlock()
+
+	if len(hubIDs) == 0 {
+		delete(trustedHubs, mapName)
+		return
+	}
+
+	trusted := make(map[string]struct{}, len(hubIDs))
+	for _, hubID := range hubIDs {
+		trusted[hubID] = struct{}{}
+	}
+	trustedHubs[mapName] = trusted
+}
+
+// IsTrustedHub returns whether the given Hub is trusted on the given map.
+// All Hubs are trusted on maps without a list of trusted Hubs.
+func IsTrustedHub(mapName, hubID string) bool {
+	trustedHubsLock.RLock()
+	defer trustedHubsLock.RUnlock()
+
+	trusted, ok := trustedHubs[mapName]
+	if !ok {
+		return true
+	}
+	_, ok = trusted[hubID]
+	return ok
+}
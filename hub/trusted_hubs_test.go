@@ -0,0 +1,43 @@
+package hub
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrustedHubs(t *testing.T) {
+	t.Parallel()
+
+	signet, h := createTestIdentity(t)
+	_, other := createTestIdentity(t)
+	data, err := SignHubMsg([]byte("test"), NewSigner(signet), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Maps without trusted Hubs accept all Hubs.
+	assert.True(t, IsTrustedHub("trusted-test", h.ID))
+	_, _, _, err = OpenHubMsg(nil, data, "trusted-test", true)
+	assert.False(t, errors.Is(err, ErrUntrustedHub), "hub should be trusted")
+
+	// Untrusted Hubs are rejected.
+	SetTrustedHubs("trusted-test", []string{other.ID})
+	assert.False(t, IsTrustedHub("trusted-test", h.ID))
+	assert.True(t, IsTrustedHub("trusted-test", other.ID))
+	_, _, _, err = OpenHubMsg(nil, data, "trusted-test", true)
+	assert.True(t, errors.Is(err, ErrUntrustedHub), "untrusted hub should be rejected")
+
+	// Other maps are not affected.
+	assert.True(t, IsTrustedHub("other-test", h.ID))
+
+	// Trusted Hubs are accepted.
+	SetTrustedHubs("trusted-test", []string{other.ID, h.ID})
+	_, _, _, err = OpenHubMsg(nil, data, "trusted-test", true)
+	assert.False(t, errors.Is(err, ErrUntrustedHub), "hub should be trusted")
+
+	// Removing the list removes the restriction.
+	SetTrustedHubs("trusted-test", nil)
+	assert.True(t, IsTrustedHub("trusted-test", h.ID))
+}
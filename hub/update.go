@@ -26,34 +26,23 @@ var (
 	clockSkewTolerance = 1 * time.Hour
 )
 
-// SignHubMsg signs the given serialized hub msg with the given configuration.
-func SignHubMsg(msg []byte, env *jess.Envelope, enableTofu bool) ([]byte, error) {
-	// start session from envelope
-	session, err := env.Correspondence(nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to initiate signing session: %s", err)
-	}
+// SignHubMsg signs the given serialized hub msg with the given signer.
+func SignHubMsg(msg []byte, signer Signer, enableTofu bool) ([]byte, error) {
 	// sign the data
-	letter, err := session.Close(msg)
+	letter, err := signer.Sign(msg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to sign msg: %s", err)
+		return nil, err
 	}
 
 	if enableTofu {
 		// smuggle the public key
 		// letter.Keys is usually only used for key exchanges and encapsulation
 		// neither is used when signing, so we can use letter.Keys to transport public keys
-		for _, sender := range env.Senders {
-			// get public key
-			public, err := sender.AsRecipient()
-			if err != nil {
-				return nil, fmt.Errorf("failed to get public key of %s: %s", sender.ID, err)
-			}
-			// serialize key
-			err = public.StoreKey()
-			if err != nil {
-				return nil, fmt.Errorf("failed to serialize public key %s: %s", sender.ID, err)
-			}
+		publicKeys, err := signer.PublicKeys()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get public keys: %s", err)
+		}
+		for _, public := range publicKeys {
 			// add to keys
 			letter.Keys = append(letter.Keys, &jess.Seal{
 				Value: public.Key,
@@ -76,27 +65,33 @@ func SignHubMsg(msg []byte, env *jess.Envelope, enableTofu bool) ([]byte, error)
 func OpenHubMsg(hub *Hub, data []byte, mapName string, tofu bool) (msg []byte, sendingHub *Hub, known bool, err error) {
 	letter, err := jess.LetterFromDSD(data)
 	if err != nil {
-		return nil, nil, false, fmt.Errorf("malformed letter: %s", err)
+		return nil, nil, false, fmt.Errorf("%w: malformed letter: %s", ErrInvalidHubMsg, err)
 	}
 
 	// check signatures
 	var seal *jess.Seal
 	switch len(letter.Signatures) {
 	case 0:
-		return nil, nil, false, errors.New("missing signature")
+		return nil, nil, false, fmt.Errorf("%w: missing signature", ErrInvalidHubMsg)
 	case 1:
 		seal = letter.Signatures[0]
 	default:
-		return nil, nil, false, fmt.Errorf("too many signatures (%d)", len(letter.Signatures))
+		return nil, nil, false, fmt.Errorf("%w: too many signatures (%d)", ErrInvalidHubMsg, len(letter.Signatures))
 	}
 
 	// check signature signer ID
 	if seal.ID == "" {
-		return nil, nil, false, errors.New("signature is missing signer ID")
+		return nil, nil, false, fmt.Errorf("%w: signature is missing signer ID", ErrInvalidHubMsg)
 	}
 
 	// get hub for public key
 	if hub == nil {
+		// Check if the Hub is trusted on this map.
+		// Hubs supplied by the caller have already been checked.
+		if !IsTrustedHub(mapName, seal.ID) {
+			return nil, nil, false, fmt.Errorf("%w: %s", ErrUntrustedHub, seal.ID)
+		}
+
 		hub, err = GetHub(mapName, seal.ID)
 		if err != nil {
 			if err != database.ErrNotFound {
@@ -114,7 +109,7 @@ func OpenHubMsg(hub *Hub, data []byte, mapName string, tofu bool) (msg []byte, s
 	if hub != nil && hub.PublicKey != nil { // bootstrap entries will not have a public key
 		// check ID integrity
 		if hub.ID != seal.ID {
-			return nil, hub, known, fmt.Errorf("ID mismatch with hub msg ID %s and hub ID %s", seal.ID, hub.ID)
+			return nil, hub, known, fmt.Errorf("%w: ID mismatch with hub msg ID %s and hub ID %s", ErrInvalidHubMsg, seal.ID, hub.ID)
 		}
 		if !verifyHubID(seal.ID, hub.PublicKey.Scheme, hub.PublicKey.Key) {
 			return nil, hub, known, fmt.Errorf("ID integrity of %s violated with existing key", seal.ID)
@@ -131,16 +126,16 @@ func OpenHubMsg(hub *Hub, data []byte, mapName string, tofu bool) (msg []byte, s
 		var pubkey *jess.Seal
 		switch len(letter.Keys) {
 		case 0:
-			return nil, nil, false, fmt.Errorf("missing key for TOFU of %s", seal.ID)
+			return nil, nil, false, fmt.Errorf("%w: missing key for TOFU of %s", ErrInvalidHubMsg, seal.ID)
 		case 1:
 			pubkey = letter.Keys[0]
 		default:
-			return nil, nil, false, fmt.Errorf("too many keys (%d) for TOFU of %s", len(letter.Keys), seal.ID)
+			return nil, nil, false, fmt.Errorf("%w: too many keys (%d) for TOFU of %s", ErrInvalidHubMsg, len(letter.Keys), seal.ID)
 		}
 
 		// check ID integrity
 		if !verifyHubID(seal.ID, seal.Scheme, pubkey.Value) {
-			return nil, nil, false, fmt.Errorf("ID integrity of %s violated with new key", seal.ID)
+			return nil, nil, false, fmt.Errorf("%w: ID integrity of %s violated with new key", ErrInvalidHubMsg, seal.ID)
 		}
 
 		hub = &Hub{
@@ -155,7 +150,7 @@ func OpenHubMsg(hub *Hub, data []byte, mapName string, tofu bool) (msg []byte, s
 		}
 		err = hub.PublicKey.LoadKey()
 		if err != nil {
-			return nil, nil, false, err
+			return nil, nil, false, fmt.Errorf("%w: failed to load key of %s: %s", ErrInvalidHubMsg, seal.ID, err)
 		}
 	}
 
@@ -168,21 +163,21 @@ func OpenHubMsg(hub *Hub, data []byte, mapName string, tofu bool) (msg []byte, s
 	// check signature
 	err = letter.Verify(hubMsgRequirements, truststore)
 	if err != nil {
-		return nil, nil, false, err
+		return nil, nil, false, fmt.Errorf("%w: %s", ErrInvalidHubMsg, err)
 	}
 
 	return letter.Data, hub, known, nil
 }
 
-// Export exports the announcement with the given signature configuration.
-func (ha *Announcement) Export(env *jess.Envelope) ([]byte, error) {
+// Export exports the announcement signed by the given signer.
+func (ha *Announcement) Export(signer Signer) ([]byte, error) {
 	// pack
 	msg, err := dsd.Dump(ha, dsd.JSON)
 	if err != nil {
 		return nil, fmt.Errorf("failed to pack announcement: %s", err)
 	}
 
-	return SignHubMsg(msg, env, true)
+	return SignHubMsg(msg, signer, true)
 }
 
 // ApplyAnnouncement applies the announcement to the Hub if it passes all the
@@ -215,10 +210,17 @@ func ApplyAnnouncement(existingHub *Hub, data []byte, mapName string, scope Scop
 		return
 	}
 
+	// Check if the Hub is retired.
+	if hub.Retirement != nil {
+		err = fmt.Errorf("%wannouncement of %s: %s", ErrOldData, hub.ID, ErrHubRetired)
+		return
+	}
+
 	// parse
 	announcement = &Announcement{}
 	_, err = dsd.Load(msg, announcement)
 	if err != nil {
+		err = fmt.Errorf("%w: failed to parse announcement: %s", ErrInvalidHubMsg, err)
 		return
 	}
 
@@ -293,6 +295,14 @@ func (hub *Hub) validateAnnouncement(announcement *Announcement, scope Scope) er
 		return err
 	}
 
+	// check supply chain
+	// The supply chain is optional and may be fixed by intel overrides, so an
+	// invalid supply chain does not invalidate the announcement. It is ignored
+	// by the navigator instead.
+	if err := announcement.validateSupplyChain(); err != nil {
+		log.Warningf("spn/hub: announcement from %s has an invalid supply chain: %s", announcement.ID, err)
+	}
+
 	// check timestamp
 	if announcement.Timestamp > time.Now().Add(clockSkewTolerance).Unix() {
 		return fmt.Errorf(
@@ -364,15 +374,15 @@ func (hub *Hub) validateAnnouncement(announcement *Announcement, scope Scope) er
 	return nil
 }
 
-// Export exports the status with the given signature configuration.
-func (hs *Status) Export(env *jess.Envelope) ([]byte, error) {
+// Export exports the status signed by the given signer.
+func (hs *Status) Export(signer Signer) ([]byte, error) {
 	// pack
 	msg, err := dsd.Dump(hs, dsd.JSON)
 	if err != nil {
 		return nil, fmt.Errorf("failed to pack status: %s", err)
 	}
 
-	return SignHubMsg(msg, env, false)
+	return SignHubMsg(msg, signer, false)
 }
 
 // ApplyStatus applies a status update if it passes all the checks.
@@ -380,7 +390,7 @@ func ApplyStatus(existingHub *Hub, data []byte, mapName string, scope Scope, sel
 	// Set valid/invalid status based on the return error.
 	defer func() {
 		if hub != nil {
-			if err != nil && !errors.Is(err, ErrOldData) {
+			if err != nil && !errors.Is(err, ErrOldData) && !errors.Is(err, ErrMissingStatusBase) {
 				hub.InvalidStatus = true
 			} else {
 				hub.InvalidStatus = false
@@ -403,31 +413,52 @@ func ApplyStatus(existingHub *Hub, data []byte, mapName string, scope Scope, sel
 		return
 	}
 
+	// Check if the Hub is retired.
+	if hub.Retirement != nil {
+		err = fmt.Errorf("%wstatus of %s: %s", ErrOldData, hub.ID, ErrHubRetired)
+		return
+	}
+
 	// parse
-	status := &Status{}
-	_, err = dsd.Load(msg, status)
+	status, delta, err := loadStatusMsg(msg)
 	if err != nil {
 		return
 	}
+	var timestamp int64
+	if delta != nil {
+		timestamp = delta.Timestamp
+	} else {
+		timestamp = status.Timestamp
+	}
 
 	// version check
 	if hub.Status != nil {
 		// check if we already have this version
 		switch {
-		case status.Timestamp == hub.Status.Timestamp && !selfcheck:
+		case timestamp == hub.Status.Timestamp && !selfcheck:
 			// The new copy is not saved, as we expect the versions to be identical.
 			// Also, the new version has not been validated at this point.
 			return
-		case status.Timestamp < hub.Status.Timestamp:
+		case timestamp < hub.Status.Timestamp:
 			// Received an old version, do not update.
 			err = fmt.Errorf(
 				"%wstatus from %s @ %s is older than current status @ %s",
-				ErrOldData, hub.StringWithoutLocking(), time.Unix(status.Timestamp, 0), time.Unix(hub.Status.Timestamp, 0),
+				ErrOldData, hub.StringWithoutLocking(), time.Unix(timestamp, 0), time.Unix(hub.Status.Timestamp, 0),
 			)
 			return
 		}
 	}
 
+	// Build the full status from a delta.
+	// If we are missing the base, we need to wait for the next full status.
+	if delta != nil {
+		status, err = delta.Apply(hub.Status)
+		if err != nil {
+			err = fmt.Errorf("failed to apply status delta of %s: %w", hub.StringWithoutLocking(), err)
+			return
+		}
+	}
+
 	// We received a new version.
 	changed = true
 
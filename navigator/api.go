@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"sort"
 	"strconv"
@@ -86,7 +87,7 @@ func registerAPIEndpoints() error {
 		BelongsTo:   module,
 		StructFunc:  handleMapMeasurementsRequest,
 		Name:        "Get SPN map measurements",
-		Description: "Returns the measurements of the map.",
+		Description: "Returns the measurements of the map, including their history.",
 	}); err != nil {
 		return err
 	}
@@ -103,6 +104,71 @@ func registerAPIEndpoints() error {
 		return err
 	}
 
+	if err := api.RegisterEndpoint(api.Endpoint{
+		Path:        `spn/map/{map:[A-Za-z0-9]{1,255}}/route/explain`,
+		Read:        api.PermitUser,
+		BelongsTo:   module,
+		StructFunc:  handleMapRouteExplainRequest,
+		Name:        "Explain SPN route decision",
+		Description: "Finds routes to the given destination IP and explains why Hubs and routes were not used.",
+		Parameters: []api.Parameter{
+			{
+				Method:      http.MethodGet,
+				Field:       "ip",
+				Value:       "IP address",
+				Description: "Specify the destination IP address to find routes to.",
+			},
+			{
+				Method:      http.MethodGet,
+				Field:       "profile",
+				Value:       "routing profile ID",
+				Description: "Specify the routing profile to use. Defaults to the map's default.",
+			},
+			{
+				Method:      http.MethodGet,
+				Field:       "max",
+				Value:       "number",
+				Description: "Specify how many routes should be found at maximum. Defaults to 10.",
+			},
+			{
+				Method:      http.MethodGet,
+				Field:       "trusted",
+				Value:       "",
+				Description: "If set, only trusted Hubs are used as Destination Hubs.",
+			},
+			{
+				Method:      http.MethodGet,
+				Field:       "probe",
+				Value:       "",
+				Description: "If set, available destination probe results are used for selecting Destination Hubs.",
+			},
+		},
+	}); err != nil {
+		return err
+	}
+
+	if err := api.RegisterEndpoint(api.Endpoint{
+		Path:        `spn/map/{map:[A-Za-z0-9]{1,255}}/snapshot`,
+		Read:        api.PermitUser,
+		BelongsTo:   module,
+		StructFunc:  handleMapSnapshotRequest,
+		Name:        "Get SPN map snapshot",
+		Description: "Returns a snapshot of the full map state, which can be loaded offline for debugging.",
+	}); err != nil {
+		return err
+	}
+
+	if err := api.RegisterEndpoint(api.Endpoint{
+		Path:        `spn/map/{map:[A-Za-z0-9]{1,255}}/health`,
+		Read:        api.PermitUser,
+		BelongsTo:   module,
+		StructFunc:  handleMapHealthRequest,
+		Name:        "Get SPN map health",
+		Description: "Returns an analysis of the map structure, including partitions, Hubs whose loss would split the map and missing lanes between regions.",
+	}); err != nil {
+		return err
+	}
+
 	if err := api.RegisterEndpoint(api.Endpoint{
 		Path:        `spn/map/{map:[A-Za-z0-9]{1,255}}/graph{format:\.[a-z]{2,4}}`,
 		Read:        api.PermitUser,
@@ -278,7 +344,9 @@ func handleMapMeasurementsRequest(ar *api.Request) (i interface{}, err error) {
 	// Copy data and return.
 	measurements := make([]*hub.Measurements, 0, len(list))
 	for _, pin := range list {
+		pin.measurements.Lock()
 		measurements = append(measurements, pin.measurements.Copy())
+		pin.measurements.Unlock()
 	}
 	return measurements, nil
 }
@@ -301,7 +369,7 @@ func handleMapMeasurementsTableRequest(ar *api.Request) (data []byte, err error)
 	// Build table and return.
 	buf := bytes.NewBuffer(nil)
 	tabWriter := tabwriter.NewWriter(buf, 8, 4, 3, ' ', 0)
-	fmt.Fprint(tabWriter, "Hub Name\tCountry\tRegion\tLatency\tCapacity\tCost\tGeo Prox.\tHub ID\tLifetime Usage\tPeriod Usage\tProt\tMine\n")
+	fmt.Fprint(tabWriter, "Hub Name\tCountry\tRegion\tLatency\tCapacity\tCost\tGeo Prox.\tLat. P50/P90 (24h)\tJitter (24h)\tFailures (24h)\tHub ID\tLifetime Usage\tPeriod Usage\tProt\tMine\n")
 	for _, pin := range list {
 		// Only print regarded Hubs.
 		if !matcher(pin) {
@@ -309,10 +377,11 @@ func handleMapMeasurementsTableRequest(ar *api.Request) (data []byte, err error)
 		}
 
 		// Add row.
+		stats := pin.measurements.GetStats(24 * time.Hour)
 		pin.measurements.Lock()
 		defer pin.measurements.Unlock()
 		fmt.Fprintf(tabWriter,
-			"%s\t%s\t%s\t%s\t%.2fMbit/s\t%.2fc\t%.2f%%\t%s",
+			"%s\t%s\t%s\t%s\t%.2fMbit/s\t%.2fc\t%.2f%%\t%s/%s\t%s\t%d/%d\t%s",
 			pin.Hub.Info.Name,
 			getPinCountry(pin),
 			pin.region.getName(),
@@ -320,6 +389,11 @@ func handleMapMeasurementsTableRequest(ar *api.Request) (data []byte, err error)
 			float64(pin.measurements.Capacity)/1000000,
 			pin.measurements.CalculatedCost,
 			pin.measurements.GeoProximity,
+			stats.LatencyP50,
+			stats.LatencyP90,
+			stats.LatencyJitter,
+			stats.Failures,
+			stats.Samples,
 			pin.Hub.ID,
 		)
 
@@ -365,6 +439,64 @@ func handleMapMeasurementsTableRequest(ar *api.Request) (data []byte, err error)
 	return buf.Bytes(), nil
 }
 
+func handleMapRouteExplainRequest(ar *api.Request) (i interface{}, err error) {
+	// Get map.
+	m, ok := getMapForAPI(ar.URLVars["map"])
+	if !ok {
+		return nil, errors.New("map not found")
+	}
+
+	// Parse destination IP.
+	query := ar.URL.Query()
+	ip := net.ParseIP(query.Get("ip"))
+	if ip == nil {
+		return nil, errors.New("missing or invalid destination IP")
+	}
+
+	// Parse options.
+	opts := m.DefaultOptions()
+	if profile := query.Get("profile"); profile != "" {
+		opts.RoutingProfile = profile
+	}
+	if _, ok := query["trusted"]; ok {
+		opts.RequireTrustedDestinationHubs = true
+	}
+	if _, ok := query["probe"]; ok {
+		opts.ProbeDestinations = true
+	}
+	maxRoutes := 10
+	if maxValue := query.Get("max"); maxValue != "" {
+		maxRoutes, err = strconv.Atoi(maxValue)
+		if err != nil || maxRoutes <= 0 {
+			return nil, errors.New("invalid max routes value")
+		}
+	}
+
+	// Explain routes. The explanation also contains any error.
+	explanation, _ := m.ExplainRoutes(ip, opts, maxRoutes)
+	return explanation, nil
+}
+
+func handleMapSnapshotRequest(ar *api.Request) (i interface{}, err error) {
+	// Get map.
+	m, ok := getMapForAPI(ar.URLVars["map"])
+	if !ok {
+		return nil, errors.New("map not found")
+	}
+
+	return m.Snapshot()
+}
+
+func handleMapHealthRequest(ar *api.Request) (i interface{}, err error) {
+	// Get map.
+	m, ok := getMapForAPI(ar.URLVars["map"])
+	if !ok {
+		return nil, errors.New("map not found")
+	}
+
+	return m.Health(), nil
+}
+
 func getPinCountry(pin *Pin) string {
 	switch {
 	case pin.LocationV4 != nil && pin.LocationV4.Country.ISOCode != "":
@@ -0,0 +1,34 @@
+package navigator
+
+import (
+	"github.com/safing/portbase/config"
+)
+
+var (
+	// Probe Destinations
+	cfgOptionProbeDestinationsKey     = "spn/probeDestinations"
+	cfgOptionProbeDestinations        config.BoolOption
+	cfgOptionProbeDestinationsDefault = false
+	cfgOptionProbeDestinationsOrder   = 146
+)
+
+func prepConfig() error {
+	err := config.Register(&config.Option{
+		Name:           "Probe Destinations",
+		Key:            cfgOptionProbeDestinationsKey,
+		Description:    "Measure the latency from Destination Hubs to the destinations of connections and prefer the fastest Destination Hubs for further connections, instead of only selecting them by geo proximity.",
+		OptType:        config.OptTypeBool,
+		ExpertiseLevel: config.ExpertiseLevelExpert,
+		DefaultValue:   cfgOptionProbeDestinationsDefault,
+		Annotations: config.Annotations{
+			config.DisplayOrderAnnotation: cfgOptionProbeDestinationsOrder,
+			config.CategoryAnnotation:     "Advanced",
+		},
+	})
+	if err != nil {
+		return err
+	}
+	cfgOptionProbeDestinations = config.Concurrent.GetAsBool(cfgOptionProbeDestinationsKey, cfgOptionProbeDestinationsDefault)
+
+	return nil
+}
@@ -8,8 +8,16 @@ const (
 )
 
 // CalculateLaneCost calculates the cost of using a Lane based on the given
-// Lane latency and capacity.
-func CalculateLaneCost(latency time.Duration, capacity int) (cost float32) {
+// Lane latency, capacity, jitter, loss rate and age.
+// Zero values for jitter, loss rate and age are treated as unknown and do not
+// add any cost, as they are not reported by older Hubs.
+func CalculateLaneCost(
+	latency time.Duration,
+	capacity int,
+	jitter time.Duration,
+	lossRate float32,
+	age time.Duration,
+) (cost float32) {
 	// - One point for every ms in latency (linear)
 	if latency != 0 {
 		cost += float32(latency) / float32(time.Millisecond)
@@ -39,6 +47,29 @@ func CalculateLaneCost(latency time.Duration, capacity int) (cost float32) {
 		cost += 5 * ((cap10Gbit - float32(capacity)) / cap10Gbit)
 	}
 
+	// - One point for every ms in jitter (linear)
+	cost += float32(jitter) / float32(time.Millisecond)
+
+	// - 50 points for every percent of pings that timed out (linear)
+	//   Lanes use reliable transports, so timed out pings are stalls of the
+	//   lane, which hurt interactive connections just as packet loss does.
+	if lossRate > 0 {
+		cost += 5000 * lossRate
+	}
+
+	// - Between 0 and 50 points for young lanes, as they are less proven to be
+	//   stable.
+	switch {
+	case age == 0:
+		// Age is unknown.
+	case age < time.Hour:
+		cost += 50
+	case age < 24*time.Hour:
+		cost += 20
+	case age < 7*24*time.Hour:
+		cost += 5
+	}
+
 	return cost
 }
 
@@ -66,3 +97,19 @@ func CalculateDestinationCost(proximity float32) (cost float32) {
 	// make high distances exponentially more expensive.
 	return float32(distance*distance) / 10
 }
+
+// CalculateProbedDestinationCost calculates the cost of a destination hub to a
+// destination server based on the measured connect latency.
+func CalculateProbedDestinationCost(latency time.Duration, failed bool) (cost float32) {
+	// Use the maximum cost, if the destination could not be reached.
+	if failed {
+		return 1000
+	}
+
+	// - One point for every ms in latency (linear), capped at the maximum cost.
+	cost = float32(latency) / float32(time.Millisecond)
+	if cost > 1000 {
+		return 1000
+	}
+	return cost
+}
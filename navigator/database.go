@@ -130,6 +130,13 @@ func withdrawMapDatabase() error {
 }
 
 func (m *Map) PushPinChanges() {
+	// Offline maps are not connected to the database. Events are published
+	// directly, as offline maps are changed by a single user only.
+	if m.offline {
+		m.publishEvents()
+		return
+	}
+
 	module.StartWorker("push pin changes", m.pushPinChangesWorker)
 }
 
@@ -143,6 +150,9 @@ func (m *Map) pushPinChangesWorker(ctx context.Context) error {
 		}
 	}
 
+	// Publish typed events.
+	m.publishEvents()
+
 	return nil
 }
 
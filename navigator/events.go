@@ -0,0 +1,300 @@
+package navigator
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultEventBufferSize is the default amount of events a subscription
+// buffers.
+const DefaultEventBufferSize = 100
+
+// MapEventType is the type of a map event.
+type MapEventType uint8
+
+// Map event types.
+const (
+	EventPinAdded MapEventType = iota + 1
+	EventPinRemoved
+	EventPinStateChanged
+	EventLaneAdded
+	EventLaneRemoved
+	EventLaneChanged
+	EventHomeHubChanged
+	EventOptimized
+	EventsDropped
+)
+
+func (t MapEventType) String() string {
+	switch t {
+	case EventPinAdded:
+		return "PinAdded"
+	case EventPinRemoved:
+		return "PinRemoved"
+	case EventPinStateChanged:
+		return "PinStateChanged"
+	case EventLaneAdded:
+		return "LaneAdded"
+	case EventLaneRemoved:
+		return "LaneRemoved"
+	case EventLaneChanged:
+		return "LaneChanged"
+	case EventHomeHubChanged:
+		return "HomeHubChanged"
+	case EventOptimized:
+		return "Optimized"
+	case EventsDropped:
+		return "EventsDropped"
+	default:
+		return "Unknown"
+	}
+}
+
+// MarshalText returns the name of the event type.
+func (t MapEventType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// MapEvent is a change on a map.
+type MapEvent struct {
+	Type MapEventType
+	Map  string
+	Time time.Time
+
+	// HubID is the ID of the Hub the event is about.
+	// For home hub changes, it is the new home hub.
+	HubID string `json:",omitempty"`
+
+	// OldState and NewState hold the Pin state before and after the change.
+	// They are set for state changes, added and removed Pins.
+	OldState PinState `json:",omitempty"`
+	NewState PinState `json:",omitempty"`
+
+	// Lane holds the lane of lane events.
+	Lane *LaneExport `json:",omitempty"`
+
+	// OldHomeHubID is the ID of the previous home hub.
+	OldHomeHubID string `json:",omitempty"`
+
+	// Optimization holds the optimization result of optimization events.
+	Optimization *OptimizationResult `json:",omitempty"`
+
+	// Dropped is the amount of events that were dropped because the
+	// subscriber did not keep up. Subscribers should resync their full state
+	// when receiving this event.
+	Dropped int `json:",omitempty"`
+}
+
+// MapSubscription is a subscription to map events.
+type MapSubscription struct {
+	m      *Map
+	events chan *MapEvent
+
+	// dropped counts the events dropped since the last delivered event.
+	// Guarded by the map's subscribersLock.
+	dropped int
+	// canceled signifies that the subscription was canceled.
+	// Guarded by the map's subscribersLock.
+	canceled bool
+}
+
+// publishedPin holds the state of a Pin when events were last published.
+type publishedPin struct {
+	state PinState
+	lanes map[string]LaneExport
+}
+
+// mapEvents holds the event subscriptions and the last published state of a
+// map.
+type mapEvents struct {
+	subscribersLock sync.Mutex
+	subscribers     []*MapSubscription
+
+	// publishLock guards the published state.
+	publishLock   sync.Mutex
+	publishedPins map[string]*publishedPin
+	publishedHome string
+}
+
+// Subscribe subscribes to the events of the map. The subscription buffers
+// up to bufferSize events. If the subscriber does not keep up, further events
+// are dropped and an EventsDropped event is delivered as soon as there is
+// space again. The map never waits for subscribers.
+func (m *Map) Subscribe(bufferSize int) *MapSubscription {
+	if bufferSize <= 0 {
+		bufferSize = DefaultEventBufferSize
+	}
+
+	sub := &MapSubscription{
+		m:      m,
+		events: make(chan *MapEvent, bufferSize),
+	}
+
+	m.events.subscribersLock.Lock()
+	defer m.events.subscribersLock.Unlock()
+
+	m.events.subscribers = append(m.events.subscribers, sub)
+	return sub
+}
+
+// Events returns the channel on which events are delivered. It is closed
+// when the subscription is canceled.
+func (sub *MapSubscription) Events() <-chan *MapEvent {
+	return sub.events
+}
+
+// Cancel cancels the subscription and closes the events channel.
+func (sub *MapSubscription) Cancel() {
+	sub.m.events.subscribersLock.Lock()
+	defer sub.m.events.subscribersLock.Unlock()
+
+	if sub.canceled {
+		return
+	}
+	sub.canceled = true
+	close(sub.events)
+
+	// Remove from subscribers.
+	for i, s := range sub.m.events.subscribers {
+		if s == sub {
+			sub.m.events.subscribers = append(sub.m.events.subscribers[:i], sub.m.events.subscribers[i+1:]...)
+			break
+		}
+	}
+}
+
+// emitEvents sends the given events to all subscribers without blocking.
+func (m *Map) emitEvents(events ...*MapEvent) {
+	m.events.subscribersLock.Lock()
+	defer m.events.subscribersLock.Unlock()
+
+	for _, sub := range m.events.subscribers {
+		for _, event := range events {
+			sub.send(event)
+		}
+	}
+}
+
+// send sends the event to the subscriber or drops it, if the buffer is full.
+// The map's subscribersLock must be held.
+func (sub *MapSubscription) send(event *MapEvent) {
+	// Notify about dropped events first.
+	if sub.dropped > 0 {
+		select {
+		case sub.events <- &MapEvent{
+			Type:    EventsDropped,
+			Map:     event.Map,
+			Time:    event.Time,
+			Dropped: sub.dropped,
+		}:
+			sub.dropped = 0
+		default:
+			sub.dropped++
+			return
+		}
+	}
+
+	select {
+	case sub.events <- event:
+	default:
+		sub.dropped++
+	}
+}
+
+// publishEvents compares the map with the last published state and emits
+// events for all changes. Subscribers should load the current state of the map
+// after subscribing and then apply the events.
+// The map must be at least read locked.
+func (m *Map) publishEvents() {
+	m.events.publishLock.Lock()
+	defer m.events.publishLock.Unlock()
+
+	if m.events.publishedPins == nil {
+		m.events.publishedPins = make(map[string]*publishedPin, len(m.all))
+	}
+
+	now := time.Now()
+	var events []*MapEvent
+	newEvent := func(eventType MapEventType, hubID string) *MapEvent {
+		event := &MapEvent{
+			Type:  eventType,
+			Map:   m.Name,
+			Time:  now,
+			HubID: hubID,
+		}
+		events = append(events, event)
+		return event
+	}
+
+	// Check for removed Pins.
+	for hubID, published := range m.events.publishedPins {
+		if _, ok := m.all[hubID]; !ok {
+			newEvent(EventPinRemoved, hubID).OldState = published.state
+			delete(m.events.publishedPins, hubID)
+		}
+	}
+
+	// Check for added and changed Pins.
+	for _, pin := range m.sortedPins(false) {
+		published, ok := m.events.publishedPins[pin.Hub.ID]
+		if !ok {
+			newEvent(EventPinAdded, pin.Hub.ID).NewState = pin.State
+			published = &publishedPin{
+				state: pin.State,
+				lanes: make(map[string]LaneExport),
+			}
+			m.events.publishedPins[pin.Hub.ID] = published
+		}
+
+		// Check state.
+		if published.state != pin.State {
+			event := newEvent(EventPinStateChanged, pin.Hub.ID)
+			event.OldState = published.state
+			event.NewState = pin.State
+			published.state = pin.State
+		}
+
+		// Check lanes.
+		for peerID, lane := range published.lanes {
+			if _, ok := pin.ConnectedTo[peerID]; !ok {
+				lane := lane
+				newEvent(EventLaneRemoved, pin.Hub.ID).Lane = &lane
+				delete(published.lanes, peerID)
+			}
+		}
+		for peerID, lane := range pin.ConnectedTo {
+			current := LaneExport{
+				HubID:    peerID,
+				Capacity: lane.Capacity,
+				Latency:  lane.Latency,
+				LossRate: lane.LossRate,
+				Jitter:   lane.Jitter,
+				Age:      lane.Age,
+			}
+			previous, ok := published.lanes[peerID]
+			switch {
+			case !ok:
+				newEvent(EventLaneAdded, pin.Hub.ID).Lane = &current
+			case previous != current:
+				newEvent(EventLaneChanged, pin.Hub.ID).Lane = &current
+			default:
+				continue
+			}
+			published.lanes[peerID] = current
+		}
+	}
+
+	// Check home hub.
+	var homeID string
+	if m.home != nil {
+		homeID = m.home.Hub.ID
+	}
+	if homeID != m.events.publishedHome {
+		newEvent(EventHomeHubChanged, homeID).OldHomeHubID = m.events.publishedHome
+		m.events.publishedHome = homeID
+	}
+
+	if len(events) > 0 {
+		m.emitEvents(events...)
+	}
+}
@@ -0,0 +1,98 @@
+package navigator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapEvents(t *testing.T) {
+	m := createSnapshotTestMap(t, 10)
+
+	// Publish initial state before subscribing.
+	m.Lock()
+	m.publishEvents()
+	m.Unlock()
+
+	sub := m.Subscribe(100)
+	collect := func() map[MapEventType][]*MapEvent {
+		m.PushPinChanges()
+		collected := make(map[MapEventType][]*MapEvent)
+		for {
+			select {
+			case event := <-sub.Events():
+				collected[event.Type] = append(collected[event.Type], event)
+			default:
+				return collected
+			}
+		}
+	}
+
+	// Check that nothing is published without changes.
+	assert.Empty(t, collect())
+
+	// Change state.
+	m.Lock()
+	pin := m.all["hub-3"]
+	oldState := pin.State
+	pin.addStates(StateFailing)
+	m.Unlock()
+	events := collect()
+	if assert.Len(t, events[EventPinStateChanged], 1) {
+		assert.Equal(t, "hub-3", events[EventPinStateChanged][0].HubID)
+		assert.Equal(t, oldState, events[EventPinStateChanged][0].OldState)
+		assert.Equal(t, oldState|StateFailing, events[EventPinStateChanged][0].NewState)
+	}
+
+	// Remove a Hub, which also removes its lanes on the peers.
+	m.RemoveHub("hub-5")
+	events = collect()
+	assert.Len(t, events[EventPinRemoved], 1)
+	assert.Len(t, events[EventLaneRemoved], 3, "ring neighbors and hub-0 should lose their lane")
+	for _, event := range events[EventLaneRemoved] {
+		assert.Equal(t, "hub-5", event.Lane.HubID)
+	}
+
+	// Change home.
+	m.Lock()
+	m.setHome(m.all["hub-1"], nil)
+	m.Unlock()
+	events = collect()
+	if assert.Len(t, events[EventHomeHubChanged], 1) {
+		assert.Equal(t, "hub-1", events[EventHomeHubChanged][0].HubID)
+		assert.Equal(t, "hub-0", events[EventHomeHubChanged][0].OldHomeHubID)
+	}
+	assert.NotEmpty(t, events[EventPinStateChanged])
+
+	// Check optimization events.
+	_, err := m.Optimize(nil)
+	assert.NoError(t, err)
+	events = collect()
+	assert.Len(t, events[EventOptimized], 1)
+
+	// Check backpressure.
+	small := m.Subscribe(1)
+	m.Lock()
+	m.all["hub-6"].addStates(StateFailing)
+	m.all["hub-7"].addStates(StateFailing)
+	m.all["hub-8"].addStates(StateFailing)
+	m.Unlock()
+	m.PushPinChanges()
+	first := <-small.Events()
+	assert.Equal(t, EventPinStateChanged, first.Type)
+	m.Lock()
+	m.all["hub-9"].addStates(StateFailing)
+	m.Unlock()
+	m.PushPinChanges()
+	dropped := <-small.Events()
+	assert.Equal(t, EventsDropped, dropped.Type)
+	assert.Equal(t, 2, dropped.Dropped)
+
+	// Check canceling.
+	small.Cancel()
+	small.Cancel()
+	_, ok := <-small.Events()
+	assert.False(t, ok)
+	sub.Cancel()
+	assert.Empty(t, m.events.subscribers)
+}
@@ -0,0 +1,230 @@
+package navigator
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// maxExplainedRoutes defines how many rejected routes are recorded in
+// detail. All rejected routes are still counted by reason.
+const maxExplainedRoutes = 100
+
+// RouteExplanation holds information on why Hubs and routes were or were not
+// selected when finding routes.
+type RouteExplanation struct {
+	// Destination is the destination IP address routes were searched for.
+	Destination net.IP
+
+	// RoutingProfile is the ID of the routing profile that was used.
+	RoutingProfile string
+
+	// Pins holds an explanation for every Pin on the map.
+	Pins []*PinExplanation
+
+	// RejectedRoutes holds a sample of routes that were rejected during route
+	// exploration.
+	RejectedRoutes []*RejectedRoute
+
+	// RejectedRoutesByReason counts all rejected routes by reason.
+	RejectedRoutesByReason map[string]int
+
+	// Routes holds the found routes, if any.
+	Routes *Routes
+
+	// Error holds the error that occurred while finding routes, if any.
+	Error string
+}
+
+// PinExplanation holds information on why a Pin was or was not regarded for
+// routing.
+type PinExplanation struct {
+	HubID string
+	Name  string
+
+	// States holds the states of the Pin at the time of the explanation.
+	States []string
+
+	// TransitRejected holds the reason why the Pin cannot be used as a Transit
+	// Hub. It is empty if the Pin can be used.
+	TransitRejected string
+
+	// DestinationRejected holds the reason why the Pin cannot be used as a
+	// Destination Hub. It is empty if the Pin can be used.
+	DestinationRejected string
+
+	// DestinationCandidate signifies that the Pin was selected as one of the
+	// nearest Destination Hubs to the destination IP.
+	DestinationCandidate bool
+
+	// DestinationCost is the calculated cost between the Pin and the
+	// destination IP, if the Pin is a Destination Candidate.
+	DestinationCost float32
+}
+
+// Route rejection reasons in addition to the route compliance reasons.
+const (
+	rejectionReasonNotGoodEnough        = "route is worse than the already found routes"
+	rejectionReasonDestinationRejected  = "last hop is rejected as Destination Hub"
+	rejectionReasonNotDestinationNearby = "last hop is not a Destination Candidate"
+)
+
+// RejectedRoute represents a route that was rejected during route
+// exploration.
+type RejectedRoute struct {
+	// Path holds the Hub IDs of the route, including the Home Hub.
+	Path []string
+
+	// TotalCost is the sum of all costs of the route at the time it was
+	// rejected.
+	TotalCost float32
+
+	// Reason holds the reason why the route was rejected.
+	Reason string
+
+	// Explored signifies that the route was not usable as is, but was still
+	// explored further by adding more hops.
+	Explored bool
+}
+
+// ExplainRoutes finds routes to the given IP, like FindRoutes, but
+// additionally records why Hubs and routes were not used.
+// The returned explanation is always set, while an error is returned if the
+// route finding failed.
+func (m *Map) ExplainRoutes(ip net.IP, opts *Options, maxRoutes int) (*RouteExplanation, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	// Set default options if unset.
+	if opts == nil {
+		opts = m.defaultOptions()
+	}
+
+	// Create explanation and explain all Pins.
+	explain := &RouteExplanation{
+		Destination:            ip,
+		RoutingProfile:         opts.RoutingProfile,
+		RejectedRoutesByReason: make(map[string]int),
+	}
+	explain.explainPins(m.sortedPins(false), opts)
+
+	// Find routes and record the result.
+	routes, err := m.findRoutesToIP(ip, opts, maxRoutes, explain)
+	if err != nil {
+		explain.Error = err.Error()
+		return explain, err
+	}
+	explain.Routes = routes
+
+	return explain, nil
+}
+
+// explainPins records why the given Pins can or cannot be used as Transit
+// and Destination Hubs.
+func (e *RouteExplanation) explainPins(pins []*Pin, opts *Options) {
+	transitMatcher := opts.compileMatcher(TransitHub)
+	destinationMatcher := opts.compileMatcher(DestinationHub)
+
+	e.Pins = make([]*PinExplanation, 0, len(pins))
+	for _, pin := range pins {
+		e.Pins = append(e.Pins, &PinExplanation{
+			HubID:               pin.Hub.ID,
+			Name:                pin.Hub.Info.Name,
+			States:              pin.State.Export(),
+			TransitRejected:     transitMatcher.explain(pin),
+			DestinationRejected: destinationMatcher.explain(pin),
+		})
+	}
+}
+
+// addNearbyPins marks the given nearby Pins as Destination Candidates.
+func (e *RouteExplanation) addNearbyPins(nearby *nearbyPins) {
+	for _, nbPin := range nearby.pins {
+		for _, pinExplanation := range e.Pins {
+			if pinExplanation.HubID == nbPin.pin.Hub.ID {
+				pinExplanation.DestinationCandidate = true
+				pinExplanation.DestinationCost = nbPin.DstCost()
+				break
+			}
+		}
+	}
+}
+
+// addRejectedRoute records a rejected route.
+func (e *RouteExplanation) addRejectedRoute(route *Route, reason string, explored bool) {
+	e.RejectedRoutesByReason[reason]++
+
+	// Only record a sample of routes in detail.
+	if len(e.RejectedRoutes) >= maxExplainedRoutes {
+		return
+	}
+
+	path := make([]string, 0, len(route.Path))
+	for _, hop := range route.Path {
+		path = append(path, hop.pin.Hub.ID)
+	}
+	e.RejectedRoutes = append(e.RejectedRoutes, &RejectedRoute{
+		Path:      path,
+		TotalCost: route.TotalCost,
+		Reason:    reason,
+		Explored:  explored,
+	})
+}
+
+// explain returns a human readable reason why the given Pin does not match.
+// If the Pin matches, an empty string is returned.
+func (pm *compiledMatcher) explain(pin *Pin) string {
+	switch pm.check(pin) {
+	case matchOk:
+		return ""
+	case matchFailedRegard:
+		return fmt.Sprintf("missing required states: %s", pm.regard.remove(pin.State))
+	case matchFailedDisregard:
+		return fmt.Sprintf("has disqualifying states: %s", pin.State&pm.disregard)
+	case matchFailedHubPolicy:
+		return "denied by hub policy or advisory"
+	case matchFailedHomeHubPolicy:
+		return "denied by home hub policy or advisory"
+	case matchFailedDestinationHubPolicy:
+		return "denied by destination hub policy or advisory"
+	default:
+		return "unknown reason"
+	}
+}
+
+// String returns a human readable summary of the explanation.
+func (e *RouteExplanation) String() string {
+	var builder strings.Builder
+
+	// Write header.
+	fmt.Fprintf(&builder, "Route explanation for %s with profile %s:\n", e.Destination, e.RoutingProfile)
+	if e.Error != "" {
+		fmt.Fprintf(&builder, "Error: %s\n", e.Error)
+	}
+	if e.Routes != nil {
+		fmt.Fprintf(&builder, "Found %d routes.\n", len(e.Routes.All))
+	}
+
+	// Write rejected Pins.
+	for _, pin := range e.Pins {
+		if pin.TransitRejected != "" {
+			fmt.Fprintf(&builder, "Hub %s rejected as transit: %s\n", pin.HubID, pin.TransitRejected)
+		}
+		if pin.DestinationRejected != "" {
+			fmt.Fprintf(&builder, "Hub %s rejected as destination: %s\n", pin.HubID, pin.DestinationRejected)
+		}
+	}
+
+	// Write rejected route stats.
+	reasons := make([]string, 0, len(e.RejectedRoutesByReason))
+	for reason, cnt := range e.RejectedRoutesByReason {
+		reasons = append(reasons, fmt.Sprintf("Rejected routes: %d %s", cnt, reason))
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Fprintln(&builder, reason)
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,86 @@
+package navigator
+
+import (
+	"testing"
+
+	"github.com/safing/spn/hub"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatcherExplain(t *testing.T) {
+	opts := &Options{}
+	transitMatcher := opts.compileMatcher(TransitHub)
+	destinationMatcher := opts.compileMatcher(DestinationHub)
+
+	p := &Pin{
+		Hub: &hub.Hub{ID: "test"},
+	}
+
+	p.addStates(StateReachable | StateActive)
+	assert.Equal(t, "", transitMatcher.explain(p))
+	assert.Equal(t, "", destinationMatcher.explain(p))
+
+	p.addStates(StateUsageAsDestinationDiscouraged)
+	assert.Equal(t, "", transitMatcher.explain(p))
+	assert.Equal(t, "has disqualifying states: UsageAsDestinationDiscouraged", destinationMatcher.explain(p))
+
+	p.removeStates(StateActive)
+	assert.Equal(t, "missing required states: Active", transitMatcher.explain(p))
+}
+
+func TestExplainRoutes(t *testing.T) {
+	// Create map and lock faking in order to guarantee reproducability of faked data.
+	m := getOptimizedDefaultTestMap(t)
+	fakeLock.Lock()
+	defer fakeLock.Unlock()
+
+	// Create a random destination address.
+	dstIP, _ := createGoodIP(true)
+
+	explanation, err := m.ExplainRoutes(dstIP, m.DefaultOptions(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, explanation.Pins, len(m.all), "all pins should be explained")
+
+	// Check if the selected routes only use Pins that were not rejected.
+	rejected := make(map[string]bool)
+	for _, pin := range explanation.Pins {
+		if pin.TransitRejected != "" {
+			rejected[pin.HubID] = true
+		}
+	}
+	for _, route := range explanation.Routes.All {
+		for _, hop := range route.Path[1:] {
+			assert.False(t, rejected[hop.HubID], "route uses rejected hub %s", hop.HubID)
+		}
+	}
+
+	t.Logf("explanation:\n%s", explanation)
+}
+
+func TestExplainRejectedRoutes(t *testing.T) {
+	m := createSnapshotTestMap(t, 10)
+
+	// Only allow hub-3 as Destination Hub.
+	dsts := &nearbyPins{
+		pins: []*nearbyPin{{pin: m.all["hub-3"], proximity: 100}},
+	}
+	explain := &RouteExplanation{
+		RejectedRoutesByReason: make(map[string]int),
+	}
+	_, err := m.findRoutes(dsts, m.defaultOptions(), 10, explain)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Check that routes are also recorded when they are explored further.
+	assert.NotZero(t, explain.RejectedRoutesByReason[complianceReasonTooShort], "short routes should be recorded")
+	assert.NotZero(t, explain.RejectedRoutesByReason[rejectionReasonNotDestinationNearby], "routes to other hubs should be recorded")
+	for _, route := range explain.RejectedRoutes {
+		switch route.Reason {
+		case complianceReasonTooShort, rejectionReasonNotDestinationNearby:
+			assert.True(t, route.Explored, "route %v should be explored", route.Path)
+		}
+	}
+}
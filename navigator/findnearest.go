@@ -23,6 +23,9 @@ type nearbyPins struct {
 type nearbyPin struct {
 	pin       *Pin
 	proximity float32
+
+	// probe holds the result of the Pin probing the destination, if available.
+	probe *DestinationProbeResult
 }
 
 // Len is the number of elements in the collection.
@@ -99,6 +102,9 @@ func (nb *nearbyPins) clean() {
 
 // nearbyPin represents a Pin and the proximity to a certain location.
 func (nb *nearbyPin) DstCost() float32 {
+	if nb.probe != nil {
+		return CalculateProbedDestinationCost(nb.probe.Latency, nb.probe.Failed)
+	}
 	return CalculateDestinationCost(nb.proximity)
 }
 
@@ -8,10 +8,18 @@ import (
 	"github.com/safing/portmaster/intel/geoip"
 )
 
+// FindRoutes finds possible routes to the given IP, with the given options.
 func (m *Map) FindRoutes(ip net.IP, opts *Options, maxRoutes int) (*Routes, error) {
 	m.Lock()
 	defer m.Unlock()
 
+	return m.findRoutesToIP(ip, opts, maxRoutes, nil)
+}
+
+// findRoutesToIP finds possible routes to the given IP, with the given
+// options. If explain is set, routing decisions are recorded in it.
+// The caller must hold the map lock.
+func (m *Map) findRoutesToIP(ip net.IP, opts *Options, maxRoutes int, explain *RouteExplanation) (*Routes, error) {
 	// Check if map is populated.
 	if m.isEmpty() {
 		return nil, ErrEmptyMap
@@ -29,15 +37,15 @@ func (m *Map) FindRoutes(ip net.IP, opts *Options, maxRoutes int) (*Routes, erro
 
 	// Handle special home routing profile.
 	if opts.RoutingProfile == RoutingProfileHomeName {
-		return &Routes{
+		routes := &Routes{
 			All: []*Route{&Route{
 				Path: []*Hop{&Hop{
-					pin:   m.home,
-					HubID: m.home.Hub.ID,
+					pin: m.home,
 				}},
-				Algorithm: RoutingProfileHomeName,
 			}},
-		}, nil
+		}
+		routes.makeExportReady(RoutingProfileHomeName)
+		return routes, nil
 	}
 
 	// Get the location of the given IP address.
@@ -58,11 +66,17 @@ func (m *Map) FindRoutes(ip net.IP, opts *Options, maxRoutes int) (*Routes, erro
 	if err != nil {
 		return nil, err
 	}
+	if opts.ProbeDestinations {
+		m.applyDestinationProbes(ip, nearby, opts.Matcher(DestinationHub))
+	}
+	if explain != nil {
+		explain.addNearbyPins(nearby)
+	}
 
-	return m.findRoutes(nearby, opts, maxRoutes)
+	return m.findRoutes(nearby, opts, maxRoutes, explain)
 }
 
-func (m *Map) findRoutes(dsts *nearbyPins, opts *Options, maxRoutes int) (*Routes, error) {
+func (m *Map) findRoutes(dsts *nearbyPins, opts *Options, maxRoutes int, explain *RouteExplanation) (*Routes, error) {
 	if m.home == nil {
 		return nil, ErrHomeHubUnset
 	}
@@ -114,17 +128,21 @@ func (m *Map) findRoutes(dsts *nearbyPins, opts *Options, maxRoutes int) (*Route
 		}
 
 		// Add Pin to the current path and remove when done.
-		route.addHop(lane.Pin, lane.Cost+lane.Pin.Cost)
+		route.addHop(lane.Pin, routingProfile.hopCost(route, lane))
 		defer route.removeHop()
 
 		// Check if the route would even make it into the list.
 		if !routes.isGoodEnough(route) {
+			if explain != nil {
+				explain.addRejectedRoute(route, rejectionReasonNotGoodEnough, false)
+			}
 			return
 		}
 
 		// Check route compliance.
 		// This also includes some algorithm-based optimizations.
-		switch routingProfile.checkRouteCompliance(route, routes) {
+		compliance, reason := routingProfile.checkRouteCompliance(route, routes)
+		switch compliance {
 		case routeOk:
 			// Route would be compliant.
 			// Now, check if the last hop qualifies as a Destination Hub.
@@ -140,15 +158,24 @@ func (m *Map) findRoutes(dsts *nearbyPins, opts *Options, maxRoutes int) (*Route
 					// We have found a route and have come to an end here.
 					return
 				}
+				reason = rejectionReasonNotDestinationNearby
+			} else {
+				reason = rejectionReasonDestinationRejected
 			}
 
 			// The Route is compliant, but we haven't found a Destination Hub yet.
 			fallthrough
 		case routeNonCompliant:
 			// Continue exploration.
+			if explain != nil {
+				explain.addRejectedRoute(route, reason, true)
+			}
 			exploreLanes(route)
 		default:
 			// Route is disqualified and we can return without further exploration.
+			if explain != nil {
+				explain.addRejectedRoute(route, reason, false)
+			}
 		}
 	}
 
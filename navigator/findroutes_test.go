@@ -3,6 +3,8 @@ package navigator
 import (
 	"net"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestFindRoutes(t *testing.T) {
@@ -50,3 +52,63 @@ func BenchmarkFindRoutes(b *testing.B) {
 		}
 	}
 }
+
+func TestFindRoutesWithRegions(t *testing.T) {
+	// Create an optimized offline map with regions.
+	sim, err := NewSimulation(GenerateSimulationSnapshot(1, 30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sim.Run(nil); err != nil {
+		t.Fatal(err)
+	}
+	m := sim.Map()
+
+	// Find a destination in the home region.
+	var dst *Pin
+	for _, pin := range m.sortedPins(false) {
+		if pin != m.home && pin.region != nil && pin.region == m.home.region {
+			dst = pin
+			break
+		}
+	}
+	if dst == nil {
+		t.Fatal("no destination in home region")
+	}
+	dsts := &nearbyPins{
+		pins: []*nearbyPin{{pin: dst, proximity: 100}},
+	}
+	findRoutes := func(profile string) *Routes {
+		opts := m.defaultOptions()
+		opts.RoutingProfile = profile
+		routes, err := m.findRoutes(dsts, opts, 10, nil)
+		if err != nil {
+			t.Fatalf("failed to find routes with profile %s: %s", profile, err)
+		}
+		return routes
+	}
+
+	// Check that region information is exported.
+	for _, route := range findRoutes(RoutingProfileDefaultName).All {
+		assert.NotEmpty(t, route.Regions)
+		for _, hop := range route.Path {
+			if hop.Pin().region != nil {
+				assert.Equal(t, hop.Pin().region.ID, hop.Region)
+				assert.NotEmpty(t, hop.RegionName)
+			} else {
+				assert.Empty(t, hop.Region)
+			}
+		}
+	}
+
+	// Check that the regional profile limits region transitions.
+	for _, route := range findRoutes(RoutingProfileRegionalName).All {
+		assert.LessOrEqual(t, route.RegionTransitions, RoutingProfileRegional.MaxRegionTransitions, route.String())
+	}
+
+	// Check that the diverse profile leaves the home region.
+	for _, route := range findRoutes(RoutingProfileDiverseName).All {
+		assert.GreaterOrEqual(t, len(route.Regions), 2, route.String())
+		assert.NotZero(t, route.RegionTransitions, route.String())
+	}
+}
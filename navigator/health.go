@@ -0,0 +1,387 @@
+package navigator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// healthDisregard holds the states that exclude a Hub from the health
+// analysis. Local states, such as failing, are not regarded, as the analysis
+// is about the network structure.
+const healthDisregard = StateInvalid | StateSuperseded | StateOffline
+
+// MapHealth holds the structural health of the map.
+type MapHealth struct {
+	Name string
+
+	// Hubs is the amount of Hubs that are part of the analysis.
+	Hubs int
+	// Lanes is the amount of lanes between the analyzed Hubs.
+	Lanes int
+
+	// Components holds all connected components of the map, largest first.
+	Components []*MapComponent
+
+	// Isolated holds the IDs of Hubs without any lanes.
+	Isolated []string
+
+	// ArticulationPoints holds all Hubs whose loss would split the map.
+	ArticulationPoints []*ArticulationPoint
+
+	// Regions holds the lane deficits of all regions.
+	Regions []*RegionHealth
+
+	// Warnings holds human-readable warnings about the map health.
+	Warnings []string
+}
+
+// MapComponent is a group of Hubs that can reach each other.
+type MapComponent struct {
+	// Hubs holds the IDs of the Hubs in the component.
+	Hubs []string
+}
+
+// ArticulationPoint is a Hub whose loss would split the map.
+type ArticulationPoint struct {
+	HubID string
+	Name  string
+
+	// Disconnected is the amount of Hubs that would be split off from the
+	// largest remaining part of its component.
+	Disconnected int
+}
+
+// RegionHealth holds the lane deficits of a region.
+type RegionHealth struct {
+	ID   string
+	Name string
+	Hubs int
+
+	// RegionalMinLanes is the minimum amount of lanes every other region
+	// should have to this region.
+	RegionalMinLanes int
+
+	// LanesFromRegions holds the amount of lanes from every other region.
+	LanesFromRegions map[string]int
+
+	// Deficits holds the amount of missing lanes from other regions.
+	// Only regions with a deficit are listed.
+	Deficits map[string]int
+
+	// TotalDeficit is the sum of all deficits.
+	TotalDeficit int
+}
+
+// Health analyzes and returns the structural health of the map.
+func (m *Map) Health() *MapHealth {
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.health()
+}
+
+// health analyzes the structural health of the map.
+// The map must be locked.
+func (m *Map) health() *MapHealth {
+	health := &MapHealth{
+		Name: m.Name,
+	}
+
+	// Collect regarded Pins.
+	pins := make([]*Pin, 0, len(m.all))
+	for _, pin := range m.sortedPins(false) {
+		if pin.State.has(StateActive) && !pin.State.hasAnyOf(healthDisregard) {
+			pins = append(pins, pin)
+		}
+	}
+	health.Hubs = len(pins)
+
+	// Build graph of the regarded Pins.
+	graph := newHealthGraph(pins)
+	health.Lanes = graph.lanes
+
+	// Analyze structure.
+	health.analyzeComponents(graph)
+	health.analyzeArticulationPoints(graph)
+	health.analyzeRegions(m.regions, graph)
+
+	return health
+}
+
+// healthGraph is an undirected graph of the regarded Pins.
+type healthGraph struct {
+	pins     []*Pin
+	adjacent [][]int
+	lanes    int
+}
+
+func newHealthGraph(pins []*Pin) *healthGraph {
+	graph := &healthGraph{
+		pins:     pins,
+		adjacent: make([][]int, len(pins)),
+	}
+
+	// Index Pins.
+	index := make(map[string]int, len(pins))
+	for i, pin := range pins {
+		index[pin.Hub.ID] = i
+	}
+
+	// Add lanes. Lanes are regarded as undirected, even if only one side has
+	// the lane.
+	added := make(map[[2]int]struct{})
+	for i, pin := range pins {
+		for _, lane := range pin.ConnectedTo {
+			j, ok := index[lane.Pin.Hub.ID]
+			if !ok || i == j {
+				continue
+			}
+			key := [2]int{i, j}
+			if j < i {
+				key = [2]int{j, i}
+			}
+			if _, ok := added[key]; ok {
+				continue
+			}
+			added[key] = struct{}{}
+
+			graph.adjacent[i] = append(graph.adjacent[i], j)
+			graph.adjacent[j] = append(graph.adjacent[j], i)
+			graph.lanes++
+		}
+	}
+
+	// Sort for stable results.
+	for _, adjacent := range graph.adjacent {
+		sort.Ints(adjacent)
+	}
+
+	return graph
+}
+
+func (health *MapHealth) analyzeComponents(graph *healthGraph) {
+	visited := make([]bool, len(graph.pins))
+	for start := range graph.pins {
+		if visited[start] {
+			continue
+		}
+
+		// Collect component with a breadth-first search.
+		component := &MapComponent{}
+		queue := []int{start}
+		visited[start] = true
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			component.Hubs = append(component.Hubs, graph.pins[current].Hub.ID)
+
+			for _, next := range graph.adjacent[current] {
+				if !visited[next] {
+					visited[next] = true
+					queue = append(queue, next)
+				}
+			}
+		}
+		sort.Strings(component.Hubs)
+		health.Components = append(health.Components, component)
+
+		// Check for isolated Hub.
+		if len(graph.adjacent[start]) == 0 {
+			health.Isolated = append(health.Isolated, graph.pins[start].Hub.ID)
+		}
+	}
+
+	// Sort components by size.
+	sort.SliceStable(health.Components, func(i, j int) bool {
+		return len(health.Components[i].Hubs) > len(health.Components[j].Hubs)
+	})
+
+	// Add warnings.
+	if len(health.Components) > 1 {
+		health.Warnings = append(health.Warnings, fmt.Sprintf(
+			"map is partitioned into %d components, %d Hubs are not part of the largest",
+			len(health.Components),
+			health.Hubs-len(health.Components[0].Hubs),
+		))
+	}
+	if len(health.Isolated) > 0 {
+		health.Warnings = append(health.Warnings, fmt.Sprintf(
+			"%d Hubs are isolated: %s",
+			len(health.Isolated),
+			strings.Join(health.Isolated, ", "),
+		))
+	}
+}
+
+// analyzeArticulationPoints finds all articulation points using Tarjan's
+// algorithm.
+func (health *MapHealth) analyzeArticulationPoints(graph *healthGraph) {
+	var (
+		counter   int
+		discovery = make([]int, len(graph.pins))
+		low       = make([]int, len(graph.pins))
+		subtree   = make([]int, len(graph.pins))
+		// splits holds the sizes of the subtrees that would be split off.
+		splits = make([][]int, len(graph.pins))
+	)
+
+	var visit func(node, parent int)
+	visit = func(node, parent int) {
+		counter++
+		discovery[node] = counter
+		low[node] = counter
+		subtree[node] = 1
+
+		for _, next := range graph.adjacent[node] {
+			switch {
+			case discovery[next] == 0:
+				visit(next, node)
+				subtree[node] += subtree[next]
+				if low[next] < low[node] {
+					low[node] = low[next]
+				}
+				// The subtree of next cannot reach above node without it.
+				if low[next] >= discovery[node] {
+					splits[node] = append(splits[node], subtree[next])
+				}
+			case next != parent && discovery[next] < low[node]:
+				low[node] = discovery[next]
+			}
+		}
+	}
+
+	for root := range graph.pins {
+		if discovery[root] != 0 {
+			continue
+		}
+		visit(root, -1)
+		componentSize := subtree[root]
+
+		// Check all nodes of the component.
+		for node := range graph.pins {
+			if discovery[node] < discovery[root] || discovery[node] >= discovery[root]+componentSize {
+				continue
+			}
+
+			// The root is always split from its only child, which is not an
+			// articulation point.
+			parts := splits[node]
+			if node == root && len(parts) < 2 {
+				continue
+			}
+			if len(parts) == 0 {
+				continue
+			}
+
+			// Calculate the remaining part and the largest part.
+			remaining := componentSize - 1
+			largest := 0
+			for _, size := range parts {
+				remaining -= size
+				if size > largest {
+					largest = size
+				}
+			}
+			if remaining > largest {
+				largest = remaining
+			}
+
+			health.ArticulationPoints = append(health.ArticulationPoints, &ArticulationPoint{
+				HubID:        graph.pins[node].Hub.ID,
+				Name:         graph.pins[node].Hub.Info.Name,
+				Disconnected: componentSize - 1 - largest,
+			})
+		}
+	}
+
+	// Sort by impact.
+	sort.SliceStable(health.ArticulationPoints, func(i, j int) bool {
+		return health.ArticulationPoints[i].Disconnected > health.ArticulationPoints[j].Disconnected
+	})
+
+	// Add warning.
+	if len(health.ArticulationPoints) > 0 {
+		ids := make([]string, 0, len(health.ArticulationPoints))
+		for _, ap := range health.ArticulationPoints {
+			ids = append(ids, ap.HubID)
+		}
+		health.Warnings = append(health.Warnings, fmt.Sprintf(
+			"%d Hubs would split the map if lost: %s",
+			len(ids),
+			strings.Join(ids, ", "),
+		))
+	}
+}
+
+func (health *MapHealth) analyzeRegions(regions []*Region, graph *healthGraph) {
+	for _, region := range regions {
+		regionHealth := &RegionHealth{
+			ID:               region.ID,
+			Name:             region.getName(),
+			Hubs:             len(region.pins),
+			RegionalMinLanes: region.regionalMinLanes,
+			LanesFromRegions: make(map[string]int),
+			Deficits:         make(map[string]int),
+		}
+
+		// Count lanes from other regions.
+		for i, pin := range graph.pins {
+			if pin.region == nil || pin.region.ID != region.ID {
+				continue
+			}
+			for _, j := range graph.adjacent[i] {
+				other := graph.pins[j].region
+				if other != nil && other.ID != region.ID {
+					regionHealth.LanesFromRegions[other.ID]++
+				}
+			}
+		}
+
+		// Calculate deficits.
+		for _, otherRegion := range regions {
+			if otherRegion.ID == region.ID {
+				continue
+			}
+			deficit := region.regionalMinLanes - regionHealth.LanesFromRegions[otherRegion.ID]
+			if deficit > 0 {
+				regionHealth.Deficits[otherRegion.ID] = deficit
+				regionHealth.TotalDeficit += deficit
+			}
+		}
+
+		// Add warning.
+		if regionHealth.TotalDeficit > 0 {
+			health.Warnings = append(health.Warnings, fmt.Sprintf(
+				"region %s is missing %d lanes from other regions",
+				regionHealth.Name,
+				regionHealth.TotalDeficit,
+			))
+		}
+
+		health.Regions = append(health.Regions, regionHealth)
+	}
+}
+
+func (health *MapHealth) String() string {
+	var builder strings.Builder
+
+	fmt.Fprintf(&builder, "Health of Map %s:\n", health.Name)
+	fmt.Fprintf(&builder, "Hubs: %d, Lanes: %d, Components: %d\n", health.Hubs, health.Lanes, len(health.Components))
+	for _, ap := range health.ArticulationPoints {
+		fmt.Fprintf(&builder, "Articulation point %s (%s) would disconnect %d Hubs\n", ap.HubID, ap.Name, ap.Disconnected)
+	}
+	for _, region := range health.Regions {
+		fmt.Fprintf(&builder, "Region %s: %d Hubs, min lanes %d, from regions %v, deficits %v\n",
+			region.Name, region.Hubs, region.RegionalMinLanes, region.LanesFromRegions, region.Deficits,
+		)
+	}
+	if len(health.Warnings) == 0 {
+		fmt.Fprintln(&builder, "No warnings.")
+	}
+	for _, warning := range health.Warnings {
+		fmt.Fprintf(&builder, "Warning: %s\n", warning)
+	}
+
+	return builder.String()
+}
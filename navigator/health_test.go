@@ -0,0 +1,89 @@
+package navigator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapHealth(t *testing.T) {
+	m := createSnapshotTestMap(t, 10)
+
+	// Check healthy map.
+	health := m.Health()
+	assert.Equal(t, 10, health.Hubs)
+	assert.Len(t, health.Components, 1)
+	assert.Empty(t, health.Isolated)
+	assert.Empty(t, health.ArticulationPoints)
+	assert.Empty(t, health.Warnings)
+
+	// Rebuild lanes as: triangle 0-1-2, bridge 2-3, line 3-4-5, isolated 6,
+	// pair 7-8, and 9 being offline.
+	m.Lock()
+	for _, pin := range m.all {
+		pin.ConnectedTo = make(map[string]*Lane)
+	}
+	link := func(a, b string) {
+		pinA, pinB := m.all[a], m.all[b]
+		pinA.ConnectedTo[b] = &Lane{Pin: pinB}
+		pinB.ConnectedTo[a] = &Lane{Pin: pinA}
+	}
+	link("hub-0", "hub-1")
+	link("hub-1", "hub-2")
+	link("hub-2", "hub-0")
+	link("hub-2", "hub-3")
+	link("hub-3", "hub-4")
+	link("hub-4", "hub-5")
+	link("hub-7", "hub-8")
+	m.all["hub-9"].addStates(StateOffline)
+	m.Unlock()
+
+	// Check partitioned map.
+	health = m.Health()
+	t.Log(health)
+	assert.Equal(t, 9, health.Hubs)
+	assert.Equal(t, 7, health.Lanes)
+	if assert.Len(t, health.Components, 3) {
+		assert.Equal(t, []string{"hub-0", "hub-1", "hub-2", "hub-3", "hub-4", "hub-5"}, health.Components[0].Hubs)
+		assert.Equal(t, []string{"hub-7", "hub-8"}, health.Components[1].Hubs)
+		assert.Equal(t, []string{"hub-6"}, health.Components[2].Hubs)
+	}
+	assert.Equal(t, []string{"hub-6"}, health.Isolated)
+
+	// Check articulation points.
+	disconnected := make(map[string]int)
+	for _, ap := range health.ArticulationPoints {
+		disconnected[ap.HubID] = ap.Disconnected
+	}
+	assert.Equal(t, map[string]int{
+		"hub-2": 2, // Splits off 3-4-5 from 0-1, or the other way round.
+		"hub-3": 2, // Splits off 4-5.
+		"hub-4": 1, // Splits off 5.
+	}, disconnected)
+	assert.Equal(t, "hub-2", health.ArticulationPoints[0].HubID)
+	assert.Len(t, health.Warnings, 3)
+}
+
+func TestMapHealthRegions(t *testing.T) {
+	sim, err := NewSimulation(GenerateSimulationSnapshot(1, 30))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without lanes, all regions have deficits.
+	health := sim.Map().Health()
+	assert.Len(t, health.Regions, 3)
+	for _, region := range health.Regions {
+		assert.Equal(t, 2*region.RegionalMinLanes, region.TotalDeficit, region.ID)
+	}
+
+	// After optimizing, there should be no deficits and no partitions.
+	if _, err := sim.Run(nil); err != nil {
+		t.Fatal(err)
+	}
+	health = sim.Map().Health()
+	assert.Len(t, health.Components, 1)
+	for _, region := range health.Regions {
+		assert.Zero(t, region.TotalDeficit, "region %s: %v", region.ID, region.Deficits)
+	}
+}
@@ -28,6 +28,7 @@ func (m *Map) UpdateIntel(update *hub.Intel) error {
 	for _, pin := range m.all {
 		m.updateIntelStatuses(pin)
 		m.updateInfoOverrides(pin)
+		m.updateSupplyChain(pin)
 	}
 
 	// Configure the map's regions.
@@ -61,11 +62,9 @@ func (m *Map) updateIntelStatuses(pin *Pin) {
 	}
 
 	// Check if Hub is trusted.
-	for _, hubID := range m.intel.TrustedHubs {
-		if pin.Hub.ID == hubID {
-			pin.addStates(StateTrusted)
-			break
-		}
+	// Hubs that rotated their identity key keep the trust of their predecessor.
+	if m.inheritsTrust(pin.Hub.ID, m.intel.TrustedHubs) {
+		pin.addStates(StateTrusted)
 	}
 
 	// Check advisories.
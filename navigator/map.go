@@ -26,19 +26,35 @@ type Map struct {
 	measuringEnabled bool
 	hubUpdateHook    *database.RegisteredHook
 
+	// offline signifies that the map was loaded from a snapshot and must not
+	// use any external data sources, such as geoip or the database.
+	offline bool
+
 	// analysisLock guards access to all of this map's Pin.analysis,
 	// regardedPins and the lastDesegrationAttempt fields.
 	analysisLock           sync.Mutex
 	regardedPins           []*Pin
 	lastDesegrationAttempt time.Time
+
+	// events holds the event subscriptions.
+	events mapEvents
+
+	// successions holds the identity key rotations of Hubs by successor ID.
+	successions map[string]*pinSuccession
+
+	// probesLock guards destinationProbes.
+	probesLock        sync.Mutex
+	destinationProbes map[string]*DestinationProbe
 }
 
 // NewMap returns a new and empty Map.
 func NewMap(name string, enableMeasuring bool) *Map {
 	m := &Map{
-		Name:             name,
-		all:              make(map[string]*Pin),
-		measuringEnabled: enableMeasuring,
+		Name:              name,
+		all:               make(map[string]*Pin),
+		measuringEnabled:  enableMeasuring,
+		destinationProbes: make(map[string]*DestinationProbe),
+		successions:       make(map[string]*pinSuccession),
 	}
 	addMapToAPI(m)
 
@@ -46,6 +62,11 @@ func NewMap(name string, enableMeasuring bool) *Map {
 }
 
 func (m *Map) Close() {
+	// Offline maps are not added to the API.
+	if m.offline {
+		return
+	}
+
 	removeMapFromAPI(m.Name)
 }
 
@@ -79,6 +100,14 @@ func (m *Map) SetHome(id string, t *docks.CraneTerminal) (ok bool) {
 		return false
 	}
 
+	m.setHome(newHome, t)
+	m.PushPinChanges()
+	return true
+}
+
+// setHome sets the given Pin as the home and recalculates the reachable Hubs.
+// The caller must hold the map lock.
+func (m *Map) setHome(newHome *Pin, t *docks.CraneTerminal) {
 	// Remove home hub state from all pins.
 	for _, pin := range m.all {
 		pin.removeStates(StateIsHomeHub)
@@ -94,9 +123,6 @@ func (m *Map) SetHome(id string, t *docks.CraneTerminal) (ok bool) {
 	if err != nil {
 		log.Warningf("spn/navigator: failed to recalculate reachable hubs: %s", err)
 	}
-
-	m.PushPinChanges()
-	return true
 }
 
 // isEmpty returns whether the Map is regarded as empty.
@@ -189,6 +189,7 @@ func createFakeHub(group string, randomFailes bool, mapIntel *hub.Intel) *hub.Hu
 	h.Measurements.CalculatedCost = CalculateLaneCost(
 		h.Measurements.Latency,
 		h.Measurements.Capacity,
+		0, 0, 0,
 	)
 
 	// Return if not failures of any kind should be simulated.
@@ -76,9 +76,12 @@ func (m *Map) measureHubs(ctx context.Context, _ *modules.Task) error {
 		tErr := docks.MeasureHub(ctx, pin.Hub, checkWithTTL)
 
 		// Independent of outcome, recalculate the cost.
-		latency, _ := pin.measurements.GetLatency()
-		capacity, _ := pin.measurements.GetCapacity()
-		calculatedCost := CalculateLaneCost(latency, capacity)
+		// Use smoothed values in order to not react to single outliers.
+		latency := pin.measurements.GetSmoothedLatency()
+		capacity := pin.measurements.GetSmoothedCapacity()
+		jitter := pin.measurements.GetSmoothedJitter()
+		lossRate := pin.measurements.GetSmoothedLossRate()
+		calculatedCost := CalculateLaneCost(latency, capacity, jitter, lossRate, 0)
 		pin.measurements.SetCalculatedCost(calculatedCost)
 		// Log result.
 		log.Infof(
@@ -105,6 +108,7 @@ func (m *Map) measureHubs(ctx context.Context, _ *modules.Task) error {
 
 		default:
 			log.Warningf("navigator: failed to measure connection to %s: %s", pin.Hub, tErr)
+			pin.measurements.AddFailure()
 			unknownErrCnt++
 			if unknownErrCnt >= 3 {
 				log.Warningf("navigator: postponing measuring task because of multiple errors")
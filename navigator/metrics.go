@@ -72,6 +72,60 @@ func registerMetrics() (err error) {
 		return err
 	}
 
+	// Map Health.
+
+	_, err = metrics.NewGauge(
+		"spn/map/main/health/components/total",
+		nil,
+		getComponents,
+		&metrics.Options{
+			Name:       "SPN Map Connected Components",
+			Permission: api.PermitUser,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = metrics.NewGauge(
+		"spn/map/main/health/hubs/isolated/total",
+		nil,
+		getIsolatedHubs,
+		&metrics.Options{
+			Name:       "SPN Map Isolated Hubs",
+			Permission: api.PermitUser,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = metrics.NewGauge(
+		"spn/map/main/health/hubs/articulation/total",
+		nil,
+		getArticulationPoints,
+		&metrics.Options{
+			Name:       "SPN Map Articulation Point Hubs",
+			Permission: api.PermitUser,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = metrics.NewGauge(
+		"spn/map/main/health/regions/deficit/lanes",
+		nil,
+		getRegionLaneDeficit,
+		&metrics.Options{
+			Name:       "SPN Map Missing Region Lanes",
+			Permission: api.PermitUser,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -87,12 +141,21 @@ type mapMetrics struct {
 	lowestForeignASLatency   float64
 	highestCapacity          float64
 	highestForeignASCapacity float64
+
+	components         float64
+	isolatedHubs       float64
+	articulationPoints float64
+	regionLaneDeficit  float64
 }
 
 func getLowestLatency() float64          { return getMapStats().lowestLatency }
 func getLowestLatencyFromFas() float64   { return getMapStats().lowestForeignASLatency }
 func getHighestCapacity() float64        { return getMapStats().highestCapacity }
 func getHighestCapacityFromFas() float64 { return getMapStats().highestForeignASCapacity }
+func getComponents() float64             { return getMapStats().components }
+func getIsolatedHubs() float64           { return getMapStats().isolatedHubs }
+func getArticulationPoints() float64     { return getMapStats().articulationPoints }
+func getRegionLaneDeficit() float64      { return getMapStats().regionLaneDeficit }
 
 func getMapStats() *mapMetrics {
 	mapStatsLock.Lock()
@@ -106,6 +169,15 @@ func getMapStats() *mapMetrics {
 	// Refresh.
 	mapStats = &mapMetrics{}
 
+	// Analyze map health.
+	health := Main.Health()
+	mapStats.components = float64(len(health.Components))
+	mapStats.isolatedHubs = float64(len(health.Isolated))
+	mapStats.articulationPoints = float64(len(health.ArticulationPoints))
+	for _, region := range health.Regions {
+		mapStats.regionLaneDeficit += float64(region.TotalDeficit)
+	}
+
 	// Get all pins and home.
 	list := Main.pinList(true)
 	home, _ := Main.GetHome()
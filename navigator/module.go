@@ -21,6 +21,10 @@ var (
 	module *modules.Module
 	Main   *Map
 
+	// Private is the map of a private network that is used alongside the
+	// main map, if configured.
+	Private *Map
+
 	devMode config.BoolOption
 )
 
@@ -29,6 +33,10 @@ func init() {
 }
 
 func prep() error {
+	if err := prepConfig(); err != nil {
+		return err
+	}
+
 	return registerAPIEndpoints()
 }
 
@@ -47,8 +55,28 @@ func start() error {
 		return err
 	}
 
+	// Start the map of a private network used alongside the main map.
+	if conf.PrivateMapName != "" {
+		Private = NewMap(conf.PrivateMapName, true)
+		Private.InitializeFromDatabase()
+		err = Private.RegisterHubUpdateHook()
+		if err != nil {
+			return err
+		}
+		module.NewTask("update private reputations", Private.updateReputations).
+			Repeat(1 * time.Minute).
+			Schedule(time.Now().Add(1 * time.Minute))
+		module.NewTask("update private states", Private.updateStates).
+			Repeat(1 * time.Hour).
+			Schedule(time.Now().Add(3 * time.Minute))
+	}
+
 	// TODO: delete superseded hubs after x amount of time
 
+	module.NewTask("update reputations", Main.updateReputations).
+		Repeat(1 * time.Minute).
+		Schedule(time.Now().Add(1 * time.Minute))
+
 	module.NewTask("update states", Main.updateStates).
 		Repeat(1 * time.Hour).
 		Schedule(time.Now().Add(3 * time.Minute))
@@ -72,7 +100,15 @@ func stop() error {
 
 	Main.CancelHubUpdateHook()
 	Main.SaveMeasuredHubs()
+	Main.SaveReputations()
 	Main.Close()
 
+	if Private != nil {
+		Private.CancelHubUpdateHook()
+		Private.SaveMeasuredHubs()
+		Private.SaveReputations()
+		Private.Close()
+	}
+
 	return nil
 }
@@ -193,7 +193,20 @@ func (m *Map) Optimize(opts *Options) (result *OptimizationResult, err error) {
 		opts = m.defaultOptions()
 	}
 
-	return m.optimize(opts)
+	result, err = m.optimize(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Publish optimization result.
+	m.emitEvents(&MapEvent{
+		Type:         EventOptimized,
+		Map:          m.Name,
+		Time:         time.Now(),
+		Optimization: result,
+	})
+
+	return result, nil
 }
 
 func (m *Map) optimize(opts *Options) (result *OptimizationResult, err error) {
@@ -368,6 +381,7 @@ func (m *Map) optimizeForLowestCost(result *OptimizationResult, max int) error {
 
 	// Sort by lowest cost.
 	sort.Sort(sortByLowestMeasuredCost(m.regardedPins))
+	m.deprioritizeSameDatacenter(m.regardedPins)
 
 	// Add to suggested pins.
 	if len(m.regardedPins) <= max {
@@ -41,6 +41,7 @@ func (m *Map) optimizeForLowestCostInRegion(result *OptimizationResult) error {
 
 	// Sort by lowest cost.
 	sort.Sort(sortByLowestMeasuredCost(region.regardedPins))
+	m.deprioritizeSameDatacenter(region.regardedPins)
 
 	// Add to suggested pins.
 	if len(region.regardedPins) <= region.internalMinLanesOnHub {
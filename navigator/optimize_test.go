@@ -57,17 +57,17 @@ func (m *Map) optimizeTestMap(t *testing.T) {
 			lanesCreatedWithResult := 0
 			for _, connectToHub := range optimizeResult.SuggestedConnections {
 				// Check if lane to suggested Hub already exists.
-				if m.home.Hub.GetLaneTo(connectToHub.ID) != nil {
+				if m.home.Hub.GetLaneTo(connectToHub.Hub.ID) != nil {
 					continue
 				}
 
 				// Add lanes to the Hub status.
-				m.home.Hub.AddLane(createLane(connectToHub.ID))
-				connectToHub.AddLane(createLane(m.home.Hub.ID))
+				m.home.Hub.AddLane(createLane(connectToHub.Hub.ID))
+				connectToHub.Hub.AddLane(createLane(m.home.Hub.ID))
 
 				// Update Hubs in map.
 				m.UpdateHub(m.home.Hub)
-				m.UpdateHub(connectToHub)
+				m.UpdateHub(connectToHub.Hub)
 				newLanes++
 				newLanesInRun++
 
@@ -180,6 +180,7 @@ func (mcf *measurementCachedFactory) getOrCreate(from, to string) *hub.Measureme
 	m.CalculatedCost = CalculateLaneCost(
 		m.Latency,
 		m.Capacity,
+		0, 0, 0,
 	)
 	mcf.cache[id] = m
 	return m
@@ -50,6 +50,11 @@ type Options struct {
 
 	// RoutingProfile defines the algorithm to use to find a route.
 	RoutingProfile string
+
+	// ProbeDestinations declares whether Destination Hubs should be selected
+	// based on their measured latency to the destination, if available, instead
+	// of only their estimated geo proximity.
+	ProbeDestinations bool
 }
 
 func (o *Options) Copy() *Options {
@@ -64,6 +69,7 @@ func (o *Options) Copy() *Options {
 		NoDefaults:                    o.NoDefaults,
 		RequireTrustedDestinationHubs: o.RequireTrustedDestinationHubs,
 		RoutingProfile:                o.RoutingProfile,
+		ProbeDestinations:             o.ProbeDestinations,
 	}
 }
 
@@ -81,6 +87,9 @@ func (m *Map) defaultOptions() *Options {
 	opts := &Options{
 		RoutingProfile: RoutingProfileDefaultName,
 	}
+	if cfgOptionProbeDestinations != nil {
+		opts.ProbeDestinations = cfgOptionProbeDestinations()
+	}
 
 	if m.intel != nil && m.intel.Parsed() != nil {
 		opts.HubPolicy = m.intel.Parsed().HubAdvisory
@@ -92,6 +101,36 @@ func (m *Map) defaultOptions() *Options {
 }
 
 func (o *Options) Matcher(hubType HubType) PinMatcher {
+	pm := o.compileMatcher(hubType)
+	return func(pin *Pin) bool {
+		return pm.check(pin) == matchOk
+	}
+}
+
+// compiledMatcher holds the compiled criteria of a PinMatcher.
+type compiledMatcher struct {
+	hubType   HubType
+	regard    PinState
+	disregard PinState
+
+	hubPolicy            endpoints.Endpoints
+	homeHubPolicy        endpoints.Endpoints
+	destinationHubPolicy endpoints.Endpoints
+}
+
+// matchCheck identifies a check of a PinMatcher.
+type matchCheck uint8
+
+const (
+	matchOk                         matchCheck = iota // All checks have passed.
+	matchFailedRegard                                 // Pin is missing required states.
+	matchFailedDisregard                              // Pin has disqualifying states.
+	matchFailedHubPolicy                              // Pin is denied by the Hub policy.
+	matchFailedHomeHubPolicy                          // Pin is denied by the Home Hub policy.
+	matchFailedDestinationHubPolicy                   // Pin is denied by the Destination Hub policy.
+)
+
+func (o *Options) compileMatcher(hubType HubType) *compiledMatcher {
 	// Compile states to regard and disregard.
 	regard := o.Regard
 	disregard := o.Disregard
@@ -120,46 +159,56 @@ func (o *Options) Matcher(hubType HubType) PinMatcher {
 	}
 
 	// Copy and activate applicable policies.
-	hubPolicy := o.HubPolicy
-	var homeHubPolicy endpoints.Endpoints
-	var destinationHubPolicy endpoints.Endpoints
+	pm := &compiledMatcher{
+		hubType:   hubType,
+		regard:    regard,
+		disregard: disregard,
+		hubPolicy: o.HubPolicy,
+	}
 	switch hubType {
 	case HomeHub:
-		homeHubPolicy = o.HomeHubPolicy
+		pm.homeHubPolicy = o.HomeHubPolicy
 	case DestinationHub:
-		destinationHubPolicy = o.DestinationHubPolicy
+		pm.destinationHubPolicy = o.DestinationHubPolicy
 	}
 
-	return func(pin *Pin) bool {
-		// Check required Pin States.
-		if !pin.State.has(regard) || pin.State.hasAnyOf(disregard) {
-			return false
-		}
+	return pm
+}
 
-		// Check main policy.
-		if hubPolicy != nil {
-			if endpointListMatch(hubPolicy, pin.EntityV4) == endpoints.Denied ||
-				endpointListMatch(hubPolicy, pin.EntityV6) == endpoints.Denied {
-				return false
-			}
-		}
+// check checks the given Pin against the compiled criteria and returns the
+// first check that failed.
+func (pm *compiledMatcher) check(pin *Pin) matchCheck {
+	// Check required Pin States.
+	if !pin.State.has(pm.regard) {
+		return matchFailedRegard
+	}
+	if pin.State.hasAnyOf(pm.disregard) {
+		return matchFailedDisregard
+	}
 
-		// Check type based policy.
-		switch {
-		case hubType == HomeHub && homeHubPolicy != nil:
-			if endpointListMatch(homeHubPolicy, pin.EntityV4) == endpoints.Denied ||
-				endpointListMatch(homeHubPolicy, pin.EntityV6) == endpoints.Denied {
-				return false
-			}
-		case hubType == DestinationHub && destinationHubPolicy != nil:
-			if endpointListMatch(destinationHubPolicy, pin.EntityV4) == endpoints.Denied ||
-				endpointListMatch(destinationHubPolicy, pin.EntityV6) == endpoints.Denied {
-				return false
-			}
+	// Check main policy.
+	if pm.hubPolicy != nil {
+		if endpointListMatch(pm.hubPolicy, pin.EntityV4) == endpoints.Denied ||
+			endpointListMatch(pm.hubPolicy, pin.EntityV6) == endpoints.Denied {
+			return matchFailedHubPolicy
 		}
+	}
 
-		return true // All checks have passed.
+	// Check type based policy.
+	switch {
+	case pm.hubType == HomeHub && pm.homeHubPolicy != nil:
+		if endpointListMatch(pm.homeHubPolicy, pin.EntityV4) == endpoints.Denied ||
+			endpointListMatch(pm.homeHubPolicy, pin.EntityV6) == endpoints.Denied {
+			return matchFailedHomeHubPolicy
+		}
+	case pm.hubType == DestinationHub && pm.destinationHubPolicy != nil:
+		if endpointListMatch(pm.destinationHubPolicy, pin.EntityV4) == endpoints.Denied ||
+			endpointListMatch(pm.destinationHubPolicy, pin.EntityV6) == endpoints.Denied {
+			return matchFailedDestinationHubPolicy
+		}
 	}
+
+	return matchOk // All checks have passed.
 }
 
 func endpointListMatch(list endpoints.Endpoints, entity *intel.Entity) endpoints.EPResult {
@@ -40,6 +40,9 @@ type Pin struct {
 	// Connection holds a information about a connection to the Hub of this Pin.
 	Connection *PinConnection
 
+	// SupplyChain holds the parsed supply chain of the Hub.
+	SupplyChain *SupplyChain
+
 	// Internal
 
 	// pushChanges is set to true if something noteworthy on the Pin changed and
@@ -59,6 +62,11 @@ type Pin struct {
 
 	// region is the region this Pin belongs to.
 	region *Region
+
+	// reputation holds the connection history with the Hub.
+	// It is always set for Pins in a Map and the reference must not be changed
+	// afterwards, as it is also used without holding the map lock.
+	reputation *Reputation
 }
 
 // PinConnection represents a connection to a terminal on the Hub.
@@ -83,6 +91,18 @@ type Lane struct {
 	// It is specified in nanoseconds.
 	Latency time.Duration
 
+	// LossRate designates the rate of pings between these Hubs that timed out
+	// in latency tests. It is specified as a fraction between 0 and 1.
+	LossRate float32
+
+	// Jitter designates the variation of the latency between these Hubs.
+	// It is specified in nanoseconds.
+	Jitter time.Duration
+
+	// Age designates how long the connection between these Hubs has been
+	// established.
+	Age time.Duration
+
 	// Cost is the routing cost of this lane.
 	Cost float32
 
@@ -171,6 +191,13 @@ func (pin *Pin) hasActiveTerminal() bool {
 }
 
 func (pin *Pin) NotifyTerminalChange() {
+	// Report abandoned sessions to the reputation.
+	pin.Lock()
+	if pin.Connection != nil && pin.Connection.Terminal != nil && pin.Connection.Terminal.IsAbandoned() {
+		pin.reportSessionAbandoned(pin.Connection.Terminal.AbandonError())
+	}
+	pin.Unlock()
+
 	pin.pushChanges.Set()
 	pin.pushChange()
 }
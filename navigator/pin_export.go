@@ -28,6 +28,10 @@ type PinExport struct {
 	ConnectedTo   map[string]*LaneExport // Key is Hub ID.
 	Route         []string               // Includes Home Hub and this Pin's ID.
 	SessionActive bool
+
+	Reputation *ReputationExport
+
+	SupplyChain *SupplyChain `json:",omitempty"`
 }
 
 // LaneExport is the exportable version of a Lane.
@@ -41,6 +45,18 @@ type LaneExport struct {
 	// Lateny designates the latency between these Hubs.
 	// It is specified in nanoseconds.
 	Latency time.Duration
+
+	// LossRate designates the rate of pings between these Hubs that timed out
+	// in latency tests. It is specified as a fraction between 0 and 1.
+	LossRate float32
+
+	// Jitter designates the variation of the latency between these Hubs.
+	// It is specified in nanoseconds.
+	Jitter time.Duration
+
+	// Age designates how long the connection between these Hubs has been
+	// established.
+	Age time.Duration
 }
 
 func (pin *Pin) Export() *PinExport {
@@ -58,6 +74,8 @@ func (pin *Pin) Export() *PinExport {
 		States:        pin.State.Export(),
 		HopDistance:   pin.HopDistance,
 		SessionActive: pin.hasActiveTerminal() || pin.State.has(StateIsHomeHub),
+		Reputation:    pin.reputation.export(),
+		SupplyChain:   pin.SupplyChain,
 	}
 
 	// Export lanes.
@@ -67,6 +85,9 @@ func (pin *Pin) Export() *PinExport {
 			HubID:    lane.Pin.Hub.ID,
 			Capacity: lane.Capacity,
 			Latency:  lane.Latency,
+			LossRate: lane.LossRate,
+			Jitter:   lane.Jitter,
+			Age:      lane.Age,
 		}
 	}
 
@@ -0,0 +1,44 @@
+package navigator
+
+import (
+	"net"
+	"sync"
+)
+
+var (
+	// privateDestinations holds the networks that are reached via the private
+	// network used alongside the main map.
+	privateDestinations     []*net.IPNet
+	privateDestinationsLock sync.RWMutex
+)
+
+// SetPrivateDestinations sets the networks that are reached via the private
+// network used alongside the main map.
+func SetPrivateDestinations(networks []*net.IPNet) {
+	privateDestinationsLock.Lock()
+	defer privateDestinationsLock.Unlock()
+
+	privateDestinations = networks
+}
+
+// isPrivateDestination returns whether the given IP is reached via the
+// private network.
+func isPrivateDestination(ip net.IP) bool {
+	privateDestinationsLock.RLock()
+	defer privateDestinationsLock.RUnlock()
+
+	for _, network := range privateDestinations {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// MapFor returns the map that is used to connect to the given IP.
+func MapFor(ip net.IP) *Map {
+	if Private != nil && isPrivateDestination(ip) {
+		return Private
+	}
+	return Main
+}
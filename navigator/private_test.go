@@ -0,0 +1,29 @@
+package navigator
+
+import (
+	"net"
+	"testing"
+)
+
+func TestPrivateDestinations(t *testing.T) {
+	_, lan, _ := net.ParseCIDR("10.8.0.0/16")
+	_, lan6, _ := net.ParseCIDR("fd00:8::/32")
+	SetPrivateDestinations([]*net.IPNet{lan, lan6})
+	defer SetPrivateDestinations(nil)
+
+	testCases := []struct {
+		ip      string
+		private bool
+	}{
+		{"10.8.1.1", true},
+		{"10.9.1.1", false},
+		{"fd00:8::1", true},
+		{"fd00:9::1", false},
+		{"1.1.1.1", false},
+	}
+	for _, tc := range testCases {
+		if isPrivateDestination(net.ParseIP(tc.ip)) != tc.private {
+			t.Errorf("%s: expected private=%v", tc.ip, tc.private)
+		}
+	}
+}
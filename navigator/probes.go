@@ -0,0 +1,175 @@
+package navigator
+
+import (
+	"net"
+	"time"
+)
+
+const (
+	// DestinationProbeTTL defines how long destination probe results are used.
+	DestinationProbeTTL = 1 * time.Hour
+
+	// DestinationProbeCandidates defines how many Destination Hubs should
+	// probe a destination.
+	DestinationProbeCandidates = 3
+
+	// destinationProbeMaxEntries defines how many destination prefixes are
+	// cached at most.
+	destinationProbeMaxEntries = 1000
+
+	// destinationProbePrefixV4 and destinationProbePrefixV6 define the prefix
+	// lengths probe results are shared within.
+	destinationProbePrefixV4 = 24
+	destinationProbePrefixV6 = 48
+)
+
+// DestinationProbe holds the probe results for a destination prefix.
+type DestinationProbe struct {
+	// Prefix is the destination prefix the results are valid for.
+	Prefix string
+
+	// Started holds when probing the destination prefix was started.
+	Started time.Time
+
+	// Results holds the probe results by Hub ID.
+	Results map[string]*DestinationProbeResult
+}
+
+// DestinationProbeResult holds the result of a Destination Hub probing a
+// destination.
+type DestinationProbeResult struct {
+	HubID string
+
+	// Latency holds the measured connect latency from the Hub to the
+	// destination.
+	Latency time.Duration
+
+	// Failed signifies that the Hub failed to connect to the destination.
+	Failed bool
+
+	// ProbedAt holds when the probe was done.
+	ProbedAt time.Time
+}
+
+// destinationPrefix returns the prefix probe results for the given IP are
+// shared within.
+func destinationPrefix(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		return (&net.IPNet{
+			IP:   v4.Mask(net.CIDRMask(destinationProbePrefixV4, 32)),
+			Mask: net.CIDRMask(destinationProbePrefixV4, 32),
+		}).String()
+	}
+	return (&net.IPNet{
+		IP:   ip.Mask(net.CIDRMask(destinationProbePrefixV6, 128)),
+		Mask: net.CIDRMask(destinationProbePrefixV6, 128),
+	}).String()
+}
+
+// StartDestinationProbe returns whether the destination of the given IP
+// should be probed and marks it as being probed. It only returns true once
+// per destination prefix within the probe TTL.
+func (m *Map) StartDestinationProbe(ip net.IP) bool {
+	m.probesLock.Lock()
+	defer m.probesLock.Unlock()
+
+	now := time.Now()
+	prefix := destinationPrefix(ip)
+
+	// Check if the destination was recently probed.
+	probe, ok := m.destinationProbes[prefix]
+	if ok && now.Sub(probe.Started) < DestinationProbeTTL {
+		return false
+	}
+
+	// Remove expired probes, if the cache is full.
+	if len(m.destinationProbes) >= destinationProbeMaxEntries {
+		for key, probe := range m.destinationProbes {
+			if now.Sub(probe.Started) >= DestinationProbeTTL {
+				delete(m.destinationProbes, key)
+			}
+		}
+		// Don't probe if the cache is still full.
+		if len(m.destinationProbes) >= destinationProbeMaxEntries {
+			return false
+		}
+	}
+
+	m.destinationProbes[prefix] = &DestinationProbe{
+		Prefix:  prefix,
+		Started: now,
+		Results: make(map[string]*DestinationProbeResult),
+	}
+	return true
+}
+
+// ReportDestinationProbe reports the result of the given Hub probing the
+// destination of the given IP.
+func (m *Map) ReportDestinationProbe(ip net.IP, hubID string, latency time.Duration, failed bool) {
+	m.probesLock.Lock()
+	defer m.probesLock.Unlock()
+
+	probe, ok := m.destinationProbes[destinationPrefix(ip)]
+	if !ok {
+		return
+	}
+
+	probe.Results[hubID] = &DestinationProbeResult{
+		HubID:    hubID,
+		Latency:  latency,
+		Failed:   failed,
+		ProbedAt: time.Now(),
+	}
+}
+
+// GetDestinationProbe returns a copy of the current probe results for the
+// destination of the given IP, if available.
+func (m *Map) GetDestinationProbe(ip net.IP) (probe *DestinationProbe, ok bool) {
+	m.probesLock.Lock()
+	defer m.probesLock.Unlock()
+
+	probe, ok = m.destinationProbes[destinationPrefix(ip)]
+	if !ok || time.Since(probe.Started) >= DestinationProbeTTL {
+		return nil, false
+	}
+
+	copied := &DestinationProbe{
+		Prefix:  probe.Prefix,
+		Started: probe.Started,
+		Results: make(map[string]*DestinationProbeResult, len(probe.Results)),
+	}
+	for hubID, result := range probe.Results {
+		copied.Results[hubID] = result
+	}
+	return copied, true
+}
+
+// applyDestinationProbes applies the probe results for the destination of the
+// given IP to the nearby Pins. Probed Pins use the probe result in place of
+// the geo proximity for their destination cost. Probed Pins that are not yet
+// in the nearby list are added, as the probe proved that they are useful.
+// The map must be locked.
+func (m *Map) applyDestinationProbes(ip net.IP, nearby *nearbyPins, matcher PinMatcher) {
+	probe, ok := m.GetDestinationProbe(ip)
+	if !ok {
+		return
+	}
+
+	for hubID, result := range probe.Results {
+		// Apply to existing nearby Pin.
+		if nbPin := nearby.get(hubID); nbPin != nil {
+			nbPin.probe = result
+			continue
+		}
+
+		// Add Pin if it was successfully probed and still qualifies.
+		pin, ok := m.all[hubID]
+		if !ok || result.Failed || !matcher(pin) {
+			continue
+		}
+		nearby.pins = append(nearby.pins, &nearbyPin{
+			pin:   pin,
+			probe: result,
+		})
+	}
+}
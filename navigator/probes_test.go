@@ -0,0 +1,59 @@
+package navigator
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDestinationProbes(t *testing.T) {
+	m := createSnapshotTestMap(t, 10)
+	ip := net.ParseIP("1.2.3.4")
+	sameNet := net.ParseIP("1.2.3.200")
+
+	// Check prefixes.
+	assert.Equal(t, "1.2.3.0/24", destinationPrefix(ip))
+	assert.Equal(t, "2001:db8:1::/48", destinationPrefix(net.ParseIP("2001:db8:1:2::1")))
+
+	// Check that probing is only started once per prefix.
+	assert.True(t, m.StartDestinationProbe(ip))
+	assert.False(t, m.StartDestinationProbe(sameNet))
+
+	// Report results: the geo-nearest Hub fails, another one is fast.
+	m.ReportDestinationProbe(ip, "hub-4", 0, true)
+	m.ReportDestinationProbe(sameNet, "hub-5", 20*time.Millisecond, false)
+	probe, ok := m.GetDestinationProbe(ip)
+	assert.True(t, ok)
+	assert.Len(t, probe.Results, 2)
+
+	// Apply probes to the nearby Pins.
+	hub4, _ := m.GetPin("hub-4")
+	nearby := &nearbyPins{
+		pins: []*nearbyPin{{pin: hub4, proximity: 100}},
+	}
+	opts := m.defaultOptions()
+	opts.ProbeDestinations = true
+	m.Lock()
+	m.applyDestinationProbes(ip, nearby, opts.Matcher(DestinationHub))
+	m.Unlock()
+	assert.Len(t, nearby.pins, 2)
+	assert.Equal(t, float32(1000), nearby.get("hub-4").DstCost())
+	assert.Equal(t, float32(20), nearby.get("hub-5").DstCost())
+
+	// Check that routing prefers the probed Hub.
+	routes, err := m.findRoutes(nearby, opts, 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "hub-5", routes.All[0].Path[len(routes.All[0].Path)-1].HubID)
+
+	// Check expiry.
+	m.probesLock.Lock()
+	m.destinationProbes[destinationPrefix(ip)].Started = time.Now().Add(-2 * DestinationProbeTTL)
+	m.probesLock.Unlock()
+	_, ok = m.GetDestinationProbe(ip)
+	assert.False(t, ok)
+	assert.True(t, m.StartDestinationProbe(ip))
+}
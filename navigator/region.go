@@ -44,6 +44,20 @@ func (region *Region) getName() string {
 	}
 }
 
+// isRegionTransition returns whether going from one Pin to the other crosses
+// from one region to another. Pins without a region are regarded as being
+// outside of all regions.
+func isRegionTransition(from, to *Pin) bool {
+	switch {
+	case from.region == nil && to.region == nil:
+		return false
+	case from.region == nil || to.region == nil:
+		return true
+	default:
+		return from.region.ID != to.region.ID
+	}
+}
+
 func (m *Map) updateRegions(config []*hub.RegionConfig) {
 	// Reset map and pins.
 	m.regions = make([]*Region, 0, len(config))
@@ -80,7 +94,7 @@ func (m *Map) updateRegions(config []*hub.RegionConfig) {
 		}
 		memberPolicy, err := endpoints.ParseEndpoints(regionConfig.MemberPolicy)
 		if err != nil {
-			log.Errorf("navigator: failed to parse member policy of region %s: %w", region.ID, err)
+			log.Errorf("navigator: failed to parse member policy of region %s: %s", region.ID, err)
 			// Abort adding this region to the map.
 			continue
 		}
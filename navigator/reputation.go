@@ -0,0 +1,420 @@
+package navigator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/safing/portbase/database"
+	"github.com/safing/portbase/database/record"
+	"github.com/safing/portbase/log"
+	"github.com/safing/portbase/modules"
+	"github.com/safing/spn/terminal"
+)
+
+const (
+	// reputationHalfLife defines after how long past events only count half.
+	reputationHalfLife = 24 * time.Hour
+
+	// reputationMaxCost is the cost that is added to a Hub with the worst
+	// possible reputation.
+	reputationMaxCost = 1000
+
+	// reputationAbandonWeight defines how much a mid-session abandon weighs
+	// compared to an establishment failure.
+	reputationAbandonWeight = 0.5
+
+	// reputationPriorSuccesses defines how many successes are assumed for
+	// every Hub, so that a single failure does not ruin a reputation.
+	reputationPriorSuccesses = 1
+
+	// reputationMinValue defines the value below which decayed values are
+	// dropped.
+	reputationMinValue = 0.01
+
+	// reputationBackoffBase and reputationBackoffMax define the exclusion
+	// duration after consecutive establishment failures. The duration is
+	// doubled with every further failure.
+	reputationBackoffBase = 1 * time.Minute
+	reputationBackoffMax  = 6 * time.Hour
+)
+
+var reputationDB = database.NewInterface(&database.Options{
+	Local:    true,
+	Internal: true,
+})
+
+func makeReputationDBKey(mapName, hubID string) string {
+	return fmt.Sprintf("cache:spn/reputation/%s/%s", mapName, hubID)
+}
+
+// Reputation tracks the connection history with a Hub. All counts decay
+// exponentially over time.
+type Reputation struct {
+	record.Base
+	sync.Mutex
+
+	// HubID is the ID of the Hub this reputation belongs to.
+	HubID string
+
+	// Successes counts successfully established sessions.
+	Successes float64
+	// Failures counts failures to establish a session.
+	Failures float64
+	// Abandons counts sessions that were abandoned because of an error.
+	Abandons float64
+	// AbandonsByError counts abandons by the terminal error ID.
+	AbandonsByError map[uint8]float64
+
+	// ConsecutiveFailures counts the establishment failures since the last
+	// success. It is used for calculating the exclusion backoff and does not
+	// decay.
+	ConsecutiveFailures int
+	// ExcludedUntil specifies until when the Hub is excluded from routing.
+	ExcludedUntil time.Time
+
+	// UpdatedAt holds when the counts were last decayed.
+	UpdatedAt time.Time
+
+	// changed signifies that the reputation needs to be saved.
+	changed bool
+}
+
+// ReputationExport is the exportable version of a Reputation.
+type ReputationExport struct {
+	Score               float64
+	Successes           float64
+	Failures            float64
+	Abandons            float64
+	ConsecutiveFailures int
+	ExcludedUntil       time.Time `json:",omitempty"`
+}
+
+func newReputation(mapName, hubID string) *Reputation {
+	rep := &Reputation{
+		HubID:           hubID,
+		AbandonsByError: make(map[uint8]float64),
+		UpdatedAt:       time.Now(),
+	}
+	rep.SetKey(makeReputationDBKey(mapName, hubID))
+	return rep
+}
+
+// loadReputation loads the reputation of the given Hub from the database or
+// returns a new one.
+func (m *Map) loadReputation(hubID string) *Reputation {
+	// Offline maps are not connected to the database.
+	if m.offline {
+		return newReputation(m.Name, hubID)
+	}
+
+	r, err := reputationDB.Get(makeReputationDBKey(m.Name, hubID))
+	if err != nil {
+		if !errors.Is(err, database.ErrNotFound) {
+			log.Warningf("spn/navigator: failed to load reputation of %s: %s", hubID, err)
+		}
+		return newReputation(m.Name, hubID)
+	}
+
+	rep, err := ensureReputation(r)
+	if err != nil {
+		log.Warningf("spn/navigator: failed to load reputation of %s: %s", hubID, err)
+		return newReputation(m.Name, hubID)
+	}
+	if rep.AbandonsByError == nil {
+		rep.AbandonsByError = make(map[uint8]float64)
+	}
+	return rep
+}
+
+// ensureReputation makes sure a database record is a Reputation.
+func ensureReputation(r record.Record) (*Reputation, error) {
+	// unwrap
+	if r.IsWrapped() {
+		// only allocate a new struct, if we need it
+		new := &Reputation{}
+		err := record.Unwrap(r, new)
+		if err != nil {
+			return nil, err
+		}
+		return new, nil
+	}
+
+	// or adjust type
+	new, ok := r.(*Reputation)
+	if !ok {
+		return nil, fmt.Errorf("record not of type *Reputation, but %T", r)
+	}
+	return new, nil
+}
+
+// decay applies the exponential decay to all counts.
+// The reputation must be locked.
+func (rep *Reputation) decay(now time.Time) {
+	elapsed := now.Sub(rep.UpdatedAt)
+	if elapsed <= 0 {
+		return
+	}
+	rep.UpdatedAt = now
+
+	factor := math.Pow(0.5, float64(elapsed)/float64(reputationHalfLife))
+	decayValue := func(value float64) float64 {
+		value *= factor
+		if value < reputationMinValue {
+			return 0
+		}
+		return value
+	}
+
+	rep.Successes = decayValue(rep.Successes)
+	rep.Failures = decayValue(rep.Failures)
+	rep.Abandons = decayValue(rep.Abandons)
+	for errID, value := range rep.AbandonsByError {
+		value = decayValue(value)
+		if value == 0 {
+			delete(rep.AbandonsByError, errID)
+		} else {
+			rep.AbandonsByError[errID] = value
+		}
+	}
+}
+
+// score returns the reputation score between 0 (bad) and 1 (good).
+// The reputation must be locked.
+func (rep *Reputation) score() float64 {
+	bad := rep.Failures + rep.Abandons*reputationAbandonWeight
+	return 1 - bad/(bad+rep.Successes+reputationPriorSuccesses)
+}
+
+// Score returns the current reputation score between 0 (bad) and 1 (good).
+func (rep *Reputation) Score() float64 {
+	if rep == nil {
+		return 1
+	}
+
+	rep.Lock()
+	defer rep.Unlock()
+
+	rep.decay(time.Now())
+	return rep.score()
+}
+
+// Cost returns the routing cost that is added to a Hub because of its
+// reputation.
+func (rep *Reputation) Cost() float32 {
+	return float32((1 - rep.Score()) * reputationMaxCost)
+}
+
+func (rep *Reputation) addSuccess() {
+	rep.Lock()
+	defer rep.Unlock()
+
+	rep.decay(time.Now())
+	rep.Successes++
+	rep.ConsecutiveFailures = 0
+	rep.ExcludedUntil = time.Time{}
+	rep.changed = true
+}
+
+func (rep *Reputation) addFailure() (excludedUntil time.Time) {
+	rep.Lock()
+	defer rep.Unlock()
+
+	now := time.Now()
+	rep.decay(now)
+	rep.Failures++
+	rep.ConsecutiveFailures++
+	rep.changed = true
+
+	// Calculate backoff.
+	backoff := reputationBackoffMax
+	if rep.ConsecutiveFailures <= 16 {
+		backoff = reputationBackoffBase << (rep.ConsecutiveFailures - 1)
+		if backoff > reputationBackoffMax {
+			backoff = reputationBackoffMax
+		}
+	}
+	rep.ExcludedUntil = now.Add(backoff)
+
+	return rep.ExcludedUntil
+}
+
+func (rep *Reputation) addAbandon(tErr *terminal.Error) {
+	rep.Lock()
+	defer rep.Unlock()
+
+	rep.decay(time.Now())
+	rep.Abandons++
+	rep.AbandonsByError[tErr.ID()]++
+	rep.changed = true
+}
+
+// export returns the exportable version of the reputation.
+func (rep *Reputation) export() *ReputationExport {
+	if rep == nil {
+		return nil
+	}
+
+	rep.Lock()
+	defer rep.Unlock()
+
+	rep.decay(time.Now())
+	return &ReputationExport{
+		Score:               rep.score(),
+		Successes:           rep.Successes,
+		Failures:            rep.Failures,
+		Abandons:            rep.Abandons,
+		ConsecutiveFailures: rep.ConsecutiveFailures,
+		ExcludedUntil:       rep.ExcludedUntil,
+	}
+}
+
+// save saves the reputation to the database, if it changed.
+func (rep *Reputation) save() error {
+	if rep == nil {
+		return nil
+	}
+
+	rep.Lock()
+	if !rep.changed {
+		rep.Unlock()
+		return nil
+	}
+	rep.changed = false
+	rep.Unlock()
+
+	return reputationDB.Put(rep)
+}
+
+// isBenignAbandonError returns whether the given error is part of the normal
+// lifecycle of a session and should not affect the reputation.
+func isBenignAbandonError(tErr *terminal.Error) bool {
+	switch {
+	case tErr.IsOK():
+		return true
+	case tErr.Is(terminal.ErrStopping),
+		tErr.Is(terminal.ErrCanceled),
+		tErr.Is(terminal.ErrTimeout):
+		// Sessions are stopped, canceled or time out when not used anymore.
+		return true
+	default:
+		return false
+	}
+}
+
+// ReportEstablishmentFailure reports that a session to the given Hub could not
+// be established. The Hub is excluded from routing with an exponential backoff
+// for consecutive failures.
+func (m *Map) ReportEstablishmentFailure(hubID string) {
+	m.Lock()
+	defer m.Unlock()
+
+	pin, ok := m.all[hubID]
+	if !ok {
+		return
+	}
+
+	// Exclude Hub until backoff expires.
+	pin.FailingUntil = pin.reputation.addFailure()
+	pin.addStates(StateFailing)
+	pin.updateCost()
+	pin.pushChanges.Set()
+
+	log.Debugf("spn/navigator: excluding %s until %s after failing to establish session", pin.Hub, pin.FailingUntil)
+}
+
+// restoreExclusion excludes the Pin from routing, if its reputation holds an
+// exclusion that has not expired yet. This keeps exclusions across restarts.
+// The map must be locked.
+func (pin *Pin) restoreExclusion(now time.Time) {
+	pin.reputation.Lock()
+	excludedUntil := pin.reputation.ExcludedUntil
+	pin.reputation.Unlock()
+
+	if now.Before(excludedUntil) && excludedUntil.After(pin.FailingUntil) {
+		pin.FailingUntil = excludedUntil
+		pin.addStates(StateFailing)
+	}
+}
+
+// ReportSessionSuccess reports that a session to the given Hub was
+// successfully established.
+func (m *Map) ReportSessionSuccess(hubID string) {
+	m.Lock()
+	defer m.Unlock()
+
+	pin, ok := m.all[hubID]
+	if !ok {
+		return
+	}
+
+	pin.reputation.addSuccess()
+	pin.removeStates(StateFailing)
+	pin.updateCost()
+	pin.pushChanges.Set()
+}
+
+// reportSessionAbandoned reports that a session to the Hub of the Pin was
+// abandoned with the given error. The cost is updated with the next reputation
+// update, as the map lock is not held.
+func (pin *Pin) reportSessionAbandoned(tErr *terminal.Error) {
+	if isBenignAbandonError(tErr) {
+		return
+	}
+
+	pin.reputation.addAbandon(tErr)
+}
+
+// updateCost updates the cost of the Pin based on the Hub's load and the
+// reputation. The map must be locked.
+func (pin *Pin) updateCost() {
+	pin.Cost = CalculateHubCost(pin.Hub.Status.Load) + pin.reputation.Cost()
+}
+
+// updateReputations applies the decayed reputations to the Pins, lifts
+// expired exclusions and saves changed reputations.
+func (m *Map) updateReputations(ctx context.Context, task *modules.Task) error {
+	now := time.Now()
+
+	m.Lock()
+	defer m.Unlock()
+
+	for _, pin := range m.all {
+		// Lift expired exclusion.
+		if pin.State.has(StateFailing) && now.After(pin.FailingUntil) {
+			pin.removeStates(StateFailing)
+			pin.pushChanges.Set()
+		}
+
+		// Apply decayed reputation.
+		pin.updateCost()
+	}
+
+	m.saveReputations()
+	m.PushPinChanges()
+	return nil
+}
+
+// SaveReputations saves all changed reputations to the database.
+func (m *Map) SaveReputations() {
+	m.RLock()
+	defer m.RUnlock()
+
+	m.saveReputations()
+}
+
+func (m *Map) saveReputations() {
+	// Offline maps are not connected to the database.
+	if m.offline {
+		return
+	}
+
+	for _, pin := range m.all {
+		if err := pin.reputation.save(); err != nil {
+			log.Warningf("spn/navigator: failed to save reputation of %s: %s", pin.Hub, err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package navigator
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/safing/spn/terminal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReputationDecay(t *testing.T) {
+	rep := newReputation("test", "hub")
+	assert.Equal(t, 1.0, rep.Score())
+	assert.Equal(t, float32(0), rep.Cost())
+
+	// Failures lower the score.
+	rep.addFailure()
+	assert.InDelta(t, 0.5, rep.Score(), 0.001)
+	rep.addSuccess()
+	assert.InDelta(t, 2.0/3.0, rep.Score(), 0.001)
+
+	// Benign abandons are ignored.
+	pin := &Pin{reputation: rep}
+	pin.reportSessionAbandoned(terminal.ErrStopping)
+	pin.reportSessionAbandoned(terminal.ErrTimeout.With("idle"))
+	pin.reportSessionAbandoned(nil)
+	assert.Zero(t, rep.Abandons)
+	pin.reportSessionAbandoned(terminal.ErrShipSunk.With("test"))
+	assert.Equal(t, 1.0, rep.Abandons)
+	assert.Equal(t, 1.0, rep.AbandonsByError[terminal.ErrShipSunk.ID()])
+
+	// Counts decay by half after the half life.
+	rep.UpdatedAt = rep.UpdatedAt.Add(-reputationHalfLife)
+	export := rep.export()
+	assert.InDelta(t, 0.5, export.Failures, 0.001)
+	assert.InDelta(t, 0.5, export.Successes, 0.001)
+	assert.InDelta(t, 0.5, rep.AbandonsByError[terminal.ErrShipSunk.ID()], 0.001)
+
+	// Small values are dropped eventually.
+	rep.UpdatedAt = rep.UpdatedAt.Add(-10 * reputationHalfLife)
+	assert.Equal(t, 1.0, rep.Score())
+	assert.Empty(t, rep.AbandonsByError)
+}
+
+func TestReputationExclusion(t *testing.T) {
+	m := createSnapshotTestMap(t, 10)
+	pin, _ := m.GetPin("hub-3")
+	baseCost := pin.Cost
+	matcher := m.DefaultOptions().Matcher(TransitHub)
+	assert.True(t, matcher(pin))
+
+	// Failures exclude with exponential backoff.
+	m.ReportEstablishmentFailure("hub-3")
+	assert.True(t, pin.State.has(StateFailing))
+	assert.False(t, matcher(pin))
+	assert.WithinDuration(t, time.Now().Add(reputationBackoffBase), pin.FailingUntil, time.Second)
+	assert.Greater(t, pin.Cost, baseCost)
+	m.ReportEstablishmentFailure("hub-3")
+	assert.WithinDuration(t, time.Now().Add(2*reputationBackoffBase), pin.FailingUntil, time.Second)
+
+	// Exclusion is lifted after the backoff.
+	pin.FailingUntil = time.Now().Add(-time.Second)
+	assert.NoError(t, m.updateReputations(nil, nil))
+	assert.False(t, pin.State.has(StateFailing))
+	assert.True(t, matcher(pin))
+
+	// Success resets the backoff, but the reputation cost remains.
+	m.ReportSessionSuccess("hub-3")
+	assert.Zero(t, pin.reputation.ConsecutiveFailures)
+	assert.Greater(t, pin.Cost, baseCost)
+	assert.NotNil(t, pin.Export().Reputation)
+}
+
+func TestReputationExclusionIsRestored(t *testing.T) {
+	m := NewMap(fmt.Sprintf("test-reputation-%d", time.Now().UnixNano()), false)
+	defer m.Close()
+
+	// Save a reputation with an active exclusion.
+	excludedUntil := time.Now().Add(time.Hour).Round(time.Second)
+	rep := newReputation(m.Name, "hub-excluded")
+	rep.Failures = 1
+	rep.ConsecutiveFailures = 1
+	rep.ExcludedUntil = excludedUntil
+	rep.changed = true
+	if err := rep.save(); err != nil {
+		t.Fatal(err)
+	}
+	defer reputationDB.Delete(rep.Key()) //nolint:errcheck
+
+	// The exclusion is applied to the Pin when loading.
+	pin := &Pin{reputation: m.loadReputation("hub-excluded")}
+	pin.restoreExclusion(time.Now())
+	assert.True(t, pin.State.has(StateFailing))
+	assert.True(t, excludedUntil.Equal(pin.FailingUntil), pin.FailingUntil)
+
+	// Expired exclusions are not applied.
+	pin = &Pin{reputation: m.loadReputation("hub-excluded")}
+	pin.restoreExclusion(excludedUntil.Add(time.Second))
+	assert.False(t, pin.State.has(StateFailing))
+	assert.True(t, pin.FailingUntil.IsZero())
+}
@@ -72,6 +72,14 @@ type Route struct {
 
 	// Algorithm is the ID of the algorithm used to calculate the route.
 	Algorithm string
+
+	// Regions holds the IDs of the regions the route passes, in order.
+	// Hubs without a region are skipped.
+	Regions []string
+
+	// RegionTransitions is the number of hops that cross from one region to
+	// another.
+	RegionTransitions int
 }
 
 type Hop struct {
@@ -82,6 +90,12 @@ type Hop struct {
 
 	// Cost is the cost for both Lane to this Hub and the Hub itself.
 	Cost float32
+
+	// Region is the ID of the region the Hub belongs to, if any.
+	Region string `json:",omitempty"`
+
+	// RegionName is the display name of the region the Hub belongs to, if any.
+	RegionName string `json:",omitempty"`
 }
 
 // addHop adds a hop to the route.
@@ -157,12 +171,58 @@ func (r *Route) makeExportReady(algorithm string) {
 	for _, hop := range r.Path {
 		hop.makeExportReady()
 	}
+
+	// Add region information.
+	r.Regions = nil
+	for _, hop := range r.Path {
+		if hop.pin.region != nil &&
+			(len(r.Regions) == 0 || r.Regions[len(r.Regions)-1] != hop.pin.region.ID) {
+			r.Regions = append(r.Regions, hop.pin.region.ID)
+		}
+	}
+	r.RegionTransitions = r.countRegionTransitions()
 }
 
 // makeExportReady fills in all the missing data fields which are meant for
 // exporting only.
 func (hop *Hop) makeExportReady() {
 	hop.HubID = hop.pin.Hub.ID
+	if hop.pin.region != nil {
+		hop.Region = hop.pin.region.ID
+		hop.RegionName = hop.pin.region.getName()
+	}
+}
+
+// countRegionTransitions returns how many hops of the route cross from one
+// region to another.
+func (r *Route) countRegionTransitions() (transitions int) {
+	for i := 1; i < len(r.Path); i++ {
+		if isRegionTransition(r.Path[i-1].pin, r.Path[i].pin) {
+			transitions++
+		}
+	}
+	return transitions
+}
+
+// countRegions returns how many different regions the route passes.
+func (r *Route) countRegions() int {
+	var regions []*Region
+	for _, hop := range r.Path {
+		if hop.pin.region == nil {
+			continue
+		}
+		seen := false
+		for _, region := range regions {
+			if region == hop.pin.region {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			regions = append(regions, hop.pin.region)
+		}
+	}
+	return len(regions)
 }
 
 func (hop *Hop) Pin() *Pin {
@@ -26,11 +26,34 @@ type RoutingProfile struct {
 	// should not interfere with finding the best route, but might reduce the
 	// amount of routes found.
 	MaxExtraCost float32
+
+	// RegionTransitionCost is added to the cost of every hop that crosses from
+	// one region to another. This makes routes prefer transit within a region.
+	RegionTransitionCost float32
+
+	// MaxRegionTransitions sets a limit on how often a route may cross from
+	// one region to another. Zero means no limit.
+	MaxRegionTransitions int
+
+	// MinRegions defines how many different regions a route must pass at
+	// minimum. Hubs without a region are not counted. This can be used to
+	// require cross-region hops for privacy.
+	MinRegions int
+
+	// AvoidSameDatacenter disqualifies routes that have multiple hops in the
+	// same verified datacenter.
+	AvoidSameDatacenter bool
+
+	// SameHosterCost is added to the cost of every hop that shares a hoster,
+	// datacenter company or Autonomous System with a previous hop of the route.
+	SameHosterCost float32
 }
 
 const (
 	RoutingProfileDefaultName  = "default"
 	RoutingProfileShortestName = "shortest"
+	RoutingProfileRegionalName = "regional"
+	RoutingProfileDiverseName  = "diverse"
 	RoutingProfileHomeName     = "home"
 )
 
@@ -41,6 +64,9 @@ var (
 		MaxHops:      5,
 		MaxExtraHops: 2,
 		MaxExtraCost: 100, // TODO: implement costs
+
+		AvoidSameDatacenter: true,
+		SameHosterCost:      50,
 	}
 
 	RoutingProfileShortest = &RoutingProfile{
@@ -50,6 +76,31 @@ var (
 		MaxExtraHops: 1,
 		MaxExtraCost: 100, // TODO: implement costs
 	}
+
+	RoutingProfileRegional = &RoutingProfile{
+		ID:                   RoutingProfileRegionalName,
+		MinHops:              3,
+		MaxHops:              5,
+		MaxExtraHops:         2,
+		MaxExtraCost:         100, // TODO: implement costs
+		RegionTransitionCost: 100,
+		MaxRegionTransitions: 1,
+
+		AvoidSameDatacenter: true,
+		SameHosterCost:      50,
+	}
+
+	RoutingProfileDiverse = &RoutingProfile{
+		ID:           RoutingProfileDiverseName,
+		MinHops:      3,
+		MaxHops:      5,
+		MaxExtraHops: 2,
+		MaxExtraCost: 100, // TODO: implement costs
+		MinRegions:   2,
+
+		AvoidSameDatacenter: true,
+		SameHosterCost:      100,
+	}
 )
 
 func getRoutingProfile(name string) *RoutingProfile {
@@ -58,6 +109,10 @@ func getRoutingProfile(name string) *RoutingProfile {
 		return RoutingProfileDefault
 	case RoutingProfileShortestName:
 		return RoutingProfileShortest
+	case RoutingProfileRegionalName:
+		return RoutingProfileRegional
+	case RoutingProfileDiverseName:
+		return RoutingProfileDiverse
 	case RoutingProfileHomeName:
 		log.Warningf("spn/navigator: routing profile %q is special and cannot be used for calculation, falling back to default", name)
 		return RoutingProfileDefault
@@ -75,39 +130,85 @@ const (
 	routeDisqualified                        // Route is disqualified and won't be able to become compliant.
 )
 
-func (rp *RoutingProfile) checkRouteCompliance(route *Route, foundRoutes *Routes) routeCompliance {
+// Route compliance reasons.
+const (
+	complianceReasonTooShort        = "route is shorter than the minimum hop count"
+	complianceReasonTooLong         = "route is longer than the maximum hop count"
+	complianceReasonHubReuse        = "route uses a Hub twice"
+	complianceReasonExceedsMaxCost  = "route exceeds the max extra cost of the best route"
+	complianceReasonExceedsMaxExtra = "route exceeds the max extra hops of the best route"
+	complianceReasonTooManyRegions  = "route exceeds the maximum region transitions"
+	complianceReasonTooFewRegions   = "route passes fewer than the minimum regions"
+	complianceReasonSameDatacenter  = "route has multiple hops in the same datacenter"
+)
+
+// checkRouteCompliance checks if the given route complies with the routing
+// profile. If the route is not ok, a reason is returned in addition.
+func (rp *RoutingProfile) checkRouteCompliance(route *Route, foundRoutes *Routes) (compliance routeCompliance, reason string) {
 	switch {
 	case len(route.Path) < rp.MinHops:
 		// Route is shorter than the defined minimum.
-		return routeNonCompliant
+		return routeNonCompliant, complianceReasonTooShort
 	case len(route.Path) > rp.MaxHops:
 		// Route is longer than the defined maximum.
-		return routeDisqualified
+		return routeDisqualified, complianceReasonTooLong
 	}
 
-	// Check for hub re-use.
+	// Check for hub and datacenter re-use.
 	if len(route.Path) >= 2 {
 		lastHop := route.Path[len(route.Path)-1]
 		for _, hop := range route.Path[:len(route.Path)-1] {
 			if lastHop.pin.Hub.ID == hop.pin.Hub.ID {
-				return routeDisqualified
+				return routeDisqualified, complianceReasonHubReuse
+			}
+			if rp.AvoidSameDatacenter && sharesDatacenter(lastHop.pin, hop.pin) {
+				return routeDisqualified, complianceReasonSameDatacenter
 			}
 		}
 	}
 
+	// Check region transitions.
+	if rp.MaxRegionTransitions > 0 && route.countRegionTransitions() > rp.MaxRegionTransitions {
+		return routeDisqualified, complianceReasonTooManyRegions
+	}
+
 	// Abort route exploration when we are outside the optimization boundaries.
 	if len(foundRoutes.All) > 0 {
 		// Get the best found route.
 		best := foundRoutes.All[0]
 		// Abort if current route exceeds max extra costs.
 		if route.TotalCost > best.TotalCost+rp.MaxExtraCost {
-			return routeDisqualified
+			return routeDisqualified, complianceReasonExceedsMaxCost
 		}
 		// Abort if current route exceeds max extra hops.
 		if len(route.Path) > len(best.Path)+rp.MaxExtraHops {
-			return routeDisqualified
+			return routeDisqualified, complianceReasonExceedsMaxExtra
 		}
 	}
 
-	return routeOk
+	// Check if the route passes enough regions.
+	// This is checked last, as more hops may still fulfill the requirement.
+	if rp.MinRegions > 0 && route.countRegions() < rp.MinRegions {
+		return routeNonCompliant, complianceReasonTooFewRegions
+	}
+
+	return routeOk, ""
+}
+
+// hopCost returns the cost of adding the hop via the given Lane to the route,
+// including any region transition and same hoster cost.
+func (rp *RoutingProfile) hopCost(route *Route, lane *Lane) float32 {
+	cost := lane.Cost + lane.Pin.Cost
+	if rp.RegionTransitionCost > 0 && isRegionTransition(route.Path[len(route.Path)-1].pin, lane.Pin) {
+		cost += rp.RegionTransitionCost
+	}
+	if rp.SameHosterCost > 0 {
+		for _, hop := range route.Path {
+			if sharesHoster(hop.pin, lane.Pin) {
+				cost += rp.SameHosterCost
+				break
+			}
+		}
+	}
+	return cost
 }
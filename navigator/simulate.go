@@ -0,0 +1,534 @@
+package navigator
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/safing/portmaster/intel/geoip"
+	"github.com/safing/spn/hub"
+)
+
+const defaultSimulationMaxRuns = 100
+
+// Simulation simulates the optimization of a network by letting every Hub on
+// the map optimize its connections and applying the suggested lanes until the
+// network converges.
+type Simulation struct {
+	m *Map
+
+	// MaxRuns defines after how many runs the simulation is stopped, if the
+	// network has not converged until then.
+	MaxRuns int
+
+	// measurements holds the simulated measurements between two Hubs.
+	// The key is made of both Hub IDs in sorted order.
+	measurements map[string]*hub.Measurements
+}
+
+// SimulationReport holds the result of a simulation.
+type SimulationReport struct {
+	// Map is the name of the simulated map.
+	Map string
+
+	// Converged signifies whether the network converged within the max runs.
+	Converged bool
+
+	// Runs holds the details of all simulation runs.
+	Runs []*SimulationRun
+
+	// NewLanes holds the total amount of lanes created in the simulation.
+	NewLanes int
+
+	// HopDistances holds the distribution of hop distances between all Hubs.
+	// The key is the hop distance as used in Pin.HopDistance, where directly
+	// connected Hubs have a distance of 2.
+	HopDistances map[int]int
+
+	// Unreachable holds the amount of Hub pairs that cannot reach each other.
+	Unreachable int
+
+	// Regions holds the connectivity of all regions.
+	Regions []*RegionReport
+
+	// Hubs holds the lane counts of all Hubs.
+	Hubs []*HubLaneReport
+}
+
+// SimulationRun holds the details of a single simulation run, in which every
+// Hub optimized once.
+type SimulationRun struct {
+	// Run is the number of the run, starting at 1.
+	Run int
+
+	// NewLanes holds the amount of lanes created in this run.
+	NewLanes int
+
+	// Purposes counts the optimization purposes of all Hubs in this run.
+	Purposes map[string]int
+}
+
+// RegionReport holds the connectivity of a region.
+type RegionReport struct {
+	ID   string
+	Hubs int
+
+	// InternalLanes holds the amount of lanes within the region.
+	InternalLanes int
+
+	// ExternalLanes holds the amount of lanes from the region to other regions
+	// or satellites.
+	ExternalLanes int
+
+	// RegionalMinLanes holds the minimum amount of lanes other regions should
+	// build to this region.
+	RegionalMinLanes int
+
+	// LanesFromRegions holds the amount of lanes from every other region.
+	LanesFromRegions map[string]int
+}
+
+// HubLaneReport holds the lane count of a Hub.
+type HubLaneReport struct {
+	ID     string
+	Name   string
+	Region string
+	Lanes  int
+}
+
+// NewSimulation creates a new simulation from the given snapshot.
+func NewSimulation(snapshot *MapSnapshot) (*Simulation, error) {
+	// Simulations always need measurements.
+	snapshot.MeasuringEnabled = true
+
+	m, err := NewMapFromSnapshot(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	if len(m.all) < 2 {
+		return nil, errors.New("map needs at least two hubs for simulation")
+	}
+
+	return &Simulation{
+		m:            m,
+		MaxRuns:      defaultSimulationMaxRuns,
+		measurements: make(map[string]*hub.Measurements),
+	}, nil
+}
+
+// Map returns the simulated map.
+func (sim *Simulation) Map() *Map {
+	return sim.m
+}
+
+// Run runs the simulation until the network converges or the max runs are
+// reached. If set, the progress function is called after every run.
+func (sim *Simulation) Run(progress func(run *SimulationRun)) (*SimulationReport, error) {
+	m := sim.m
+	m.Lock()
+	defer m.Unlock()
+
+	// Save the original home, as we will be switching the home around.
+	originalHome := m.home
+	originalHomeTerminal := m.homeTerminal
+	defer func() {
+		if originalHome != nil {
+			m.setHome(originalHome, originalHomeTerminal)
+		}
+	}()
+
+	report := &SimulationReport{
+		Map: m.Name,
+	}
+	pins := m.sortedPins(false)
+
+	for runNumber := 1; runNumber <= sim.MaxRuns; runNumber++ {
+		run := &SimulationRun{
+			Run:      runNumber,
+			Purposes: make(map[string]int),
+		}
+		// Let's check if we have a run without any map changes.
+		converged := true
+
+		for _, pin := range pins {
+			// Switch the home to this Pin and optimize.
+			newLanes, purpose, err := sim.optimizePin(pin)
+			if err != nil {
+				return nil, fmt.Errorf("failed to optimize %s in run %d: %w", pin, runNumber, err)
+			}
+			run.NewLanes += newLanes
+			run.Purposes[purpose]++
+
+			// Check if we are still changing the map.
+			if newLanes > 0 || purpose != OptimizePurposeTargetStructure {
+				converged = false
+			}
+		}
+
+		// Record run.
+		report.Runs = append(report.Runs, run)
+		report.NewLanes += run.NewLanes
+		if progress != nil {
+			progress(run)
+		}
+
+		if converged {
+			report.Converged = true
+			break
+		}
+	}
+
+	// Analyze resulting network.
+	sim.analyzeHopDistances(report, pins)
+	sim.analyzeRegions(report)
+	sim.analyzeHubLanes(report, pins)
+
+	return report, nil
+}
+
+// optimizePin sets the given Pin as the home, optimizes and applies the
+// suggested lanes. The caller must hold the map lock.
+func (sim *Simulation) optimizePin(pin *Pin) (newLanes int, purpose string, err error) {
+	m := sim.m
+
+	// Set Home to this Pin for this iteration and update measurements.
+	m.setHome(pin, nil)
+	for _, peer := range m.all {
+		if peer != pin {
+			peer.measurements = sim.getMeasurements(pin, peer)
+		}
+	}
+	// Every simulated Hub has its own desegregation backoff.
+	m.lastDesegrationAttempt = time.Time{}
+
+	// Optimize.
+	result, err := m.optimize(m.defaultOptions())
+	if err != nil {
+		return 0, "", err
+	}
+
+	// Apply suggested lanes.
+	for _, suggested := range result.SuggestedConnections {
+		// Only create as many lanes as suggested by the result.
+		if newLanes >= result.MaxConnect {
+			break
+		}
+
+		// Check if lane to suggested Hub already exists.
+		if pin.Hub.GetLaneTo(suggested.Hub.ID) != nil {
+			continue
+		}
+
+		// Add lanes to both Hubs.
+		measurements := sim.getMeasurements(pin, suggested.pin)
+		_ = pin.Hub.AddLane(&hub.Lane{
+			ID:       suggested.Hub.ID,
+			Latency:  measurements.Latency,
+			Capacity: measurements.Capacity,
+		})
+		_ = suggested.Hub.AddLane(&hub.Lane{
+			ID:       pin.Hub.ID,
+			Latency:  measurements.Latency,
+			Capacity: measurements.Capacity,
+		})
+
+		// Update Hubs in map.
+		m.updateHub(pin.Hub, false, true)
+		m.updateHub(suggested.Hub, false, true)
+		newLanes++
+	}
+
+	return newLanes, result.Purpose, nil
+}
+
+// getMeasurements returns simulated measurements between the given Pins.
+// The measurements are derived from the location of the Pins and are
+// deterministic.
+func (sim *Simulation) getMeasurements(a, b *Pin) *hub.Measurements {
+	// Build ID.
+	var id string
+	switch strings.Compare(a.Hub.ID, b.Hub.ID) {
+	case 0:
+		return nil
+	case 1:
+		id = a.Hub.ID + "-" + b.Hub.ID
+	default:
+		id = b.Hub.ID + "-" + a.Hub.ID
+	}
+
+	// Return cached.
+	measurements, ok := sim.measurements[id]
+	if ok {
+		return measurements
+	}
+
+	// Estimate proximity.
+	var proximity float32
+	locA := a.LocationV4
+	if locA == nil {
+		locA = a.LocationV6
+	}
+	locB := b.LocationV4
+	if locB == nil {
+		locB = b.LocationV6
+	}
+	if locA != nil && locB != nil {
+		proximity = locA.EstimateNetworkProximity(locB)
+	}
+
+	// Derive a stable pseudo-random value from the ID for the capacity.
+	idHash := fnv.New32a()
+	_, _ = idHash.Write([]byte(id))
+	capacity := cap10Mbit + int(idHash.Sum32()%uint32(cap1Gbit-cap10Mbit))
+
+	// Create measurements.
+	measurements = hub.NewMeasurements()
+	measurements.Latency = time.Duration(5+(100-proximity)*2) * time.Millisecond
+	measurements.Capacity = capacity
+	measurements.GeoProximity = proximity
+	measurements.CalculatedCost = CalculateLaneCost(measurements.Latency, measurements.Capacity, 0, 0, 0)
+	sim.measurements[id] = measurements
+
+	return measurements
+}
+
+func (sim *Simulation) analyzeHopDistances(report *SimulationReport, pins []*Pin) {
+	m := sim.m
+	report.HopDistances = make(map[int]int)
+
+	for _, pin := range pins {
+		m.setHome(pin, nil)
+
+		for _, peer := range pins {
+			switch {
+			case peer == pin:
+				// Skip self.
+			case peer.State.has(StateReachable):
+				report.HopDistances[peer.HopDistance]++
+			default:
+				report.Unreachable++
+			}
+		}
+	}
+}
+
+func (sim *Simulation) analyzeRegions(report *SimulationReport) {
+	for _, region := range sim.m.regions {
+		regionReport := &RegionReport{
+			ID:               region.ID,
+			Hubs:             len(region.pins),
+			RegionalMinLanes: region.regionalMinLanes,
+			LanesFromRegions: make(map[string]int),
+		}
+
+		for _, pin := range region.pins {
+			for _, lane := range pin.ConnectedTo {
+				switch {
+				case lane.Pin.region == nil:
+					regionReport.ExternalLanes++
+				case lane.Pin.region.ID == region.ID:
+					// Lanes within the region are seen from both sides.
+					if pin.Hub.ID < lane.Pin.Hub.ID {
+						regionReport.InternalLanes++
+					}
+				default:
+					regionReport.ExternalLanes++
+					regionReport.LanesFromRegions[lane.Pin.region.ID]++
+				}
+			}
+		}
+
+		report.Regions = append(report.Regions, regionReport)
+	}
+}
+
+func (sim *Simulation) analyzeHubLanes(report *SimulationReport, pins []*Pin) {
+	report.Hubs = make([]*HubLaneReport, 0, len(pins))
+	for _, pin := range pins {
+		report.Hubs = append(report.Hubs, &HubLaneReport{
+			ID:     pin.Hub.ID,
+			Name:   pin.Hub.Info.Name,
+			Region: pin.region.getName(),
+			Lanes:  len(pin.ConnectedTo),
+		})
+	}
+}
+
+// String returns a human readable summary of the report.
+func (report *SimulationReport) String() string {
+	var builder strings.Builder
+
+	// Write convergence.
+	fmt.Fprintf(&builder, "Simulation of Map %s:\n", report.Map)
+	if report.Converged {
+		fmt.Fprintf(&builder, "Converged after %d runs with %d new lanes.\n", len(report.Runs), report.NewLanes)
+	} else {
+		fmt.Fprintf(&builder, "Did not converge after %d runs with %d new lanes.\n", len(report.Runs), report.NewLanes)
+	}
+	for _, run := range report.Runs {
+		purposes := make([]string, 0, len(run.Purposes))
+		for purpose, cnt := range run.Purposes {
+			purposes = append(purposes, fmt.Sprintf("%s=%d", purpose, cnt))
+		}
+		sort.Strings(purposes)
+		fmt.Fprintf(&builder, "Run #%d: %d new lanes (%s)\n", run.Run, run.NewLanes, strings.Join(purposes, " "))
+	}
+
+	// Write hop distances.
+	fmt.Fprintln(&builder, "\nHop Distances:")
+	distances := make([]int, 0, len(report.HopDistances))
+	for distance := range report.HopDistances {
+		distances = append(distances, distance)
+	}
+	sort.Ints(distances)
+	for _, distance := range distances {
+		fmt.Fprintf(&builder, "%d Hops: %d Hub pairs\n", distance, report.HopDistances[distance])
+	}
+	fmt.Fprintf(&builder, "Unreachable: %d Hub pairs\n", report.Unreachable)
+
+	// Write regions.
+	if len(report.Regions) > 0 {
+		fmt.Fprintln(&builder, "\nRegions:")
+		for _, region := range report.Regions {
+			fmt.Fprintf(
+				&builder,
+				"%s: %d Hubs, %d internal lanes, %d external lanes (min %d per region): %v\n",
+				region.ID, region.Hubs, region.InternalLanes, region.ExternalLanes,
+				region.RegionalMinLanes, region.LanesFromRegions,
+			)
+		}
+	}
+
+	// Write lane stats.
+	fmt.Fprintln(&builder, "\nHub Lanes:")
+	for _, h := range report.Hubs {
+		fmt.Fprintf(&builder, "%s (%s, region %s): %d lanes\n", h.Name, h.ID, h.Region, h.Lanes)
+	}
+
+	return builder.String()
+}
+
+// simulationRegion defines a region for generated simulation topologies.
+type simulationRegion struct {
+	id        string
+	continent string
+	countries []string
+	latitude  float64
+	longitude float64
+	weight    int
+}
+
+var simulationRegions = []*simulationRegion{
+	{id: "eu", continent: "EU", countries: []string{"DE", "FR", "NL", "AT"}, latitude: 50, longitude: 8, weight: 40},
+	{id: "na", continent: "NA", countries: []string{"US", "CA"}, latitude: 40, longitude: -90, weight: 30},
+	{id: "as", continent: "AS", countries: []string{"JP", "SG"}, latitude: 25, longitude: 120, weight: 15},
+	// Satellites are not configured as a region.
+	{continent: "SA", countries: []string{"BR", "AR"}, latitude: -20, longitude: -50, weight: 5},
+	{continent: "OC", countries: []string{"AU"}, latitude: -30, longitude: 145, weight: 5},
+	{continent: "AF", countries: []string{"ZA"}, latitude: -30, longitude: 25, weight: 5},
+}
+
+// GenerateSimulationSnapshot generates a reproducible network topology of
+// the given size without any lanes for simulation.
+func GenerateSimulationSnapshot(seed int64, size int) *MapSnapshot {
+	rng := rand.New(rand.NewSource(seed)) //nolint:gosec // Reproducible simulation data.
+
+	snapshot := &MapSnapshot{
+		Version:          SnapshotVersion,
+		Name:             fmt.Sprintf("Simulation-%d", seed),
+		Created:          time.Now(),
+		MeasuringEnabled: true,
+		Intel:            &hub.Intel{},
+	}
+
+	// Configure regions.
+	var totalWeight int
+	for _, region := range simulationRegions {
+		totalWeight += region.weight
+		if region.id == "" {
+			continue
+		}
+
+		memberPolicy := make([]string, 0, len(region.countries)+1)
+		for _, country := range region.countries {
+			memberPolicy = append(memberPolicy, "+ "+country)
+		}
+		memberPolicy = append(memberPolicy, "- *")
+		snapshot.Intel.Regions = append(snapshot.Intel.Regions, &hub.RegionConfig{
+			ID:                     region.id,
+			Name:                   strings.ToUpper(region.id),
+			MemberPolicy:           memberPolicy,
+			RegionalMinLanesPerHub: defaultRegionalMinLanesPerHub,
+		})
+	}
+	// Generated data is always valid.
+	_ = snapshot.Intel.ParseAdvisories()
+
+	// Create Hubs.
+	for i := 0; i < size; i++ {
+		// Select region by weight.
+		selected := rng.Intn(totalWeight)
+		var region *simulationRegion
+		for _, region = range simulationRegions {
+			if selected < region.weight {
+				break
+			}
+			selected -= region.weight
+		}
+
+		// Create location.
+		location := &geoip.Location{}
+		location.Continent.Code = region.continent
+		location.Country.ISOCode = region.countries[rng.Intn(len(region.countries))]
+		location.Coordinates.Latitude = region.latitude + rng.Float64()*10 - 5
+		location.Coordinates.Longitude = region.longitude + rng.Float64()*20 - 10
+		location.Coordinates.AccuracyRadius = 100
+		location.AutonomousSystemNumber = uint(64500 + rng.Intn(20))
+
+		// Create Hub.
+		id := fmt.Sprintf("sim-%04d", i)
+		h := &hub.Hub{
+			ID:  id,
+			Map: snapshot.Name,
+			Info: &hub.Announcement{
+				ID:        id,
+				Timestamp: snapshot.Created.Unix(),
+				Name:      fmt.Sprintf("Sim %s %d", location.Country.ISOCode, i),
+				Group:     fmt.Sprintf("group-%d", i/5),
+				IPv4:      net.IPv4(byte(80+i/65536), byte(i/256), byte(i), 1),
+			},
+			Status: &hub.Status{
+				Timestamp: snapshot.Created.Unix(),
+				// Hubs are only regarded as active with a valid key.
+				Keys: map[string]*hub.Key{
+					"sim": {Scheme: "simulation", Expires: snapshot.Created.Add(365 * 24 * time.Hour).Unix()},
+				},
+				Load: rng.Intn(80),
+			},
+			FirstSeen: snapshot.Created,
+		}
+
+		// Add to snapshot.
+		pinSnapshot := &PinSnapshot{
+			Hub:        h,
+			LocationV4: location,
+			State:      StateActive,
+		}
+		if region.id != "" {
+			pinSnapshot.RegionID = region.id
+		}
+		snapshot.Pins = append(snapshot.Pins, pinSnapshot)
+	}
+
+	// Set the first Hub as Home.
+	if len(snapshot.Pins) > 0 {
+		snapshot.HomeHubID = snapshot.Pins[0].Hub.ID
+	}
+
+	return snapshot
+}
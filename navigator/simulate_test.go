@@ -0,0 +1,41 @@
+package navigator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimulation(t *testing.T) {
+	sim, err := NewSimulation(GenerateSimulationSnapshot(1, 50))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := sim.Run(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, report.Converged, "simulation should converge")
+	assert.NotZero(t, report.NewLanes)
+	assert.Zero(t, report.Unreachable, "all hubs should be able to reach each other")
+	assert.Len(t, report.Hubs, 50)
+	assert.Len(t, report.Regions, 3)
+	for _, h := range report.Hubs {
+		assert.NotZero(t, h.Lanes, "%s should have lanes", h.ID)
+	}
+
+	// Check that the simulation is reproducible.
+	sim2, err := NewSimulation(GenerateSimulationSnapshot(1, 50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report2, err := sim2.Run(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, report.NewLanes, report2.NewLanes)
+	assert.Equal(t, report.HopDistances, report2.HopDistances)
+
+	t.Logf("report:\n%s", report)
+}
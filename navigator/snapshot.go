@@ -0,0 +1,305 @@
+package navigator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/safing/portmaster/intel"
+	"github.com/safing/portmaster/intel/geoip"
+	"github.com/safing/portmaster/network/netutils"
+	"github.com/safing/spn/hub"
+	"github.com/tevino/abool"
+)
+
+// SnapshotVersion is the current version of the map snapshot format.
+const SnapshotVersion = 1
+
+// MapSnapshot holds the full state of a Map, which can be used to load the
+// Map elsewhere without a database or network access.
+type MapSnapshot struct {
+	// Version is the version of the snapshot format.
+	Version int
+
+	// Name is the name of the snapshotted map.
+	Name string
+
+	// Created holds when the snapshot was created.
+	Created time.Time
+
+	// MeasuringEnabled signifies whether measuring was enabled on the map.
+	MeasuringEnabled bool
+
+	// HomeHubID is the ID of the Home Hub at the time of the snapshot.
+	HomeHubID string
+
+	// Pins holds all Pins of the map.
+	Pins []*PinSnapshot
+
+	// Intel holds the intel data of the map, including the regions.
+	Intel *hub.Intel
+}
+
+// PinSnapshot holds the state of a Pin in a MapSnapshot.
+type PinSnapshot struct {
+	// Hub holds the Hub with its announcement, status and lanes.
+	Hub *hub.Hub
+
+	// Measurements holds the measurements of the Pin.
+	Measurements *hub.Measurements
+
+	// LocationV4 and LocationV6 hold the location data of the Hub's IPs, as
+	// geoip is not available when loading a snapshot.
+	LocationV4 *geoip.Location
+	LocationV6 *geoip.Location
+
+	// RegionID holds the ID of the region the Pin belonged to.
+	RegionID string
+
+	// State holds the states of the Pin. States are recalculated when loading
+	// the snapshot, but the time dependent states StateFailing and StateActive
+	// are restored from here in order to make loading deterministic.
+	State PinState
+
+	// FailingUntil specifies until when the Hub was regarded as failing.
+	FailingUntil time.Time
+}
+
+// Snapshot creates a snapshot of the full state of the map.
+func (m *Map) Snapshot() (*MapSnapshot, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	snapshot := &MapSnapshot{
+		Version:          SnapshotVersion,
+		Name:             m.Name,
+		Created:          time.Now(),
+		MeasuringEnabled: m.measuringEnabled,
+		Intel:            m.intel,
+		Pins:             make([]*PinSnapshot, 0, len(m.all)),
+	}
+	if m.home != nil {
+		snapshot.HomeHubID = m.home.Hub.ID
+	}
+
+	// Snapshot all Pins in a stable order.
+	for _, pin := range m.sortedPins(false) {
+		pinSnapshot, err := pin.snapshot()
+		if err != nil {
+			return nil, fmt.Errorf("failed to snapshot %s: %w", pin, err)
+		}
+		snapshot.Pins = append(snapshot.Pins, pinSnapshot)
+	}
+
+	return snapshot, nil
+}
+
+func (pin *Pin) snapshot() (*PinSnapshot, error) {
+	pin.Lock()
+	defer pin.Unlock()
+
+	// Copy Hub.
+	h := &hub.Hub{
+		ID:            pin.Hub.ID,
+		PublicKey:     pin.Hub.PublicKey,
+		Map:           pin.Hub.Map,
+		FirstSeen:     pin.Hub.FirstSeen,
+		VerifiedIPs:   pin.Hub.VerifiedIPs,
+		InvalidInfo:   pin.Hub.InvalidInfo,
+		InvalidStatus: pin.Hub.InvalidStatus,
+	}
+	var err error
+	h.Info, err = pin.Hub.Info.Copy()
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy announcement: %w", err)
+	}
+	h.Status, err = pin.Hub.Status.Copy()
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy status: %w", err)
+	}
+
+	// Create snapshot.
+	pinSnapshot := &PinSnapshot{
+		Hub:          h,
+		LocationV4:   pin.LocationV4,
+		LocationV6:   pin.LocationV6,
+		State:        pin.State,
+		FailingUntil: pin.FailingUntil,
+	}
+	if pin.region != nil {
+		pinSnapshot.RegionID = pin.region.ID
+	}
+	if pin.measurements != nil {
+		pin.measurements.Lock()
+		pinSnapshot.Measurements = pin.measurements.Copy()
+		pin.measurements.Unlock()
+	}
+
+	return pinSnapshot, nil
+}
+
+// ExportSnapshot creates a snapshot of the map and serializes it.
+func (m *Map) ExportSnapshot() ([]byte, error) {
+	snapshot, err := m.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(snapshot)
+}
+
+// ParseSnapshot parses a serialized map snapshot.
+func ParseSnapshot(data []byte) (*MapSnapshot, error) {
+	snapshot := &MapSnapshot{}
+	err := json.Unmarshal(data, snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot: %w", err)
+	}
+
+	// Check version.
+	switch {
+	case snapshot.Version == 0:
+		return nil, errors.New("snapshot is missing version")
+	case snapshot.Version > SnapshotVersion:
+		return nil, fmt.Errorf("snapshot version %d is not supported", snapshot.Version)
+	}
+
+	// Parse intel data.
+	if snapshot.Intel != nil {
+		err = snapshot.Intel.ParseAdvisories()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse snapshot intel: %w", err)
+		}
+	}
+
+	return snapshot, nil
+}
+
+// NewMapFromSnapshot returns a new Map loaded from the given snapshot.
+// The returned map is offline: it does not use geoip or the database, does
+// not push changes to the database interface and is not added to the API.
+func NewMapFromSnapshot(snapshot *MapSnapshot) (*Map, error) {
+	if snapshot.Version == 0 || snapshot.Version > SnapshotVersion {
+		return nil, fmt.Errorf("snapshot version %d is not supported", snapshot.Version)
+	}
+	if snapshot.Intel != nil && snapshot.Intel.Parsed() == nil {
+		return nil, errors.New("snapshot intel data is not parsed")
+	}
+
+	m := &Map{
+		Name:              snapshot.Name,
+		all:               make(map[string]*Pin),
+		measuringEnabled:  snapshot.MeasuringEnabled,
+		offline:           true,
+		destinationProbes: make(map[string]*DestinationProbe),
+		successions:       make(map[string]*pinSuccession),
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	// Configure intel and regions first, so that Pins can be assigned.
+	m.intel = snapshot.Intel
+	if m.intel != nil {
+		m.updateRegions(m.intel.Regions)
+	}
+
+	// Add all Pins before updating them, so that all Lanes can be built.
+	for _, pinSnapshot := range snapshot.Pins {
+		if pinSnapshot.Hub == nil || pinSnapshot.Hub.Info == nil || pinSnapshot.Hub.Status == nil {
+			return nil, errors.New("snapshot contains incomplete hub")
+		}
+		m.all[pinSnapshot.Hub.ID] = pinSnapshot.newPin(m.measuringEnabled)
+	}
+
+	// Update all Pins in order to calculate states and lanes.
+	for _, pinSnapshot := range snapshot.Pins {
+		m.updateHub(pinSnapshot.Hub, false, true)
+
+		// Restore time dependent states.
+		pin := m.all[pinSnapshot.Hub.ID]
+		pin.removeStates(StateActive)
+		pin.addStates(pinSnapshot.State & (StateFailing | StateActive))
+		pin.FailingUntil = pinSnapshot.FailingUntil
+
+		// Restore region.
+		if pinSnapshot.RegionID != "" {
+			for _, region := range m.regions {
+				if region.ID == pinSnapshot.RegionID {
+					region.addPin(pin)
+					break
+				}
+			}
+		}
+	}
+
+	// Set the Home Hub.
+	if snapshot.HomeHubID != "" {
+		home, ok := m.all[snapshot.HomeHubID]
+		if !ok {
+			return nil, fmt.Errorf("home hub %s is not in the snapshot", snapshot.HomeHubID)
+		}
+		m.home = home
+		m.home.addStates(StateIsHomeHub)
+		if err := m.recalculateReachableHubs(); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// newPin creates a new Pin from the snapshot, with all the data that the
+// Map cannot gather itself when offline.
+func (pinSnapshot *PinSnapshot) newPin(measuringEnabled bool) *Pin {
+	pin := &Pin{
+		Hub:         pinSnapshot.Hub,
+		LocationV4:  pinSnapshot.LocationV4,
+		LocationV6:  pinSnapshot.LocationV6,
+		ConnectedTo: make(map[string]*Lane),
+		pushChanges: abool.New(),
+	}
+	pin.EntityV4 = newOfflineEntity(pinSnapshot.Hub.Info.IPv4, pinSnapshot.LocationV4)
+	pin.EntityV6 = newOfflineEntity(pinSnapshot.Hub.Info.IPv6, pinSnapshot.LocationV6)
+
+	// Use the snapshotted measurements directly instead of the shared ones, as
+	// they belong to this map only.
+	if measuringEnabled {
+		pin.measurements = pinSnapshot.Measurements
+		if pin.measurements == nil {
+			pin.measurements = hub.NewMeasurements()
+		} else {
+			pin.measurements = pin.measurements.Copy()
+		}
+	}
+
+	return pin
+}
+
+// newOfflineEntity creates a new entity from the given IP and location.
+// The location data from the snapshot is set directly on the entity, so that
+// the Map does not need to look it up. Note that endpoint policies that match
+// on the location still request the location from the entity, which consults
+// geoip, if available.
+func newOfflineEntity(ip net.IP, location *geoip.Location) *intel.Entity {
+	if ip == nil {
+		return nil
+	}
+
+	entity := &intel.Entity{
+		IP:      ip,
+		IPScope: netutils.GetIPScope(ip),
+	}
+
+	// Set the location data from the snapshot.
+	if location != nil {
+		entity.Country = location.Country.ISOCode
+		entity.Coordinates = &location.Coordinates
+		entity.ASN = location.AutonomousSystemNumber
+		entity.ASOrg = location.AutonomousSystemOrganization
+	}
+
+	return entity
+}
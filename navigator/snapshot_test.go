@@ -0,0 +1,157 @@
+package navigator
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/safing/portmaster/intel/geoip"
+	"github.com/safing/portmaster/network/netutils"
+	"github.com/safing/spn/hub"
+	"github.com/stretchr/testify/assert"
+)
+
+// createSnapshotTestMap creates a small offline map from a snapshot, which
+// does not need geoip or the database. Hubs are connected in a ring, with
+// every third Hub having an additional lane across.
+func createSnapshotTestMap(t *testing.T, size int) *Map {
+	snapshot := &MapSnapshot{
+		Version:          SnapshotVersion,
+		Name:             "Test-Snapshot-Map",
+		MeasuringEnabled: true,
+	}
+
+	// Create Hubs.
+	hubs := make([]*hub.Hub, 0, size)
+	for i := 0; i < size; i++ {
+		hubs = append(hubs, &hub.Hub{
+			ID: fmt.Sprintf("hub-%d", i),
+			Info: &hub.Announcement{
+				ID:   fmt.Sprintf("hub-%d", i),
+				Name: fmt.Sprintf("Hub %d", i),
+			},
+			Status: &hub.Status{
+				Load: 10,
+			},
+		})
+	}
+
+	// Connect Hubs.
+	connect := func(a, b *hub.Hub) {
+		a.Status.Lanes = append(a.Status.Lanes, &hub.Lane{ID: b.ID, Capacity: cap100Mbit, Latency: 10 * time.Millisecond})
+		b.Status.Lanes = append(b.Status.Lanes, &hub.Lane{ID: a.ID, Capacity: cap100Mbit, Latency: 10 * time.Millisecond})
+	}
+	for i := 0; i < size; i++ {
+		connect(hubs[i], hubs[(i+1)%size])
+		if i%3 == 0 {
+			connect(hubs[i], hubs[(i+size/2)%size])
+		}
+	}
+
+	// Add to snapshot.
+	for _, h := range hubs {
+		snapshot.Pins = append(snapshot.Pins, &PinSnapshot{
+			Hub:   h,
+			State: StateActive,
+		})
+	}
+	snapshot.HomeHubID = hubs[0].ID
+
+	m, err := NewMapFromSnapshot(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestSnapshotLoading(t *testing.T) {
+	m := createSnapshotTestMap(t, 10)
+
+	// Check home and reachability.
+	home, _ := m.GetHome()
+	assert.Equal(t, "hub-0", home.Hub.ID)
+	for _, pin := range m.all {
+		assert.True(t, pin.State.has(StateReachable|StateActive), "%s should be reachable and active", pin)
+		assert.NotZero(t, pin.HopDistance)
+	}
+	pin, _ := m.GetPin("hub-2")
+	assert.Equal(t, 3, pin.HopDistance)
+	assert.Len(t, pin.ConnectedTo, 2)
+
+	// Check routing on the offline map.
+	dst, _ := m.GetPin("hub-4")
+	routes, err := m.findRoutes(&nearbyPins{
+		pins: []*nearbyPin{{pin: dst, proximity: 100}},
+	}, m.defaultOptions(), 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEmpty(t, routes.All)
+	for _, route := range routes.All {
+		assert.Equal(t, "hub-4", route.Path[len(route.Path)-1].HubID)
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	m := createSnapshotTestMap(t, 10)
+
+	// Export and load again.
+	data, err := m.ExportSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := ParseSnapshot(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := NewMapFromSnapshot(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Compare maps.
+	assert.Equal(t, len(m.all), len(loaded.all))
+	assert.Equal(t, m.home.Hub.ID, loaded.home.Hub.ID)
+	for id, pin := range m.all {
+		loadedPin, ok := loaded.all[id]
+		if !assert.True(t, ok, "%s is missing", pin) {
+			continue
+		}
+		assert.Equal(t, pin.State, loadedPin.State)
+		assert.Equal(t, pin.HopDistance, loadedPin.HopDistance)
+		assert.Equal(t, len(pin.ConnectedTo), len(loadedPin.ConnectedTo))
+		assert.Equal(t, pin.Cost, loadedPin.Cost)
+	}
+
+	// Check version handling.
+	_, err = ParseSnapshot([]byte(`{"Version":999}`))
+	assert.Error(t, err)
+	_, err = ParseSnapshot([]byte(`{}`))
+	assert.Error(t, err)
+}
+
+func TestOfflineEntity(t *testing.T) {
+	assert.Nil(t, newOfflineEntity(nil, nil))
+
+	location := &geoip.Location{}
+	location.Country.ISOCode = "AT"
+	location.Coordinates.Latitude = 48.2
+	location.Coordinates.Longitude = 16.4
+	location.AutonomousSystemNumber = 64500
+	location.AutonomousSystemOrganization = "Example Hosting GmbH"
+
+	entity := newOfflineEntity(net.IPv4(198, 51, 100, 1), location)
+	assert.True(t, entity.IP.Equal(net.IPv4(198, 51, 100, 1)))
+	assert.Equal(t, netutils.GetIPScope(entity.IP), entity.IPScope)
+	assert.Equal(t, "AT", entity.Country)
+	assert.Equal(t, location.Coordinates, *entity.Coordinates)
+	assert.Equal(t, uint(64500), entity.ASN)
+	assert.Equal(t, "Example Hosting GmbH", entity.ASOrg)
+
+	// Entities without snapshotted location only have the IP.
+	entity = newOfflineEntity(net.IPv4(198, 51, 100, 2), nil)
+	assert.NotNil(t, entity.IP)
+	assert.Empty(t, entity.Country)
+	assert.Nil(t, entity.Coordinates)
+}
@@ -0,0 +1,129 @@
+package navigator
+
+import (
+	"github.com/safing/portbase/log"
+	"github.com/safing/spn/hub"
+)
+
+// maxSuccessionDepth defines how many predecessors are followed when checking
+// whether a Hub inherits trust from a predecessor.
+const maxSuccessionDepth = 10
+
+// pinSuccession holds the state of a Pin whose Hub rotated its identity key,
+// until it is migrated to the Pin of the successor Hub.
+type pinSuccession struct {
+	// predecessorID is the ID of the retired predecessor Hub.
+	predecessorID string
+
+	// migrated signifies that the state was carried over to the successor.
+	migrated bool
+
+	reputation   *Reputation
+	measurements *hub.Measurements
+}
+
+// recordSuccession records the succession of the given retired Hub and
+// migrates the state of its Pin to the successor Pin, if it already exists.
+// The map and the Hub must be locked.
+func (m *Map) recordSuccession(h *hub.Hub) {
+	successorID := h.Succession.Successor
+
+	// Keep the state of the predecessor Pin, if it is still on the map.
+	s, ok := m.successions[successorID]
+	if !ok {
+		s = &pinSuccession{predecessorID: h.ID}
+		m.successions[successorID] = s
+	}
+	if pin, ok := m.all[h.ID]; ok && !s.migrated {
+		s.reputation = pin.reputation
+		s.measurements = pin.measurements
+	}
+
+	// Migrate immediately if the successor was already added.
+	successor, ok := m.all[successorID]
+	if !ok {
+		return
+	}
+	func() {
+		successor.Hub.Lock()
+		defer successor.Hub.Unlock()
+
+		m.migrateSuccession(successor)
+	}()
+	m.updateIntelStatuses(successor)
+	successor.pushChanges.Set()
+}
+
+// migrateSuccession carries over the reputation and measurements of the
+// predecessor to the given successor Pin, if it has a pending succession.
+// The map and the Hub of the Pin must be locked.
+func (m *Map) migrateSuccession(pin *Pin) {
+	s, ok := m.successions[pin.Hub.ID]
+	if !ok || s.migrated {
+		return
+	}
+	s.migrated = true
+
+	if s.reputation != nil {
+		// The migrated reputation is marked as changed and saved with the next
+		// reputation update.
+		if pin.reputation == nil {
+			pin.reputation = newReputation(m.Name, pin.Hub.ID)
+		}
+		pin.reputation.migrateFrom(s.reputation)
+	}
+	if s.measurements != nil && m.measuringEnabled {
+		pin.measurements = s.measurements
+		pin.Hub.SetMeasurementsWithLockedHub(s.measurements)
+	}
+
+	// Release the migrated state.
+	s.reputation = nil
+	s.measurements = nil
+
+	log.Infof("spn/navigator: migrated %s to successor %s on map %s", s.predecessorID, pin.Hub.ID, m.Name)
+}
+
+// inheritsTrust returns whether the Hub with the given ID or one of its
+// predecessors is in the given list of trusted Hubs.
+// The map must be locked.
+func (m *Map) inheritsTrust(hubID string, trustedHubs []string) bool {
+	for i := 0; i <= maxSuccessionDepth; i++ {
+		for _, trustedID := range trustedHubs {
+			if hubID == trustedID {
+				return true
+			}
+		}
+
+		// Continue with predecessor.
+		s, ok := m.successions[hubID]
+		if !ok {
+			return false
+		}
+		hubID = s.predecessorID
+	}
+
+	return false
+}
+
+// migrateFrom carries over the history of the predecessor's reputation.
+// The reputation is updated in place instead of being replaced, as abandoned
+// sessions are reported to it without holding the map lock.
+func (rep *Reputation) migrateFrom(predecessor *Reputation) {
+	predecessor.Lock()
+	defer predecessor.Unlock()
+	rep.Lock()
+	defer rep.Unlock()
+
+	rep.Successes = predecessor.Successes
+	rep.Failures = predecessor.Failures
+	rep.Abandons = predecessor.Abandons
+	rep.AbandonsByError = make(map[uint8]float64, len(predecessor.AbandonsByError))
+	for errID, count := range predecessor.AbandonsByError {
+		rep.AbandonsByError[errID] = count
+	}
+	rep.ConsecutiveFailures = predecessor.ConsecutiveFailures
+	rep.ExcludedUntil = predecessor.ExcludedUntil
+	rep.UpdatedAt = predecessor.UpdatedAt
+	rep.changed = true
+}
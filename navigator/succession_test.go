@@ -0,0 +1,118 @@
+package navigator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/safing/spn/hub"
+	"github.com/safing/spn/terminal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuccessionMigration(t *testing.T) {
+	m := createSnapshotTestMap(t, 10)
+	m.intel = &hub.Intel{
+		TrustedHubs: []string{"hub-3"},
+	}
+	if err := m.intel.ParseAdvisories(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Build up history of the predecessor.
+	predecessorPin, _ := m.GetPin("hub-3")
+	m.ReportEstablishmentFailure("hub-3")
+	m.ReportSessionSuccess("hub-3")
+	predecessorPin.measurements.SetLatency(42 * time.Millisecond)
+
+	// Retire predecessor with a succession.
+	predecessor := &hub.Hub{
+		ID:     "hub-3",
+		Info:   predecessorPin.Hub.Info,
+		Status: predecessorPin.Hub.Status,
+		Succession: &hub.Succession{
+			ID:        "hub-3",
+			Successor: "hub-3-successor",
+			Timestamp: time.Now().Unix(),
+		},
+		Retirement: &hub.Retirement{
+			ID:        "hub-3",
+			Successor: "hub-3-successor",
+		},
+	}
+	m.UpdateHub(predecessor)
+	_, ok := m.GetPin("hub-3")
+	assert.False(t, ok, "retired predecessor should be removed")
+
+	// Add successor.
+	successor := &hub.Hub{
+		ID: "hub-3-successor",
+		Info: &hub.Announcement{
+			ID:   "hub-3-successor",
+			Name: "Hub 3",
+		},
+		Status: &hub.Status{
+			Load:  10,
+			Lanes: predecessorPin.Hub.Status.Lanes,
+		},
+	}
+	m.UpdateHub(successor)
+	successorPin, ok := m.GetPin("hub-3-successor")
+	if !assert.True(t, ok, "successor should be added") {
+		return
+	}
+
+	// Check that the history was migrated.
+	assert.Equal(t, "hub-3-successor", successorPin.reputation.HubID)
+	assert.InDelta(t, predecessorPin.reputation.Failures, successorPin.reputation.Failures, 0.001)
+	assert.InDelta(t, predecessorPin.reputation.Successes, successorPin.reputation.Successes, 0.001)
+	latency, _ := successorPin.measurements.GetLatency()
+	assert.Equal(t, 42*time.Millisecond, latency)
+	assert.True(t, successorPin.State.has(StateTrusted), "successor should inherit trust")
+
+	// Other Hubs do not inherit anything.
+	otherPin, _ := m.GetPin("hub-4")
+	assert.False(t, otherPin.State.has(StateTrusted))
+}
+
+func TestSuccessionMigrationWhileAbandoning(t *testing.T) {
+	m := createSnapshotTestMap(t, 10)
+
+	// Report abandoned sessions to the successor like terminal change
+	// notifications do, without holding the map lock.
+	successorPin, _ := m.GetPin("hub-4")
+	reputation := successorPin.reputation
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			successorPin.Lock()
+			successorPin.reportSessionAbandoned(terminal.ErrShipSunk.With("test"))
+			successorPin.Unlock()
+		}
+	}()
+
+	// Retire hub-3 with the already existing hub-4 as successor.
+	predecessorPin, _ := m.GetPin("hub-3")
+	m.UpdateHub(&hub.Hub{
+		ID:     "hub-3",
+		Info:   predecessorPin.Hub.Info,
+		Status: predecessorPin.Hub.Status,
+		Succession: &hub.Succession{
+			ID:        "hub-3",
+			Successor: "hub-4",
+			Timestamp: time.Now().Unix(),
+		},
+		Retirement: &hub.Retirement{
+			ID:        "hub-3",
+			Successor: "hub-4",
+		},
+	})
+	<-done
+
+	// The reputation must be migrated in place.
+	successorPin.Lock()
+	defer successorPin.Unlock()
+	assert.Same(t, reputation, successorPin.reputation)
+	assert.Equal(t, "hub-4", successorPin.reputation.HubID)
+	assert.True(t, successorPin.reputation.changed)
+}
@@ -0,0 +1,199 @@
+package navigator
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/safing/portbase/log"
+	"github.com/safing/portmaster/intel/geoip"
+	"github.com/safing/spn/hub"
+)
+
+// SupplyChain holds the parsed and cross-checked supply chain of a Hub.
+type SupplyChain struct {
+	// Datacenter holds the parsed datacenter, if announced.
+	Datacenter *hub.Datacenter `json:",omitempty"`
+
+	// Hosters holds the normalized company names of the hoster supply chain.
+	Hosters []string `json:",omitempty"`
+
+	// ASN and ASOrg hold the Autonomous System of the Hub, as seen by geoip.
+	ASN   uint   `json:",omitempty"`
+	ASOrg string `json:",omitempty"`
+
+	// CountryMismatch signifies that the country of the datacenter does not
+	// match the geoip country of the Hub.
+	CountryMismatch bool `json:",omitempty"`
+
+	// ASOrgConfirmed signifies that the geoip AS organization matches the
+	// datacenter company or one of the hosters.
+	ASOrgConfirmed bool `json:",omitempty"`
+
+	// DatacenterVerified signifies that the datacenter is used for diversity
+	// decisions. This is the case if it was set by the intel or if it is
+	// confirmed by geoip: the AS organization must be confirmed and the
+	// country must match.
+	DatacenterVerified bool `json:",omitempty"`
+}
+
+// updateSupplyChain parses and cross-checks the supply chain of the Pin.
+// It must be called after the location data and info overrides were applied.
+func (m *Map) updateSupplyChain(pin *Pin) {
+	if pin.Hub.Info == nil {
+		pin.SupplyChain = nil
+		return
+	}
+	datacenter := pin.Hub.Info.Datacenter
+	hosters := pin.Hub.Info.Hosters
+	var datacenterFromIntel bool
+
+	// Apply intel overrides.
+	if m.intel != nil && m.intel.InfoOverrides != nil {
+		if overrides, ok := m.intel.InfoOverrides[pin.Hub.ID]; ok {
+			if overrides.Datacenter != "" {
+				datacenter = overrides.Datacenter
+				datacenterFromIntel = true
+			}
+			if len(overrides.Hosters) > 0 {
+				hosters = overrides.Hosters
+			}
+		}
+	}
+
+	sc := &SupplyChain{}
+
+	// Parse datacenter and hosters.
+	// Invalid values are only logged when the announcement is applied, so
+	// both the announcement and the overrides need to be checked here.
+	if datacenter != "" {
+		dc, err := hub.ParseDatacenter(datacenter)
+		if err != nil {
+			log.Warningf("spn/navigator: ignoring datacenter of %s: %s", pin, err)
+		} else {
+			sc.Datacenter = dc
+		}
+	}
+	parsedHosters, err := hub.ParseHosters(hosters)
+	if err != nil {
+		log.Warningf("spn/navigator: ignoring hosters of %s: %s", pin, err)
+	} else {
+		sc.Hosters = parsedHosters
+	}
+
+	// Cross-check with geoip data.
+	var countries []string
+	for _, location := range []*geoip.Location{pin.LocationV4, pin.LocationV6} {
+		if location == nil {
+			continue
+		}
+		if location.Country.ISOCode != "" {
+			countries = append(countries, location.Country.ISOCode)
+		}
+		if sc.ASN == 0 {
+			sc.ASN = location.AutonomousSystemNumber
+			sc.ASOrg = location.AutonomousSystemOrganization
+		}
+	}
+
+	if sc.Datacenter != nil && len(countries) > 0 {
+		sc.CountryMismatch = true
+		for _, country := range countries {
+			if country == sc.Datacenter.Country {
+				sc.CountryMismatch = false
+				break
+			}
+		}
+		if sc.CountryMismatch {
+			log.Warningf(
+				"spn/navigator: datacenter country of %s does not match geoip: %s not in %v",
+				pin, sc.Datacenter.Country, countries,
+			)
+		}
+	}
+
+	if sc.ASOrg != "" {
+		asOrg := hub.NormalizeCompany(sc.ASOrg)
+		if sc.Datacenter != nil && strings.Contains(asOrg, hub.NormalizeCompany(sc.Datacenter.Company)) {
+			sc.ASOrgConfirmed = true
+		}
+		for _, hoster := range sc.Hosters {
+			if strings.Contains(asOrg, hoster) {
+				sc.ASOrgConfirmed = true
+				break
+			}
+		}
+	}
+
+	// Only use datacenters for diversity decisions that are backed by the
+	// intel or geoip. Otherwise, a Hub could claim the datacenter of other
+	// Hubs in order to push them out of routes.
+	if sc.Datacenter != nil {
+		sc.DatacenterVerified = datacenterFromIntel ||
+			(sc.ASOrgConfirmed && !sc.CountryMismatch)
+	}
+
+	pin.SupplyChain = sc
+}
+
+// verifiedDatacenter returns the datacenter, if it is verified.
+func (sc *SupplyChain) verifiedDatacenter() *hub.Datacenter {
+	if sc == nil || !sc.DatacenterVerified {
+		return nil
+	}
+	return sc.Datacenter
+}
+
+// sharesDatacenter returns whether both Pins are in the same verified
+// datacenter.
+func sharesDatacenter(a, b *Pin) bool {
+	aDC := a.SupplyChain.verifiedDatacenter()
+	bDC := b.SupplyChain.verifiedDatacenter()
+	if aDC == nil || bDC == nil {
+		return false
+	}
+	return aDC.Key() == bDC.Key()
+}
+
+// deprioritizeSameDatacenter moves all Pins that share the datacenter with
+// the Home Hub to the end of the list, while keeping the order otherwise.
+// Connecting to Hubs in the same datacenter adds little to the network.
+func (m *Map) deprioritizeSameDatacenter(pins []*Pin) {
+	if m.home == nil {
+		return
+	}
+	sort.SliceStable(pins, func(i, j int) bool {
+		return !sharesDatacenter(m.home, pins[i]) && sharesDatacenter(m.home, pins[j])
+	})
+}
+
+// sharesHoster returns whether both Pins share a company in their supply
+// chain. This includes the hosters, the verified datacenter company and the
+// Autonomous System.
+func sharesHoster(a, b *Pin) bool {
+	if a.SupplyChain == nil || b.SupplyChain == nil {
+		return false
+	}
+	if a.SupplyChain.ASN != 0 && a.SupplyChain.ASN == b.SupplyChain.ASN {
+		return true
+	}
+
+	aCompanies := a.SupplyChain.companies()
+	for company := range b.SupplyChain.companies() {
+		if _, ok := aCompanies[company]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// companies returns the set of normalized company names in the supply chain.
+func (sc *SupplyChain) companies() map[string]struct{} {
+	companies := make(map[string]struct{}, len(sc.Hosters)+1)
+	for _, hoster := range sc.Hosters {
+		companies[hoster] = struct{}{}
+	}
+	if dc := sc.verifiedDatacenter(); dc != nil {
+		companies[hub.NormalizeCompany(dc.Company)] = struct{}{}
+	}
+	return companies
+}
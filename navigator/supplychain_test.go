@@ -0,0 +1,76 @@
+package navigator
+
+import (
+	"testing"
+
+	"github.com/safing/portmaster/intel/geoip"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSupplyChain(t *testing.T) {
+	m := createSnapshotTestMap(t, 10)
+
+	// Put hub-1, hub-2 and hub-3 in the same datacenter and let hub-4 and
+	// hub-6 share a hoster. Only hub-1 and hub-2 are confirmed by geoip.
+	setSupplyChain := func(hubID, asOrg, datacenter string, hosters ...string) *Pin {
+		pin := m.all[hubID]
+		pin.Hub.Info.Datacenter = datacenter
+		pin.Hub.Info.Hosters = hosters
+		pin.LocationV4 = &geoip.Location{}
+		pin.LocationV4.Country.ISOCode = "DE"
+		pin.LocationV4.AutonomousSystemOrganization = asOrg
+		pin.LocationV6 = nil
+		m.updateSupplyChain(pin)
+		return pin
+	}
+	hub1 := setSupplyChain("hub-1", "Example Datacenters", "DE-Example-DC1")
+	hub2 := setSupplyChain("hub-2", "Some Reseller Ltd", "DE-Example-DC1", "Some Reseller")
+	hub3 := setSupplyChain("hub-3", "Unrelated Networks", "DE-Example-DC1")
+	hub4 := setSupplyChain("hub-4", "", "", "Example Hosting")
+	hub6 := setSupplyChain("hub-6", "", "AT-Other-DC2", "Example  hosting")
+
+	assert.True(t, sharesDatacenter(hub1, hub2))
+	assert.False(t, sharesDatacenter(hub1, hub3), "unconfirmed datacenter should be ignored")
+	assert.False(t, sharesDatacenter(hub1, hub6))
+	assert.True(t, sharesHoster(hub1, hub2), "datacenter company should be shared")
+	assert.True(t, sharesHoster(hub4, hub6), "hoster should be shared")
+	assert.False(t, sharesHoster(hub2, hub6))
+
+	// Check that routes never pass the same datacenter twice.
+	dsts := &nearbyPins{
+		pins: []*nearbyPin{{pin: m.all["hub-3"], proximity: 100}},
+	}
+	routes, err := m.findRoutes(dsts, m.defaultOptions(), 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEmpty(t, routes.All)
+	for _, route := range routes.All {
+		for i, a := range route.Path {
+			for _, b := range route.Path[i+1:] {
+				assert.False(t, sharesDatacenter(a.Pin(), b.Pin()), route.String())
+			}
+		}
+	}
+
+	// Check that sharing a hoster with a previous hop is more expensive.
+	route := &Route{Path: []*Hop{{pin: hub4}}}
+	lane := &Lane{Pin: hub6, Cost: 10}
+	assert.Equal(t, lane.Cost+hub6.Cost+RoutingProfileDefault.SameHosterCost, RoutingProfileDefault.hopCost(route, lane))
+	assert.Equal(t, lane.Cost+hub6.Cost, RoutingProfileShortest.hopCost(route, lane))
+
+	// Check that Hubs in the same datacenter as the Home Hub are deprioritized.
+	m.home = hub1
+	pins := []*Pin{hub2, hub4, hub6}
+	m.deprioritizeSameDatacenter(pins)
+	assert.Equal(t, []*Pin{hub4, hub6, hub2}, pins)
+
+	// Check cross-checking with geoip and intel overrides.
+	hub6.LocationV4.AutonomousSystemNumber = 64500
+	hub6.LocationV4.AutonomousSystemOrganization = "Example Hosting GmbH"
+	m.updateSupplyChain(hub6)
+	assert.True(t, hub6.SupplyChain.CountryMismatch)
+	assert.True(t, hub6.SupplyChain.ASOrgConfirmed)
+	assert.False(t, hub6.SupplyChain.DatacenterVerified, "mismatching datacenter should be ignored")
+	assert.Equal(t, uint(64500), hub6.SupplyChain.ASN)
+}
@@ -113,6 +113,12 @@ func (m *Map) RemoveHub(id string) {
 	m.Lock()
 	defer m.Unlock()
 
+	m.removeHub(id)
+}
+
+// removeHub removes a Hub from the Map.
+// The map must be locked.
+func (m *Map) removeHub(id string) {
 	// Get pin and remove it from the map, if it exists.
 	pin, ok := m.all[id]
 	if !ok {
@@ -120,6 +126,12 @@ func (m *Map) RemoveHub(id string) {
 	}
 	delete(m.all, id)
 
+	// Unset the Home Hub, if it was removed.
+	if m.home == pin {
+		m.home = nil
+		m.homeTerminal = nil
+	}
+
 	// Remove lanes from removed Pin.
 	for id, _ := range pin.ConnectedTo {
 		// Remove Lane from peer.
@@ -131,9 +143,12 @@ func (m *Map) RemoveHub(id string) {
 	}
 
 	// Push update to subscriptions.
-	export := pin.Export()
-	export.Meta().Delete()
-	mapDBController.PushUpdate(export)
+	// Offline maps are not connected to the database.
+	if !m.offline {
+		export := pin.Export()
+		export.Meta().Delete()
+		mapDBController.PushUpdate(export)
+	}
 	// Push lane changes.
 	m.PushPinChanges()
 }
@@ -153,6 +168,19 @@ func (m *Map) updateHub(h *hub.Hub, lockMap, lockHub bool) {
 		defer h.Unlock()
 	}
 
+	// Remove retired Hubs.
+	if h.Retirement != nil {
+		// Carry over the state of Hubs that rotated their identity key.
+		if h.Succession != nil {
+			m.recordSuccession(h)
+		}
+		if _, ok := m.all[h.ID]; ok {
+			log.Infof("spn/navigator: removing retired Hub %s from map %s", h.ID, m.Name)
+			m.removeHub(h.ID)
+		}
+		return
+	}
+
 	// Hub requires both Info and Status to be added to the Map.
 	if h.Info == nil || h.Status == nil {
 		return
@@ -175,13 +203,26 @@ func (m *Map) updateHub(h *hub.Hub, lockMap, lockHub bool) {
 	// 1. Update Pin Data.
 
 	// Add/Update location data from IP addresses.
-	pin.updateLocationData()
+	// Offline maps have their location data supplied by the snapshot.
+	if !m.offline {
+		pin.updateLocationData()
+	}
 
 	// Override Pin Data.
 	m.updateInfoOverrides(pin)
+	m.updateSupplyChain(pin)
+
+	// Migrate the state of the predecessor to new Pins of successor Hubs.
+	m.migrateSuccession(pin)
+
+	// Load reputation of new Pins.
+	if pin.reputation == nil {
+		pin.reputation = m.loadReputation(h.ID)
+		pin.restoreExclusion(time.Now())
+	}
 
 	// Update Hub cost.
-	pin.Cost = CalculateHubCost(pin.Hub.Status.Load)
+	pin.updateCost()
 
 	// Ensure measurements are set when enabled.
 	if m.measuringEnabled && pin.measurements == nil {
@@ -189,9 +230,13 @@ func (m *Map) updateHub(h *hub.Hub, lockMap, lockHub bool) {
 		pin.measurements = pin.Hub.GetMeasurementsWithLockedHub()
 
 		// Update cost calculation.
-		latency, _ := pin.measurements.GetLatency()
-		capacity, _ := pin.measurements.GetCapacity()
-		pin.measurements.SetCalculatedCost(CalculateLaneCost(latency, capacity))
+		pin.measurements.SetCalculatedCost(CalculateLaneCost(
+			pin.measurements.GetSmoothedLatency(),
+			pin.measurements.GetSmoothedCapacity(),
+			pin.measurements.GetSmoothedJitter(),
+			pin.measurements.GetSmoothedLossRate(),
+			0,
+		))
 
 		// Update geo proximity.
 		// Get own location.
@@ -225,7 +270,7 @@ func (m *Map) updateHub(h *hub.Hub, lockMap, lockHub bool) {
 	// 2. Update Pin States.
 
 	// Update the invalid status of the Pin.
-	if pin.Hub.InvalidInfo || pin.Hub.InvalidStatus {
+	if pin.Hub.InvalidInfo || pin.Hub.InvalidStatus || pin.Hub.Equivocated {
 		pin.addStates(StateInvalid)
 	} else {
 		pin.removeStates(StateInvalid)
@@ -356,14 +401,39 @@ func (m *Map) updateHubLane(pin *Pin, lane *hub.Lane, peer *Pin) {
 		combinedCapacity = maxUnconfirmedCapacity
 	}
 
+	// Calculate combined loss rate and jitter, use the greater value.
+	combinedLossRate := lane.LossRate
+	if peerLane.LossRate > combinedLossRate {
+		combinedLossRate = peerLane.LossRate
+	}
+	combinedJitter := lane.Jitter
+	if peerLane.Jitter > combinedJitter {
+		combinedJitter = peerLane.Jitter
+	}
+
+	// Calculate combined age, use the lesser existing value.
+	combinedAge := lane.Age
+	if combinedAge == 0 || (peerLane.Age > 0 && peerLane.Age < combinedAge) {
+		combinedAge = peerLane.Age
+	}
+
 	// Calculate lane cost.
-	laneCost := CalculateLaneCost(combinedLatency, combinedCapacity)
+	laneCost := CalculateLaneCost(
+		combinedLatency,
+		combinedCapacity,
+		combinedJitter,
+		combinedLossRate,
+		combinedAge,
+	)
 
 	// Add Lane to both Pins and override old values in the process.
 	pin.ConnectedTo[peer.Hub.ID] = &Lane{
 		Pin:      peer,
 		Capacity: combinedCapacity,
 		Latency:  combinedLatency,
+		LossRate: combinedLossRate,
+		Jitter:   combinedJitter,
+		Age:      combinedAge,
 		Cost:     laneCost,
 		active:   true,
 	}
@@ -371,6 +441,9 @@ func (m *Map) updateHubLane(pin *Pin, lane *hub.Lane, peer *Pin) {
 		Pin:      pin,
 		Capacity: combinedCapacity,
 		Latency:  combinedLatency,
+		LossRate: combinedLossRate,
+		Jitter:   combinedJitter,
+		Age:      combinedAge,
 		Cost:     laneCost,
 		active:   true,
 	}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/safing/portbase/formats/varint"
 
@@ -47,6 +48,16 @@ type DuplexFlowQueue struct {
 	// flush is used to send a finish function to the handler, which will write
 	// all pending messages and then call the received function.
 	flush chan func()
+
+	// rttReporter receives passive round trip time estimates, if set.
+	rttReporter func(rtt time.Duration)
+	// rttProbeLock locks the RTT probe.
+	rttProbeLock sync.Mutex
+	// rttProbeSentAt holds when the current RTT probe was sent.
+	rttProbeSentAt time.Time
+	// rttProbeAwaitingSpace holds the amount of space that needs to be reported
+	// by the other end until the RTT probe is regarded as received.
+	rttProbeAwaitingSpace int32
 }
 
 func NewDuplexFlowQueue(
@@ -72,6 +83,48 @@ func NewDuplexFlowQueue(
 	return dfq
 }
 
+// SetRTTReporter sets a function that receives passive round trip time
+// estimates. These are derived from the time it takes the other end to report
+// the space of a sent container, which includes the processing time of the
+// other end. The reporter must be set before the flow handler is started and
+// should only be used on flow queues that span a single hop.
+func (dfq *DuplexFlowQueue) SetRTTReporter(reporter func(rtt time.Duration)) {
+	dfq.rttReporter = reporter
+}
+
+// startRTTProbe starts a new RTT probe with the container that was just sent,
+// if no probe is in progress.
+func (dfq *DuplexFlowQueue) startRTTProbe(sendSpace int32) {
+	dfq.rttProbeLock.Lock()
+	defer dfq.rttProbeLock.Unlock()
+
+	if dfq.rttProbeAwaitingSpace > 0 {
+		return
+	}
+
+	// All containers in flight, including this one, must be reported as
+	// received before the probe is received.
+	dfq.rttProbeSentAt = time.Now()
+	dfq.rttProbeAwaitingSpace = int32(cap(dfq.sendQueue)) - sendSpace
+}
+
+// checkRTTProbe checks if the RTT probe was received with the reported space
+// and reports the RTT.
+func (dfq *DuplexFlowQueue) checkRTTProbe(reportedSpace int32) {
+	dfq.rttProbeLock.Lock()
+	defer dfq.rttProbeLock.Unlock()
+
+	if dfq.rttProbeAwaitingSpace <= 0 {
+		return
+	}
+
+	dfq.rttProbeAwaitingSpace -= reportedSpace
+	if dfq.rttProbeAwaitingSpace <= 0 {
+		dfq.rttProbeAwaitingSpace = 0
+		dfq.rttReporter(time.Since(dfq.rttProbeSentAt))
+	}
+}
+
 // shouldReportRecvSpace returns whether the receive space should be reported.
 func (dfq *DuplexFlowQueue) shouldReportRecvSpace() bool {
 	return atomic.LoadInt32(dfq.reportedSpace) < int32(float32(cap(dfq.recvQueue))*forceReportBelowPercent)
@@ -192,10 +245,16 @@ sending:
 			dfq.submitUpstream(c)
 
 			// Decrease the send space and set flag if depleted.
-			if dfq.decrementSendSpace() <= 0 {
+			sendSpace := dfq.decrementSendSpace()
+			if sendSpace <= 0 {
 				sendSpaceDepleted = true
 			}
 
+			// Probe RTT, if enabled.
+			if dfq.rttReporter != nil {
+				dfq.startRTTProbe(sendSpace)
+			}
+
 			// Check if the send queue is empty now and signal flushers.
 			if flushFinished != nil && len(dfq.sendQueue) == 0 {
 				flushFinished()
@@ -313,6 +372,11 @@ func (dfq *DuplexFlowQueue) Deliver(c *container.Container) *Error {
 	}
 	if addSpace > 0 {
 		dfq.addToSendSpace(int32(addSpace))
+
+		// Check RTT probe, if enabled.
+		if dfq.rttReporter != nil {
+			dfq.checkRTTProbe(int32(addSpace))
+		}
 	}
 	// Abort processing if the container only contained a space update.
 	if !c.HoldsData() {
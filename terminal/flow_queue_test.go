@@ -0,0 +1,41 @@
+package terminal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlowQueueRTTProbe(t *testing.T) {
+	var reported []time.Duration
+	dfq := NewDuplexFlowQueue(nil, 10, nil)
+	dfq.SetRTTReporter(func(rtt time.Duration) {
+		reported = append(reported, rtt)
+	})
+
+	// Send three containers, the first one starts the probe.
+	dfq.startRTTProbe(dfq.decrementSendSpace())
+	dfq.startRTTProbe(dfq.decrementSendSpace())
+	dfq.startRTTProbe(dfq.decrementSendSpace())
+	assert.Equal(t, int32(1), dfq.rttProbeAwaitingSpace)
+
+	// Receiving the space report for the probe reports the RTT.
+	time.Sleep(10 * time.Millisecond)
+	dfq.checkRTTProbe(1)
+	assert.Len(t, reported, 1)
+	assert.GreaterOrEqual(t, int64(reported[0]), int64(10*time.Millisecond))
+
+	// Further reports do not report again until a new probe is started.
+	dfq.checkRTTProbe(2)
+	assert.Len(t, reported, 1)
+
+	// A new probe needs all containers in flight to be reported.
+	dfq.addToSendSpace(1)
+	dfq.startRTTProbe(dfq.decrementSendSpace())
+	assert.Equal(t, int32(3), dfq.rttProbeAwaitingSpace)
+	dfq.checkRTTProbe(2)
+	assert.Len(t, reported, 1)
+	dfq.checkRTTProbe(1)
+	assert.Len(t, reported, 2)
+}